@@ -0,0 +1,8 @@
+// Команда l2sort сортирует строки файлов; сама утилита реализована в пакете l2sort
+package main
+
+import "github.com/anyEugeny/forDmitri/l2sort"
+
+func main() {
+	l2sort.Main()
+}
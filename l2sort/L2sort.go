@@ -0,0 +1,468 @@
+// Package l2sort сортирует строки файлов и потоков, как утилита sort, с типами ключей,
+// внешней сортировкой и подкомандами. Main - сама утилита l2sort; для встраивания служат
+// NewSorter и Sorter.SortFile, SortWriter для строк, подаваемых по одной, RegisterKeyType
+// для своих типов ключей и ReadIndex для индексов --index
+package l2sort
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+// Row представляет структуру для хранения строки и ее ключей для сортировки
+type Row struct {
+	Original string
+	Keys     []Key
+	// data - байты строки и следом тексты ключей, которых в ней нет дословно; ключи
+	// ссылаются на свой текст смещениями в data. Вывод берет Original, поэтому замена
+	// Original (метка источника, счетчик --freq) ключи не затрагивает
+	data string
+}
+
+// keyText возвращает текст k-го ключа строки
+func (r *Row) keyText(k int) string {
+	key := &r.Keys[k]
+	return r.data[key.start:key.end]
+}
+
+// RowSlice представляет срез строк для сортировки компаратором Sorter
+type RowSlice struct {
+	rows   []Row
+	sorter *Sorter
+}
+
+func (s RowSlice) Len() int { return len(s.rows) }
+
+func (s RowSlice) Swap(i, j int) { s.rows[i], s.rows[j] = s.rows[j], s.rows[i] }
+
+func (s RowSlice) Less(i, j int) bool {
+	return s.sorter.sortOrder(&s.rows[i], &s.rows[j]) < 0
+}
+
+// CompareRows сравнивает строки по ключам лексикографически; при совпадении общей части
+// строка с меньшим числом ключей идет раньше (или как задано --missing). При --length сначала сравнивается длина.
+// Модификатор r ключа -k обращает сравнение этого ключа; общий -r учитывает compareOrder.
+// Задает строгий слабый порядок, поэтому пригоден для sort.Sort и проверки -c.
+// Возвращает -1, 0 или 1
+func (s *Sorter) CompareRows(a, b *Row) int {
+	s.stats.addComparison()
+	if s.order != nil {
+		return s.compareChain(a, b)
+	}
+	if s.opts.Length {
+		if c := compareOrdered(int64(s.rowLength(a)), int64(s.rowLength(b))); c != 0 {
+			return c
+		}
+	}
+	for k := 0; k < len(a.Keys) && k < len(b.Keys); k++ {
+		if a.keyText(k) == b.keyText(k) {
+			continue
+		}
+		if c := s.compareKeys(k, a, b); c != 0 {
+			if k < len(s.opts.Keys) && s.opts.Keys[k].Reverse {
+				return -c
+			}
+			return c
+		}
+	}
+	return s.compareMissing(a, b)
+}
+
+// rowLength возвращает длину в рунах, по которой упорядочивает --length: при -k или
+// --key-regex - длину выбранного ключа (всех ключей вместе), иначе - длину всей строки
+func (s *Sorter) rowLength(row *Row) int {
+	if len(s.opts.Keys) == 0 && s.keyRegex == nil {
+		return utf8.RuneCountInString(row.Original)
+	}
+	n := 0
+	for k := range row.Keys {
+		n += utf8.RuneCountInString(row.keyText(k))
+	}
+	return n
+}
+
+var (
+	opts          = Options{LongLines: longLineTruncateKey}
+	batchManifest string
+	recursiveDir  string
+	recursiveGlob string
+	watchFile     bool
+	watchDebounce time.Duration
+	serveAddr     string
+	serveGRPCAddr string
+	serveMaxBody  string
+	files0From    string
+	filesFrom     string
+	configPath    string
+	verifyPath    string
+	windowLines   int
+	windowTime    time.Duration
+	interactive   bool
+	interactiveN  int
+)
+
+// registerMainFlags регистрирует флаги утилиты в flag.CommandLine. Это делает Main, а не
+// init, чтобы импорт пакета не добавлял флаги в программу, которая его импортирует
+func registerMainFlags() {
+	opts.registerFlags(flag.CommandLine)
+	flag.StringVar(&batchManifest, "batch", "", "Выполнить задания из JSON-манифеста (input, output, flags) общим пулом обработчиков")
+	flag.StringVar(&recursiveDir, "recursive", "", "Отсортировать на месте каждый файл в дереве каталога, параллельно, со сводкой измененных файлов")
+	flag.StringVar(&recursiveGlob, "glob", "*", "Шаблон имен файлов для --recursive, например '*.txt'")
+	flag.BoolVar(&watchFile, "watch", false, "Следить за файлом и пересортировывать его после каждого изменения до Ctrl+C")
+	flag.DurationVar(&watchDebounce, "watch-debounce", 500*time.Millisecond, "Сколько файл не должен меняться перед пересортировкой в режиме --watch")
+	flag.StringVar(&serveAddr, "serve", "", "Запустить HTTP-сервис сортировки на адресе (например, :8080): POST /sort, флаги - в параметрах запроса")
+	flag.StringVar(&serveGRPCAddr, "serve-grpc", "", "Запустить сервис gRPC (HTTP/2 без TLS) на адресе: потоковый метод "+grpcSortMethod+" принимает флаги и строки и возвращает их отсортированными")
+	flag.StringVar(&files0From, "files0-from", "", "Читать входные файлы из списка F, имена разделены нулевым байтом (\"-\" - stdin); аргумент - файл результата")
+	flag.StringVar(&filesFrom, "files-from", "", "То же, что --files0-from, но по одному имени в строке")
+	flag.StringVar(&serveMaxBody, "serve-max-body", serveMaxBodyDefault, "Наибольший размер тела запроса в режиме --serve и входа вызова --serve-grpc (суффиксы K, M, G)")
+	flag.IntVar(&windowLines, "window", 0, "Сортировать поток окнами по N строк: читать файл или stdin по мере поступления и выводить каждое окно отсортированным")
+	flag.DurationVar(&windowTime, "window-time", 0, "Закрывать окно --window по времени, например 5s, даже если строк меньше N (можно без --window)")
+	flag.BoolVar(&interactive, "interactive", false, "Показать первые строки отсортированного файла и переключать -n, -r, -u, -b, -k, разделитель полей (--field-regex, --tsv) и другие флаги командами, обновляя предпросмотр; файл записывается только командой w")
+	flag.IntVar(&interactiveN, "interactive-lines", 20, "Сколько строк показывать в режиме --interactive")
+	flag.StringVar(&verifyPath, "verify", "", "Проверить файлы по списку SHA-256 (например, ФАЙЛ.sha256 от --checksum-file) и выйти")
+	flag.StringVar(&configPath, "config", "", "Файл настроек с флагами по умолчанию (по умолчанию ~/"+configFileName+"); флаги из "+optsEnvVar+" и командной строки важнее")
+	flag.StringVar(&logFormat, "log-format", logFormatText, "Формат ошибок и предупреждений: text или json (по записи JSON на строку в stderr)")
+}
+
+// Main выполняет утилиту l2sort с аргументами командной строки и завершает процесс с ее
+// кодом завершения
+func Main() {
+	// Отложенная очистка срабатывает и при обычном возврате, и при панике
+	defer temps.cleanup()
+
+	registerMainFlags()
+	flag.Parse()
+	defaultsErr := applyDefaults(flag.CommandLine, configPath)
+	if err := checkLogFormat(logFormat); err != nil {
+		logFormat = logFormatText
+		exit(reportUsage(err))
+	}
+	if defaultsErr != nil {
+		exit(reportUsage(defaultsErr))
+	}
+	temps.keep = opts.KeepTemp
+	args := flag.Args()
+
+	// SIGINT и SIGTERM отменяют контекст: работа прекращается, временные файлы удаляются,
+	// а исходный файл остается нетронутым. Повторный сигнал завершает процесс сразу
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	// Закрытый получатель вывода (l2sort ... | head) дает ошибку записи EPIPE вместо
+	// завершения сигналом, чтобы временные файлы были удалены
+	signal.Ignore(syscall.SIGPIPE)
+
+	if verifyPath != "" {
+		exit(runVerify(verifyPath))
+	}
+	if batchManifest != "" {
+		exit(runBatch(ctx, batchManifest, opts))
+	}
+	if recursiveDir != "" {
+		exit(runRecursive(ctx, recursiveDir, recursiveGlob, opts))
+	}
+
+	if serveAddr != "" {
+		maxBody, err := parseServeMaxBody(serveMaxBody)
+		if err != nil {
+			exit(reportUsage(err))
+		}
+		exit(runServe(ctx, serveAddr, opts, maxBody))
+	}
+
+	if serveGRPCAddr != "" {
+		maxBody, err := parseServeMaxBody(serveMaxBody)
+		if err != nil {
+			exit(reportUsage(err))
+		}
+		exit(runServeGRPC(ctx, serveGRPCAddr, opts, maxBody))
+	}
+
+	if windowLines != 0 || windowTime != 0 {
+		if len(args) > 1 {
+			printUsage()
+			exit(exitUsage)
+		}
+		input := "-"
+		if len(args) == 1 {
+			input = args[0]
+		}
+		exit(runWindow(ctx, input, opts, windowLines, windowTime))
+	}
+
+	if len(args) > 0 {
+		if cmd, ok := findCommand(args[0]); ok {
+			exit(cmd.run(ctx, args[1:], opts))
+		}
+	}
+
+	if len(args) != 1 {
+		printUsage()
+		flag.PrintDefaults()
+		exit(exitUsage)
+	}
+
+	filePath := args[0]
+	if files0From != "" || filesFrom != "" {
+		exit(sortFileList(ctx, filePath))
+	}
+	if interactive {
+		exit(runInteractive(ctx, filePath, opts, interactiveN))
+	}
+	if watchFile {
+		exit(runWatch(ctx, filePath, opts, watchDebounce))
+	}
+	exit(runSortFiles(ctx, opts, []string{filePath}, filePath))
+}
+
+// sortFileList сортирует файлы из списка --files0-from или --files-from вместе
+// и записывает результат в output
+func sortFileList(ctx context.Context, output string) int {
+	if files0From != "" && filesFrom != "" {
+		return reportUsage(fmt.Errorf("в параметрах: --files0-from и --files-from нельзя указывать вместе"))
+	}
+	if watchFile || opts.CheckUnique {
+		return reportUsage(fmt.Errorf("в параметрах: --watch и --check-unique не работают со списком файлов"))
+	}
+	listPath, sep := files0From, byte(0)
+	if filesFrom != "" {
+		listPath, sep = filesFrom, '\n'
+	}
+	inputs, err := readFileList(listPath, sep)
+	if err != nil {
+		return reportError(fmt.Errorf("при чтении списка файлов: %w", err))
+	}
+	return runSortFiles(ctx, opts, inputs, output)
+}
+
+// parseInterspersed разбирает флаги подкоманды, допуская их и до, и после позиционных
+// аргументов, и возвращает позиционные аргументы по порядку
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// sorterWithFlags создает Sorter по флагам сортировки из строки (например, из --flags
+// подкоманды), разобранным поверх общих настроек base
+func sorterWithFlags(base Options, flags string) (*Sorter, error) {
+	subOpts := base.clone()
+	fs := flag.NewFlagSet("flags", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	subOpts.registerFlags(fs)
+	if err := fs.Parse(strings.Fields(flags)); err != nil {
+		return nil, fmt.Errorf("в параметре --flags: %w", err)
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("в параметре --flags: лишние аргументы %v", fs.Args())
+	}
+	return NewSorter(subOpts)
+}
+
+// checkKeyValues проверяет значения ключей строки. Числовые ключи, не помещающиеся в
+// int64, при --debug дают предупреждение, а при --strict - ошибку, как и непустые ключи
+// -n и -h, которые не удалось разобрать как число
+func (s *Sorter) checkKeyValues(row *Row, lineNum int) error {
+	for i := range row.Keys {
+		key, text := &row.Keys[i], row.keyText(i)
+		kind := s.kindOf(i).name
+		if s.opts.Strict && text != "" && (kind == typeNumeric || kind == typeHuman) && !key.parsed() {
+			return fmt.Errorf("строка %d: ключ %d %q не число", lineNum, i+1, text)
+		}
+		if key.tag != valueOverflow {
+			continue
+		}
+		if s.opts.Strict {
+			return fmt.Errorf("строка %d: число %q выходит за пределы int64", lineNum, text)
+		}
+		if s.opts.Debug {
+			warnf("строка %d: число %q выходит за пределы int64", lineNum, text)
+		}
+	}
+	return nil
+}
+
+// Политики обработки строк длиннее --max-line-bytes
+const (
+	longLineTruncateKey = "truncate-key"
+	longLineSkip        = "skip"
+	longLineError       = "error"
+)
+
+// Политики --binary для строк с некорректным UTF-8
+const (
+	binaryRaw   = "raw"
+	binarySkip  = "skip"
+	binaryError = "error"
+)
+
+// checkUTF8 применяет политику --binary к строке с некорректным UTF-8 и возвращает признак
+// того, что строку нужно пропустить. При raw строка остается: ключи с некорректными байтами
+// не нормализуются, а при текстовом сравнении такие байты сравниваются как есть
+func (s *Sorter) checkUTF8(line string, lineNum int) (skip bool, err error) {
+	if s.opts.Binary == "" || s.opts.Binary == binaryRaw || utf8.ValidString(line) {
+		return false, nil
+	}
+	if s.opts.Binary == binarySkip {
+		return true, nil
+	}
+	offset := 0
+	for offset < len(line) {
+		r, size := utf8.DecodeRuneInString(line[offset:])
+		if r == utf8.RuneError && size == 1 {
+			break
+		}
+		offset += size
+	}
+	return false, fmt.Errorf("строка %d: некорректный UTF-8 в байте %d", lineNum, offset+1)
+}
+
+// checkLineLength применяет политику --long-lines к строке длиннее --max-line-bytes.
+// Возвращает текст, из которого извлекаются ключи (при truncate-key - начало строки,
+// сама строка выводится целиком), и признак того, что строку нужно пропустить
+func (s *Sorter) checkLineLength(line string, lineNum int) (keyText string, skip bool, err error) {
+	if s.opts.MaxLineBytes <= 0 || int64(len(line)) <= s.opts.MaxLineBytes {
+		return line, false, nil
+	}
+	switch s.opts.LongLines {
+	case longLineSkip:
+		warnf("строка %d длиной %d байт пропущена", lineNum, len(line))
+		return "", true, nil
+	case longLineError:
+		return "", false, fmt.Errorf("строка %d длиной %d байт превышает --max-line-bytes", lineNum, len(line))
+	}
+	return s.keyPart(line), false, nil
+}
+
+// keyPart возвращает часть строки, из которой извлекаются ключи: при --max-line-bytes
+// и политике truncate-key это начало строки, иначе вся строка
+func (s *Sorter) keyPart(line string) string {
+	if s.opts.MaxLineBytes > 0 && int64(len(line)) > s.opts.MaxLineBytes {
+		return line[:s.opts.MaxLineBytes]
+	}
+	return line
+}
+
+func (s *Sorter) extractKeys(line string) []string {
+	line = s.recordKeyLine(line)
+	if s.expr != nil {
+		return s.trimKeyBlanks(s.exprKeys(line))
+	}
+	if s.keyRegex != nil {
+		return s.trimKeyBlanks(s.regexKeys(line))
+	}
+	if s.opts.Compat == compatGNU && !s.ownFields() {
+		return s.trimKeyBlanks(s.gnuKeys(line))
+	}
+	// -k 0 и --hash-order без -k сравнивают всю строку, а не каждое поле
+	if len(s.opts.Keys) == 0 && (s.opts.WholeLineKey || s.defaultKind.name == typeHash) {
+		return s.trimKeyBlanks([]string{line})
+	}
+	// Без -k ключи - все поля строки, поэтому число из нескольких слов ("двадцать одна")
+	// заменяется до разбиения, иначе каждое его слово стало бы отдельным ключом
+	if s.opts.Numerals && len(s.opts.Keys) == 0 {
+		line = replaceNumerals(line)
+	}
+	var fields []string
+	if s.ownFields() {
+		fields = s.splitFields(line)
+	} else if s.kindOf(0).name == typeTime {
+		s.fields = appendFields(s.fields[:0], line, 0)
+		fields = mergeTimeFields(s.layouts, s.fields, max(s.keyColumn()-1, 0))
+	} else {
+		// Поля правее последней колонки -k не сравниваются, и строка дальше не разбирается
+		s.fields = appendFields(s.fields[:0], line, s.lastKeyColumn())
+		fields = s.fields
+	}
+	return s.trimKeyBlanks(s.pickKeys(fields))
+}
+
+// regexKeys извлекает ключи по --key-regex: ключами становятся группы захвата, а если
+// групп нет - все совпадение. При -k ключами становятся группы с указанными номерами. Строка без совпадения
+// не имеет ключей и идет раньше остальных
+func (s *Sorter) regexKeys(line string) []string {
+	match := s.keyRegex.FindStringSubmatch(line)
+	if match == nil {
+		return nil
+	}
+	if len(match) > 1 {
+		match = match[1:]
+	}
+	return s.pickKeys(match)
+}
+
+func reverse(rows []Row) {
+	for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+		rows[i], rows[j] = rows[j], rows[i]
+	}
+}
+
+// writeOutputs открывает основной вывод и --also-output и за один проход записывает в них
+// данные из body. extra - дополнительный получатель (например, запись в кэш), который
+// получает поток до сжатия и сохраняется вместе с остальными
+func (s *Sorter) writeOutputs(filePath string, extra io.WriteCloser, body func(w io.Writer) error) (err error) {
+	var files []io.WriteCloser
+	var writers []io.Writer
+	if extra != nil {
+		files = append(files, extra)
+		writers = append(writers, extra)
+	}
+	defer func() {
+		if err != nil {
+			for _, file := range files {
+				discardOutput(file)
+			}
+		}
+	}()
+	sum, err := s.outputHash(filePath)
+	if err != nil {
+		return err
+	}
+	for i, path := range append([]string{filePath}, s.opts.AlsoOutputs...) {
+		var file io.WriteCloser
+		var hash io.Writer
+		if i == 0 && sum != nil {
+			hash = sum
+		}
+		if path == "-" && s.stdout != nil {
+			file = nopCloser{s.stdout}
+		} else if file, err = openOutput(path, s.opts.Backup && i == 0, s.opts.CompressOutput, hash); err != nil {
+			return fmt.Errorf("при создании файла: %w", err)
+		}
+		files = append(files, file)
+		writers = append(writers, file)
+	}
+
+	// Один проход по отсортированным строкам питает всех получателей сразу
+	if err := body(io.MultiWriter(writers...)); err != nil {
+		return fmt.Errorf("при записи результата: %w", err)
+	}
+	for i, file := range files {
+		if err := file.Close(); err != nil {
+			files = files[i+1:]
+			return fmt.Errorf("при сохранении результата: %w", err)
+		}
+	}
+	if sum != nil {
+		return s.reportChecksum(filePath, sum.Sum(nil))
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package l2sort
+
+import (
+	"encoding/hex"
+	"net"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// parseEmailKey разбирает адрес электронной почты (в том числе вида "Имя <user@host>")
+// на части в порядке сравнения --email: домен без учета регистра, затем локальная часть
+func parseEmailKey(text string) []string {
+	addr := text
+	if parsed, err := mail.ParseAddress(text); err == nil {
+		addr = parsed.Address
+	}
+	at := strings.LastIndexByte(addr, '@')
+	if at <= 0 || at == len(addr)-1 || strings.ContainsAny(addr, " \t") {
+		return nil
+	}
+	return []string{strings.ToLower(addr[at+1:]), addr[:at]}
+}
+
+// parseURLKey разбирает URL на части в порядке сравнения --url: хост без учета регистра
+// (с портом), путь и строка запроса. Адрес без схемы, например example.com/path,
+// считается начинающимся с хоста
+func parseURLKey(text string) []string {
+	raw := text
+	if !strings.Contains(raw, "://") {
+		raw = "//" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	return []string{strings.ToLower(u.Host), u.Path, u.RawQuery}
+}
+
+// compareParts сравнивает разобранные части ключей по порядку текстовым сравнением
+func (s *Sorter) compareParts(a, b []string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := s.compareText(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return compareOrdered(int64(len(a)), int64(len(b)))
+}
+
+// parseMACKey разбирает MAC-адрес в любой записи net.ParseMAC (01:23:..., 01-23-...,
+// 0123.4567.89ab) без учета регистра
+func parseMACKey(text string) net.HardwareAddr {
+	mac, err := net.ParseMAC(text)
+	if err != nil {
+		return nil
+	}
+	return mac
+}
+
+// parseUUIDKey разбирает UUID (с дефисами или без, в фигурных скобках или с префиксом
+// urn:uuid:) без учета регистра и возвращает байты в порядке сравнения --uuid. У версий
+// 6 и 7 метка времени стоит в начале, и побайтовый порядок уже хронологический; у версии 1
+// части метки времени переставляются так же, чтобы и эти UUID шли по времени
+func parseUUIDKey(text string) ([16]byte, bool) {
+	var id [16]byte
+	text = strings.TrimPrefix(strings.ToLower(text), "urn:uuid:")
+	if strings.HasPrefix(text, "{") && strings.HasSuffix(text, "}") {
+		text = text[1 : len(text)-1]
+	}
+	if len(text) == 36 {
+		if text[8] != '-' || text[13] != '-' || text[18] != '-' || text[23] != '-' {
+			return id, false
+		}
+		text = text[:8] + text[9:13] + text[14:18] + text[19:23] + text[24:]
+	}
+	if len(text) != 32 {
+		return id, false
+	}
+	if _, err := hex.Decode(id[:], []byte(text)); err != nil {
+		return id, false
+	}
+	if id[6]>>4 == 1 {
+		// time_low(4) time_mid(2) time_hi_and_version(2) -> time_hi time_mid time_low
+		var t [8]byte
+		copy(t[0:2], id[6:8])
+		copy(t[2:4], id[4:6])
+		copy(t[4:8], id[0:4])
+		t[0] &= 0x0f
+		copy(id[0:8], t[:])
+	}
+	return id, true
+}
@@ -0,0 +1,82 @@
+package l2sort
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// collation - порядок символов из файла --alphabet. Символ может быть и
+// многосимвольной лексемой: при сравнении строка разбивается на лексемы жадно, по
+// самому длинному совпадению. Символы, которых нет в алфавите, идут после всех
+// перечисленных в порядке их кодов
+type collation struct {
+	rank map[string]int
+	// longest - длина самой длинной лексемы в байтах
+	longest int
+}
+
+// loadAlphabet читает файл алфавита: по одному символу или лексеме на строку в порядке
+// возрастания, пустые строки пропускаются
+func loadAlphabet(path string) (*collation, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c := &collation{rank: make(map[string]int)}
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		symbol := strings.TrimSpace(scanner.Text())
+		if symbol == "" {
+			continue
+		}
+		if _, ok := c.rank[symbol]; ok {
+			return nil, fmt.Errorf("строка %d: символ %q уже есть в алфавите", lineNum, symbol)
+		}
+		c.rank[symbol] = len(c.rank)
+		c.longest = max(c.longest, len(symbol))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(c.rank) == 0 {
+		return nil, fmt.Errorf("файл %s не содержит ни одного символа", path)
+	}
+	return c, nil
+}
+
+// next отделяет от начала s лексему и возвращает ее вес и остаток строки
+func (c *collation) next(s string) (int, string) {
+	for n := min(c.longest, len(s)); n > 0; n-- {
+		if rank, ok := c.rank[s[:n]]; ok {
+			return rank, s[n:]
+		}
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	return len(c.rank) + int(r), s[size:]
+}
+
+// compare сравнивает строки по весам лексем; строка-префикс идет раньше.
+// Возвращает -1, 0 или 1
+func (c *collation) compare(a, b string) int {
+	for a != "" && b != "" {
+		var wa, wb int
+		wa, a = c.next(a)
+		wb, b = c.next(b)
+		if wa != wb {
+			return compareOrdered(int64(wa), int64(wb))
+		}
+	}
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a == "":
+		return -1
+	default:
+		return 1
+	}
+}
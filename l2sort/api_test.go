@@ -0,0 +1,125 @@
+package l2sort_test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/anyEugeny/forDmitri/l2sort"
+)
+
+// Тесты вызывают пакет так же, как импортирующая его программа
+
+// sortInFile записывает lines во временный файл, сортирует его sorter и возвращает результат
+func sortInFile(t *testing.T, sorter *l2sort.Sorter, lines ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	input, output := filepath.Join(dir, "in.txt"), filepath.Join(dir, "out.txt")
+	if err := os.WriteFile(input, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sorter.SortFile(input, output); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// byLength - тип ключа, сравнивающий ключи по длине
+type byLength struct{}
+
+func (byLength) Parse(text string) (any, bool) { return len(text), text != "" }
+
+func (byLength) Compare(a, b any) int { return cmp.Compare(a.(int), b.(int)) }
+
+func TestRegisterKeyType(t *testing.T) {
+	l2sort.RegisterKeyType("api-test-length", byLength{})
+	sorter, err := l2sort.NewSorter(l2sort.Options{KeyTypes: []string{"api-test-length"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sortInFile(t, sorter, "ccc", "a", "bb"), "a\nbb\nccc\n"; got != want {
+		t.Errorf("результат %q, ожидалось %q", got, want)
+	}
+	if _, err := l2sort.NewSorter(l2sort.Options{KeyTypes: []string{"api-test-unknown"}}); err == nil {
+		t.Error("незарегистрированный тип принят")
+	}
+}
+
+func TestReadIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.idx")
+	sorter, err := l2sort.NewSorter(l2sort.Options{Index: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sortInFile(t, sorter, "b 2", "a 1", "b 3", "c 1"), "a 1\nb 2\nb 3\nc 1\n"; got != want {
+		t.Fatalf("результат %q, ожидалось %q", got, want)
+	}
+	x, err := l2sort.ReadIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []l2sort.IndexEntry{{Offset: 0, Key: "a"}, {Offset: 4, Key: "b"}, {Offset: 12, Key: "c"}}
+	if !slices.Equal(x.Entries, want) {
+		t.Errorf("индекс %+v, ожидалось %+v", x.Entries, want)
+	}
+	if start, end := sorter.IndexRange(x, "b", "b"); start != 4 || end != 12 {
+		t.Errorf("IndexRange(b, b) = %d, %d, ожидалось 4, 12", start, end)
+	}
+	if _, err := l2sort.ReadIndex(filepath.Join(t.TempDir(), "none.idx")); err == nil {
+		t.Error("прочитан несуществующий индекс")
+	}
+}
+
+func TestSortWriter(t *testing.T) {
+	w, err := l2sort.NewSortWriter(context.Background(), l2sort.Options{Unique: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"c", "a", "b", "a"} {
+		if err := w.Write(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var out strings.Builder
+	result, err := w.Flush(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "a\nb\nc\n"; got != want || result.Lines != 4 {
+		t.Errorf("Flush: %q, %d строк, ожидалось %q, 4 строки", got, result.Lines, want)
+	}
+	// После Flush SortWriter начинает новую сортировку
+	out.Reset()
+	w.Write("z")
+	w.Write("y")
+	if _, err := w.Flush(&out); err != nil || out.String() != "y\nz\n" {
+		t.Errorf("второй Flush: %q, %v", out.String(), err)
+	}
+	if err := w.Write("a\nb"); err == nil {
+		t.Error("строка с переводом строки принята")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w, err = l2sort.NewSortWriter(ctx, l2sort.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write("a")
+	cancel()
+	out.Reset()
+	if _, err := w.Flush(&out); !errors.Is(err, context.Canceled) || out.Len() != 0 {
+		t.Errorf("Flush после отмены: %q, %v", out.String(), err)
+	}
+	if _, err := l2sort.NewSortWriter(context.Background(), l2sort.Options{Check: true}); err == nil {
+		t.Error("SortWriter с -c создан")
+	}
+}
@@ -0,0 +1,181 @@
+package l2sort
+
+import (
+	"cmp"
+	"unicode"
+	"unsafe"
+)
+
+// Размеры блоков, из которых выделяются строки и ключи при чтении
+const (
+	lineArenaSize = 1 << 20
+	keySlabSize   = 4096
+)
+
+// lineArena копирует прочитанные строки в большие общие блоки байт и выдает строки,
+// ссылающиеся прямо на них, вместо отдельного выделения памяти на каждую строку.
+// Блок никогда не изменяется после записи, поэтому строки остаются неизменяемыми;
+// сборщик мусора освобождает блок, когда на него не ссылается ни одна строка.
+// Каждый выделенный блок учитывается в budget (nil - без учета)
+type lineArena struct {
+	buf    []byte
+	budget *memBudget
+	// size - размер блока, 0 - lineArenaSize
+	size int
+}
+
+// grow начинает новый блок, в который поместится n байт
+func (a *lineArena) grow(n int) {
+	a.buf = make([]byte, 0, max(cmp.Or(a.size, lineArenaSize), n))
+	if a.budget != nil {
+		a.budget.add(int64(cap(a.buf)))
+	}
+}
+
+// owns сообщает, что строка s лежит в текущем блоке арены
+func (a *lineArena) owns(s string) bool {
+	_, ok := offsetIn(unsafe.String(unsafe.SliceData(a.buf), cap(a.buf)), s)
+	return ok && len(s) > 0
+}
+
+// intern возвращает строку с содержимым b, размещенную в блоке арены
+func (a *lineArena) intern(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	if len(b) > cap(a.buf)-len(a.buf) {
+		a.grow(len(b))
+	}
+	start := len(a.buf)
+	a.buf = append(a.buf, b...)
+	return unsafe.String(&a.buf[start], len(b))
+}
+
+// extend возвращает строку из line и следом extra. Если line - последняя строка блока
+// и в нем хватает места, extra дописывается сразу за ней, иначе обе копируются в блок.
+// Нулевой указатель выделяет память обычным образом
+func (a *lineArena) extend(line string, extra []byte) string {
+	if a == nil {
+		return line + string(extra)
+	}
+	n := len(a.buf)
+	if len(line) > 0 && len(line) <= n && unsafe.StringData(line) == &a.buf[n-len(line)] && len(extra) <= cap(a.buf)-n {
+		a.buf = append(a.buf, extra...)
+		return unsafe.String(&a.buf[n-len(line)], len(line)+len(extra))
+	}
+	if len(line)+len(extra) > cap(a.buf)-n {
+		a.grow(len(line) + len(extra))
+		n = 0
+	}
+	a.buf = append(append(a.buf, line...), extra...)
+	if len(a.buf) == n {
+		return ""
+	}
+	return unsafe.String(&a.buf[n], len(a.buf)-n)
+}
+
+// keySlab выдает срезы ключей из общих блоков, чтобы не выделять память под ключи
+// каждой строки отдельно. Емкость выданного среза равна длине, поэтому соседние
+// срезы не пересекаются. Нулевой указатель выделяет память обычным образом.
+// Выделенные блоки и отдельные большие срезы учитываются в budget (nil - без учета)
+type keySlab struct {
+	keys   []Key
+	budget *memBudget
+	// size - число ключей в блоке, 0 - keySlabSize
+	size int
+}
+
+func (k *keySlab) alloc(n int) []Key {
+	if k == nil {
+		return make([]Key, n)
+	}
+	size := cmp.Or(k.size, keySlabSize)
+	if n > size/4 {
+		k.charge(n)
+		return make([]Key, n)
+	}
+	if n > cap(k.keys)-len(k.keys) {
+		k.charge(size)
+		k.keys = make([]Key, 0, size)
+	}
+	start := len(k.keys)
+	k.keys = k.keys[:start+n]
+	return k.keys[start : start+n : start+n]
+}
+
+// charge учитывает выделение n ключей
+func (k *keySlab) charge(n int) {
+	if k.budget != nil {
+		k.budget.add(int64(n) * keySize)
+	}
+}
+
+// rowArena размещает данные строк Row в общих блоках: байты строк и текстов ключей -
+// в lineArena, ключи - в keySlab. Нулевой указатель выделяет память обычным образом
+type rowArena struct {
+	lines lineArena
+	keys  keySlab
+}
+
+// newRowArena создает арену, которая учитывает в budget выделенные блоки, а также
+// данные строк, лежащие вне ее блоков (в отображении --mmap или в отдельной строке).
+// При малом ограничении -S блоки уменьшаются, чтобы в порцию помещалось много строк
+func newRowArena(budget *memBudget) *rowArena {
+	a := &rowArena{lines: lineArena{budget: budget}, keys: keySlab{budget: budget}}
+	if budget != nil && budget.limit > 0 {
+		block := min(budget.limit/16, lineArenaSize)
+		a.lines.size = int(max(block, 4096))
+		a.keys.size = int(min(max(block/keySize, 64), keySlabSize))
+	}
+	return a
+}
+
+// reset оставляет текущие блоки строкам, которые на них ссылаются, и начинает новые:
+// после сброса порции на диск учет начинается заново
+func (a *rowArena) reset() {
+	a.lines.buf, a.keys.keys = nil, nil
+}
+
+// charge учитывает строку row, если она не лежит в блоке арены. Данные ключей либо
+// совпадают со строкой, либо дописаны в арену и уже учтены вместе с блоком
+func (a *rowArena) charge(row *Row) {
+	if a.lines.budget != nil && !a.lines.owns(row.Original) {
+		a.lines.budget.add(int64(len(row.Original)))
+	}
+}
+
+// offsetIn возвращает смещение sub в s, если sub - часть s, а не копия
+func offsetIn(s, sub string) (int, bool) {
+	if len(sub) == 0 {
+		return 0, true
+	}
+	base, p := uintptr(unsafe.Pointer(unsafe.StringData(s))), uintptr(unsafe.Pointer(unsafe.StringData(sub)))
+	if len(s) == 0 || p < base || p+uintptr(len(sub)) > base+uintptr(len(s)) {
+		return 0, false
+	}
+	return int(p - base), true
+}
+
+// appendFields добавляет к dst поля строки s, разделенные пробельными символами,
+// так же, как strings.Fields, но без выделения нового среза на каждую строку. При limit > 0
+// строка разбирается только до поля номер limit включительно
+func appendFields(dst []string, s string, limit int) []string {
+	start, n := -1, 0
+	for i, r := range s {
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				dst = append(dst, s[start:i])
+				start = -1
+				if n++; n == limit {
+					return dst
+				}
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		dst = append(dst, s[start:])
+	}
+	return dst
+}
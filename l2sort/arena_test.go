@@ -0,0 +1,169 @@
+package l2sort
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+// benchInput возвращает n строк набора данных bench "mixed"
+func benchInput(n int) [][]byte {
+	rng := rand.New(rand.NewPCG(1, 2))
+	var dataset benchDataset
+	for _, d := range benchDatasets {
+		if d.name == "mixed" {
+			dataset = d
+		}
+	}
+	lines := make([][]byte, n)
+	for i := range lines {
+		lines[i] = dataset.line(nil, rng)
+	}
+	return lines
+}
+
+// TestNewRowKeyText проверяет, что ключи, взятые из строки дословно, ссылаются на ее байты,
+// а преобразованные дописываются в арену сразу за строкой, не копируя ее
+func TestNewRowKeyText(t *testing.T) {
+	s := newTestSorter(t, []string{"-k", "2@lower", "-k", "1"})
+	var arena rowArena
+	for _, line := range []string{"x ABC y", "Q Rst", "w"} {
+		row := s.newRow(&arena, arena.lines.intern([]byte(line)), s.extractKeys(line))
+		if row.Original != line || !strings.HasPrefix(row.data, line) {
+			t.Errorf("строка %q: Original %q, данные %q", line, row.Original, row.data)
+		}
+		if unsafe.StringData(row.data) != unsafe.StringData(row.Original) {
+			t.Errorf("строка %q скопирована ради текста ключей", line)
+		}
+		fields := strings.Fields(line)
+		want := []string{}
+		if len(fields) > 1 {
+			want = append(want, strings.ToLower(fields[1]), fields[0])
+		}
+		var got []string
+		for k := range row.Keys {
+			got = append(got, row.keyText(k))
+		}
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("строка %q: ключи %q, ожидалось %q", line, got, want)
+		}
+	}
+}
+
+// TestRowArenaBudget проверяет, что арена учитывает целиком выделенные блоки строк
+// и ключей, а строки вне ее блоков (как при --mmap) - по их длине
+func TestRowArenaBudget(t *testing.T) {
+	s := newTestSorter(t, []string{"-k", "2@upper"})
+	budget := newMemBudget(0)
+	arena := newRowArena(budget)
+	block := int64(lineArenaSize) + keySlabSize*keySize
+	s.newRow(arena, arena.lines.intern([]byte("a b")), s.extractKeys("a b"))
+	if budget.used != block {
+		t.Errorf("после первой строки учтено %d байт, ожидалось %d", budget.used, block)
+	}
+	s.newRow(arena, arena.lines.intern([]byte("c d")), s.extractKeys("c d"))
+	if budget.used != block {
+		t.Errorf("строка в том же блоке учтена повторно: %d байт", budget.used)
+	}
+	mapped := strings.Clone("e f")
+	s.newRow(arena, mapped, s.extractKeys(mapped))
+	if want := block + int64(len("e f")); budget.used != want {
+		t.Errorf("строка вне арены: учтено %d байт, ожидалось %d", budget.used, want)
+	}
+	arena.reset()
+	s.newRow(arena, arena.lines.intern([]byte("g h")), s.extractKeys("g h"))
+	if want := 2*block + int64(len("e f")); budget.used != want {
+		t.Errorf("после reset учтено %d байт, ожидалось %d", budget.used, want)
+	}
+}
+
+// TestSortSpillsArenaBlocks проверяет, что блоки арены сбрасываются на диск по -S:
+// вход вдвое больше ограничения дает несколько прогонов и сортируется верно
+func TestSortSpillsArenaBlocks(t *testing.T) {
+	var lines []string
+	for i := range 20000 {
+		lines = append(lines, fmt.Sprintf("%05d строка", (i*7919)%20000))
+	}
+	sorter := newTestSorter(t, []string{"-S", "256K"})
+	dir := t.TempDir()
+	input, output := filepath.Join(dir, "in.txt"), filepath.Join(dir, "out.txt")
+	if err := os.WriteFile(input, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := sorter.SortFile(input, output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Runs < 2 {
+		t.Errorf("прогонов %d, ожидалось не меньше 2", result.Runs)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if !slices.IsSorted(got) || len(got) != len(lines) {
+		t.Errorf("результат не отсортирован или потерял строки: %d строк", len(got))
+	}
+}
+
+// BenchmarkSort сравнивает выделения памяти при подготовке и сортировке строк до
+// перехода на арены (отдельная строка и срез ключей на каждую строку) и после (строки
+// и тексты ключей в общих блоках lineArena, ключи из keySlab), а также сортировку
+// файла целиком
+func BenchmarkSort(b *testing.B) {
+	lines := benchInput(100_000)
+	for _, args := range [][]string{{}, {"-k", "2n"}, {"-k", "3h", "-k", "1"}} {
+		s := newTestSorter(b, args)
+		name := strings.Join(args, " ")
+		if name == "" {
+			name = "text"
+		}
+		b.Run(name+"/без арены", func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				rows := make([]Row, 0, len(lines))
+				for _, text := range lines {
+					line := string(text)
+					rows = append(rows, s.newRow(nil, line, s.extractKeys(line)))
+				}
+				s.sortRows(rows)
+			}
+		})
+		b.Run(name+"/арена", func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				var arena rowArena
+				rows := make([]Row, 0, len(lines))
+				for _, text := range lines {
+					line := arena.lines.intern(text)
+					rows = append(rows, s.newRow(&arena, line, s.extractKeys(line)))
+				}
+				s.sortRows(rows)
+			}
+		})
+		b.Run(name+"/файл", func(b *testing.B) {
+			dir := b.TempDir()
+			input, output := filepath.Join(dir, "in.txt"), filepath.Join(dir, "out.txt")
+			var data []byte
+			for _, line := range lines {
+				data = append(append(data, line...), '\n')
+			}
+			if err := os.WriteFile(input, data, 0o644); err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := s.SortFile(input, output); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package l2sort
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// batchManifestFile описывает набор заданий сортировки для --batch
+//
+//	{
+//	  "workers": 8,
+//	  "jobs": [
+//	    {"input": "a.txt", "output": "a.sorted", "flags": ["-n", "-k", "2"]},
+//	    {"input": "b.txt"}
+//	  ]
+//	}
+//
+// Флаги задания дополняют флаги командной строки; без output файл сортируется на месте
+type batchManifestFile struct {
+	Workers int        `json:"workers"`
+	Jobs    []batchJob `json:"jobs"`
+}
+
+type batchJob struct {
+	Input  string   `json:"input"`
+	Output string   `json:"output"`
+	Flags  []string `json:"flags"`
+}
+
+// batchOutcome - итог одного задания
+type batchOutcome struct {
+	result  Result
+	err     error
+	elapsed time.Duration
+	// changed - изменилось ли содержимое файла (определяется, только если запрошено)
+	changed bool
+}
+
+// runBatch выполняет задания манифеста общим пулом обработчиков и печатает сводку.
+// Возвращает код завершения: 0, если все задания выполнены успешно
+func runBatch(ctx context.Context, path string, base Options) int {
+	manifest, err := readBatchManifest(path)
+	if err != nil {
+		return reportError(fmt.Errorf("при чтении манифеста: %w", err))
+	}
+	return runBatchJobs(ctx, manifest, base, false)
+}
+
+// runBatchJobs выполняет задания общим пулом обработчиков и печатает сводку. При
+// detectChanges для каждого задания сравнивается содержимое результата до и после
+// сортировки, и сводка перечисляет измененные файлы
+func runBatchJobs(ctx context.Context, manifest *batchManifestFile, base Options, detectChanges bool) int {
+	workers := manifest.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	start := time.Now()
+	outcomes := make([]batchOutcome, len(manifest.Jobs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				jobStart := time.Now()
+				var before []byte
+				if detectChanges {
+					before = batchJobDigest(manifest.Jobs[i])
+				}
+				result, err := runBatchJob(ctx, manifest.Jobs[i], base)
+				outcomes[i] = batchOutcome{result: result, err: err, elapsed: time.Since(jobStart)}
+				if detectChanges && err == nil {
+					after := batchJobDigest(manifest.Jobs[i])
+					outcomes[i].changed = after == nil || !bytes.Equal(before, after)
+				}
+			}
+		}()
+	}
+	for i := range manifest.Jobs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	failed, lines, changed := 0, 0, 0
+	code := 0
+	for i, outcome := range outcomes {
+		job := manifest.Jobs[i]
+		lines += outcome.result.Lines
+		switch {
+		case outcome.err != nil:
+			failed++
+			if code == 0 {
+				code = kindExitCodes[errorKind(outcome.err)]
+			}
+			fmt.Printf("Задание %d (%s): ошибка %v\n", i+1, job.Input, outcome.err)
+			reportJobError(job.Input, outcome.err)
+		case outcome.result.AlreadySorted:
+			fmt.Printf("Задание %d (%s): данные уже отсортированы\n", i+1, job.Input)
+		case outcome.changed:
+			changed++
+			fmt.Printf("Задание %d (%s): файл изменен\n", i+1, job.Input)
+		}
+	}
+	fmt.Printf("Заданий: %d, успешно: %d, с ошибками: %d, строк: %d, время: %s\n",
+		len(outcomes), len(outcomes)-failed, failed, lines, time.Since(start).Round(time.Millisecond))
+	if detectChanges {
+		fmt.Printf("Изменено файлов: %d, без изменений: %d\n", changed, len(outcomes)-failed-changed)
+	}
+	if ctx.Err() != nil {
+		fmt.Println("Прервано: незавершенные задания не изменили свои файлы.")
+		return exitInterrupted
+	}
+	// При ошибках код завершения - по виду ошибки первого неудачного задания
+	return code
+}
+
+func readBatchManifest(path string) (*batchManifestFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var manifest batchManifestFile
+	dec := json.NewDecoder(file)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&manifest); err != nil {
+		return nil, err
+	}
+	for i, job := range manifest.Jobs {
+		if job.Input == "" {
+			return nil, fmt.Errorf("задание %d: не указан input", i+1)
+		}
+	}
+	return &manifest, nil
+}
+
+// batchJobDigest возвращает хэш содержимого файла результата задания; nil, если файл
+// не удалось прочитать (например, его еще нет)
+func batchJobDigest(job batchJob) []byte {
+	output := job.Output
+	if output == "" {
+		output = job.Input
+	}
+	h := sha256.New()
+	if err := hashFile(h, output); err != nil {
+		return nil
+	}
+	return h.Sum(nil)
+}
+
+// runBatchJob разбирает флаги задания поверх общих настроек и выполняет сортировку
+func runBatchJob(ctx context.Context, job batchJob, base Options) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	jobOpts := base.clone()
+	fs := flag.NewFlagSet(job.Input, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	jobOpts.registerFlags(fs)
+	if err := fs.Parse(job.Flags); err != nil {
+		return Result{}, fmt.Errorf("в флагах задания: %w", err)
+	}
+	if fs.NArg() > 0 {
+		return Result{}, fmt.Errorf("в флагах задания: лишние аргументы %v", fs.Args())
+	}
+
+	sorter, err := NewSorter(jobOpts)
+	if err != nil {
+		return Result{}, err
+	}
+	output := job.Output
+	if output == "" {
+		output = job.Input
+	}
+	return sorter.SortFileContext(ctx, job.Input, output)
+}
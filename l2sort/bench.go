@@ -0,0 +1,243 @@
+package l2sort
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// benchDataset - синтетический набор данных bench: line дописывает в буфер одну строку
+type benchDataset struct {
+	name string
+	line func(b []byte, rng *rand.Rand) []byte
+}
+
+// benchDatasets - наборы данных bench в порядке вывода
+var benchDatasets = []benchDataset{
+	{"numeric", func(b []byte, rng *rand.Rand) []byte {
+		b = fmt.Appendf(b, "%d", rng.Int64N(2_000_000_000)-1_000_000_000)
+		if rng.IntN(4) == 0 {
+			b = fmt.Appendf(b, ".%02d", rng.IntN(100))
+		}
+		return b
+	}},
+	{"text", func(b []byte, rng *rand.Rand) []byte {
+		for i := range rng.IntN(4) + 1 {
+			if i > 0 {
+				b = append(b, ' ')
+			}
+			b = appendBenchWord(b, rng)
+		}
+		return b
+	}},
+	{"mixed", func(b []byte, rng *rand.Rand) []byte {
+		b = appendBenchWord(b, rng)
+		b = fmt.Appendf(b, " %d %dK file%d ", rng.IntN(100000), rng.IntN(4096), rng.IntN(1000))
+		return time.Unix(1_600_000_000+rng.Int64N(100_000_000), 0).UTC().AppendFormat(b, time.RFC3339)
+	}},
+	{"dups", func(b []byte, rng *rand.Rand) []byte {
+		// Около сотни различных строк: проверяет сравнение равных ключей и -u
+		return fmt.Appendf(b, "key%03d value%d", rng.IntN(100), rng.IntN(10))
+	}},
+}
+
+// benchMode - режим сортировки bench: имя и флаги поверх общих
+type benchMode struct {
+	name, flags string
+}
+
+// benchModes - режимы сортировки bench в порядке вывода
+var benchModes = []benchMode{
+	{"text", ""},
+	{"reverse", "-r"},
+	{"unique", "-u"},
+	{"numeric", "-n"},
+	{"human", "-h"},
+	{"natural", "--natural"},
+	{"key", "-k 2"},
+	{"time", "-k 5:time"},
+}
+
+// benchComparisons - число случайных сравнений при замере компаратора
+const benchComparisons = 200000
+
+// runBench выполняет подкоманду
+//
+//	l2sort bench [--size 4M] [--datasets numeric,...] [--modes text,...] [--flags '...'] [--seed N]
+//
+// Для каждого набора синтетических данных (numeric - числа, text - слова, mixed - поля
+// разных типов, dups - много повторов) создается файл размера --size, который сортируется
+// каждым режимом с общими флагами и --flags, например -S: так видно, как -S влияет на
+// число прогонов и скорость. Для каждого режима печатаются скорость сортировки, число
+// прогонов на диске, выделения памяти и время одного сравнения строк. Файлы создаются
+// во временном каталоге (-T) и удаляются после замеров. Возвращает код завершения
+func runBench(ctx context.Context, args []string, base Options) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	size := fs.String("size", "4M", "Размер каждого набора данных, например 64M")
+	datasets := fs.String("datasets", "numeric,text,mixed,dups", "Наборы данных через запятую: numeric, text, mixed, dups")
+	modes := fs.String("modes", "", "Режимы через запятую: text, reverse, unique, numeric, human, natural, key, time; по умолчанию все")
+	flags := fs.String("flags", "", "Флаги сортировки, добавляемые ко всем режимам, например '-S 1M'")
+	seed := fs.Uint64("seed", 1, "Начальное значение генератора данных")
+	cmd, _ := findCommand("bench")
+	fs.Usage = func() {
+		fmt.Println("Использование: l2sort " + cmd.usage)
+		fs.PrintDefaults()
+	}
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(files) > 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	bytes, err := parseSize(*size)
+	if err != nil || bytes <= 0 {
+		return reportUsage(fmt.Errorf("в параметре --size: ожидался положительный размер, например 4M"))
+	}
+	var sets []benchDataset
+	for _, name := range strings.Split(*datasets, ",") {
+		i := slices.IndexFunc(benchDatasets, func(d benchDataset) bool { return d.name == name })
+		if i < 0 {
+			return reportUsage(fmt.Errorf("в параметре --datasets: неизвестный набор %q", name))
+		}
+		sets = append(sets, benchDatasets[i])
+	}
+	selected := benchModes
+	if *modes != "" {
+		selected = nil
+		for _, name := range strings.Split(*modes, ",") {
+			i := slices.IndexFunc(benchModes, func(m benchMode) bool { return m.name == name })
+			if i < 0 {
+				return reportUsage(fmt.Errorf("в параметре --modes: неизвестный режим %q", name))
+			}
+			selected = append(selected, benchModes[i])
+		}
+	}
+	sorters := make([]*Sorter, len(selected))
+	for i, m := range selected {
+		if sorters[i], err = sorterWithFlags(base, m.flags+" "+*flags); err != nil {
+			return reportUsage(fmt.Errorf("в режиме %s: %w", m.name, err))
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	rng := rand.New(rand.NewPCG(*seed, 0))
+	for n, set := range sets {
+		// Таблица выводится по наборам, чтобы результаты появлялись по мере замеров
+		if n > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, "набор\tрежим\tстрок\tМБ/с\tстрок/с\tпрогонов\tвыделений\tМБ выделено\tнс/сравнение\t")
+		input, err := writeBenchDataset(base.TempDir, set, bytes, rng)
+		if err != nil {
+			return reportError(fmt.Errorf("при создании набора %s: %w", set.name, err))
+		}
+		for i, m := range selected {
+			r, err := measureBench(ctx, sorters[i], base.TempDir, input)
+			if err != nil {
+				os.Remove(input)
+				return reportError(fmt.Errorf("в режиме %s на наборе %s: %w", m.name, set.name, err))
+			}
+			seconds := r.elapsed.Seconds()
+			fmt.Fprintf(w, "%s\t%s\t%d\t%.1f\t%.0f\t%d\t%d\t%.1f\t%.0f\t\n", set.name, m.name, r.lines,
+				float64(bytes)/(1<<20)/seconds, float64(r.lines)/seconds, r.runs, r.mallocs,
+				float64(r.allocated)/(1<<20), r.compare)
+		}
+		w.Flush()
+		os.Remove(input)
+	}
+	return 0
+}
+
+// appendBenchWord дописывает случайное слово из 3-12 строчных латинских букв
+func appendBenchWord(b []byte, rng *rand.Rand) []byte {
+	for range rng.IntN(10) + 3 {
+		b = append(b, byte('a'+rng.IntN(26)))
+	}
+	return b
+}
+
+// writeBenchDataset записывает во временный файл строки набора общим размером около size
+func writeBenchDataset(tempDir string, set benchDataset, size int64, rng *rand.Rand) (string, error) {
+	file, err := temps.create(tempDir, "bench-"+set.name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+	var line []byte
+	for written := int64(0); written < size; {
+		line = append(set.line(line[:0], rng), '\n')
+		w.Write(line)
+		written += int64(len(line))
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return file.Name(), file.Close()
+}
+
+// benchResult - замеры одного режима на одном наборе
+type benchResult struct {
+	lines     int
+	runs      int
+	elapsed   time.Duration
+	mallocs   uint64
+	allocated uint64
+	// compare - среднее время одного сравнения строк в наносекундах
+	compare float64
+}
+
+// measureBench сортирует input в новый временный файл, замеряя время и выделения памяти,
+// а затем отдельно замеряет компаратор на случайных парах строк input
+func measureBench(ctx context.Context, s *Sorter, tempDir, input string) (benchResult, error) {
+	var r benchResult
+	output, err := temps.create(tempDir, "bench-out")
+	if err != nil {
+		return r, err
+	}
+	output.Close()
+	defer os.Remove(output.Name())
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	result, err := s.SortFileContext(ctx, input, output.Name())
+	r.elapsed = time.Since(start)
+	runtime.ReadMemStats(&after)
+	if err != nil {
+		return r, err
+	}
+	r.lines, r.runs = result.Lines, result.Runs
+	r.mallocs, r.allocated = after.Mallocs-before.Mallocs, after.TotalAlloc-before.TotalAlloc
+
+	in, err := s.readRows(ctx, []string{input}, newMemBudget(0))
+	if err != nil {
+		return r, err
+	}
+	defer in.close()
+	if len(in.rows) == 0 {
+		return r, nil
+	}
+	rng := rand.New(rand.NewPCG(1, 0))
+	pairs := make([][2]int, benchComparisons)
+	for i := range pairs {
+		pairs[i] = [2]int{rng.IntN(len(in.rows)), rng.IntN(len(in.rows))}
+	}
+	start = time.Now()
+	for _, p := range pairs {
+		s.compareOrder(&in.rows[p[0]], &in.rows[p[1]])
+	}
+	r.compare = float64(time.Since(start).Nanoseconds()) / benchComparisons
+	return r, nil
+}
@@ -0,0 +1,91 @@
+package l2sort
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// cacheFormatVersion меняется при изменениях, после которых старые результаты в кэше недействительны
+const cacheFormatVersion = "1"
+
+// usesCache сообщает, что результат берется из --cache-dir и сохраняется в него. Кэш
+// не используется, когда результат - не один поток строк, когда он случаен и когда
+// порядок зависит от сведений о файлах (--by, :mtime, :size), которых нет в ключе кэша
+func (s *Sorter) usesCache() bool {
+	o := s.opts
+	return o.CacheDir != "" && !o.Check && o.PartitionBy == 0 && o.Split == 0 && o.DupsOutput == "" &&
+		o.Index == "" && o.ToSQLite == "" && !s.randomized() && s.stat == nil
+}
+
+// cacheKey вычисляет ключ кэша по содержимому входа, настройкам, влияющим на результат,
+// и файлу --alphabet
+func (s *Sorter) cacheKey(inputs []string) (string, error) {
+	h := sha256.New()
+	io.WriteString(h, cacheFormatVersion+"\x00")
+	for i, input := range inputs {
+		if i > 0 {
+			h.Write([]byte{0})
+		}
+		if err := hashFile(h, input); err != nil {
+			return "", err
+		}
+	}
+
+	// Настройки, определяющие только куда и как хранится результат, в ключ не входят
+	o := s.opts.clone()
+	o.AlsoOutputs, o.Backup, o.CompressOutput = nil, false, false
+	o.KeepTemp, o.TempDir, o.BufferSize, o.CompressTemp, o.CompressProgram = false, "", "", false, ""
+	o.Debug, o.MaxLineSize, o.CacheDir = false, "", ""
+	fingerprint, err := json.Marshal(o)
+	if err != nil {
+		return "", err
+	}
+	h.Write([]byte{0})
+	h.Write(fingerprint)
+	// Порядок --alphabet задается содержимым файла, а не его именем
+	if o.Alphabet != "" {
+		h.Write([]byte{0})
+		if err := hashFile(h, o.Alphabet); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cachePath возвращает путь к результату в кэше
+func (s *Sorter) cachePath(key string) string {
+	return filepath.Join(s.opts.CacheDir, key+".out")
+}
+
+// openCached открывает результат из кэша; ok=false, если его там нет
+func (s *Sorter) openCached(key string) (file *os.File, ok bool) {
+	file, err := os.Open(s.cachePath(key))
+	if err != nil {
+		return nil, false
+	}
+	return file, true
+}
+
+// createCacheEntry открывает запись нового результата в кэш. Запись атомарна,
+// поэтому прерванный запуск не оставит в кэше неполный результат
+func (s *Sorter) createCacheEntry(key string) (io.WriteCloser, error) {
+	if err := os.MkdirAll(s.opts.CacheDir, 0o755); err != nil {
+		return nil, err
+	}
+	return createAtomic(s.cachePath(key), false)
+}
+
+// hashFile добавляет содержимое файла path в h
+func hashFile(h io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(h, file)
+	return err
+}
@@ -0,0 +1,140 @@
+package l2sort
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// checksumSuffix - расширение файла контрольной суммы рядом с результатом
+const checksumSuffix = ".sha256"
+
+// checkChecksum проверяет совместимость --checksum и --checksum-file: сумма считается
+// для одного файла результата
+func checkChecksum(o Options) error {
+	if (o.Checksum || o.ChecksumFile) && (o.PartitionBy > 0 || o.Split > 0) {
+		return fmt.Errorf("в параметре --checksum: сумма считается для одного файла результата и несовместима с --partition-by и --split")
+	}
+	return nil
+}
+
+// outputHash возвращает хэш для основного результата, если запрошена контрольная сумма
+func (s *Sorter) outputHash(path string) (hash.Hash, error) {
+	if !s.opts.Checksum && !s.opts.ChecksumFile {
+		return nil, nil
+	}
+	if s.opts.ChecksumFile && path == "-" {
+		return nil, fmt.Errorf("в параметре --checksum-file: результат выводится в стандартный вывод, и файлу суммы негде лежать")
+	}
+	return sha256.New(), nil
+}
+
+// hashedOutput передает в hash байты, записанные получателю (после сжатия), поэтому сумма
+// совпадает с sha256sum готового файла
+type hashedOutput struct {
+	io.WriteCloser
+	hash io.Writer
+}
+
+func (w *hashedOutput) Write(p []byte) (int, error) {
+	n, err := w.WriteCloser.Write(p)
+	w.hash.Write(p[:n])
+	return n, err
+}
+
+func (w *hashedOutput) discard() {
+	discardOutput(w.WriteCloser)
+}
+
+// reportChecksum печатает сумму записанного результата в stderr в формате sha256sum и
+// при --checksum-file сохраняет ее в ФАЙЛ.sha256
+func (s *Sorter) reportChecksum(path string, sum []byte) error {
+	line := fmt.Sprintf("%x  %s\n", sum, filepath.Base(path))
+	if s.opts.Checksum {
+		fmt.Fprintf(os.Stderr, "%x  %s\n", sum, path)
+	}
+	if !s.opts.ChecksumFile {
+		return nil
+	}
+	file, err := createAtomic(path+checksumSuffix, false)
+	if err != nil {
+		return fmt.Errorf("при записи контрольной суммы: %w", err)
+	}
+	if _, err := file.WriteString(line); err != nil {
+		file.discard()
+		return fmt.Errorf("при записи контрольной суммы: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("при записи контрольной суммы: %w", err)
+	}
+	return nil
+}
+
+// runVerify проверяет файлы по списку сумм в формате sha256sum (например, созданному
+// --checksum-file); имена в нем отсчитываются от каталога списка. Печатает итог по каждому
+// файлу и возвращает код завершения: 0, если все суммы совпали, exitCheck, если нет
+func runVerify(path string) int {
+	file, err := os.Open(path)
+	if err != nil {
+		return reportError(fmt.Errorf("в параметре --verify: %w", err))
+	}
+	defer file.Close()
+
+	dir := filepath.Dir(path)
+	mismatched, checked := 0, 0
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		want, name, ok := parseChecksumLine(line)
+		if !ok {
+			return reportUsage(fmt.Errorf("в параметре --verify: строка %d: ожидалось \"СУММА  ИМЯ\"", lineNum))
+		}
+		target := name
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, name)
+		}
+		h := sha256.New()
+		if err := hashFile(h, target); err != nil {
+			return reportError(fmt.Errorf("при проверке %s: %w", name, err))
+		}
+		checked++
+		if hex.EncodeToString(h.Sum(nil)) == want {
+			fmt.Printf("%s: OK\n", name)
+		} else {
+			mismatched++
+			fmt.Printf("%s: сумма не совпадает\n", name)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return reportError(fmt.Errorf("в параметре --verify: %w", err))
+	}
+	if mismatched > 0 {
+		return reportCheck(fmt.Errorf("при проверке сумм: не совпало %d из %d", mismatched, checked))
+	}
+	return 0
+}
+
+// parseChecksumLine разбирает строку sha256sum: сумма, пробел, признак режима (пробел
+// или * для двоичного) и имя файла
+func parseChecksumLine(line string) (sum, name string, ok bool) {
+	if len(line) < sha256.Size*2+3 || line[sha256.Size*2] != ' ' {
+		return "", "", false
+	}
+	sum = strings.ToLower(line[:sha256.Size*2])
+	if _, err := hex.DecodeString(sum); err != nil {
+		return "", "", false
+	}
+	if mode := line[sha256.Size*2+1]; mode != ' ' && mode != '*' {
+		return "", "", false
+	}
+	return sum, line[sha256.Size*2+2:], true
+}
@@ -0,0 +1,73 @@
+package l2sort
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CheckUnique проверяет, не сортируя, что в уже отсортированном входе нет повторяющихся
+// ключей: соседние строки с равными ключами печатаются с номерами строк, как и место,
+// где нарушен порядок (после него повторы могут оказаться не рядом). Возвращает число
+// найденных нарушений
+func (s *Sorter) CheckUnique(ctx context.Context, input string) (int, error) {
+	problems := 0
+	var prev Row
+	prevLine := 0
+	ordered := true
+	in, err := s.scanRows(ctx, []string{input}, nil, func(in *inputData, row Row) error {
+		if prevLine > 0 {
+			switch {
+			case s.sameGroup(&prev, &row):
+				problems++
+				fmt.Printf("Строка %d: ключ %q повторяет строку %d\n", in.lines, keysText(&row), prevLine)
+			case ordered && s.compareOrder(&row, &prev) < 0:
+				problems++
+				ordered = false
+				fmt.Printf("Строка %d: нарушен порядок сортировки, дальнейшие повторы могут быть не найдены\n", in.lines)
+			}
+		}
+		prev, prevLine = row, in.lines
+		return nil
+	})
+	if err != nil {
+		return problems, err
+	}
+	in.close()
+	return problems, nil
+}
+
+// CheckSorted проверяет, не изменяя вход, что он отсортирован (при -u - еще и без
+// одинаковых строк подряд). Возвращает номер первой строки, нарушающей порядок, или 0
+func (s *Sorter) CheckSorted(ctx context.Context, input string) (int, error) {
+	var prev Row
+	prevLine, bad := 0, 0
+	errStop := errors.New("нарушен порядок")
+	in, err := s.scanRows(ctx, []string{input}, nil, func(in *inputData, row Row) error {
+		if prevLine > 0 {
+			if s.sortOrder(&row, &prev) < 0 || s.opts.Unique && s.sameGroup(&prev, &row) && s.dedupLine(&row) == s.dedupLine(&prev) {
+				bad = in.lines
+				return errStop
+			}
+		}
+		prev, prevLine = row, in.lines
+		return nil
+	})
+	if in != nil {
+		in.close()
+	}
+	if err != nil && !errors.Is(err, errStop) {
+		return 0, err
+	}
+	return bad, nil
+}
+
+// keysText возвращает текст ключей строки через пробел
+func keysText(row *Row) string {
+	texts := make([]string, len(row.Keys))
+	for i := range row.Keys {
+		texts[i] = row.keyText(i)
+	}
+	return strings.Join(texts, " ")
+}
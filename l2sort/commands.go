@@ -0,0 +1,282 @@
+package l2sort
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+)
+
+// command - подкоманда l2sort со своим набором флагов
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, args []string, base Options) int
+}
+
+// commands - подкоманды в порядке вывода в справке. Флаги сортировки, заданные до имени
+// подкоманды (и в файле настроек), служат общими настройками для нее. Вызов без
+// подкоманды, l2sort [опции] файл, - то же, что l2sort sort [опции] файл. Таблица
+// заполняется в init, потому что подкоманды сами ищут в ней свою строку использования
+var commands []command
+
+func init() {
+	commands = []command{
+		{"sort", "sort [опции] [-o результат] файл...", runSortCommand},
+		{"merge", "merge [опции] [--tag-source name|index] [-o результат] файл...", runMerge},
+		{"check", "check [опции] файл...", runCheck},
+		{"shuffle", "shuffle [опции] [--seed N] [-o результат] файл...", runShuffle},
+		{"sheet", "sheet --column C [--header-rows N] [опции] [-o результат] файл.xlsx|файл.ods", runSheet},
+		{"parquet", "parquet --column ИМЯ [опции] [-o результат.parquet|результат.ndjson] файл.parquet", runParquet},
+		{"join", "join [--type ...] [--flags '...'] файл1 файл2", runJoin},
+		{"look", "look [--flags '...'] файл префикс", runLook},
+		{"diff", "diff [-1] [-2] [-3] [--flags '...'] файл1 файл2", runDiff},
+		{"set", "set --union|--intersect|--except [--flags '...'] файл...", runSet},
+		{"selftest", "selftest файл [--flags '...']", runSelftest},
+		{"bench", "bench [--size 4M] [--datasets numeric,...] [--modes text,...] [--flags '...']", runBench},
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// printUsage печатает строки использования l2sort и его подкоманд
+func printUsage() {
+	fmt.Println("Использование: l2sort [опции] файл")
+	for _, cmd := range commands {
+		fmt.Println("               l2sort " + cmd.usage)
+	}
+	fmt.Println("               l2sort --files0-from=список [опции] результат")
+	fmt.Println("               l2sort --serve :8080 [опции]")
+	fmt.Println("               l2sort --serve-grpc :9090 [опции]")
+	fmt.Println("               l2sort --recursive каталог [--glob '*.txt'] [опции]")
+	fmt.Println("               l2sort --window N [--window-time 5s] [опции] [файл|-]")
+	fmt.Println("               l2sort --interactive [--interactive-lines N] [опции] файл")
+	fmt.Println("Справка по флагам подкоманды: l2sort ПОДКОМАНДА -h")
+}
+
+// sortFlagSet создает набор флагов подкоманды с флагами сортировки поверх base и
+// возвращает его вместе с настройками, которые он заполняет
+func sortFlagSet(name, usage string, base Options) (*flag.FlagSet, *Options) {
+	o := base.clone()
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	o.registerFlags(fs)
+	fs.Usage = func() {
+		fmt.Println("Использование: l2sort " + usage)
+		fs.PrintDefaults()
+	}
+	return fs, &o
+}
+
+// runSortCommand выполняет подкоманду
+//
+//	l2sort sort [опции] [-o результат] файл...
+//
+// Без -o единственный файл сортируется на месте, как при вызове без подкоманды; с -o
+// все файлы сортируются вместе, как один вход. Возвращает код завершения
+func runSortCommand(ctx context.Context, args []string, base Options) int {
+	return sortCommand(ctx, "sort", args, base, false)
+}
+
+// runShuffle выполняет подкоманду
+//
+//	l2sort shuffle [опции] [--seed N] [-o результат] файл...
+//
+// То же, что sort --shuffle: строки перемешиваются в равновероятном порядке
+func runShuffle(ctx context.Context, args []string, base Options) int {
+	return sortCommand(ctx, "shuffle", args, base, true)
+}
+
+func sortCommand(ctx context.Context, name string, args []string, base Options, shuffle bool) int {
+	cmd, _ := findCommand(name)
+	fs, o := sortFlagSet(name, cmd.usage, base)
+	output := fs.String("o", "", "Файл результата (\"-\" - стандартный вывод); по умолчанию единственный файл сортируется на месте")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(files) == 0 || len(files) > 1 && *output == "" {
+		fs.Usage()
+		return exitUsage
+	}
+	o.Shuffle = o.Shuffle || shuffle
+	if *output == "" {
+		*output = files[0]
+	}
+	return runSortFiles(ctx, *o, files, *output)
+}
+
+// runSortFiles сортирует inputs вместе в output по настройкам o и печатает итог;
+// при --check-unique единственный вход только проверяется. Возвращает код завершения
+func runSortFiles(ctx context.Context, o Options, inputs []string, output string) int {
+	sorter, err := NewSorter(o)
+	if err != nil {
+		return reportUsage(err)
+	}
+	if o.CheckUnique {
+		if len(inputs) != 1 {
+			return reportUsage(fmt.Errorf("в параметре --check-unique: проверяется только один файл"))
+		}
+		problems, err := sorter.CheckUnique(ctx, inputs[0])
+		if err != nil {
+			return reportError(fmt.Errorf("при чтении файла: %w", err))
+		}
+		if problems > 0 {
+			return reportCheck(fmt.Errorf("при проверке --check-unique: найдено нарушений: %d", problems))
+		}
+		fmt.Println("Повторяющихся ключей нет.")
+		return 0
+	}
+	result, err := sorter.SortFilesContext(ctx, inputs, output)
+	if err != nil {
+		return reportError(err)
+	}
+	if o.DryRun {
+		printDryRun(result, o.Unique)
+		return 0
+	}
+	if result.AlreadySorted {
+		fmt.Println("Данные уже отсортированы.")
+	}
+	return 0
+}
+
+// runMerge выполняет подкоманду
+//
+//	l2sort merge [опции] [-o результат] файл...
+//
+// Сливает уже отсортированные с теми же флагами файлы в один упорядоченный поток, не
+// сортируя их заново (как sort -m); неупорядоченный вход - ошибка. Возвращает код завершения
+func runMerge(ctx context.Context, args []string, base Options) int {
+	cmd, _ := findCommand("merge")
+	fs, o := sortFlagSet("merge", cmd.usage, base)
+	output := fs.String("o", "-", "Файл результата (\"-\" - стандартный вывод)")
+	var tag SourceTag
+	fs.StringVar(&tag.Mode, "tag-source", "", "Помечать каждую строку файлом, из которого она пришла: name - именем файла, index - его номером с 1")
+	fs.BoolVar(&tag.Append, "tag-append", false, "Ставить метку --tag-source последним полем строки, а не первым")
+	fs.StringVar(&tag.Sep, "tag-sep", "\t", "Разделитель между меткой --tag-source и строкой")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(files) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if err := tag.check(*o); err != nil {
+		return reportUsage(err)
+	}
+	sorter, err := NewSorter(*o)
+	if err != nil {
+		return reportUsage(err)
+	}
+	if err := sorter.MergeFilesTagged(ctx, files, *output, tag); err != nil {
+		return reportError(err)
+	}
+	return 0
+}
+
+// MergeFiles сливает отсортированные inputs и записывает результат в output. При -u
+// повторы отбрасываются и между файлами. Входы читаются потоком: строка выводится, как
+// только определено, из какого входа она идет, и чтение ждет, пока получатель вывода
+// не заберет строки, поэтому в памяти держится не больше streamBuffer строк каждого
+// входа. Неупорядоченный вход обнаруживается при чтении, когда часть результата уже
+// выведена; файл результата при этом не создается
+func (s *Sorter) MergeFiles(ctx context.Context, inputs []string, output string) error {
+	return s.MergeFilesTagged(ctx, inputs, output, SourceTag{})
+}
+
+// MergeFilesTagged работает как MergeFiles и помечает каждую строку результата файлом,
+// из которого она пришла, по tag
+func (s *Sorter) MergeFilesTagged(ctx context.Context, inputs []string, output string, tag SourceTag) error {
+	if err := tag.check(s.opts); err != nil {
+		return err
+	}
+	if s.opts.InferTypes {
+		return fmt.Errorf("в параметре --infer-types: сливаемые файлы уже отсортированы, типы ключей нужно указать явно, как при их сортировке")
+	}
+	if s.opts.UniqueOnly {
+		return fmt.Errorf("в параметре --unique-only: слияние сохраняет порядок сортировки; для удаления повторов при слиянии укажите -u")
+	}
+	// Отмена останавливает чтение входов, если вывод прекратился раньше их конца
+	ctx, cancel := context.WithCancel(ctx)
+	streams := make([]*streamInput, len(inputs))
+	sources := make([]rowSource, len(inputs))
+	for i, path := range inputs {
+		streams[i] = s.streamInput(ctx, path)
+		sources[i] = streams[i]
+	}
+	defer func() {
+		cancel()
+		for _, st := range streams {
+			st.close()
+		}
+	}()
+	merge := s.newMergeSource(sources)
+	merge.tag = tag.tagger(inputs)
+	var src rowSource = merge
+	if s.opts.Unique {
+		src = &uniqueSource{src: peekSource{src: src}, sorter: s, keepLast: s.opts.UniqueKeep == uniqueKeepLast}
+	}
+	// Кодировка и окончания строк вывода берутся из первой строки первого входа. Последняя
+	// строка результата завершается всегда: иначе окончание выведенной строки пришлось бы
+	// придерживать до конца всех входов
+	first := streams[0]
+	<-first.started
+	if first.enc == nil {
+		// Первый вход не удалось прочитать
+		<-first.done
+		return first.err
+	}
+	eol := first.eol
+	eol.final = true
+	return s.writeOutputs(output, nil, func(w io.Writer) error {
+		return writeRows(first.enc.encoder(w), src, s.format, eol)
+	})
+}
+
+// runCheck выполняет подкоманду
+//
+//	l2sort check [опции] файл...
+//
+// Проверяет, не изменяя файлы, что каждый из них отсортирован с заданными флагами (при -u -
+// еще и без повторяющихся строк), и печатает первое нарушение. Возвращает код завершения:
+// 0, если все файлы отсортированы, exitCheck, если нет
+func runCheck(ctx context.Context, args []string, base Options) int {
+	cmd, _ := findCommand("check")
+	fs, o := sortFlagSet("check", cmd.usage, base)
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(files) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	sorter, err := NewSorter(*o)
+	if err != nil {
+		return reportUsage(err)
+	}
+	unsorted := 0
+	for _, path := range files {
+		line, err := sorter.CheckSorted(ctx, path)
+		if err != nil {
+			return reportError(fmt.Errorf("при чтении файла %s: %w", path, err))
+		}
+		if line > 0 {
+			unsorted++
+			fmt.Printf("%s: строка %d: нарушен порядок сортировки\n", path, line)
+		}
+	}
+	if unsorted > 0 {
+		return reportCheck(fmt.Errorf("при проверке: не отсортировано файлов: %d", unsorted))
+	}
+	fmt.Println("Данные уже отсортированы.")
+	return 0
+}
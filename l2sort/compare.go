@@ -0,0 +1,58 @@
+package l2sort
+
+import "strings"
+
+// naturalCompare сравнивает строки, разбивая их на последовательности цифр и остальных символов:
+// цифровые части сравниваются как числа, остальные - как строки, поэтому "file2" < "file10".
+// Возвращает -1, 0 или 1
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		chunkA, restA := nextChunk(a)
+		chunkB, restB := nextChunk(b)
+		var c int
+		if isDigit(chunkA[0]) && isDigit(chunkB[0]) {
+			c = compareDigits(chunkA, chunkB)
+		} else {
+			c = strings.Compare(chunkA, chunkB)
+		}
+		if c != 0 {
+			return c
+		}
+		a, b = restA, restB
+	}
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a == "":
+		return -1
+	default:
+		return 1
+	}
+}
+
+// nextChunk отделяет от начала строки последовательность цифр либо последовательность прочих символов
+func nextChunk(s string) (string, string) {
+	digits := isDigit(s[0])
+	i := 1
+	for i < len(s) && isDigit(s[i]) == digits {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// compareDigits сравнивает строки из цифр как числа произвольной длины
+func compareDigits(a, b string) int {
+	trimmedA := strings.TrimLeft(a, "0")
+	trimmedB := strings.TrimLeft(b, "0")
+	if len(trimmedA) != len(trimmedB) {
+		if len(trimmedA) < len(trimmedB) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(trimmedA, trimmedB)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,115 @@
+package l2sort
+
+import (
+	"cmp"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+// testTokens - значения полей для случайных строк: числа, в том числе с суффиксами,
+// разделителями и знаками, месяцы, версии, регистр и пустые поля, чтобы равные
+// и не разобранные типом ключи встречались часто
+var testTokens = []string{
+	"", "0", "-0", "1", "01", "10", "-2", "2.5", "1e3", "+3", "1,000", "0x1f",
+	"1K", "2k", "1M", "-1G", "jan", "Feb", "DEC", "sept",
+	"a", "A", "b", "ab", "a1", "a10", "v1.2", "v1.10", "1.2.3", " ", "é", "x y",
+}
+
+// testLine - случайная строка из одного-трех полей testTokens для testing/quick
+type testLine string
+
+func (testLine) Generate(r *rand.Rand, _ int) reflect.Value {
+	fields := make([]string, 1+r.Intn(3))
+	for i := range fields {
+		fields[i] = testTokens[r.Intn(len(testTokens))]
+	}
+	return reflect.ValueOf(testLine(strings.Join(fields, " ")))
+}
+
+// compareFlagSets - наборы флагов, для которых проверяются свойства сравнения
+var compareFlagSets = [][]string{
+	{},
+	{"-n"},
+	{"-h"},
+	{"-M"},
+	{"--natural"},
+	{"--time"},
+	{"-b"},
+	{"-s"},
+	{"--compound"},
+	{"--numerals"},
+	{"--length"},
+	{"-k", "2n", "-k", "1"},
+	{"-k", "1f", "-k", "3r"},
+	{"-k", "2h", "-k", "1M"},
+	{"--ignore-leading-zeros", "-n"},
+	{"-n", "--epsilon", "0.5"},
+	{"-k", "2h~1", "-k", "1n~0.1"},
+	{"--compat", "gnu", "-n"},
+	{"--compat", "gnu", "-k", "2,2h"},
+}
+
+// TestCompareRowsOrder проверяет, что CompareRows и итоговый порядок sortOrder задают
+// строгий слабый порядок при любых типах ключей, с -r и без: сравнение антисимметрично
+// и транзитивно (в том числе равенство)
+func TestCompareRowsOrder(t *testing.T) {
+	for _, flags := range compareFlagSets {
+		for _, reverse := range []bool{false, true} {
+			args := flags
+			if reverse {
+				args = append([]string{"-r"}, flags...)
+			}
+			t.Run(strings.Join(args, " "), func(t *testing.T) {
+				s := newTestSorter(t, args)
+				row := func(line testLine) *Row {
+					text := string(line)
+					row := s.newRow(nil, text, s.extractKeys(text))
+					return &row
+				}
+				for name, compare := range map[string]func(a, b *Row) int{
+					"CompareRows": s.CompareRows,
+					"sortOrder":   s.sortOrder,
+				} {
+					antisymmetric := func(a, b testLine) bool {
+						ra, rb := row(a), row(b)
+						return cmp.Compare(compare(ra, rb), 0) == -cmp.Compare(compare(rb, ra), 0)
+					}
+					if err := quick.Check(antisymmetric, &quick.Config{MaxCount: 2000}); err != nil {
+						t.Errorf("%s не антисимметрично: %v", name, err)
+					}
+					transitive := func(a, b, c testLine) bool {
+						ra, rb, rc := row(a), row(b), row(c)
+						ab, bc, ac := compare(ra, rb), compare(rb, rc), compare(ra, rc)
+						switch {
+						case ab <= 0 && bc <= 0 && (ab < 0 || bc < 0):
+							return ac < 0
+						case ab >= 0 && bc >= 0 && (ab > 0 || bc > 0):
+							return ac > 0
+						case ab == 0 && bc == 0:
+							return ac == 0
+						}
+						return true
+					}
+					if err := quick.Check(transitive, &quick.Config{MaxCount: 5000}); err != nil {
+						t.Errorf("%s не транзитивно: %v", name, err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestEpsilonGroups проверяет, что --epsilon не меняет порядок сортировки, а -u сводит
+// в одну группу значения, близкие к первому в группе, но не цепочку близких соседей
+func TestEpsilonGroups(t *testing.T) {
+	input := []string{"1.3", "1.0", "0.8", "1.6", "5"}
+	if got, want := sortLines(t, []string{"-n", "--epsilon", "0.5"}, input), []string{"0.8", "1.0", "1.3", "1.6", "5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("сортировка с допуском: %q, ожидалось %q", got, want)
+	}
+	if got, want := sortLines(t, []string{"-n", "-u", "--epsilon", "0.5"}, input), []string{"0.8", "1.6", "5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("-u с допуском: %q, ожидалось %q", got, want)
+	}
+}
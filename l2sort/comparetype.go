@@ -0,0 +1,181 @@
+package l2sort
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Типы сравнения ключей. У каждого ключа ровно один тип, и правила выбора такие:
+//
+//  1. модификатор ключа -k (буква, как в GNU sort, или :имя): -k 2n, -k 3:time;
+//  2. иначе общий флаг типа (-n, -h, -M, --natural, -R, --time, --duration, --ip, --email,
+//     --url, --mac, --uuid, --by, --hash-order, --compound или --key-type); таких флагов может быть не больше одного,
+//     сочетание двух - ошибка;
+//  3. иначе ключ сравнивается как текст, а при --numerals - естественно, как при --natural.
+//
+// Ключи --key-regex и --expr, а также ключ-строка без -k получают тип из общего флага.
+// Ключ, который не удалось разобрать своим типом, идет раньше разобранных, а такие
+// ключи между собой и равные значения сравниваются как текст
+const (
+	typeText     = "text"
+	typeNumeric  = "numeric"
+	typeHuman    = "human"
+	typeMonth    = "month"
+	typeNatural  = "natural"
+	typeRandom   = "random"
+	typeTime     = "time"
+	typeDuration = "duration"
+	typeIP       = "ip"
+	typeEmail    = "email"
+	typeURL      = "url"
+	typeMAC      = "mac"
+	typeUUID     = "uuid"
+	typeMtime    = "mtime"
+	typeSize     = "size"
+	typeHash     = "hash"
+	typeCompound = "compound"
+)
+
+// typeLetters - однобуквенные модификаторы типа в -k
+var typeLetters = map[rune]string{
+	'n': typeNumeric,
+	'h': typeHuman,
+	'M': typeMonth,
+	'V': typeNatural,
+	'R': typeRandom,
+}
+
+// builtinTypes - встроенные типы, которые можно указать в -k как :имя
+var builtinTypes = map[string]bool{
+	typeText: true, typeNumeric: true, typeHuman: true, typeMonth: true, typeNatural: true,
+	typeRandom: true, typeTime: true, typeDuration: true, typeIP: true, typeEmail: true,
+	typeURL: true, typeMAC: true, typeUUID: true, typeMtime: true, typeSize: true,
+	typeHash: true, typeCompound: true,
+}
+
+// keyKind - тип сравнения одного ключа; custom задан для типов RegisterKeyType
+type keyKind struct {
+	name   string
+	custom *namedKeyType
+	// epsilon - допуск сравнения чисел -n и -h, 0 - сравнение точное
+	epsilon float64
+}
+
+// globalTypeFlag - общий флаг типа сравнения
+type globalTypeFlag struct {
+	flag, name string
+}
+
+// globalTypeFlags возвращает включенные общие флаги типа сравнения в порядке справки
+func globalTypeFlags(o Options) []globalTypeFlag {
+	var flags []globalTypeFlag
+	add := func(on bool, flag, name string) {
+		if on {
+			flags = append(flags, globalTypeFlag{flag, name})
+		}
+	}
+	add(o.Numeric, "-n", typeNumeric)
+	add(o.HumanNumeric, "-h", typeHuman)
+	add(o.Month, "-M", typeMonth)
+	add(o.Natural, "--natural", typeNatural)
+	add(o.RandomSort, "-R", typeRandom)
+	add(o.Time, "--time", typeTime)
+	add(o.Duration, "--duration", typeDuration)
+	add(o.IP, "--ip", typeIP)
+	add(o.Email, "--email", typeEmail)
+	add(o.URL, "--url", typeURL)
+	add(o.MAC, "--mac", typeMAC)
+	add(o.UUID, "--uuid", typeUUID)
+	add(o.By != "", "--by "+o.By, o.By)
+	add(o.HashOrder, "--hash-order", typeHash)
+	add(o.Compound, "--compound", typeCompound)
+	for _, name := range o.KeyTypes {
+		add(true, "--key-type "+name, name)
+	}
+	return flags
+}
+
+// resolveKeyKinds определяет тип сравнения каждого ключа -k и ключей без модификатора
+func (s *Sorter) resolveKeyKinds() error {
+	flags := globalTypeFlags(s.opts)
+	if len(flags) > 1 {
+		return fmt.Errorf("в параметрах: %s и %s задают разные типы сравнения; тип отдельного ключа задается в -k, например -k 1n -k 2:time", flags[0].flag, flags[1].flag)
+	}
+	s.defaultKind = keyKind{name: typeText}
+	if len(flags) == 1 {
+		kind, err := lookupKeyKind(flags[0].name)
+		if err != nil {
+			return fmt.Errorf("в параметре --key-type: %w", err)
+		}
+		s.defaultKind = kind
+	} else if s.opts.Numerals {
+		s.defaultKind = keyKind{name: typeNatural}
+	}
+	s.keyKinds = make([]keyKind, len(s.opts.Keys))
+	for i, spec := range s.opts.Keys {
+		if spec.Type == "" {
+			s.keyKinds[i] = s.defaultKind
+			continue
+		}
+		kind, err := lookupKeyKind(spec.Type)
+		if err != nil {
+			return fmt.Errorf("в параметре -k: в ключе %s: %w", spec, err)
+		}
+		s.keyKinds[i] = kind
+	}
+	return nil
+}
+
+// lookupKeyKind находит встроенный или зарегистрированный тип по имени
+func lookupKeyKind(name string) (keyKind, error) {
+	if builtinTypes[name] {
+		return keyKind{name: name}, nil
+	}
+	types, err := lookupKeyTypes([]string{name})
+	if err != nil {
+		return keyKind{}, fmt.Errorf("%w; встроенные типы: %s", err, strings.Join(slices.Sorted(maps.Keys(builtinTypes)), ", "))
+	}
+	return keyKind{name: name, custom: &types[0]}, nil
+}
+
+// kindOf возвращает тип сравнения ключа номер k (с 0)
+func (s *Sorter) kindOf(k int) keyKind {
+	if k < len(s.keyKinds) {
+		return s.keyKinds[k]
+	}
+	return s.defaultKind
+}
+
+// usesKind сообщает, что тип name есть хотя бы у одного ключа
+func (s *Sorter) usesKind(name string) bool {
+	if s.defaultKind.name == name {
+		return true
+	}
+	for _, kind := range s.keyKinds {
+		if kind.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseKeyType разбирает тип сравнения после модификаторов ключа -k: букву из typeLetters
+// или :имя. Возвращает имя типа или пустую строку, если тип не указан
+func parseKeyType(letters []rune, name string) (string, error) {
+	var types []string
+	for _, r := range letters {
+		types = append(types, typeLetters[r])
+	}
+	if name != "" {
+		types = append(types, name)
+	}
+	switch len(types) {
+	case 0:
+		return "", nil
+	case 1:
+		return types[0], nil
+	}
+	return "", fmt.Errorf("у ключа может быть только один тип сравнения, указаны: %s", strings.Join(types, ", "))
+}
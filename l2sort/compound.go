@@ -0,0 +1,38 @@
+package l2sort
+
+import "strings"
+
+// compoundSepDefault - разделитель частей составного номера --compound по умолчанию
+const compoundSepDefault = "."
+
+// parseCompoundKey разбирает составной номер вроде 3.12.7.1 на части через sep. Каждая
+// часть - непустая строка цифр; один разделитель в конце (нумерация пунктов "3.12.")
+// допускается. Для ключа другого вида возвращает nil
+func parseCompoundKey(text, sep string) []string {
+	text = strings.TrimSuffix(strings.TrimSpace(text), sep)
+	if text == "" {
+		return nil
+	}
+	parts := strings.Split(text, sep)
+	for _, part := range parts {
+		if part == "" || strings.TrimFunc(part, func(r rune) bool { return r >= '0' && r <= '9' }) != "" {
+			return nil
+		}
+	}
+	return parts
+}
+
+// compareCompound сравнивает составные номера по частям как целые числа любой длины;
+// номер, который является началом другого (3.12 и 3.12.1), идет раньше
+func compareCompound(a, b []string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		x, y := strings.TrimLeft(a[i], "0"), strings.TrimLeft(b[i], "0")
+		if c := compareOrdered(int64(len(x)), int64(len(y))); c != 0 {
+			return c
+		}
+		if c := strings.Compare(x, y); c != 0 {
+			return c
+		}
+	}
+	return compareOrdered(int64(len(a)), int64(len(b)))
+}
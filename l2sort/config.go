@@ -0,0 +1,175 @@
+package l2sort
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Источники флагов по умолчанию
+const (
+	configFileName = ".l2sortrc"
+	optsEnvVar     = "L2SORT_OPTS"
+)
+
+// flagSetting - одно вхождение флага в источнике по умолчанию
+type flagSetting struct {
+	name, value string
+}
+
+// settingRecorder записывает вхождения флага, не применяя их: значения проверяются
+// позже, когда известно, какие флаги заданы в командной строке
+type settingRecorder struct {
+	name     string
+	isBool   bool
+	settings *[]flagSetting
+}
+
+func (r settingRecorder) String() string { return "" }
+
+func (r settingRecorder) Set(value string) error {
+	*r.settings = append(*r.settings, flagSetting{r.name, value})
+	return nil
+}
+
+func (r settingRecorder) IsBoolFlag() bool { return r.isBool }
+
+// defaultSource - флаги из файла настроек или переменной окружения
+type defaultSource struct {
+	name     string
+	settings []flagSetting
+}
+
+// applyDefaults дополняет уже разобранную командную строку флагами из L2SORT_OPTS и
+// файла настроек (--config, по умолчанию ~/.l2sortrc, если он есть). Командная строка
+// важнее переменной окружения, а та - файла: флаг, заданный в более важном источнике,
+// из менее важного не берется вовсе, в том числе повторяемые флаги вроде -k
+func applyDefaults(fset *flag.FlagSet, configPath string) error {
+	var sources []defaultSource
+	if env := os.Getenv(optsEnvVar); env != "" {
+		src, err := parseDefaults(fset, "переменной "+optsEnvVar, env)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, src)
+	}
+	text, path, err := readConfigFile(configPath)
+	if err != nil {
+		return err
+	}
+	if text != "" {
+		src, err := parseDefaults(fset, "файле настроек "+path, text)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, src)
+	}
+
+	explicit := make(map[string]bool)
+	fset.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	for _, src := range sources {
+		applied := make(map[string]bool)
+		for _, s := range src.settings {
+			if explicit[s.name] {
+				continue
+			}
+			if err := fset.Set(s.name, s.value); err != nil {
+				return fmt.Errorf("в %s: в параметре -%s: %w", src.name, s.name, err)
+			}
+			applied[s.name] = true
+		}
+		for name := range applied {
+			explicit[name] = true
+		}
+	}
+	return nil
+}
+
+// readConfigFile читает файл настроек. Без --config отсутствие ~/.l2sortrc не ошибка
+func readConfigFile(path string) (text, used string, err error) {
+	explicit := path != ""
+	if !explicit {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", nil
+		}
+		path = filepath.Join(home, configFileName)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return "", "", nil
+		}
+		return "", "", fmt.Errorf("в параметре --config: %w", err)
+	}
+	return string(data), path, nil
+}
+
+// parseDefaults разбирает флаги источника по правилам командной строки, запоминая их
+// вхождения. Строки, начинающиеся с #, - комментарии; значения с пробелами берутся в
+// кавычки, например --field-regex '\s*\|\s*'
+func parseDefaults(fset *flag.FlagSet, name, text string) (defaultSource, error) {
+	src := defaultSource{name: name}
+	args, err := splitOptions(text)
+	if err != nil {
+		return src, fmt.Errorf("в %s: %w", name, err)
+	}
+	rec := flag.NewFlagSet(name, flag.ContinueOnError)
+	rec.SetOutput(io.Discard)
+	fset.VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		rec.Var(settingRecorder{name: f.Name, isBool: ok && b.IsBoolFlag(), settings: &src.settings}, f.Name, f.Usage)
+	})
+	if err := rec.Parse(args); err != nil {
+		return src, fmt.Errorf("в %s: %w", name, err)
+	}
+	if rec.NArg() > 0 {
+		return src, fmt.Errorf("в %s: лишние аргументы %v", name, rec.Args())
+	}
+	return src, nil
+}
+
+// splitOptions делит текст на слова по пробелам и переводам строк. Одинарные и двойные
+// кавычки объединяют слово с пробелами и в результат не входят
+func splitOptions(text string) ([]string, error) {
+	var args []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		var word strings.Builder
+		inWord := false
+		var quote rune
+		for _, r := range line {
+			switch {
+			case quote != 0 && r == quote:
+				quote = 0
+			case quote != 0:
+				word.WriteRune(r)
+			case r == '\'' || r == '"':
+				quote, inWord = r, true
+			case r == ' ' || r == '\t' || r == '\r':
+				if inWord {
+					args = append(args, word.String())
+					word.Reset()
+					inWord = false
+				}
+			default:
+				word.WriteRune(r)
+				inWord = true
+			}
+		}
+		if quote != 0 {
+			return nil, fmt.Errorf("незакрытая кавычка в строке %q", line)
+		}
+		if inWord {
+			args = append(args, word.String())
+		}
+	}
+	return args, nil
+}
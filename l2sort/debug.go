@@ -0,0 +1,146 @@
+package l2sort
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// debugSource при --debug пропускает строки без изменений и для каждой выводит в out
+// разметку в духе GNU sort --debug: подчеркивание байт, взятых ключами, и правило,
+// по которому сравнивался каждый ключ
+type debugSource struct {
+	src    rowSource
+	sorter *Sorter
+	out    io.Writer
+}
+
+func (d *debugSource) next() (Row, bool, error) {
+	row, ok, err := d.src.next()
+	if ok && err == nil {
+		fmt.Fprint(d.out, d.sorter.annotateRow(&row))
+	}
+	return row, ok, err
+}
+
+// annotateRow возвращает строку, под ней линию с подчеркнутыми ключами и список правил.
+// Ключ, которого нет в строке дословно (например, склеенная из полей метка времени),
+// не подчеркивается, а отмечается в списке правил
+func (s *Sorter) annotateRow(row *Row) string {
+	line := row.Original
+	marked := make([]bool, len(line))
+	var rules []string
+	pos := 0
+	for i := range row.Keys {
+		key, text := &row.Keys[i], row.keyText(i)
+		rule := s.keyRule(s.kindOf(i), key)
+		if i < len(s.opts.Keys) && s.opts.Keys[i].Reverse {
+			rule += ", обратный порядок ключа"
+		}
+		// Ключи -k могут идти не в порядке колонок, поэтому ключ ищется сначала после
+		// предыдущего, а затем с начала строки
+		start := -1
+		if text != "" {
+			if start = strings.Index(line[pos:], text); start >= 0 {
+				start += pos
+			} else {
+				start = strings.Index(line, text)
+			}
+		}
+		if start < 0 && s.expr != nil {
+			rules = append(rules, fmt.Sprintf("ключ %d: %s, вычислен --expr: %q", i+1, rule, text))
+			continue
+		}
+		if start < 0 && s.transformsKey(i) {
+			rules = append(rules, fmt.Sprintf("ключ %d: %s, после преобразований: %q", i+1, rule, text))
+			continue
+		}
+		if start < 0 {
+			rules = append(rules, fmt.Sprintf("ключ %d: %s, не найден в строке", i+1, rule))
+			continue
+		}
+		for b := start; b < start+len(text); b++ {
+			marked[b] = true
+		}
+		pos = start + len(text)
+		rules = append(rules, fmt.Sprintf("ключ %d: %s", i+1, rule))
+	}
+	if len(row.Keys) == 0 {
+		rules = append(rules, "ключей нет, строка идет раньше остальных")
+	}
+	if s.opts.Reverse {
+		rules = append(rules, "обратный порядок")
+	}
+
+	var marks strings.Builder
+	for b, c := range line {
+		switch {
+		case c == '\t':
+			marks.WriteByte('\t')
+		case marked[b]:
+			marks.WriteByte('_')
+		default:
+			marks.WriteByte(' ')
+		}
+	}
+	return fmt.Sprintf("%s\n%s\n  %s\n", line, strings.TrimRight(marks.String(), " "), strings.Join(rules, "; "))
+}
+
+// keyRule называет правило, которое решает сравнение ключа: его тип, если ключ им
+// разобран, иначе текстовое сравнение
+func (s *Sorter) keyRule(kind keyKind, key *Key) string {
+	var name, unparsed string
+	switch kind.name {
+	case typeRandom:
+		return "случайный порядок (хэш ключа)"
+	case typeHash:
+		return "хэш ключа с солью --hash-salt"
+	case typeIP:
+		name, unparsed = "IP-адрес", "не IP-адрес"
+	case typeEmail:
+		name, unparsed = "email (домен, затем имя)", "не email"
+	case typeURL:
+		name, unparsed = "URL (хост, путь, запрос)", "не URL"
+	case typeMAC:
+		name, unparsed = "MAC-адрес", "не MAC-адрес"
+	case typeUUID:
+		name, unparsed = "UUID", "не UUID"
+	case typeCompound:
+		name, unparsed = "составной номер", "не составной номер"
+	case typeNumeric:
+		name, unparsed = "число", "не число"
+		if key.tag == valueOverflow {
+			name = "число (вне int64)"
+		}
+	case typeHuman:
+		name, unparsed = "число с суффиксом", "не число с суффиксом"
+	case typeMonth:
+		name, unparsed = "месяц", "не месяц"
+	case typeTime:
+		name, unparsed = "время", "не время"
+	case typeDuration:
+		name, unparsed = "длительность", "не длительность"
+	case typeMtime:
+		name, unparsed = "время изменения файла", "нет файла"
+	case typeSize:
+		name, unparsed = "размер файла", "нет файла"
+	}
+	if kind.custom != nil {
+		name, unparsed = kind.name, "не "+kind.name
+	}
+	parsed := name != "" && key.parsed()
+	if parsed {
+		return name
+	}
+	rule := "побайтово"
+	if s.alphabet != nil && !s.opts.Bytes {
+		rule = "по алфавиту --alphabet"
+	}
+	if kind.name == typeNatural {
+		rule = "естественный порядок"
+	}
+	if unparsed != "" {
+		rule += " (" + unparsed + ")"
+	}
+	return rule
+}
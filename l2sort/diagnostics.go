@@ -0,0 +1,173 @@
+package l2sort
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// Форматы диагностики --log-format
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// Коды завершения:
+//
+//	0   - успех;
+//	1   - проверка не пройдена: вход не отсортирован при -c и check, повторяющиеся
+//	      ключи при --check-unique, несовпадение сумм --verify, нарушения компаратора
+//	      в selftest;
+//	2   - неверные флаги, аргументы или их сочетание;
+//	3   - ошибка чтения или записи файлов;
+//	4   - недопустимые данные: нечисловой ключ, нет колонки, некорректный UTF-8 при
+//	      --strict и прочие ошибки в содержимом входа;
+//	130 - прерывание SIGINT или SIGTERM, как у оболочки.
+//
+// Подкоманды look и diff, как cmp и diff, сообщают об ошибке кодом 2 независимо от ее вида
+const (
+	exitCheck       = 1
+	exitUsage       = 2
+	exitIO          = 3
+	exitData        = 4
+	exitInterrupted = 130
+)
+
+// Виды ошибок в поле kind диагностики --log-format json
+const (
+	kindUsage       = "usage"
+	kindIO          = "io"
+	kindData        = "data"
+	kindCheck       = "check"
+	kindInterrupted = "interrupted"
+)
+
+var kindExitCodes = map[string]int{
+	kindUsage:       exitUsage,
+	kindIO:          exitIO,
+	kindData:        exitData,
+	kindCheck:       exitCheck,
+	kindInterrupted: exitInterrupted,
+}
+
+// logFormat - формат ошибок и предупреждений: text - текстом, как раньше (ошибки в
+// стандартный вывод, предупреждения в stderr), json - по объекту JSON на строку в stderr
+var logFormat = logFormatText
+
+// diagnostic - одна запись диагностики в формате --log-format json
+//
+//	{"level":"error","kind":"io","exit":3,"message":"при чтении файла: open a.txt: no such file or directory"}
+//	{"level":"warning","message":"строка 7 длиной 2000000 байт пропущена"}
+type diagnostic struct {
+	Level   string `json:"level"`
+	Kind    string `json:"kind,omitempty"`
+	Exit    int    `json:"exit,omitempty"`
+	File    string `json:"file,omitempty"`
+	Message string `json:"message"`
+}
+
+// diagnosticsMu не дает перемешаться записям от параллельных заданий --batch
+var diagnosticsMu sync.Mutex
+
+func emitDiagnostic(d diagnostic) {
+	diagnosticsMu.Lock()
+	defer diagnosticsMu.Unlock()
+	json.NewEncoder(os.Stderr).Encode(d)
+}
+
+// checkLogFormat проверяет значение --log-format
+func checkLogFormat(format string) error {
+	switch format {
+	case logFormatText, logFormatJSON:
+		return nil
+	}
+	return fmt.Errorf("в параметре --log-format: неизвестный формат %q (text, json)", format)
+}
+
+// warnf печатает предупреждение: в stderr текстом или записью JSON
+func warnf(format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	if logFormat == logFormatJSON {
+		emitDiagnostic(diagnostic{Level: "warning", Message: message})
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Предупреждение: %s\n", message)
+}
+
+// errNotSorted - вход не отсортирован при -c
+var errNotSorted = errors.New("данные не отсортированы")
+
+// errorKind определяет вид ошибки по цепочке обернутых ошибок. Ошибки настроек
+// сообщаются через reportUsage, поэтому здесь различаются только прерывание, непройденная
+// проверка -c, ввод-вывод и ошибки данных
+func errorKind(err error) string {
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	var sysErr *os.SyscallError
+	var netErr *net.OpError
+	switch {
+	case errors.Is(err, context.Canceled):
+		return kindInterrupted
+	case errors.Is(err, errNotSorted):
+		return kindCheck
+	case errors.As(err, &pathErr), errors.As(err, &linkErr), errors.As(err, &sysErr), errors.As(err, &netErr):
+		return kindIO
+	}
+	return kindData
+}
+
+// report печатает ошибку вида kind и возвращает соответствующий код завершения
+func report(kind string, err error) int {
+	code := kindExitCodes[kind]
+	if logFormat == logFormatJSON {
+		message := err.Error()
+		if kind == kindInterrupted {
+			message = "прервано: исходный файл не изменен, временные файлы удалены"
+		}
+		emitDiagnostic(diagnostic{Level: "error", Kind: kind, Exit: code, Message: message})
+		return code
+	}
+	if kind == kindInterrupted {
+		fmt.Fprintln(os.Stderr, "Прервано: исходный файл не изменен, временные файлы удалены.")
+		return code
+	}
+	fmt.Fprintf(os.Stderr, "Ошибка %v\n", err)
+	return code
+}
+
+// reportError печатает ошибку и возвращает код завершения по ее виду. Прерывание сигналом
+// сообщается отдельно: результат при этом не записан и исходный файл не изменен. Закрытый
+// получатель вывода ошибкой не считается
+func reportError(err error) int {
+	if errors.Is(err, syscall.EPIPE) {
+		// Получатель вывода закрыл канал, прочитав сколько ему нужно (например, head)
+		return 0
+	}
+	return report(errorKind(err), err)
+}
+
+// reportUsage печатает ошибку в флагах или аргументах и возвращает exitUsage
+func reportUsage(err error) int {
+	return report(kindUsage, err)
+}
+
+// reportCheck сообщает о непройденной проверке и возвращает exitCheck
+func reportCheck(err error) int {
+	return report(kindCheck, err)
+}
+
+// reportJobError дублирует ошибку задания --batch записью JSON с именем файла; в
+// текстовом формате ошибка уже есть в сводке
+func reportJobError(file string, err error) {
+	if logFormat != logFormatJSON {
+		return
+	}
+	kind := errorKind(err)
+	emitDiagnostic(diagnostic{Level: "error", Kind: kind, Exit: kindExitCodes[kind], File: file, Message: err.Error()})
+}
@@ -0,0 +1,144 @@
+package l2sort
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// runDiff выполняет подкоманду
+//
+//	l2sort diff [--flags '-k 1'] [-1] [-2] [-3] [-o вывод] [--presorted] ФАЙЛ1 ФАЙЛ2
+//
+// Оба входа упорядочиваются по ключам из --flags поверх общих и сравниваются слиянием,
+// как утилитой comm, но по ключам, а не по строкам целиком: в первой колонке выводятся
+// строки, ключи которых есть только в первом файле, во второй (после табуляции) - только
+// во втором, в третьей (после двух табуляций) - общие, строкой из первого файла. Строки
+// с равными ключами сопоставляются попарно, лишние считаются непарными. Возвращает код
+// завершения: 0, если различий нет, 1, если есть, 2 при ошибке
+func runDiff(ctx context.Context, args []string, base Options) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	flags := fs.String("flags", "", "Флаги сортировки, задающие ключ сравнения, например '-k 2 -n'")
+	output := fs.String("o", "-", "Файл результата (\"-\" - стандартный вывод)")
+	presorted := fs.Bool("presorted", false, "Входы уже отсортированы: только проверить порядок, не сортируя")
+	var hide [3]bool
+	fs.BoolVar(&hide[0], "1", false, "Не выводить строки, которые есть только в первом файле")
+	fs.BoolVar(&hide[1], "2", false, "Не выводить строки, которые есть только во втором файле")
+	fs.BoolVar(&hide[2], "3", false, "Не выводить общие строки")
+	usage := func() {
+		fmt.Println("Использование: l2sort diff [опции] файл1 файл2")
+		fs.PrintDefaults()
+	}
+	fs.Usage = usage
+
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(files) != 2 {
+		usage()
+		return 2
+	}
+	sorter, err := sorterWithFlags(base, *flags)
+	if err != nil {
+		return reportUsage(err)
+	}
+	differ, err := sorter.DiffFiles(ctx, files[0], files[1], *output, hide, *presorted)
+	if err != nil {
+		if reportError(err) == exitInterrupted {
+			return exitInterrupted
+		}
+		return 2
+	}
+	if differ {
+		return 1
+	}
+	return 0
+}
+
+// DiffFiles сравнивает left и right по ключам и записывает в output три колонки в духе
+// comm; hide скрывает колонки. Возвращает, нашлись ли строки только в одном из файлов
+func (s *Sorter) DiffFiles(ctx context.Context, left, right, output string, hide [3]bool, presorted bool) (bool, error) {
+	sources, in, err := s.sortedInputs(ctx, []string{left, right}, presorted)
+	defer closeInputs(in)
+	if err != nil {
+		return false, err
+	}
+	d := &diffSource{
+		sorter: s,
+		left:   peekSource{src: sources[0]},
+		right:  peekSource{src: sources[1]},
+		hide:   hide,
+	}
+	var src rowSource = &ctxSource{ctx: ctx, src: d}
+	err = s.writeOutputs(output, nil, func(w io.Writer) error {
+		return writeRows(in[0].enc.encoder(w), src, s.format, in[0].eol)
+	})
+	return d.differ, err
+}
+
+// diffSource сливает два упорядоченных потока и выдает строки колонок сравнения
+type diffSource struct {
+	sorter *Sorter
+	left   peekSource
+	right  peekSource
+	hide   [3]bool
+	// differ - встретилась ли строка только в одном из файлов
+	differ bool
+}
+
+func (d *diffSource) next() (Row, bool, error) {
+	for {
+		l, lok, err := d.left.peek()
+		if err != nil {
+			return Row{}, false, err
+		}
+		r, rok, err := d.right.peek()
+		if err != nil {
+			return Row{}, false, err
+		}
+		if !lok && !rok {
+			return Row{}, false, nil
+		}
+		var c int
+		switch {
+		case !rok:
+			c = -1
+		case !lok:
+			c = 1
+		default:
+			c = d.sorter.compareOrder(l, r)
+		}
+
+		column, row := 2, *l
+		switch {
+		case c < 0:
+			column = 0
+		case c > 0:
+			column, row = 1, *r
+		}
+		if c <= 0 {
+			d.left.loaded = false
+		}
+		if c >= 0 {
+			d.right.loaded = false
+		}
+		if column != 2 {
+			d.differ = true
+		}
+		if d.hide[column] {
+			continue
+		}
+		// Колонка сдвигается табуляциями за счет скрытых колонок перед ней, как у comm
+		indent := 0
+		for i := range column {
+			if !d.hide[i] {
+				indent++
+			}
+		}
+		row.Original = strings.Repeat("\t", indent) + row.Original
+		return row, true, nil
+	}
+}
@@ -0,0 +1,36 @@
+package l2sort
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestDiff проверяет колонки сравнения по ключам, их скрытие и код завершения
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		left, right string
+		code        int
+		want        []string
+	}{
+		{"три колонки", nil, "c\na\nb\n", "d\nb\n", 1, []string{"a", "\t\tb", "c", "\td"}},
+		{"без различий", nil, "b\na\n", "a\nb\n", 0, []string{"\t\ta", "\t\tb"}},
+		{"скрыта общая колонка", []string{"-3"}, "a\nb\n", "b\nc\n", 1, []string{"a", "\tc"}},
+		{"только общие", []string{"-1", "-2"}, "a\nb\n", "b\nc\n", 1, []string{"b"}},
+		{"по ключу", []string{"--flags", "-k 1 -n"}, "2 x\n10 y\n", "10 z\n3 w\n", 1, []string{"2 x", "\t3 w", "\t\t10 y"}},
+		{"повторы ключей", []string{"--flags", "-k 1"}, "a 1\na 2\n", "a 3\n", 1, []string{"\t\ta 1", "a 2"}},
+		{"presorted с неупорядоченным входом", []string{"--presorted"}, "b\na\n", "a\n", 2, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, got := runCommand(t, runDiff, tt.args, tt.left, tt.right)
+			if code != tt.code {
+				t.Fatalf("код %d, ожидался %d", code, tt.code)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("получено %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package l2sort
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// dryRun оценивает, что сделала бы сортировка inputs, ничего не записывая: сколько
+// строк сменят позицию и сколько повторов отбросит -u. Для подсчета перемещений все
+// строки держатся в памяти, ограничение -S не применяется
+func (s *Sorter) dryRun(ctx context.Context, inputs []string) (Result, error) {
+	in, err := s.readRows(ctx, inputs, newMemBudget(0))
+	if err != nil {
+		return Result{}, fmt.Errorf("при чтении файла: %w", err)
+	}
+	defer in.close()
+	result := Result{Lines: in.lines}
+
+	// Итоговый порядок считается по номерам строк, сами строки остаются на местах
+	order := make([]int, len(in.rows))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return s.sortOrder(&in.rows[order[i]], &in.rows[order[j]]) < 0
+	})
+	for i, from := range order {
+		if i != from {
+			result.Moved++
+		}
+	}
+
+	// Повторы -u ищутся так же, как в uniqueSource: внутри группы равных ключей
+	if s.opts.Unique {
+		var seen map[string]bool
+		var first *Row
+		for _, i := range order {
+			row := &in.rows[i]
+			if first == nil || s.CompareRows(row, first) != 0 {
+				first, seen = row, make(map[string]bool)
+			}
+			line := s.dedupLine(row)
+			if seen[line] {
+				result.Duplicates++
+			}
+			seen[line] = true
+		}
+	}
+	result.AlreadySorted = result.Moved == 0 && result.Duplicates == 0
+	return result, nil
+}
+
+// printDryRun печатает итог --dry-run
+func printDryRun(result Result, unique bool) {
+	fmt.Printf("Строк: %d, сменят позицию: %d\n", result.Lines, result.Moved)
+	if unique {
+		fmt.Printf("Повторов, которые удалит -u: %d\n", result.Duplicates)
+	}
+	if result.AlreadySorted {
+		fmt.Println("Данные уже отсортированы.")
+	}
+	fmt.Println("Файлы не изменены (--dry-run).")
+}
@@ -0,0 +1,57 @@
+package l2sort
+
+import "fmt"
+
+// Какое вхождение повторяющейся строки оставлять при -u
+const (
+	uniqueKeepFirst = "first"
+	uniqueKeepLast  = "last"
+)
+
+// dupReport считает строки, отброшенные -u, в порядке их появления в результате
+type dupReport struct {
+	counts map[string]int
+	order  []string
+}
+
+func newDupReport() *dupReport {
+	return &dupReport{counts: make(map[string]int)}
+}
+
+// add учитывает отброшенную копию строки; у nil-отчета ничего не делает
+func (d *dupReport) add(line string) {
+	if d == nil {
+		return
+	}
+	if d.counts[line] == 0 {
+		d.order = append(d.order, line)
+	}
+	d.counts[line]++
+}
+
+// writeDupsReport записывает отчет --dups-output в формате uniq -c: число отброшенных
+// копий и сама строка, в кодировке и с окончаниями строк входа
+func (s *Sorter) writeDupsReport(d *dupReport, in *inputData) (err error) {
+	file, err := openOutput(s.opts.DupsOutput, false, s.opts.CompressOutput, nil)
+	if err != nil {
+		return fmt.Errorf("при создании файла повторов: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			discardOutput(file)
+		}
+	}()
+	rows := make([]Row, len(d.order))
+	for i, line := range d.order {
+		rows[i] = Row{Original: fmt.Sprintf("%7d %s", d.counts[line], line)}
+	}
+	eol := in.eol
+	eol.final = true
+	if err := writeRows(in.enc.encoder(file), &sliceSource{rows: rows}, nil, eol); err != nil {
+		return fmt.Errorf("при записи файла повторов: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("при сохранении файла повторов: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package l2sort
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// textEncoding описывает кодировку входа: как декодировать его в UTF-8 для сравнения
+// и как закодировать результат обратно при выводе
+type textEncoding struct {
+	name  string
+	bom   []byte            // метка порядка байт, которая воспроизводится в начале вывода
+	codec encoding.Encoding // nil для UTF-8
+}
+
+// Метки порядка байт
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// Метка UTF-16 обрабатывается в detectEncoding, поэтому кодеки ее не ищут и не пишут
+var (
+	utf16LE = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	utf16BE = unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+)
+
+// lookupEncoding находит кодировку по имени из --encoding
+func lookupEncoding(name string) (*textEncoding, error) {
+	switch strings.ToLower(strings.ReplaceAll(name, "_", "-")) {
+	case "", "utf-8", "utf8", "auto":
+		return &textEncoding{name: "utf-8"}, nil
+	case "windows-1251", "cp1251":
+		return &textEncoding{name: "windows-1251", codec: charmap.Windows1251}, nil
+	case "koi8-r", "koi8r":
+		return &textEncoding{name: "koi8-r", codec: charmap.KOI8R}, nil
+	case "utf-16le":
+		return &textEncoding{name: "utf-16le", codec: utf16LE}, nil
+	case "utf-16be", "utf-16":
+		return &textEncoding{name: "utf-16be", codec: utf16BE}, nil
+	}
+	return nil, fmt.Errorf("неизвестная кодировка %q (поддерживаются utf-8, windows-1251, koi8-r, utf-16le, utf-16be)", name)
+}
+
+// detectEncoding проверяет метку порядка байт в начале входа. Метка имеет приоритет
+// над --encoding, отбрасывается при чтении и воспроизводится при выводе
+func detectEncoding(r io.Reader, configured *textEncoding) (io.Reader, *textEncoding) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(3)
+	enc := *configured
+	switch {
+	case bytes.HasPrefix(head, utf8BOM):
+		br.Discard(len(utf8BOM))
+		enc = textEncoding{name: "utf-8", bom: utf8BOM}
+	case bytes.HasPrefix(head, utf16LEBOM):
+		br.Discard(len(utf16LEBOM))
+		enc = textEncoding{name: "utf-16le", bom: utf16LEBOM, codec: utf16LE}
+	case bytes.HasPrefix(head, utf16BEBOM):
+		br.Discard(len(utf16BEBOM))
+		enc = textEncoding{name: "utf-16be", bom: utf16BEBOM, codec: utf16BE}
+	}
+	return enc.decoder(br), &enc
+}
+
+// transcoded сообщает, что вход перекодируется и смещения в нем не совпадают с UTF-8
+func (e *textEncoding) transcoded() bool {
+	return e.codec != nil
+}
+
+// decoder возвращает поток, выдающий содержимое r в UTF-8
+func (e *textEncoding) decoder(r io.Reader) io.Reader {
+	if e.codec == nil {
+		return r
+	}
+	return transform.NewReader(r, e.codec.NewDecoder())
+}
+
+// encoder возвращает поток, кодирующий записываемый UTF-8 в эту кодировку. Непредставимые
+// символы заменяются символом замены кодировки. Руна, разрезанная между двумя вызовами
+// Write, дожидается своего окончания
+func (e *textEncoding) encoder(w io.Writer) io.Writer {
+	if len(e.bom) > 0 {
+		w = &bomWriter{dst: w, bom: e.bom}
+	}
+	if e.codec == nil {
+		return w
+	}
+	return transform.NewWriter(w, encoding.ReplaceUnsupported(e.codec.NewEncoder()))
+}
+
+// bomWriter записывает метку порядка байт перед первыми данными
+type bomWriter struct {
+	dst io.Writer
+	bom []byte
+}
+
+func (w *bomWriter) Write(p []byte) (int, error) {
+	if w.bom != nil {
+		if _, err := w.dst.Write(w.bom); err != nil {
+			return 0, err
+		}
+		w.bom = nil
+	}
+	return w.dst.Write(p)
+}
+
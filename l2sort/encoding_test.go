@@ -0,0 +1,45 @@
+package l2sort
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestEncoding сортирует входы в разных кодировках: ключи сравниваются после
+// декодирования в UTF-8, а вывод кодируется обратно вместе с меткой BOM
+func TestEncoding(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		input []byte
+		want  []byte
+	}{
+		{"windows-1251", []string{"--encoding", "windows-1251"}, []byte("\xff\n\xe1\n\xe0\n"), []byte("\xe0\n\xe1\n\xff\n")},
+		{"koi8-r", []string{"--encoding", "koi8-r"}, []byte("\xc0\n\xc1\n"), []byte("\xc1\n\xc0\n")},
+		{"utf-16le с BOM", nil, []byte("\xff\xfeb\x00\n\x00a\x00\n\x00"), []byte("\xff\xfea\x00\n\x00b\x00\n\x00")},
+		{"utf-16be", []string{"--encoding", "utf-16be"}, []byte("\xd8\x3d\xde\x00\x00\n\x00a\x00\n"), []byte("\x00a\x00\n\xd8\x3d\xde\x00\x00\n")},
+		{"utf-8 с BOM", nil, []byte("\xef\xbb\xbfb\na\n"), []byte("\xef\xbb\xbfa\nb\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sorter := newTestSorter(t, tt.args)
+			dir := t.TempDir()
+			input, output := filepath.Join(dir, "in.txt"), filepath.Join(dir, "out.txt")
+			if err := os.WriteFile(input, tt.input, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := sorter.SortFile(input, output); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("получено %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
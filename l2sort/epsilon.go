@@ -0,0 +1,154 @@
+package l2sort
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+// resolveEpsilon задает допуск сравнения числовых ключей: свой у ключа (-k 2n~1e-9) или
+// общий --epsilon для всех ключей -n и -h без своего допуска. Допуск у ключа другого
+// типа - ошибка
+func (s *Sorter) resolveEpsilon() error {
+	o := s.opts
+	if o.Epsilon < 0 || math.IsNaN(o.Epsilon) || math.IsInf(o.Epsilon, 0) {
+		return fmt.Errorf("в параметре --epsilon: допуск должен быть неотрицательным числом")
+	}
+	if !o.usesEpsilon() {
+		return nil
+	}
+	if o.Compat != "" {
+		return fmt.Errorf("в параметрах: --compat gnu сравнивает числа точно и несовместим с допуском --epsilon и ~ в -k")
+	}
+	numeric := false
+	if epsilonKind(s.defaultKind.name) {
+		s.defaultKind.epsilon = o.Epsilon
+		numeric = true
+	}
+	for i, spec := range o.Keys {
+		if !epsilonKind(s.keyKinds[i].name) {
+			if spec.Epsilon > 0 {
+				return fmt.Errorf("в параметре -k: в ключе %s: допуск ~ действует только для числовых типов numeric и human", spec)
+			}
+			continue
+		}
+		s.keyKinds[i].epsilon = cmp.Or(spec.Epsilon, o.Epsilon)
+		numeric = true
+	}
+	if !numeric && !o.InferTypes {
+		return fmt.Errorf("в параметре --epsilon: нет числовых ключей; допуск действует для -n, -h и ключей -k 2n, -k 2h")
+	}
+	return nil
+}
+
+// usesEpsilon сообщает, что задан общий или свой для ключа допуск сравнения чисел
+func (o Options) usesEpsilon() bool {
+	if o.Epsilon > 0 {
+		return true
+	}
+	for _, spec := range o.Keys {
+		if spec.Epsilon > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// epsilonKind сообщает, что ключи типа name сравниваются с допуском
+func epsilonKind(name string) bool {
+	return name == typeNumeric || name == typeHuman
+}
+
+// sameGroup сообщает, что строка b попадает в группу строки a при -u и --group-by: ключи
+// равны по CompareRows или числовые ключи отличаются не больше чем на допуск. Допуск
+// в само сравнение не входит - равенство с допуском нетранзитивно, и сортировка с ним
+// не задавала бы порядка; группы же сравниваются с первой строкой, поэтому значения
+// a < b < c, где a и c отличаются больше чем на допуск, в одну группу не попадут
+func (s *Sorter) sameGroup(a, b *Row) bool {
+	if s.CompareRows(a, b) == 0 {
+		return true
+	}
+	if !s.opts.usesEpsilon() {
+		return false
+	}
+	if s.order == nil {
+		if s.opts.Length && s.rowLength(a) != s.rowLength(b) || len(a.Keys) != len(b.Keys) {
+			return false
+		}
+		for k := range a.Keys {
+			if !s.nearKeys(k, a, b) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, rule := range s.order {
+		switch rule.name {
+		case orderLength:
+			if utf8.RuneCountInString(a.Original) != utf8.RuneCountInString(b.Original) {
+				return false
+			}
+		case orderLine:
+			if s.compareText(a.Original, b.Original) != 0 {
+				return false
+			}
+		default:
+			k := rule.key
+			if k >= len(a.Keys) || k >= len(b.Keys) {
+				if len(a.Keys) != len(b.Keys) {
+					return false
+				}
+				continue
+			}
+			if !s.nearKeys(k, a, b) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// nearKeys сообщает, что k-е ключи строк равны точно или с допуском своего типа
+func (s *Sorter) nearKeys(k int, a, b *Row) bool {
+	kind := s.kindOf(k)
+	if a.keyText(k) == b.keyText(k) || kind.epsilon > 0 && nearEqual(kind, &a.Keys[k], &b.Keys[k]) {
+		return true
+	}
+	return s.compareKeys(k, a, b) == 0
+}
+
+// nearEqual сообщает, что разобранные числовые ключи отличаются не больше чем на допуск ключа
+func nearEqual(kind keyKind, a, b *Key) bool {
+	switch kind.name {
+	case typeNumeric:
+		if a.parsed() && b.parsed() {
+			return math.Abs(numericValue(a)-numericValue(b)) <= kind.epsilon
+		}
+	case typeHuman:
+		x, xok := a.floatValue()
+		y, yok := b.floatValue()
+		return xok && yok && math.Abs(x-y) <= kind.epsilon
+	}
+	return false
+}
+
+// numericValue возвращает значение разобранного -n ключа как float64
+func numericValue(key *Key) float64 {
+	if n, ok := key.intValue(); ok {
+		return float64(n)
+	}
+	f, _ := key.floatValue()
+	return f
+}
+
+// dedupLine возвращает то, по чему -u различает строки внутри группы равных ключей:
+// саму строку (после --normalize) или пустую строку, если повторами считаются все строки
+// с равными ключами - при --compat gnu, --ignore-leading-zeros и при допуске сравнения
+// чисел, когда равные ключи обычно записаны по-разному
+func (s *Sorter) dedupLine(row *Row) string {
+	if s.opts.Compat == compatGNU || s.opts.usesEpsilon() || s.opts.IgnoreLeadingZeros {
+		return ""
+	}
+	return s.normalizeKey(row.Original)
+}
@@ -0,0 +1,97 @@
+package l2sort
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// exprRow - данные, доступные в шаблоне --expr
+type exprRow struct {
+	Line string   // исходная строка (при --record-sep - строка записи с ключом)
+	F    []string // поля строки, разделенные пробелами, --field-regex или --fixed-cols
+}
+
+// Field возвращает поле с номером n (с нуля) или "", если такого поля нет
+func (r exprRow) Field(n int) string {
+	if n < 0 || n >= len(r.F) {
+		return ""
+	}
+	return r.F[n]
+}
+
+// exprFuncs - функции шаблона --expr в дополнение к встроенным (index, slice, len, printf...).
+// Арифметические функции принимают числа в виде строк и возвращают результат строкой,
+// чтобы его можно было сравнить с -n
+var exprFuncs = template.FuncMap{
+	"first": func(n int, s string) string {
+		runes := []rune(s)
+		return string(runes[:min(max(n, 0), len(runes))])
+	},
+	"last": func(n int, s string) string {
+		runes := []rune(s)
+		return string(runes[len(runes)-min(max(n, 0), len(runes)):])
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+	"add":   exprArith(func(a, b float64) float64 { return a + b }),
+	"sub":   exprArith(func(a, b float64) float64 { return a - b }),
+	"mul":   exprArith(func(a, b float64) float64 { return a * b }),
+	"div":   exprArith(func(a, b float64) float64 { return a / b }),
+}
+
+func exprArith(op func(a, b float64) float64) func(a, b string) (string, error) {
+	return func(a, b string) (string, error) {
+		x, err := strconv.ParseFloat(strings.TrimSpace(a), 64)
+		if err != nil {
+			return "", fmt.Errorf("не число: %q", a)
+		}
+		y, err := strconv.ParseFloat(strings.TrimSpace(b), 64)
+		if err != nil {
+			return "", fmt.Errorf("не число: %q", b)
+		}
+		return strconv.FormatFloat(op(x, y), 'g', -1, 64), nil
+	}
+}
+
+// compileExpr разбирает шаблон --expr, вычисляющий ключ строки, например
+//
+//	{{last 2 .Line}}
+//	{{div (.Field 1) (.Field 2)}}
+//
+// и выполняет его один раз на пустой строке: ошибки самого шаблона, например
+// несуществующее поле, иначе обнаружились бы только при сортировке и оставили бы
+// все строки без ключа
+func compileExpr(text string) (*template.Template, error) {
+	t, err := template.New("expr").Funcs(exprFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Execute(io.Discard, exprRow{}); err != nil && !exprDataError(err) {
+		return nil, err
+	}
+	return t, nil
+}
+
+// exprDataError сообщает, что шаблон не выполнился из-за данных строки: функция
+// вернула ошибку (поле не число, индекс за концом строки). Остальные ошибки
+// выполнения - ошибки шаблона, они от строки не зависят
+func exprDataError(err error) bool {
+	var execErr template.ExecError
+	return errors.As(err, &execErr) && errors.Unwrap(execErr.Err) != nil
+}
+
+// exprKeys вычисляет по --expr единственный ключ строки. Если шаблон не удалось выполнить
+// (например, поле не число), у строки нет ключа, и она идет раньше остальных, как
+// строка без колонки -k
+func (s *Sorter) exprKeys(line string) []string {
+	var buf strings.Builder
+	if err := s.expr.Execute(&buf, exprRow{Line: line, F: s.splitFields(line)}); err != nil {
+		return nil
+	}
+	return []string{buf.String()}
+}
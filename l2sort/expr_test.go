@@ -0,0 +1,25 @@
+package l2sort
+
+import "testing"
+
+func TestCompileExpr(t *testing.T) {
+	tests := []struct {
+		expr string
+		ok   bool
+	}{
+		{"{{last 2 .Line}}", true},
+		{"{{div (.Field 1) (.Field 2)}}", true},
+		{"{{index .F 3}}", true},
+		{"{{slice .Line 0 1}}", true},
+		{"{{.Nope}}", false},
+		{"{{first .Line 2}}", false},
+		{"{{.Field}}", false},
+		{"{{last 2", false},
+	}
+	for _, tt := range tests {
+		_, err := compileExpr(tt.expr)
+		if (err == nil) != tt.ok {
+			t.Errorf("compileExpr(%q): ошибка %v, ожидалось ok=%v", tt.expr, err, tt.ok)
+		}
+	}
+}
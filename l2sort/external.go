@@ -0,0 +1,708 @@
+package l2sort
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// rowSource последовательно выдает строки: из памяти, из файла прогона или из слияния
+type rowSource interface {
+	next() (Row, bool, error)
+}
+
+// sliceSource выдает строки из среза в памяти
+type sliceSource struct {
+	rows []Row
+	pos  int
+}
+
+func (s *sliceSource) next() (Row, bool, error) {
+	if s.pos >= len(s.rows) {
+		return Row{}, false, nil
+	}
+	s.pos++
+	return s.rows[s.pos-1], true, nil
+}
+
+// sortRows сортирует порцию строк в памяти в итоговом порядке. Если sortOrder сравнивает
+// равные по ключам строки целиком, равны только одинаковые строки, и порядок задан
+// полностью - тогда хватает неустойчивой slices.SortFunc. При -s и -u (и --order)
+// сортировка устойчивая: строки с равными ключами сохраняют порядок входа и при -r
+func (s *Sorter) sortRows(rows []Row) {
+	if s.stats != nil {
+		defer s.stats.timeSort(time.Now())
+	}
+	if s.opts.Shuffle {
+		s.shuffleRows(rows)
+		return
+	}
+	if s.opts.Stable || s.opts.Unique {
+		sort.Stable(RowSlice{rows: rows, sorter: s})
+		return
+	}
+	slices.SortFunc(rows, func(a, b Row) int {
+		return s.sortOrder(&a, &b)
+	})
+}
+
+// spillRun сортирует порцию строк и записывает ее во временный файл прогона,
+// при необходимости сжимая его
+func (s *Sorter) spillRun(rows []Row) (string, error) {
+	s.sortRows(rows)
+	var file *os.File
+	var err error
+	if s.resume != nil {
+		file, err = s.resume.createRun()
+	} else {
+		file, err = temps.createSized(s.opts.TempDir, "run", s.runSize(rows), s.maxTemp)
+	}
+	if err != nil {
+		return "", err
+	}
+	s.stats.addTempFile()
+	defer file.Close()
+	if s.runRows != nil {
+		s.runRows[file.Name()] = len(rows)
+	}
+	if err := s.writeRun(file, &sliceSource{rows: rows}); err != nil {
+		return "", err
+	}
+	temps.finish(file)
+	return file.Name(), file.Close()
+}
+
+// writeRun записывает отсортированный поток строк в файл прогона, при необходимости
+// сжимая его
+func (s *Sorter) writeRun(file *os.File, src rowSource) error {
+	zw, err := s.newRunWriter(file)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(zw)
+	for {
+		row, ok, err := src.next()
+		if err != nil {
+			zw.Close()
+			return err
+		}
+		if !ok {
+			break
+		}
+		if s.multiline() {
+			w.WriteString(escapeRecord(row.Original))
+		} else {
+			w.WriteString(row.Original)
+		}
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// runSize оценивает размер прогона из rows на диске; для сжатого прогона размер
+// заранее неизвестен и оценка равна 0
+func (s *Sorter) runSize(rows []Row) int64 {
+	if s.opts.CompressTemp || s.opts.CompressProgram != "" {
+		return 0
+	}
+	var size int64
+	for _, row := range rows {
+		size += int64(len(row.Original)) + 1
+	}
+	return size
+}
+
+// runSource читает отсортированный прогон с диска, заново извлекая ключи. Прочитанный
+// до конца прогон с remove удаляется сразу, не дожидаясь конца сортировки
+type runSource struct {
+	sorter  *Sorter
+	path    string
+	remove  bool
+	file    *os.File
+	reader  io.ReadCloser
+	scanner *bufio.Scanner
+	arena   rowArena
+}
+
+// openRun открывает прогон с буфером чтения readAhead байт. Прогоны --resume нужны
+// для возобновления и после чтения не удаляются
+func (s *Sorter) openRun(path string, readAhead int) (*runSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := s.newRunReader(bufio.NewReaderSize(file, readAhead))
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	remove := s.resume == nil || filepath.Dir(path) != s.resume.dir
+	return &runSource{sorter: s, path: path, remove: remove, file: file, reader: reader, scanner: newLineScanner(reader, 0)}, nil
+}
+
+func (r *runSource) next() (Row, bool, error) {
+	if !r.scanner.Scan() {
+		err := r.scanner.Err()
+		if cerr := r.reader.Close(); err == nil {
+			err = cerr
+		}
+		r.file.Close()
+		if err == nil && r.remove {
+			temps.remove(r.path)
+		}
+		return Row{}, false, err
+	}
+	line := r.arena.lines.intern(r.scanner.Bytes())
+	if r.sorter.multiline() {
+		line = unescapeRecord(line)
+	}
+	return r.sorter.newRow(&r.arena, line, r.sorter.extractKeys(r.sorter.keyPart(line))), true, nil
+}
+
+// mergeItem - текущая строка одного из сливаемых источников
+type mergeItem struct {
+	row Row
+	src int
+}
+
+// mergeHeap упорядочивает головы источников; при равенстве побеждает источник с меньшим номером,
+// что сохраняет порядок прогонов
+type mergeHeap struct {
+	items  []mergeItem
+	sorter *Sorter
+}
+
+func (h *mergeHeap) Len() int { return len(h.items) }
+
+func (h *mergeHeap) Less(i, j int) bool {
+	if c := h.sorter.sortOrder(&h.items[i].row, &h.items[j].row); c != 0 {
+		return c < 0
+	}
+	return h.items[i].src < h.items[j].src
+}
+
+func (h *mergeHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *mergeHeap) Push(x any) { h.items = append(h.items, x.(mergeItem)) }
+
+func (h *mergeHeap) Pop() any {
+	item := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return item
+}
+
+// mergeSource выполняет k-путевое слияние отсортированных источников. Если задан tag,
+// выдаваемая строка помечается номером своего источника уже после сравнений
+type mergeSource struct {
+	sources []rowSource
+	heap    mergeHeap
+	started bool
+	// taken - строка на вершине кучи уже выдана; замена из ее источника читается при
+	// следующем вызове, чтобы выдача строки не ждала чтения этого источника
+	taken bool
+	tag   func(line string, src int) string
+}
+
+func (s *Sorter) newMergeSource(sources []rowSource) *mergeSource {
+	return &mergeSource{sources: sources, heap: mergeHeap{sorter: s}}
+}
+
+func (m *mergeSource) next() (Row, bool, error) {
+	if !m.started {
+		m.started = true
+		for i, src := range m.sources {
+			row, ok, err := src.next()
+			if err != nil {
+				return Row{}, false, err
+			}
+			if ok {
+				m.heap.items = append(m.heap.items, mergeItem{row: row, src: i})
+			}
+		}
+		heap.Init(&m.heap)
+	}
+	if m.taken {
+		m.taken = false
+		row, ok, err := m.sources[m.heap.items[0].src].next()
+		if err != nil {
+			return Row{}, false, err
+		}
+		if ok {
+			m.heap.items[0].row = row
+			heap.Fix(&m.heap, 0)
+		} else {
+			heap.Pop(&m.heap)
+		}
+	}
+	if len(m.heap.items) == 0 {
+		return Row{}, false, nil
+	}
+	top := m.heap.items[0]
+	m.taken = true
+	if m.tag != nil {
+		top.row.Original = m.tag(top.row.Original, top.src)
+	}
+	return top.row, true, nil
+}
+
+// uniqueSource пропускает повторяющиеся строки в отсортированном потоке. Одинаковые строки
+// имеют одинаковые ключи и потому идут внутри одной группы равных ключей, так что
+// в памяти держится только текущая группа. При keepLast из повторов остается последнее
+// вхождение в группе, иначе первое; отброшенные строки учитываются в dups, если он задан
+type uniqueSource struct {
+	src      peekSource
+	sorter   *Sorter
+	keepLast bool
+	dups     *dupReport
+	pending  []Row
+}
+
+func (u *uniqueSource) next() (Row, bool, error) {
+	for len(u.pending) == 0 {
+		group, err := u.src.group(u.sorter)
+		if err != nil || group == nil {
+			return Row{}, false, err
+		}
+		if u.keepLast {
+			reverse(group)
+		}
+		seen := make(map[string]bool, len(group))
+		for _, row := range group {
+			line := u.sorter.dedupLine(&row)
+			if seen[line] {
+				u.dups.add(row.Original)
+				u.sorter.stats.addDuplicate()
+				continue
+			}
+			seen[line] = true
+			u.pending = append(u.pending, row)
+		}
+		if u.keepLast {
+			reverse(u.pending)
+		}
+	}
+	row := u.pending[0]
+	u.pending = u.pending[1:]
+	return row, true, nil
+}
+
+// cancelCheckInterval - через сколько строк проверяется отмена контекста
+const cancelCheckInterval = 1024
+
+// ctxSource прекращает поток с ошибкой ctx, когда контекст отменен, чтобы прерванная
+// запись не подменила файл результата
+type ctxSource struct {
+	ctx context.Context
+	src rowSource
+	n   int
+}
+
+func (c *ctxSource) next() (Row, bool, error) {
+	c.n++
+	if c.n%cancelCheckInterval == 0 {
+		if err := c.ctx.Err(); err != nil {
+			return Row{}, false, err
+		}
+	}
+	return c.src.next()
+}
+
+// inputData - результат чтения входа: порция строк в памяти, прогоны на диске
+// и сведения о самом входе
+type inputData struct {
+	rows   []Row
+	runs   []string
+	sorted bool
+	// unsorted - номер первой строки, нарушившей порядок, если вход не отсортирован
+	unsorted int
+	lines    int
+	// kept - число строк, прошедших фильтры
+	kept int
+	// invalidUTF8 - число строк, пропущенных по --binary skip
+	invalidUTF8 int
+	// header - начальные строки, пропущенные через --skip
+	header []string
+	// dups - строки, отброшенные при чтении как повторы --unique-only
+	dups []string
+	eol  eolStyle
+	enc  *textEncoding
+	// mapped - отображение входа при --mmap, на которое ссылаются строки
+	mapped *mappedInput
+}
+
+// close освобождает отображение входа; после этого строки использовать нельзя
+func (in *inputData) close() {
+	in.mapped.close()
+	in.mapped = nil
+}
+
+// readRows читает строки файла потоком, отбрасывая не прошедшие фильтр. Как только учтенный
+// объем данных превышает -S, накопленная порция сортируется и сбрасывается на диск в прогон.
+// Попутно проверяется, отсортирован ли вход, подсчитываются строки и определяются окончания строк
+func (s *Sorter) readRows(ctx context.Context, inputs []string, budget *memBudget) (*inputData, error) {
+	var top *topRows
+	if s.boundedHead() {
+		top = &topRows{sorter: s, n: s.opts.Head}
+	}
+	var sample *reservoir
+	if s.opts.Sample > 0 {
+		sample = &reservoir{sorter: s, n: s.opts.Sample}
+	}
+	var unique *firstSeen
+	if s.opts.UniqueOnly {
+		unique = &firstSeen{sorter: s, keepLast: s.opts.UniqueKeep == uniqueKeepLast}
+	}
+	// Арена учитывает в budget свои блоки и данные строк вне них, в том числе строки
+	// отображения --mmap: страницы, на которые ссылаются строки, тоже занимают память
+	arena := newRowArena(budget)
+	// skip отбрасывает строку, не попавшую в порцию. Она занимает блоки арены наравне
+	// с сохраненными, но пока порция пуста, сбрасывать на диск нечего, и учет начинается заново
+	skip := func(in *inputData) error {
+		if len(in.rows) == 0 && budget.exceeded() {
+			arena.reset()
+			budget.reset()
+		}
+		return nil
+	}
+	in, err := s.scanRows(ctx, inputs, arena, func(in *inputData, row Row) error {
+		if sample != nil {
+			sample.offer(row)
+			return skip(in)
+		}
+		if unique != nil {
+			// Без сортировки сбрасывать на диск нечего: в памяти только различные строки
+			unique.offer(row)
+			return skip(in)
+		}
+		// Случайное решение принимается и для строк, покрытых --resume, чтобы при том же
+		// --seed выборка не зависела от прерывания
+		if !s.sampled() {
+			return skip(in)
+		}
+		if s.resume != nil && in.lines <= s.resume.lines {
+			return skip(in) // строка уже в готовом прогоне прошлого запуска
+		}
+		if top != nil {
+			top.offer(row)
+			return skip(in)
+		}
+		oldCap := cap(in.rows)
+		in.rows = append(in.rows, row)
+		budget.growSlice(oldCap, cap(in.rows), rowSize)
+
+		if budget.exceeded() {
+			run, err := s.spillRun(in.rows)
+			if err != nil {
+				return err
+			}
+			progressFrom(ctx).addRun()
+			if s.resume != nil {
+				if err := s.resume.addRun(run, in.lines, in.rows); err != nil {
+					return err
+				}
+			}
+			in.runs = append(in.runs, run)
+			in.rows = nil
+			arena.reset()
+			budget.reset()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if top != nil {
+		in.rows = top.rows
+	}
+	if sample != nil {
+		in.rows = sample.rows
+	}
+	if unique != nil {
+		in.rows, in.dups = unique.result(), unique.dups
+	}
+	if s.resume != nil {
+		// Прогоны прошлого запуска покрывают начало входа и идут первыми, чтобы слияние
+		// сохранило исходный порядок равных строк
+		in.runs = append(slices.Clone(s.resume.resumed), in.runs...)
+	}
+	return in, nil
+}
+
+// scanRows читает строки входных файлов одним потоком и передает в fn каждую строку данных с готовыми
+// ключами; in.lines в этот момент - номер текущей строки. Строки --skip и комментарии
+// --comments откладываются в in.header, не прошедшие фильтр отбрасываются. Попутно
+// проверяется, отсортирован ли вход, и определяются кодировка и окончания строк.
+// Строки и ключи размещаются в arena (nil - в своей арене без учета памяти).
+// Чтение прекращается с ошибкой ctx, если контекст отменен
+func (s *Sorter) scanRows(ctx context.Context, inputs []string, arena *rowArena, fn func(in *inputData, row Row) error) (*inputData, error) {
+	progress := progressFrom(ctx)
+	in := &inputData{sorted: true}
+	// При ошибке строки никуда не попадут, и отображение можно сразу освободить
+	done := false
+	defer func() {
+		if !done {
+			in.close()
+		}
+	}()
+	var eols eolCounter
+	if arena == nil {
+		arena = &rowArena{}
+	}
+	// Строки и ключи размещаются в общих блоках (или в отображении --mmap), а не по отдельности
+	var lines lineReader
+	if s.opts.Mmap && len(inputs) == 1 {
+		mapped, err := s.mapInput(inputs[0])
+		if err != nil {
+			return nil, err
+		}
+		if mapped != nil {
+			in.mapped, in.enc = mapped, mapped.enc
+			lines = &mappedScanner{data: mapped.text, split: eols.split, maxLine: s.maxLine, progress: progress}
+		}
+	}
+	if lines == nil {
+		reader := &inputChain{sorter: s, paths: inputs, progress: progress}
+		if err := reader.openNext(); err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		in.enc = reader.enc
+		scanner := newLineScanner(reader, s.maxLine)
+		scanner.Split(eols.split)
+		lines = &arenaScanner{Scanner: scanner, arena: &arena.lines}
+	}
+	var prev Row
+	havePrev := false
+	var scanner lineReader = lines
+	var records *recordScanner
+	if s.recordSep != nil {
+		records = &recordScanner{lines: lines, sorter: s}
+		scanner = records
+	}
+	if s.opts.InferTypes {
+		scanner = &inferReader{lines: scanner, sorter: s}
+	}
+	if s.stat != nil {
+		scanner = &statPrefetcher{lines: scanner, sorter: s}
+	}
+	style := func() eolStyle {
+		st := eols.style()
+		if records != nil {
+			st.records, st.recordSep = true, records.sepLine
+		}
+		st.multiline = s.multiline()
+		return st
+	}
+	styled := false
+	// comments - комментарии --comments=keep, ждущие следующей строки данных
+	var comments []string
+	for scanner.Scan() {
+		in.lines++
+		progress.addLine()
+		if in.lines%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		line := scanner.Text()
+		if in.lines <= s.opts.Skip {
+			in.header = append(in.header, line)
+			continue
+		}
+		if s.isComment(line) {
+			switch s.opts.Comments {
+			case commentsTop:
+				in.header = append(in.header, line)
+			case commentsKeep:
+				comments = append(comments, line)
+			}
+			continue
+		}
+		keyText, skip, err := s.checkLineLength(line, in.lines)
+		if err != nil {
+			return nil, err
+		}
+		if skip || (s.keep != nil && !s.keep(line)) {
+			continue
+		}
+		if skip, err := s.checkUTF8(line, in.lines); err != nil {
+			return nil, err
+		} else if skip {
+			in.invalidUTF8++
+			continue
+		}
+		in.kept++
+		texts := s.extractKeys(keyText)
+		if err := s.checkMissing(texts, in.lines, line); err != nil {
+			return nil, err
+		}
+		row := s.newRow(arena, line, texts)
+		if err := s.checkKeyValues(&row, in.lines); err != nil {
+			return nil, err
+		}
+		if skip, err := s.checkStat(&row, in.lines); err != nil {
+			return nil, err
+		} else if skip {
+			continue
+		}
+		if len(comments) > 0 {
+			row.Original = strings.Join(append(comments, row.Original), "\n")
+			comments = comments[:0]
+			if arena.lines.budget != nil {
+				// Строка с комментариями собрана вне арены
+				arena.lines.budget.add(int64(len(row.Original)))
+			}
+		}
+		if in.sorted && havePrev && s.sortOrder(&row, &prev) < 0 {
+			in.sorted, in.unsorted = false, in.lines
+		}
+		prev, havePrev = row, true
+		if !styled {
+			// Потоковому слиянию окончания строк нужны уже во время чтения
+			in.eol, styled = style(), true
+		}
+		if err := fn(in, row); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			err = fmt.Errorf("строка %d длиннее --max-line-size (%d байт)", in.lines+1, s.maxLine)
+		}
+		return nil, err
+	}
+	if in.invalidUTF8 > 0 {
+		warnf("пропущено строк с некорректным UTF-8: %d", in.invalidUTF8)
+	}
+	// Комментарии в конце входа не относятся ни к одной строке и выводятся в начале
+	in.header = append(in.header, comments...)
+	in.eol = style()
+	done = true
+	return in, nil
+}
+
+// mergeRuns сливает прогоны с диска и оставшуюся в памяти порцию в один отсортированный
+// поток. Прогонов больше, чем можно открыть сразу по плану planMerge, сначала сливаются
+// группами в промежуточные прогоны
+func (s *Sorter) mergeRuns(ctx context.Context, runs []string, rows []Row) (rowSource, error) {
+	plan := s.planMerge(len(runs))
+	if s.stats != nil {
+		s.stats.merge = &plan
+	}
+	runs, err := s.reduceRuns(ctx, runs, plan)
+	if err != nil {
+		return nil, err
+	}
+	s.sortRows(rows)
+	return s.openRuns(runs, rows, plan.readAhead)
+}
+
+// openRuns открывает прогоны и сливает их с отсортированной порцией rows (nil - без нее)
+func (s *Sorter) openRuns(runs []string, rows []Row, readAhead int) (rowSource, error) {
+	var sources []rowSource
+	var counts []int
+	for _, path := range runs {
+		run, err := s.openRun(path, readAhead)
+		if err != nil {
+			for _, src := range sources {
+				src.(*runSource).file.Close()
+			}
+			return nil, err
+		}
+		sources = append(sources, run)
+		counts = append(counts, s.runRows[path])
+	}
+	if rows != nil {
+		sources = append(sources, &sliceSource{rows: rows})
+		counts = append(counts, len(rows))
+	}
+	if s.opts.Shuffle {
+		return newShuffleMerge(s.rng, sources, counts), nil
+	}
+	return s.newMergeSource(sources), nil
+}
+
+// writeRows записывает поток строк в w, формируя текст через format, если он задан.
+// Строки завершаются окончанием eol.sep; после последней строки оно ставится,
+// только если и во входе последняя строка была завершена
+func writeRows(w io.Writer, src rowSource, format rowFormatter, eol eolStyle) error {
+	return writeRowsIndexed(w, src, format, eol, nil)
+}
+
+// writeRowsIndexed работает как writeRows и, если idx задан, сообщает ему смещение
+// каждой строки от начала записанного в w
+func writeRowsIndexed(w io.Writer, src rowSource, format rowFormatter, eol eolStyle, idx *indexWriter) error {
+	bw := bufio.NewWriter(w)
+	var pos int64
+	// Ошибка записи (например, получатель закрыл канал) прекращает чтение строк сразу
+	var werr error
+	write := func(text string) {
+		if _, err := bw.WriteString(text); err != nil && werr == nil {
+			werr = err
+		}
+		pos += int64(len(text))
+	}
+	first := true
+	// sepPending - окончание последней выведенной строки еще не записано
+	sepPending := false
+	for werr == nil {
+		// Если следующую строку придется ждать, уже готовые уходят получателю сразу. Окончание
+		// последней из них записывается заранее, только если оно будет и в конце вывода
+		if sourceIdle(src) {
+			if sepPending && eol.final {
+				write(eol.sep)
+				sepPending = false
+			}
+			if err := bw.Flush(); err != nil {
+				return err
+			}
+		}
+		row, ok, err := src.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		line := row.Original
+		if format != nil {
+			if line, err = format(&row); err != nil {
+				return err
+			}
+		}
+		if sepPending {
+			write(eol.sep)
+		}
+		if !first && eol.records {
+			write(eol.recordSep + eol.sep)
+		}
+		first = false
+		if idx != nil {
+			if err := idx.add(&row, pos); err != nil {
+				return err
+			}
+		}
+		write(eol.text(line))
+		sepPending = true
+	}
+	if werr != nil {
+		return werr
+	}
+	if sepPending && eol.final {
+		write(eol.sep)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,52 @@
+package l2sort
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// readFileList читает список входных файлов для --files0-from (имена разделены нулевым
+// байтом, как в GNU sort) или --files-from (по одному имени в строке, пустые строки
+// пропускаются). "-" означает стандартный ввод
+func readFileList(path string, sep byte) ([]string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSuffix(data, []byte{sep})
+	var names []string
+	for i, name := range bytes.Split(data, []byte{sep}) {
+		if len(data) == 0 {
+			break
+		}
+		if sep == '\n' {
+			name = bytes.TrimSuffix(name, []byte{'\r'})
+			if len(bytes.TrimSpace(name)) == 0 {
+				continue
+			}
+		} else if len(name) == 0 {
+			return nil, fmt.Errorf("пустое имя файла в записи %d списка %s", i+1, path)
+		}
+		names = append(names, string(name))
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("список файлов %s пуст", path)
+	}
+	return names, nil
+}
+
+// inputsName возвращает краткое описание входов для сообщений
+func inputsName(inputs []string) string {
+	if len(inputs) == 1 {
+		return inputs[0]
+	}
+	return fmt.Sprintf("%s (и еще файлов: %d)", inputs[0], len(inputs)-1)
+}
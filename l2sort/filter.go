@@ -0,0 +1,352 @@
+package l2sort
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// linePredicate решает, попадает ли строка в сортировку
+type linePredicate func(line string) bool
+
+// buildLineFilter собирает предикат из --filter, --grep и --exclude: строка проходит, если
+// удовлетворяет выражению, соответствует --grep и не соответствует --exclude. Возвращает
+// nil, если фильтры не заданы
+func buildLineFilter(filterExpr, grepPattern, excludePattern string) (linePredicate, error) {
+	var preds []linePredicate
+	if filterExpr != "" {
+		pred, err := compileFilter(filterExpr)
+		if err != nil {
+			return nil, fmt.Errorf("--filter: %w", err)
+		}
+		preds = append(preds, pred)
+	}
+	if grepPattern != "" {
+		re, err := regexp.Compile(grepPattern)
+		if err != nil {
+			return nil, fmt.Errorf("--grep: %w", err)
+		}
+		preds = append(preds, re.MatchString)
+	}
+	if excludePattern != "" {
+		re, err := regexp.Compile(excludePattern)
+		if err != nil {
+			return nil, fmt.Errorf("--exclude: %w", err)
+		}
+		preds = append(preds, func(line string) bool { return !re.MatchString(line) })
+	}
+	if len(preds) == 0 {
+		return nil, nil
+	}
+	return func(line string) bool {
+		for _, pred := range preds {
+			if !pred(line) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// compileFilter разбирает выражение вида
+//
+//	fields[2] == "ERROR" && (fields[3] > 100 || fields[0] ~ "^web")
+//	f3 == "ERROR" && f4 > 100
+//
+// Поля берутся из strings.Fields: fields[N] нумеруются с нуля, fN - с единицы, как
+// колонки -k и $N в awk; отсутствующее поле равно "".
+// Сравнение числовое, если обе стороны - числа и ни одна не задана строковым литералом.
+// Операторы: == != < <= > >= ~ !~ && || ! и скобки
+func compileFilter(expr string) (linePredicate, error) {
+	tokens, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("неожиданный токен %q", p.tokens[p.pos].text)
+	}
+	return func(line string) bool {
+		return node(strings.Fields(line))
+	}, nil
+}
+
+type filterTokenKind int
+
+const (
+	tokOp filterTokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+}
+
+// filterOps перечислены так, чтобы двухсимвольные операторы проверялись раньше односимвольных
+var filterOps = []string{"==", "!=", "<=", ">=", "!~", "&&", "||", "<", ">", "~", "!", "(", ")", "[", "]"}
+
+func tokenizeFilter(expr string) ([]filterToken, error) {
+	var tokens []filterToken
+	for i := 0; i < len(expr); {
+		c := rune(expr[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(expr) && expr[j] != '"' {
+				if expr[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(expr) {
+				return nil, fmt.Errorf("незакрытая строка в позиции %d", i)
+			}
+			text, err := strconv.Unquote(expr[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("некорректная строка в позиции %d: %w", i, err)
+			}
+			tokens = append(tokens, filterToken{tokString, text})
+			i = j + 1
+		case unicode.IsDigit(c) || c == '-' || c == '.':
+			j := i + 1
+			for j < len(expr) && (isDigit(expr[j]) || expr[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, filterToken{tokNumber, expr[i:j]})
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i + 1
+			for j < len(expr) && (unicode.IsLetter(rune(expr[j])) || isDigit(expr[j]) || expr[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, filterToken{tokIdent, expr[i:j]})
+			i = j
+		default:
+			found := false
+			for _, op := range filterOps {
+				if strings.HasPrefix(expr[i:], op) {
+					tokens = append(tokens, filterToken{tokOp, op})
+					i += len(op)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("неожиданный символ %q в позиции %d", c, i)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+type filterNode func(fields []string) bool
+
+// filterOperand вычисляет значение операнда для строки; literal означает строковый литерал
+type filterOperand struct {
+	value   func(fields []string) string
+	literal bool
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek() (filterToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return filterToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *filterParser) acceptOp(op string) bool {
+	if tok, ok := p.peek(); ok && tok.kind == tokOp && tok.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptOp("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(fields []string) bool { return l(fields) || right(fields) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptOp("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(fields []string) bool { return l(fields) && right(fields) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	if p.acceptOp("!") {
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(fields []string) bool { return !inner(fields) }, nil
+	}
+	if p.acceptOp("(") {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.acceptOp(")") {
+			return nil, fmt.Errorf("ожидалась )")
+		}
+		return inner, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (filterNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	tok, ok := p.peek()
+	if !ok || tok.kind != tokOp {
+		return nil, fmt.Errorf("ожидался оператор сравнения")
+	}
+	p.pos++
+
+	if tok.text == "~" || tok.text == "!~" {
+		next, ok := p.peek()
+		if !ok || next.kind != tokString {
+			return nil, fmt.Errorf("справа от %s ожидалось регулярное выражение в кавычках", tok.text)
+		}
+		p.pos++
+		re, err := regexp.Compile(next.text)
+		if err != nil {
+			return nil, err
+		}
+		negate := tok.text == "!~"
+		return func(fields []string) bool {
+			return re.MatchString(left.value(fields)) != negate
+		}, nil
+	}
+
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	var accept func(c int) bool
+	switch tok.text {
+	case "==":
+		accept = func(c int) bool { return c == 0 }
+	case "!=":
+		accept = func(c int) bool { return c != 0 }
+	case "<":
+		accept = func(c int) bool { return c < 0 }
+	case "<=":
+		accept = func(c int) bool { return c <= 0 }
+	case ">":
+		accept = func(c int) bool { return c > 0 }
+	case ">=":
+		accept = func(c int) bool { return c >= 0 }
+	default:
+		return nil, fmt.Errorf("неизвестный оператор %q", tok.text)
+	}
+	textual := left.literal || right.literal
+	return func(fields []string) bool {
+		a, b := left.value(fields), right.value(fields)
+		if !textual {
+			x, err1 := strconv.ParseFloat(a, 64)
+			y, err2 := strconv.ParseFloat(b, 64)
+			if err1 == nil && err2 == nil {
+				return accept(compareOrdered(x, y))
+			}
+		}
+		return accept(strings.Compare(a, b))
+	}, nil
+}
+
+func (p *filterParser) parseOperand() (filterOperand, error) {
+	tok, ok := p.peek()
+	if !ok {
+		return filterOperand{}, fmt.Errorf("неожиданный конец выражения")
+	}
+	p.pos++
+	switch tok.kind {
+	case tokString:
+		return filterOperand{value: func([]string) string { return tok.text }, literal: true}, nil
+	case tokNumber:
+		return filterOperand{value: func([]string) string { return tok.text }}, nil
+	case tokIdent:
+		if n, ok := filterFieldNumber(tok.text); ok {
+			return filterOperand{value: func(fields []string) string {
+				if n > len(fields) {
+					return ""
+				}
+				return fields[n-1]
+			}}, nil
+		}
+		if tok.text != "fields" {
+			return filterOperand{}, fmt.Errorf("неизвестный идентификатор %q: ожидалось fN или fields[N]", tok.text)
+		}
+		if !p.acceptOp("[") {
+			return filterOperand{}, fmt.Errorf("ожидалось fields[N]")
+		}
+		idx, ok := p.peek()
+		if !ok || idx.kind != tokNumber {
+			return filterOperand{}, fmt.Errorf("ожидался номер поля")
+		}
+		p.pos++
+		n, err := strconv.Atoi(idx.text)
+		if err != nil || n < 0 {
+			return filterOperand{}, fmt.Errorf("некорректный номер поля %q", idx.text)
+		}
+		if !p.acceptOp("]") {
+			return filterOperand{}, fmt.Errorf("ожидалась ]")
+		}
+		return filterOperand{value: func(fields []string) string {
+			if n >= len(fields) {
+				return ""
+			}
+			return fields[n]
+		}}, nil
+	}
+	return filterOperand{}, fmt.Errorf("неожиданный токен %q", tok.text)
+}
+
+// filterFieldNumber разбирает идентификатор поля fN с номером N с единицы
+func filterFieldNumber(ident string) (int, bool) {
+	digits, ok := strings.CutPrefix(ident, "f")
+	if !ok || digits == "" || strings.TrimFunc(digits, unicode.IsDigit) != "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(digits)
+	return n, err == nil && n > 0
+}
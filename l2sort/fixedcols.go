@@ -0,0 +1,73 @@
+package l2sort
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// fixedCol - колонка --fixed-cols: позиции символов с start по end включительно,
+// считая с нуля; end < 0 означает "до конца строки"
+type fixedCol struct {
+	start, end int
+}
+
+// parseFixedCols разбирает список колонок вида "0-9,10-25,26-" для --fixed-cols
+func parseFixedCols(value string) ([]fixedCol, error) {
+	var cols []fixedCol
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		from, to, ok := strings.Cut(part, "-")
+		start, err := strconv.Atoi(from)
+		if !ok || err != nil || start < 0 {
+			return nil, fmt.Errorf("некорректная колонка %q: ожидался диапазон позиций, например 0-9 или 26-", part)
+		}
+		col := fixedCol{start: start, end: -1}
+		if to != "" {
+			if col.end, err = strconv.Atoi(to); err != nil || col.end < start {
+				return nil, fmt.Errorf("некорректная колонка %q: конец диапазона должен быть не меньше начала", part)
+			}
+		}
+		cols = append(cols, col)
+	}
+	return cols, nil
+}
+
+// cutFixedCols вырезает из строки колонки --fixed-cols. Позиции считаются в символах,
+// а не в байтах, чтобы кириллица в выгрузках не сдвигала колонки. Часть колонки за концом
+// строки пуста; пробелы выравнивания остаются в значении (их убирает модификатор b)
+func (s *Sorter) cutFixedCols(line string) []string {
+	ascii := true
+	for i := 0; i < len(line); i++ {
+		if line[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	// offsets[n] - байтовое смещение символа n при не-ASCII строке
+	var offsets []int
+	if !ascii {
+		offsets = make([]int, 0, len(line)+1)
+		for i := range line {
+			offsets = append(offsets, i)
+		}
+		offsets = append(offsets, len(line))
+	}
+	at := func(n int) int {
+		if ascii {
+			return min(n, len(line))
+		}
+		return offsets[min(n, len(offsets)-1)]
+	}
+
+	fields := make([]string, len(s.fixedCols))
+	for i, col := range s.fixedCols {
+		start, end := at(col.start), len(line)
+		if col.end >= 0 {
+			end = at(col.end + 1)
+		}
+		fields[i] = line[start:end]
+	}
+	return fields
+}
@@ -0,0 +1,376 @@
+package l2sort
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// recordKeyType - целый тип ключа двоичной записи для --key-type: ширина в байтах,
+// знаковость и порядок байт
+type recordKeyType struct {
+	size   int
+	signed bool
+	little bool
+}
+
+// recordKeyBytes - тип ключа по умолчанию: байты сравниваются как есть
+const recordKeyBytes = "bytes"
+
+var recordKeyTypes = map[string]recordKeyType{
+	"uint16be": {2, false, false}, "uint32be": {4, false, false}, "uint64be": {8, false, false},
+	"int16be": {2, true, false}, "int32be": {4, true, false}, "int64be": {8, true, false},
+	"uint16le": {2, false, true}, "uint32le": {4, false, true}, "uint64le": {8, false, true},
+	"int16le": {2, true, true}, "int32le": {4, true, true}, "int64le": {8, true, true},
+}
+
+// value читает ключ как беззнаковое число, порядок которого совпадает с порядком
+// значений типа: у знаковых инвертируется старший бит
+func (t recordKeyType) value(b []byte) uint64 {
+	var v uint64
+	for i := range t.size {
+		if t.little {
+			v = v<<8 | uint64(b[t.size-1-i])
+		} else {
+			v = v<<8 | uint64(b[i])
+		}
+	}
+	if t.signed {
+		v ^= 1 << (8*t.size - 1)
+	}
+	return v
+}
+
+// recordLayout - разметка двоичных записей фиксированной длины из --record-size,
+// --key-offset, --key-size и --key-type
+type recordLayout struct {
+	size, offset, keySize int
+	// keyType - целый тип ключа, nil - сравнение байтов
+	keyType *recordKeyType
+}
+
+// parseRecordLayout проверяет параметры режима двоичных записей. Возвращает nil, если
+// --record-size не задан
+func parseRecordLayout(o Options) (*recordLayout, error) {
+	if o.RecordSize == 0 {
+		if o.KeyOffset != 0 || o.KeySize != 0 {
+			return nil, fmt.Errorf("в параметрах: --key-offset и --key-size действуют только вместе с --record-size")
+		}
+		return nil, nil
+	}
+	if o.RecordSize < 0 || o.KeyOffset < 0 || o.KeySize < 0 {
+		return nil, fmt.Errorf("в параметрах: --record-size, --key-offset и --key-size не могут быть отрицательными")
+	}
+	switch {
+	case len(o.Keys) > 0 || o.KeyRegex != "" || o.Expr != "" || o.FixedCols != "":
+		return nil, fmt.Errorf("в параметре --record-size: ключ записи задается --key-offset и --key-size, а не -k, --key-regex, --expr или --fixed-cols")
+	case textTypeFlag(o) != "":
+		return nil, fmt.Errorf("в параметре --record-size: тип ключа записи задается --key-type, а не %s", textTypeFlag(o))
+	case o.Check || o.DryRun || o.Resume || o.Shuffle || o.GroupBy || o.Freq:
+		return nil, fmt.Errorf("в параметре --record-size: несовместим с -c, --dry-run, --resume, --shuffle, --group-by и --freq")
+	case o.PartitionBy > 0 || o.Split > 0 || o.ToSQLite != "" || o.Index != "" || o.DupsOutput != "":
+		return nil, fmt.Errorf("в параметре --record-size: несовместим с --partition-by, --split, --to-sqlite, --index и --dups-output")
+	case o.Skip > 0 || o.Head > 0 || o.Sample > 0 || o.SamplePct > 0 || o.Filter != "" || o.Grep != "" || o.Exclude != "" || o.RecordSep != "" || o.Comments != "":
+		return nil, fmt.Errorf("в параметре --record-size: строковые режимы (--skip, --head, --sample, --filter, --grep, --exclude, --record-sep, --comments) к двоичным записям не применяются")
+	case len(o.KeyTypes) > 1:
+		return nil, fmt.Errorf("в параметре --key-type: у записи один ключ, указано типов: %d", len(o.KeyTypes))
+	}
+	l := &recordLayout{size: o.RecordSize, offset: o.KeyOffset, keySize: o.KeySize}
+	if len(o.KeyTypes) == 1 && o.KeyTypes[0] != recordKeyBytes {
+		t, ok := recordKeyTypes[o.KeyTypes[0]]
+		if !ok {
+			return nil, fmt.Errorf("в параметре --key-type: неизвестный тип ключа записи %q; ожидалось bytes или %s", o.KeyTypes[0], strings.Join(slices.Sorted(maps.Keys(recordKeyTypes)), ", "))
+		}
+		if l.keySize == 0 {
+			l.keySize = t.size
+		}
+		if l.keySize != t.size {
+			return nil, fmt.Errorf("в параметре --key-size: тип %s занимает %d байт, а не %d", o.KeyTypes[0], t.size, l.keySize)
+		}
+		l.keyType = &t
+	}
+	if l.keySize == 0 {
+		l.keySize = l.size - l.offset
+	}
+	if l.offset+l.keySize > l.size || l.keySize <= 0 {
+		return nil, fmt.Errorf("в параметрах: ключ (смещение %d, %d байт) выходит за пределы записи в %d байт", l.offset, l.keySize, l.size)
+	}
+	return l, nil
+}
+
+// textTypeFlag возвращает первый общий флаг типа сравнения текста, кроме
+// --key-type, или пустую строку
+func textTypeFlag(o Options) string {
+	for _, f := range globalTypeFlags(o) {
+		if !strings.HasPrefix(f.flag, "--key-type") {
+			return f.flag
+		}
+	}
+	return ""
+}
+
+// compareRecords сравнивает ключи двух записей с учетом -r
+func (s *Sorter) compareRecords(a, b []byte) int {
+	l := s.records
+	ka, kb := a[l.offset:l.offset+l.keySize], b[l.offset:l.offset+l.keySize]
+	var c int
+	if l.keyType != nil {
+		c = compareOrdered(l.keyType.value(ka), l.keyType.value(kb))
+	} else {
+		c = bytes.Compare(ka, kb)
+	}
+	if s.opts.Reverse {
+		c = -c
+	}
+	return c
+}
+
+// sortRecords сортирует двоичные записи фиксированной длины из inputs ("-" -
+// стандартный ввод) по ключу на известном смещении. Записи читаются порциями по -S,
+// каждая порция сортируется устойчиво и при нехватке памяти сбрасывается в прогон на
+// диске, прогоны сливаются. Записи выводятся без изменений и без разделителей; при -u
+// из записей с равными ключами остается первая
+func (s *Sorter) sortRecords(ctx context.Context, inputs []string, output string) (Result, error) {
+	var result Result
+	r, err := openRecordInputs(inputs)
+	if err != nil {
+		return result, fmt.Errorf("при открытии файла: %w", err)
+	}
+	defer r.Close()
+
+	size := s.records.size
+	chunk := 0
+	if s.limit > 0 {
+		chunk = int(max(s.limit/int64(size), 1))
+	}
+	var runs []string
+	defer func() {
+		for _, run := range runs {
+			os.Remove(run)
+		}
+	}()
+	var records [][]byte
+	br := bufio.NewReaderSize(r, 1<<16)
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		records, err = readRecordChunk(br, size, chunk)
+		result.Lines += len(records)
+		if err != nil {
+			return result, fmt.Errorf("при чтении файла: %w", err)
+		}
+		if chunk == 0 || len(records) < chunk {
+			break
+		}
+		// Порция заполнена: если вход не кончился, она уходит в прогон
+		if _, err := br.Peek(1); err == io.EOF {
+			break
+		}
+		run, err := s.spillRecords(records)
+		if err != nil {
+			return result, fmt.Errorf("при записи временного файла: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	sort.SliceStable(records, func(i, j int) bool { return s.compareRecords(records[i], records[j]) < 0 })
+	result.Runs = len(runs)
+
+	err = s.writeOutputs(output, nil, func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+		var last []byte
+		emit := func(rec []byte) error {
+			if s.opts.Unique && last != nil && s.compareRecords(last, rec) == 0 {
+				return nil
+			}
+			last = append(last[:0], rec...)
+			_, err := bw.Write(rec)
+			return err
+		}
+		if len(runs) == 0 {
+			for _, rec := range records {
+				if err := emit(rec); err != nil {
+					return err
+				}
+			}
+			return bw.Flush()
+		}
+		if err := s.mergeRecords(ctx, runs, records, emit); err != nil {
+			return err
+		}
+		return bw.Flush()
+	})
+	return result, err
+}
+
+// openRecordInputs открывает входы подряд, как один поток; "-" - стандартный ввод
+func openRecordInputs(inputs []string) (io.ReadCloser, error) {
+	var readers []io.Reader
+	var closers []io.Closer
+	closeAll := func() error {
+		var errs []error
+		for _, c := range closers {
+			errs = append(errs, c.Close())
+		}
+		return errors.Join(errs...)
+	}
+	for _, path := range inputs {
+		if path == "-" {
+			readers = append(readers, os.Stdin)
+			continue
+		}
+		file, err := openInput(path)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		readers = append(readers, file)
+		closers = append(closers, file)
+	}
+	return readCloser{io.MultiReader(readers...), closeAll}, nil
+}
+
+// readRecordChunk читает до n записей (0 - все) в один буфер
+func readRecordChunk(r io.Reader, size, n int) ([][]byte, error) {
+	var records [][]byte
+	var buf []byte
+	for n == 0 || len(records) < n {
+		if len(buf) < size {
+			buf = make([]byte, size*min(max(len(records), 64), 1<<16))
+		}
+		got, err := io.ReadFull(r, buf[:size])
+		if err == io.EOF {
+			return records, nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			return records, fmt.Errorf("вход обрывается на середине записи: последние %d байт из %d", got, size)
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, buf[:size:size])
+		buf = buf[size:]
+	}
+	return records, nil
+}
+
+// spillRecords сортирует порцию записей и записывает ее во временный файл прогона
+func (s *Sorter) spillRecords(records [][]byte) (string, error) {
+	sort.SliceStable(records, func(i, j int) bool { return s.compareRecords(records[i], records[j]) < 0 })
+	file, err := temps.create(s.opts.TempDir, "records")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+	for _, rec := range records {
+		w.Write(rec)
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return file.Name(), file.Close()
+}
+
+// recordHead - текущая запись одного из сливаемых прогонов
+type recordHead struct {
+	rec []byte
+	src int
+}
+
+// recordHeap упорядочивает головы прогонов; при равенстве побеждает прогон с меньшим
+// номером, что сохраняет порядок входа
+type recordHeap struct {
+	items  []recordHead
+	sorter *Sorter
+}
+
+func (h *recordHeap) Len() int { return len(h.items) }
+func (h *recordHeap) Less(i, j int) bool {
+	if c := h.sorter.compareRecords(h.items[i].rec, h.items[j].rec); c != 0 {
+		return c < 0
+	}
+	return h.items[i].src < h.items[j].src
+}
+func (h *recordHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *recordHeap) Push(x any)    { h.items = append(h.items, x.(recordHead)) }
+func (h *recordHeap) Pop() any {
+	item := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return item
+}
+
+// mergeRecords сливает прогоны и последнюю порцию в памяти, передавая записи в emit
+func (s *Sorter) mergeRecords(ctx context.Context, runs []string, last [][]byte, emit func([]byte) error) error {
+	size := s.records.size
+	var next []func() ([]byte, error)
+	for _, run := range runs {
+		file, err := os.Open(run)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		br := bufio.NewReader(file)
+		next = append(next, func() ([]byte, error) {
+			rec := make([]byte, size)
+			if _, err := io.ReadFull(br, rec); err != nil {
+				if err == io.EOF {
+					return nil, nil
+				}
+				return nil, err
+			}
+			return rec, nil
+		})
+	}
+	next = append(next, func() ([]byte, error) {
+		if len(last) == 0 {
+			return nil, nil
+		}
+		rec := last[0]
+		last = last[1:]
+		return rec, nil
+	})
+
+	h := &recordHeap{sorter: s}
+	for i, read := range next {
+		rec, err := read()
+		if err != nil {
+			return err
+		}
+		if rec != nil {
+			h.items = append(h.items, recordHead{rec, i})
+		}
+	}
+	heap.Init(h)
+	for n := 0; h.Len() > 0; n++ {
+		if n%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		top := &h.items[0]
+		if err := emit(top.rec); err != nil {
+			return err
+		}
+		rec, err := next[top.src]()
+		if err != nil {
+			return err
+		}
+		if rec == nil {
+			heap.Pop(h)
+			continue
+		}
+		top.rec = rec
+		heap.Fix(h, 0)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package l2sort
+
+import (
+	"fmt"
+	"sort"
+)
+
+// freqEntry - различная строка и число ее вхождений
+type freqEntry struct {
+	row   Row
+	count int
+}
+
+// freqSource выдает различные строки по убыванию числа вхождений, как
+// sort | uniq -c | sort -rn. Подсчет ведется в хеш-таблице по отсортированному потоку,
+// поэтому в памяти держатся только различные строки, а строки с равной частотой остаются
+// в обычном порядке сортировки. При --no-counts число вхождений не выводится
+type freqSource struct {
+	src     rowSource
+	counts  bool
+	entries []*freqEntry
+	pos     int
+	loaded  bool
+}
+
+func (f *freqSource) next() (Row, bool, error) {
+	if !f.loaded {
+		if err := f.load(); err != nil {
+			return Row{}, false, err
+		}
+		f.loaded = true
+	}
+	if f.pos >= len(f.entries) {
+		return Row{}, false, nil
+	}
+	entry := f.entries[f.pos]
+	f.pos++
+	row := entry.row
+	if f.counts {
+		row.Original = fmt.Sprintf("%7d %s", entry.count, row.Original)
+	}
+	return row, true, nil
+}
+
+func (f *freqSource) load() error {
+	index := make(map[string]*freqEntry)
+	for {
+		row, ok, err := f.src.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		if entry := index[row.Original]; entry != nil {
+			entry.count++
+			continue
+		}
+		entry := &freqEntry{row: row, count: 1}
+		index[row.Original] = entry
+		f.entries = append(f.entries, entry)
+	}
+	sort.SliceStable(f.entries, func(i, j int) bool {
+		return f.entries[i].count > f.entries[j].count
+	})
+	return nil
+}
@@ -0,0 +1,257 @@
+package l2sort
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+)
+
+// compatGNU - значение --compat, при котором ключи выделяются и сравниваются как в
+// coreutils sort при LC_ALL=C
+const compatGNU = "gnu"
+
+// checkCompat проверяет --compat
+func checkCompat(o Options) error {
+	switch o.Compat {
+	case "", compatGNU:
+		return nil
+	}
+	return fmt.Errorf("в параметре --compat: неизвестный режим %q, ожидалось gnu", o.Compat)
+}
+
+// gnuKinds - типы сравнения, которые при --compat gnu сравниваются по правилам GNU sort
+var gnuKinds = map[string]bool{typeText: true, typeNumeric: true, typeHuman: true, typeMonth: true, typeRandom: true}
+
+// checkGNUKinds отклоняет при --compat gnu типы, которых нет в GNU sort или которые
+// он сравнивает иначе (например, -V)
+func (s *Sorter) checkGNUKinds() error {
+	if s.opts.Compat != compatGNU {
+		return nil
+	}
+	for _, kind := range append([]keyKind{s.defaultKind}, s.keyKinds...) {
+		if !gnuKinds[kind.name] {
+			return fmt.Errorf("в параметрах: при --compat gnu тип сравнения %s недоступен; доступны text, numeric (-n), human (-h), month (-M) и random (-R)", kind.name)
+		}
+	}
+	return nil
+}
+
+// gnuKeySpecs приводит ключи -k к правилам GNU sort. Пара ключей одного указания -k 2,3
+// объединяется в ключ от поля до поля; модификаторы можно указать у любого из двух полей.
+// Ключ со своими модификаторами не наследует общие -r, -b и тип сравнения: его r
+// обращается при общем -r, который затем обратит весь порядок, а общий -b переносится
+// на ключи без модификаторов
+func gnuKeySpecs(o *Options) error {
+	specs, err := joinGNUKeySpecs(o.Keys)
+	if err != nil {
+		return err
+	}
+	for i, key := range specs {
+		if key.Reverse || key.IgnoreBlanks || key.Type != "" {
+			key.Reverse = key.Reverse != o.Reverse
+			key.Type = cmp.Or(key.Type, typeText)
+		} else {
+			key.IgnoreBlanks = o.IgnoreBlanks
+		}
+		specs[i] = key
+	}
+	o.Keys = specs
+	if len(specs) > 0 {
+		o.IgnoreBlanks = false
+	}
+	return nil
+}
+
+// joinGNUKeySpecs объединяет пары ключей одного указания -k N,M
+func joinGNUKeySpecs(specs keySpecList) (keySpecList, error) {
+	var out keySpecList
+	for i := 0; i < len(specs); {
+		j := i + 1
+		for j < len(specs) && specs[i].Group != 0 && specs[j].Group == specs[i].Group {
+			j++
+		}
+		switch j - i {
+		case 1:
+			out = append(out, specs[i])
+		case 2:
+			key, end := specs[i], specs[i+1]
+			if key.Type != "" && end.Type != "" && key.Type != end.Type {
+				return nil, fmt.Errorf("в параметре -k: у ключа %d,%d два типа сравнения: %s и %s", key.Column, end.Column, key.Type, end.Type)
+			}
+			key.End = end.Column
+			key.Reverse = key.Reverse || end.Reverse
+			key.Type = cmp.Or(key.Type, end.Type)
+			if end.IgnoreBlanks && key.Type == "" {
+				// b у конца ключа без номеров символов ничего не меняет, но, как любой
+				// модификатор, отменяет наследование общих параметров
+				key.Type = typeText
+			}
+			key.Transform = cmp.Or(key.Transform, end.Transform)
+			out = append(out, key)
+		default:
+			return nil, fmt.Errorf("в параметре -k: при --compat gnu ключ - поле или диапазон полей N,M, а не %d полей через запятую", j-i)
+		}
+		i = j
+	}
+	return out, nil
+}
+
+// sortOrder - итоговый порядок строк при сортировке: compareOrder, а строки с равными
+// ключами, как в GNU sort, в последнюю очередь сравниваются целиком (с учетом -r). Это
+// сравнение отключают -s и -u; тогда равные строки сохраняют порядок входа
+func (s *Sorter) sortOrder(a, b *Row) int {
+	c := s.compareOrder(a, b)
+	if c != 0 || s.opts.Stable || s.opts.Unique {
+		return c
+	}
+	c = s.compareText(a.Original, b.Original)
+	if s.opts.Reverse {
+		c = -c
+	}
+	return c
+}
+
+// gnuKeys выделяет ключи по правилам GNU sort: без -k ключ - вся строка, ключ -k N -
+// от начала поля N до конца строки, -k N,M - до конца поля M. Поле начинается с
+// пробелов, отделяющих его от предыдущего, поэтому без b они входят в ключ; у строки
+// с меньшим числом полей ключ пуст
+func (s *Sorter) gnuKeys(line string) []string {
+	if len(s.opts.Keys) == 0 {
+		return []string{line}
+	}
+	keys := make([]string, len(s.opts.Keys))
+	for i, spec := range s.opts.Keys {
+		start := skipGNUFields(line, spec.Column-1)
+		end := len(line)
+		if spec.End > 0 {
+			end = max(skipGNUFields(line, spec.End), start)
+		}
+		keys[i] = line[start:end]
+	}
+	return keys
+}
+
+// skipGNUFields возвращает позицию в строке после n полей
+func skipGNUFields(line string, n int) int {
+	pos := 0
+	for range n {
+		for pos < len(line) && isBlank(line[pos]) {
+			pos++
+		}
+		for pos < len(line) && !isBlank(line[pos]) {
+			pos++
+		}
+	}
+	return pos
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+// compareGNUNumeric сравнивает ключи как GNU sort -n: начальные пробелы пропускаются,
+// число - необязательный минус, цифры и дробная часть после точки; остаток ключа не
+// учитывается, а ключ без числа равен нулю. Числа сравниваются точно, как строки цифр
+func compareGNUNumeric(a, b string) int {
+	negA, intA, fracA := parseGNUNumber(a)
+	negB, intB, fracB := parseGNUNumber(b)
+	if negA != negB {
+		if negA {
+			return -1
+		}
+		return 1
+	}
+	c := compareOrdered(int64(len(intA)), int64(len(intB)))
+	if c == 0 {
+		c = strings.Compare(intA, intB)
+	}
+	if c == 0 {
+		c = strings.Compare(fracA, fracB)
+	}
+	if negA {
+		c = -c
+	}
+	return c
+}
+
+// parseGNUNumber разбирает начало ключа в знак, целую часть без ведущих нулей и дробную
+// без конечных нулей. У нуля, в том числе -0, знак положительный
+func parseGNUNumber(text string) (neg bool, intPart, frac string) {
+	text = strings.TrimLeft(text, " \t")
+	if strings.HasPrefix(text, "-") {
+		neg, text = true, text[1:]
+	}
+	end := 0
+	for end < len(text) && isDigit(text[end]) {
+		end++
+	}
+	intPart = strings.TrimLeft(text[:end], "0")
+	if end < len(text) && text[end] == '.' {
+		start := end + 1
+		end = start
+		for end < len(text) && isDigit(text[end]) {
+			end++
+		}
+		frac = strings.TrimRight(text[start:end], "0")
+	}
+	if intPart == "" && frac == "" {
+		neg = false
+	}
+	return neg, intPart, frac
+}
+
+// gnuUnitOrder - порядок суффиксов единиц для -h, как в GNU sort
+var gnuUnitOrder = map[byte]int{'k': 1, 'K': 1, 'M': 2, 'G': 3, 'T': 4, 'P': 5, 'E': 6, 'Z': 7, 'Y': 8, 'R': 9, 'Q': 10}
+
+// compareGNUHuman сравнивает ключи как GNU sort -h: сначала по суффиксу единиц сразу
+// после числа (у отрицательного числа порядок суффикса обратный, у нуля суффикс не
+// учитывается), а при равных суффиксах - как -n
+func compareGNUHuman(a, b string) int {
+	if c := cmp.Compare(gnuUnit(a), gnuUnit(b)); c != 0 {
+		return c
+	}
+	return compareGNUNumeric(a, b)
+}
+
+// gnuUnit возвращает порядок суффикса единиц числа в начале ключа
+func gnuUnit(text string) int {
+	text = strings.TrimLeft(text, " \t")
+	neg := strings.HasPrefix(text, "-")
+	if neg {
+		text = text[1:]
+	}
+	nonzero := false
+	end := 0
+	for end < len(text) && isDigit(text[end]) {
+		nonzero = nonzero || text[end] != '0'
+		end++
+	}
+	if end < len(text) && text[end] == '.' {
+		for end++; end < len(text) && isDigit(text[end]); end++ {
+			nonzero = nonzero || text[end] != '0'
+		}
+	}
+	if !nonzero || end == len(text) {
+		return 0
+	}
+	order := gnuUnitOrder[text[end]]
+	if neg {
+		return -order
+	}
+	return order
+}
+
+// gnuMonths - сокращения месяцев локали C в порядке года
+var gnuMonths = []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}
+
+// gnuMonth возвращает номер месяца (с 1), сокращение которого без учета регистра
+// начинает ключ после пробелов, как GNU sort -M; 0 - ключ не начинается с месяца
+func gnuMonth(text string) int {
+	text = strings.TrimLeft(text, " \t")
+	for i, month := range gnuMonths {
+		if len(text) >= len(month) && strings.EqualFold(text[:len(month)], month) {
+			return i + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,252 @@
+package l2sort
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// parseTestFlags разбирает флаги args, как l2sort sort, поверх настроек по умолчанию
+func parseTestFlags(t testing.TB, args []string) Options {
+	t.Helper()
+	opts := Options{LongLines: longLineTruncateKey}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	opts.registerFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("флаги %q: %v", args, err)
+	}
+	return opts
+}
+
+// newTestSorter создает Sorter с флагами args
+func newTestSorter(t testing.TB, args []string) *Sorter {
+	t.Helper()
+	sorter, err := NewSorter(parseTestFlags(t, args))
+	if err != nil {
+		t.Fatalf("флаги %q: %v", args, err)
+	}
+	return sorter
+}
+
+// sortLines сортирует lines с флагами args, как l2sort sort -o, и возвращает строки результата
+func sortLines(t testing.TB, args []string, lines []string) []string {
+	t.Helper()
+	sorter := newTestSorter(t, args)
+	dir := t.TempDir()
+	input, output := filepath.Join(dir, "in.txt"), filepath.Join(dir, "out.txt")
+	if err := os.WriteFile(input, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sorter.SortFile(input, output); err != nil {
+		t.Fatalf("флаги %q: %v", args, err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// gnuInput - вход сравнения с GNU sort: пробелы в начале и между полями, регистр,
+// числа с суффиксами единиц, месяцы и повторы
+var gnuInput = []string{
+	"b  10 x",
+	"a 2 y",
+	"B 1K z",
+	"a  -3 w",
+	"c 1.5M v",
+	" d 007 u",
+	"a 2 y",
+	"e jan t",
+	"f Feb s",
+	"g 0 r",
+	"h abc q",
+}
+
+// TestCompatGNU сравнивает результат --compat gnu с выводом coreutils sort 9.1 при LC_ALL=C
+// на том же входе и с теми же флагами
+func TestCompatGNU(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"вся строка побайтово", []string{}, []string{
+			" d 007 u",
+			"B 1K z",
+			"a  -3 w",
+			"a 2 y",
+			"a 2 y",
+			"b  10 x",
+			"c 1.5M v",
+			"e jan t",
+			"f Feb s",
+			"g 0 r",
+			"h abc q",
+		}},
+		{"от поля 2 с пробелами до конца строки", []string{"-k", "2"}, []string{
+			"a  -3 w",
+			"b  10 x",
+			"g 0 r",
+			" d 007 u",
+			"c 1.5M v",
+			"B 1K z",
+			"a 2 y",
+			"a 2 y",
+			"f Feb s",
+			"h abc q",
+			"e jan t",
+		}},
+		{"число поля 2", []string{"-k", "2,2", "-n"}, []string{
+			"a  -3 w",
+			"e jan t",
+			"f Feb s",
+			"g 0 r",
+			"h abc q",
+			"B 1K z",
+			"c 1.5M v",
+			"a 2 y",
+			"a 2 y",
+			" d 007 u",
+			"b  10 x",
+		}},
+		{"число с начала поля 2", []string{"-n", "-k", "2"}, []string{
+			"a  -3 w",
+			"e jan t",
+			"f Feb s",
+			"g 0 r",
+			"h abc q",
+			"B 1K z",
+			"c 1.5M v",
+			"a 2 y",
+			"a 2 y",
+			" d 007 u",
+			"b  10 x",
+		}},
+		{"суффиксы единиц", []string{"-k", "2,2", "-h"}, []string{
+			"a  -3 w",
+			"e jan t",
+			"f Feb s",
+			"g 0 r",
+			"h abc q",
+			"a 2 y",
+			"a 2 y",
+			" d 007 u",
+			"b  10 x",
+			"B 1K z",
+			"c 1.5M v",
+		}},
+		{"месяцы", []string{"-k", "2,2", "-M"}, []string{
+			" d 007 u",
+			"B 1K z",
+			"a  -3 w",
+			"a 2 y",
+			"a 2 y",
+			"b  10 x",
+			"c 1.5M v",
+			"g 0 r",
+			"h abc q",
+			"e jan t",
+			"f Feb s",
+		}},
+		{"обратный порядок", []string{"-r", "-k", "2,2n"}, []string{
+			"a  -3 w",
+			"h abc q",
+			"g 0 r",
+			"f Feb s",
+			"e jan t",
+			"B 1K z",
+			"c 1.5M v",
+			"a 2 y",
+			"a 2 y",
+			" d 007 u",
+			"b  10 x",
+		}},
+		{"повторы по ключу", []string{"-u", "-k", "1,1"}, []string{
+			" d 007 u",
+			"B 1K z",
+			"a 2 y",
+			"b  10 x",
+			"c 1.5M v",
+			"e jan t",
+			"f Feb s",
+			"g 0 r",
+			"h abc q",
+		}},
+		{"без начальных пробелов", []string{"-b", "-k", "2"}, []string{
+			"a  -3 w",
+			"g 0 r",
+			" d 007 u",
+			"c 1.5M v",
+			"b  10 x",
+			"B 1K z",
+			"a 2 y",
+			"a 2 y",
+			"f Feb s",
+			"h abc q",
+			"e jan t",
+		}},
+		{"ключ со своим модификатором", []string{"-k", "1,1", "-k", "2,2nr"}, []string{
+			" d 007 u",
+			"B 1K z",
+			"a 2 y",
+			"a 2 y",
+			"a  -3 w",
+			"b  10 x",
+			"c 1.5M v",
+			"e jan t",
+			"f Feb s",
+			"g 0 r",
+			"h abc q",
+		}},
+		{"устойчивая сортировка", []string{"-s", "-k", "1,1"}, []string{
+			" d 007 u",
+			"B 1K z",
+			"a 2 y",
+			"a  -3 w",
+			"a 2 y",
+			"b  10 x",
+			"c 1.5M v",
+			"e jan t",
+			"f Feb s",
+			"g 0 r",
+			"h abc q",
+		}},
+		{"модификаторы у обоих ключей", []string{"-k", "2b,2", "-k", "1,1r"}, []string{
+			"a  -3 w",
+			"g 0 r",
+			" d 007 u",
+			"c 1.5M v",
+			"b  10 x",
+			"B 1K z",
+			"a 2 y",
+			"a 2 y",
+			"f Feb s",
+			"h abc q",
+			"e jan t",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sortLines(t, append([]string{"--compat", "gnu"}, tt.args...), gnuInput)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("sort %s:\nполучено %q\nожидалось %q", strings.Join(tt.args, " "), got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCompatGNURejectsKinds проверяет, что типы без аналога в GNU sort отклоняются
+func TestCompatGNURejectsKinds(t *testing.T) {
+	for _, args := range [][]string{{"--natural"}, {"-k", "2V"}, {"--time"}, {"-k", "1:ip"}} {
+		opts := parseTestFlags(t, append([]string{"--compat", "gnu"}, args...))
+		if _, err := NewSorter(opts); err == nil {
+			t.Errorf("--compat gnu %s: ожидалась ошибка", strings.Join(args, " "))
+		}
+	}
+}
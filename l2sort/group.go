@@ -0,0 +1,114 @@
+package l2sort
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// aggregate - одна функция из --aggregate над колонкой column (с 1; для count не нужна)
+type aggregate struct {
+	fn     string
+	column int
+}
+
+// parseAggregates разбирает список вида "count,sum:3,max:3,first:2". По умолчанию - count
+func parseAggregates(spec string) ([]aggregate, error) {
+	if strings.TrimSpace(spec) == "" {
+		return []aggregate{{fn: "count"}}, nil
+	}
+	var aggs []aggregate
+	for _, item := range strings.Split(spec, ",") {
+		fn, col, hasCol := strings.Cut(strings.TrimSpace(item), ":")
+		switch fn {
+		case "count":
+			if hasCol {
+				return nil, fmt.Errorf("count не принимает колонку")
+			}
+			aggs = append(aggs, aggregate{fn: fn})
+			continue
+		case "sum", "min", "max", "first", "last":
+		default:
+			return nil, fmt.Errorf("неизвестная функция %q", fn)
+		}
+		n, err := strconv.Atoi(col)
+		if !hasCol || err != nil || n <= 0 {
+			return nil, fmt.Errorf("для %s нужна колонка, например %s:3", fn, fn)
+		}
+		aggs = append(aggs, aggregate{fn: fn, column: n})
+	}
+	return aggs, nil
+}
+
+// groupSource сворачивает каждую группу подряд идущих строк с равными ключами в одну
+// строку: текст ключей группы, за которым через пробел следуют значения --aggregate
+type groupSource struct {
+	src    peekSource
+	sorter *Sorter
+	aggs   []aggregate
+}
+
+func (g *groupSource) next() (Row, bool, error) {
+	rows, err := g.src.group(g.sorter)
+	if err != nil || rows == nil {
+		return Row{}, false, err
+	}
+	first := &rows[0]
+	parts := make([]string, 0, len(first.Keys)+len(g.aggs))
+	for k := range first.Keys {
+		parts = append(parts, first.keyText(k))
+	}
+	for _, agg := range g.aggs {
+		parts = append(parts, agg.apply(rows, g.sorter.splitFields))
+	}
+	return Row{Original: strings.Join(parts, " "), Keys: first.Keys, data: first.data}, true, nil
+}
+
+// apply вычисляет функцию над группой. sum, min и max учитывают только числовые значения
+// колонки и дают "-", если таких нет; first и last берут значение как есть ("-" без колонки)
+func (a aggregate) apply(rows []Row, split func(string) []string) string {
+	if a.fn == "count" {
+		return strconv.Itoa(len(rows))
+	}
+	var values []string
+	for _, row := range rows {
+		fields := split(row.Original)
+		if a.column <= len(fields) {
+			values = append(values, fields[a.column-1])
+		}
+	}
+	switch a.fn {
+	case "first", "last":
+		if len(values) == 0 {
+			return "-"
+		}
+		if a.fn == "first" {
+			return values[0]
+		}
+		return values[len(values)-1]
+	}
+
+	result, found := 0.0, false
+	for _, value := range values {
+		x, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(x) {
+			continue
+		}
+		switch {
+		case !found:
+			result = x
+		case a.fn == "sum":
+			result += x
+		case a.fn == "min":
+			result = math.Min(result, x)
+		case a.fn == "max":
+			result = math.Max(result, x)
+		}
+		found = true
+	}
+	if !found {
+		return "-"
+	}
+	return strconv.FormatFloat(result, 'f', -1, 64)
+}
@@ -0,0 +1,345 @@
+package l2sort
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// grpcSortMethod - путь метода сервиса --serve-grpc. Сервис описывается так:
+//
+//	syntax = "proto3";
+//	package l2sort;
+//
+//	service Sorter {
+//	  rpc Sort(stream SortRequest) returns (stream SortResponse);
+//	}
+//
+//	message SortRequest {
+//	  repeated string args = 1;    // флаги сортировки, как в командной строке; только в первом сообщении
+//	  repeated bytes records = 2;  // строки входа без перевода строки
+//	}
+//
+//	message SortResponse {
+//	  repeated bytes records = 1;  // отсортированные строки
+//	}
+const grpcSortMethod = "/l2sort.Sorter/Sort"
+
+// Коды состояния gRPC, которые возвращает --serve-grpc
+const (
+	grpcOK                = 0
+	grpcInvalidArgument   = 3
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+)
+
+// grpcResponseBatch - сколько байт строк собирается в одно сообщение SortResponse
+const grpcResponseBatch = 32 << 10
+
+// grpcError - ошибка запроса с кодом состояния gRPC
+type grpcError struct {
+	code int
+	err  error
+}
+
+func (e *grpcError) Error() string { return e.err.Error() }
+
+// runServeGRPC запускает сервис gRPC на addr до отмены ctx. HTTP/2 без TLS (h2c), метод
+// Sort - двунаправленный поток: клиент передает флаги и строки, сервис после конца входа
+// сортирует их, как POST /sort в --serve (с диском при нехватке памяти), и возвращает
+// строки потоком сообщений. Вход больше maxBody отклоняется с RESOURCE_EXHAUSTED
+func runServeGRPC(ctx context.Context, addr string, base Options, maxBody int64) int {
+	server := newGRPCServer(ctx, addr, base, maxBody)
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	fmt.Printf("Сервис gRPC слушает %s, %s\n", addr, grpcSortMethod)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return reportError(err)
+	}
+	fmt.Println("Сервис остановлен.")
+	return 0
+}
+
+// newGRPCServer создает сервер HTTP/2 без TLS с методом Sort; запросы получают контекст ctx
+func newGRPCServer(ctx context.Context, addr string, base Options, maxBody int64) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+grpcSortMethod, func(w http.ResponseWriter, r *http.Request) {
+		serveGRPCSort(w, r, base, maxBody)
+	})
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		Protocols:         protocols,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+}
+
+// serveGRPCSort обрабатывает один вызов Sort: сохраняет строки во временный файл,
+// сортирует его и передает результат сообщениями SortResponse. Ошибка возвращается
+// состоянием gRPC в трейлерах ответа
+func serveGRPCSort(w http.ResponseWriter, r *http.Request, base Options, maxBody int64) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "ожидался запрос gRPC", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	out := &grpcOutput{w: w}
+	err := grpcSort(r, base, maxBody, out)
+	if err == nil {
+		err = out.flush(true)
+	}
+	code, message := grpcOK, ""
+	if err != nil {
+		code, message = grpcInternal, err.Error()
+		var status *grpcError
+		if errors.As(err, &status) {
+			code = status.code
+		}
+	}
+	// До первого сообщения состояние передается в заголовках (ответ без тела), после -
+	// в трейлерах
+	prefix := ""
+	if out.started {
+		prefix = http.TrailerPrefix
+	}
+	w.Header().Set(prefix+"Grpc-Status", strconv.Itoa(code))
+	if message != "" {
+		w.Header().Set(prefix+"Grpc-Message", grpcPercentEncode(message))
+	}
+}
+
+// grpcSort читает сообщения SortRequest, сортирует их строки и пишет результат в out
+func grpcSort(r *http.Request, base Options, maxBody int64, out *grpcOutput) error {
+	input, err := temps.create(base.TempDir, "request")
+	if err != nil {
+		return fmt.Errorf("при создании временного файла: %w", err)
+	}
+	defer temps.remove(input.Name())
+	defer input.Close()
+
+	var sorter *Sorter
+	var size int64
+	body := bufio.NewReader(r.Body)
+	w := bufio.NewWriter(input)
+	for {
+		msg, err := readGRPCMessage(body)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		args, records, err := decodeSortRequest(msg)
+		if err != nil {
+			return &grpcError{grpcInvalidArgument, fmt.Errorf("некорректное сообщение SortRequest: %w", err)}
+		}
+		if len(args) > 0 {
+			if sorter != nil || size > 0 {
+				return &grpcError{grpcInvalidArgument, errors.New("флаги args передаются только в первом сообщении")}
+			}
+			if sorter, err = sorterFromArgs(base, args); err != nil {
+				return &grpcError{grpcInvalidArgument, err}
+			}
+		}
+		for _, record := range records {
+			if strings.ContainsAny(record, "\r\n") {
+				return &grpcError{grpcInvalidArgument, errors.New("строка records не должна содержать перевод строки")}
+			}
+			if size += int64(len(record)) + 1; size > maxBody {
+				return &grpcError{grpcResourceExhausted, fmt.Errorf("вход больше %d байт", maxBody)}
+			}
+			w.WriteString(record)
+			w.WriteByte('\n')
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("при записи временного файла: %w", err)
+	}
+	if err := input.Close(); err != nil {
+		return fmt.Errorf("при записи временного файла: %w", err)
+	}
+	if sorter == nil {
+		if sorter, err = NewSorter(base); err != nil {
+			return &grpcError{grpcInvalidArgument, err}
+		}
+	}
+	sorter.stdout = out
+	_, err = sorter.SortFileContext(r.Context(), input.Name(), "-")
+	return err
+}
+
+// sorterFromArgs создает Sorter по флагам args поверх общих настроек base; флаги
+// serveDeniedFlags, как и в --serve, недоступны
+func sorterFromArgs(base Options, args []string) (*Sorter, error) {
+	reqOpts := base.clone()
+	fs := flag.NewFlagSet("grpc", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	reqOpts.registerFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("лишние аргументы %v: файлы не указываются, строки передаются в records", fs.Args())
+	}
+	var denied error
+	fs.Visit(func(f *flag.Flag) {
+		if serveDeniedFlags[f.Name] && denied == nil {
+			denied = fmt.Errorf("параметр %s недоступен в режиме сервиса", f.Name)
+		}
+	})
+	if denied != nil {
+		return nil, denied
+	}
+	return newServiceSorter(reqOpts)
+}
+
+// readGRPCMessage читает одно сообщение gRPC: признак сжатия, длину и само сообщение.
+// io.EOF - поток закончился на границе сообщений
+func readGRPCMessage(r io.Reader) ([]byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, &grpcError{grpcInvalidArgument, errors.New("поток оборван внутри заголовка сообщения")}
+		}
+		return nil, err
+	}
+	if header[0] != 0 {
+		return nil, &grpcError{grpcUnimplemented, errors.New("сжатые сообщения не поддерживаются")}
+	}
+	n := binary.BigEndian.Uint32(header[1:])
+	if n > 1<<30 {
+		return nil, &grpcError{grpcResourceExhausted, fmt.Errorf("сообщение длиной %d байт слишком велико", n)}
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, &grpcError{grpcInvalidArgument, errors.New("поток оборван внутри сообщения")}
+		}
+		return nil, err
+	}
+	return msg, nil
+}
+
+// decodeSortRequest разбирает сообщение SortRequest в protobuf; неизвестные поля
+// пропускаются
+func decodeSortRequest(msg []byte) (args, records []string, err error) {
+	for len(msg) > 0 {
+		tag, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return nil, nil, errors.New("некорректный номер поля")
+		}
+		msg = msg[n:]
+		field, wire := tag>>3, tag&7
+		switch wire {
+		case 0:
+			if _, n = binary.Uvarint(msg); n <= 0 {
+				return nil, nil, errors.New("некорректное число")
+			}
+			msg = msg[n:]
+		case 1, 5:
+			size := 8
+			if wire == 5 {
+				size = 4
+			}
+			if len(msg) < size {
+				return nil, nil, errors.New("сообщение оборвано")
+			}
+			msg = msg[size:]
+		case 2:
+			length, n := binary.Uvarint(msg)
+			if n <= 0 || length > uint64(len(msg)-n) {
+				return nil, nil, errors.New("некорректная длина поля")
+			}
+			value := string(msg[n : n+int(length)])
+			msg = msg[n+int(length):]
+			switch field {
+			case 1:
+				args = append(args, value)
+			case 2:
+				records = append(records, value)
+			}
+		default:
+			return nil, nil, fmt.Errorf("неподдерживаемый тип поля %d", wire)
+		}
+	}
+	return args, records, nil
+}
+
+// grpcOutput собирает вывод сортировки в сообщения SortResponse по строкам
+type grpcOutput struct {
+	w       http.ResponseWriter
+	pending []byte
+	started bool
+}
+
+func (o *grpcOutput) Write(p []byte) (int, error) {
+	o.pending = append(o.pending, p...)
+	if len(o.pending) >= grpcResponseBatch {
+		if err := o.flush(false); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// flush отправляет накопленные целые строки одним сообщением; при final - и
+// незавершенную последнюю строку
+func (o *grpcOutput) flush(final bool) error {
+	data := o.pending
+	end := strings.LastIndexByte(string(data), '\n') + 1
+	if final {
+		end = len(data)
+	}
+	if end == 0 {
+		return nil
+	}
+	var msg []byte
+	for line := range strings.SplitSeq(strings.TrimSuffix(string(data[:end]), "\n"), "\n") {
+		msg = binary.AppendUvarint(msg, 1<<3|2)
+		msg = binary.AppendUvarint(msg, uint64(len(line)))
+		msg = append(msg, line...)
+	}
+	o.pending = append(o.pending[:0], data[end:]...)
+	frame := binary.BigEndian.AppendUint32([]byte{0}, uint32(len(msg)))
+	o.started = true
+	if _, err := o.w.Write(append(frame, msg...)); err != nil {
+		return err
+	}
+	if f, ok := o.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// grpcPercentEncode кодирует текст для заголовка grpc-message: байты вне печатного ASCII
+// и % записываются как %XX
+func grpcPercentEncode(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
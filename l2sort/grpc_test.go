@@ -0,0 +1,137 @@
+package l2sort
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// rawCodec передает сообщения клиенту grpc-go готовыми байтами protobuf, чтобы тест
+// не зависел от сгенерированного кода
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) { return *v.(*[]byte), nil }
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	*v.(*[]byte) = slices.Clone(data)
+	return nil
+}
+
+func (rawCodec) Name() string { return "proto" }
+
+// sortRequest кодирует сообщение SortRequest
+func sortRequest(args []string, records ...string) []byte {
+	var msg []byte
+	for _, arg := range args {
+		msg = protowire.AppendTag(msg, 1, protowire.BytesType)
+		msg = protowire.AppendString(msg, arg)
+	}
+	for _, record := range records {
+		msg = protowire.AppendTag(msg, 2, protowire.BytesType)
+		msg = protowire.AppendString(msg, record)
+	}
+	return msg
+}
+
+// sortResponseRecords разбирает строки сообщения SortResponse
+func sortResponseRecords(t *testing.T, msg []byte) []string {
+	t.Helper()
+	var records []string
+	for len(msg) > 0 {
+		field, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 || field != 1 || typ != protowire.BytesType {
+			t.Fatalf("некорректное сообщение SortResponse: поле %d, тип %d", field, typ)
+		}
+		msg = msg[n:]
+		value, n := protowire.ConsumeBytes(msg)
+		if n < 0 {
+			t.Fatal("некорректная длина строки SortResponse")
+		}
+		records = append(records, string(value))
+		msg = msg[n:]
+	}
+	return records
+}
+
+// TestGRPCInterop вызывает Sort клиентом grpc-go: сообщения, потоки и коды состояния
+// сервиса --serve-grpc должны читаться настоящей реализацией gRPC
+func TestGRPCInterop(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newGRPCServer(ctx, listener.Addr().String(), Options{}, 1<<10)
+	go server.Serve(listener)
+	defer server.Close()
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(rawCodec{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		name     string
+		requests [][]byte
+		want     []string
+		code     codes.Code
+	}{
+		{"числа", [][]byte{sortRequest([]string{"-n"}, "10", "9"), sortRequest(nil, "100", "-1")}, []string{"-1", "9", "10", "100"}, codes.OK},
+		{"без флагов", [][]byte{sortRequest(nil, "b", "a")}, []string{"a", "b"}, codes.OK},
+		{"пустой вход", nil, nil, codes.OK},
+		{"флаги не первыми", [][]byte{sortRequest(nil, "a"), sortRequest([]string{"-r"})}, nil, codes.InvalidArgument},
+		{"флаг сервиса запрещен", [][]byte{sortRequest([]string{"--by", "size"}, "a")}, nil, codes.InvalidArgument},
+		{"перевод строки", [][]byte{sortRequest(nil, "a\nb")}, nil, codes.InvalidArgument},
+		{"вход больше предела", [][]byte{sortRequest(nil, string(make([]byte, 2<<10)))}, nil, codes.ResourceExhausted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ClientStreams: true, ServerStreams: true}, grpcSortMethod)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, req := range tt.requests {
+				if err := stream.SendMsg(&req); err != nil && !errors.Is(err, io.EOF) {
+					t.Fatal(err)
+				}
+			}
+			if err := stream.CloseSend(); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for {
+				var msg []byte
+				err := stream.RecvMsg(&msg)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					if code := status.Code(err); code != tt.code {
+						t.Fatalf("состояние %v (%v), ожидалось %v", code, err, tt.code)
+					}
+					return
+				}
+				got = append(got, sortResponseRecords(t, msg)...)
+			}
+			if tt.code != codes.OK {
+				t.Fatalf("вызов завершился успешно, ожидалось состояние %v", tt.code)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("строки %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
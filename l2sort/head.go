@@ -0,0 +1,95 @@
+package l2sort
+
+import (
+	"bufio"
+	"container/heap"
+	"io"
+	"strings"
+)
+
+// topRows хранит при --head N только N лучших строк в итоговом порядке. Вершина кучи -
+// худшая из них, поэтому новая строка либо вытесняет ее, либо отбрасывается сразу,
+// и память не зависит от размера входа
+type topRows struct {
+	rows   []Row
+	sorter *Sorter
+	n      int
+}
+
+func (t *topRows) Len() int { return len(t.rows) }
+
+func (t *topRows) Less(i, j int) bool {
+	return t.sorter.sortOrder(&t.rows[i], &t.rows[j]) > 0
+}
+
+func (t *topRows) Swap(i, j int) { t.rows[i], t.rows[j] = t.rows[j], t.rows[i] }
+
+func (t *topRows) Push(x any) { t.rows = append(t.rows, x.(Row)) }
+
+func (t *topRows) Pop() any {
+	row := t.rows[len(t.rows)-1]
+	t.rows = t.rows[:len(t.rows)-1]
+	return row
+}
+
+// offer добавляет строку, если она входит в первые n. Принятая строка копируется из
+// общих блоков чтения, чтобы несколько строк не удерживали в памяти блоки целиком
+func (t *topRows) offer(row Row) {
+	if len(t.rows) < t.n {
+		heap.Push(t, t.sorter.detachRow(row))
+		return
+	}
+	if t.sorter.sortOrder(&row, &t.rows[0]) < 0 {
+		t.rows[0] = t.sorter.detachRow(row)
+		heap.Fix(t, 0)
+	}
+}
+
+// detachRow возвращает копию строки, не ссылающуюся на блоки lineArena и keySlab
+func (s *Sorter) detachRow(row Row) Row {
+	line := strings.Clone(row.Original)
+	return s.newRow(nil, line, s.extractKeys(s.keyPart(line)))
+}
+
+// compareOrder сравнивает строки в итоговом порядке вывода, то есть с учетом -r
+func (s *Sorter) compareOrder(a, b *Row) int {
+	c := s.CompareRows(a, b)
+	if s.opts.Reverse {
+		c = -c
+	}
+	return c
+}
+
+// boundedHead сообщает, можно ли при --head отбирать строки кучей уже при чтении.
+// Нельзя, если число выводимых строк зависит от удаления повторов или свертки групп,
+// или если строки сначала отбираются в выборку --sample
+func (s *Sorter) boundedHead() bool {
+	return s.opts.Head > 0 && !s.opts.Unique && !s.opts.UniqueOnly && !s.opts.Freq && !s.opts.GroupBy && s.opts.Sample == 0 && !s.opts.Shuffle
+}
+
+// headSource пропускает не больше n строк
+type headSource struct {
+	src rowSource
+	n   int
+}
+
+func (h *headSource) next() (Row, bool, error) {
+	if h.n <= 0 {
+		return Row{}, false, nil
+	}
+	h.n--
+	return h.src.next()
+}
+
+// writeHeader выводит пропущенные через --skip начальные строки без изменений
+func writeHeader(w io.Writer, header []string, eol eolStyle) error {
+	if len(header) == 0 {
+		return nil
+	}
+	bw := bufio.NewWriter(w)
+	for _, line := range header {
+		bw.WriteString(eol.text(line))
+		bw.WriteString(eol.between())
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,165 @@
+package l2sort
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// indexHeader - первая строка файла индекса --index
+const indexHeader = "# l2sort index v1"
+
+// indexWriter при записи результата составляет индекс --index: для каждой строки, с которой
+// начинается новое значение первого ключа, - смещение строки в байтах и сам ключ. При
+// stride записи идут не чаще, чем раз в stride байт результата
+type indexWriter struct {
+	sorter *Sorter
+	file   *os.File
+	bw     *bufio.Writer
+	// base - смещение первой строки данных (после метки порядка байт и заголовка)
+	base   int64
+	stride int64
+	// prev - первый ключ предыдущей строки, last - смещение последней записи индекса
+	prev    Row
+	started bool
+	last    int64
+}
+
+func (s *Sorter) createIndex(path string, base int64) (*indexWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	x := &indexWriter{sorter: s, file: file, bw: bufio.NewWriter(file), base: base, stride: s.indexStride}
+	x.bw.WriteString(indexHeader + "\n")
+	return x, nil
+}
+
+// writeIndexed записывает строки результата и рядом с ним индекс --index
+func (s *Sorter) writeIndexed(w io.Writer, src rowSource, in *inputData) (err error) {
+	base := int64(len(in.enc.bom))
+	for _, line := range in.header {
+		base += int64(len(in.eol.text(line)) + len(in.eol.between()))
+	}
+	x, err := s.createIndex(s.opts.Index, base)
+	if err != nil {
+		return fmt.Errorf("при создании индекса: %w", err)
+	}
+	defer func() {
+		if cerr := x.close(err != nil); err == nil && cerr != nil {
+			err = fmt.Errorf("при записи индекса: %w", cerr)
+		}
+	}()
+	return writeRowsIndexed(w, src, s.format, in.eol, x)
+}
+
+// add учитывает строку row, которая начинается со смещения pos от начала строк данных
+func (x *indexWriter) add(row *Row, pos int64) error {
+	key := Row{Keys: row.Keys[:min(len(row.Keys), 1)], data: row.data}
+	if x.started && x.sorter.compareOrder(&key, &x.prev) == 0 {
+		return nil
+	}
+	first := !x.started
+	x.prev, x.started = key, true
+	offset := x.base + pos
+	if !first && x.stride > 0 && offset-x.last < x.stride {
+		return nil
+	}
+	x.last = offset
+	text := ""
+	if len(key.Keys) > 0 {
+		text = key.keyText(0)
+	}
+	_, err := fmt.Fprintf(x.bw, "%d\t%s\n", offset, strconv.Quote(text))
+	return err
+}
+
+// close дописывает индекс; при ошибке записи результата индекс удаляется
+func (x *indexWriter) close(failed bool) error {
+	err := x.bw.Flush()
+	if cerr := x.file.Close(); err == nil {
+		err = cerr
+	}
+	if failed || err != nil {
+		os.Remove(x.file.Name())
+	}
+	return err
+}
+
+// IndexEntry - запись индекса: смещение строки в результате и ее первый ключ
+type IndexEntry struct {
+	Offset int64
+	Key    string
+}
+
+// Index - индекс, прочитанный ReadIndex; записи упорядочены по смещению
+type Index struct {
+	Entries []IndexEntry
+}
+
+// ReadIndex читает файл индекса, записанный с --index
+func ReadIndex(path string) (*Index, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := newLineScanner(file, 0)
+	if !scanner.Scan() || scanner.Text() != indexHeader {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s не является индексом l2sort", path)
+	}
+	x := &Index{}
+	for lineNum := 2; scanner.Scan(); lineNum++ {
+		offset, key, ok := strings.Cut(scanner.Text(), "\t")
+		var entry IndexEntry
+		var err error
+		if ok {
+			if entry.Offset, err = strconv.ParseInt(offset, 10, 64); err == nil {
+				entry.Key, err = strconv.Unquote(key)
+			}
+		}
+		if !ok || err != nil {
+			return nil, fmt.Errorf("%s, строка %d: некорректная запись индекса", path, lineNum)
+		}
+		x.Entries = append(x.Entries, entry)
+	}
+	return x, scanner.Err()
+}
+
+// IndexRange возвращает по индексу x участок результата [start, end), в котором лежат все
+// строки с первым ключом от from до to включительно в порядке сортировки s (тех же флагов,
+// с которыми записан результат). end равен -1, если участок доходит до конца файла.
+// Участок может захватывать соседние строки, поэтому при чтении ключи нужно проверять
+func (s *Sorter) IndexRange(x *Index, from, to string) (start, end int64) {
+	if len(x.Entries) == 0 {
+		return 0, -1
+	}
+	keyRow := func(text string) Row { return s.newRow(nil, "", []string{text}) }
+	lo, hi := keyRow(from), keyRow(to)
+	compareEntry := func(i int, probe *Row) int {
+		row := keyRow(x.Entries[i].Key)
+		return s.compareOrder(&row, probe)
+	}
+
+	// Строки с ключом from начинаются с записи, равной from, а если ее нет (индекс
+	// разреженный) - с последней записи меньше from
+	i := sort.Search(len(x.Entries), func(i int) bool { return compareEntry(i, &lo) >= 0 })
+	switch {
+	case i < len(x.Entries) && compareEntry(i, &lo) == 0, i == 0:
+		start = x.Entries[i].Offset
+	default:
+		start = x.Entries[i-1].Offset
+	}
+	j := sort.Search(len(x.Entries), func(j int) bool { return compareEntry(j, &hi) > 0 })
+	if j == len(x.Entries) {
+		return start, -1
+	}
+	return start, x.Entries[j].Offset
+}
@@ -0,0 +1,169 @@
+package l2sort
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// inferSampleLines - сколько первых строк входа просматривает --infer-types
+const inferSampleLines = 1000
+
+// Классы значений ключа, которые различает --infer-types, и типы сравнения для них.
+// Целые и дробные вместе сравниваются как числа и смешанными не считаются
+var inferClasses = []struct {
+	name, kind string
+}{
+	{"целые", typeNumeric},
+	{"дробные", typeNumeric},
+	{"даты", typeTime},
+	{"месяцы", typeMonth},
+	{"версии", typeNatural},
+	{"строки", typeText},
+}
+
+// versionPattern - номер версии из нескольких чисел через точку: 1.2.3, v2.10, 1.0.0-rc1
+var versionPattern = regexp.MustCompile(`^[vV]?\d+(\.\d+)+([-+~.]?[0-9A-Za-z][0-9A-Za-z.+~-]*)?$`)
+
+// checkInferTypes отклоняет --infer-types вместе с общими флагами типа: они уже задают
+// тип всех ключей без модификатора
+func checkInferTypes(o Options) error {
+	if !o.InferTypes {
+		return nil
+	}
+	if flags := globalTypeFlags(o); len(flags) > 0 {
+		return fmt.Errorf("в параметрах: --infer-types определяет типы ключей сам и несовместим с %s; тип отдельного ключа задается в -k, например -k 2n", flags[0].flag)
+	}
+	if o.Compat != "" || o.RecordSize > 0 {
+		return fmt.Errorf("в параметрах: --infer-types несовместим с --compat и --record-size")
+	}
+	return nil
+}
+
+// inferReader при --infer-types читает первые строки входа, определяет по ним типы
+// ключей и затем отдает все строки дальше без изменений
+type inferReader struct {
+	lines    lineReader
+	sorter   *Sorter
+	queue    []string
+	text     string
+	inferred bool
+}
+
+func (r *inferReader) Scan() bool {
+	if !r.inferred {
+		r.inferred = true
+		for len(r.queue) < inferSampleLines && r.lines.Scan() {
+			r.queue = append(r.queue, r.lines.Text())
+		}
+		r.sorter.inferKeyKinds(r.queue)
+	}
+	if len(r.queue) > 0 {
+		r.text, r.queue = r.queue[0], r.queue[1:]
+		return true
+	}
+	if !r.lines.Scan() {
+		return false
+	}
+	r.text = r.lines.Text()
+	return true
+}
+
+func (r *inferReader) Text() string { return r.text }
+
+func (r *inferReader) Err() error { return r.lines.Err() }
+
+// inferKeyKinds определяет по строкам lines тип сравнения каждого ключа без модификатора
+// типа: ключ получает тип самого частого класса значений, а если классов несколько,
+// выводится предупреждение. Пустые ключи не учитываются; ключ без значений в выборке
+// сравнивается как текст. Строки --skip, комментарии и не прошедшие фильтр пропускаются
+func (s *Sorter) inferKeyKinds(lines []string) {
+	var counts [][]int
+	for n, line := range lines {
+		if n < s.opts.Skip || s.isComment(line) || s.keep != nil && !s.keep(line) {
+			continue
+		}
+		for i, text := range s.extractKeys(s.keyPart(line)) {
+			if i < len(s.opts.Keys) && s.opts.Keys[i].Type != "" {
+				continue
+			}
+			class := s.classifyKey(s.normalizeKey(s.transformKey(i, text)))
+			if class < 0 {
+				continue
+			}
+			for len(counts) <= i {
+				counts = append(counts, make([]int, len(inferClasses)))
+			}
+			counts[i][class]++
+		}
+	}
+
+	kinds := make([]keyKind, max(len(s.opts.Keys), len(counts)))
+	for i := range kinds {
+		if i < len(s.opts.Keys) && s.opts.Keys[i].Type != "" {
+			kinds[i] = s.keyKinds[i]
+			continue
+		}
+		kinds[i] = s.defaultKind
+		if i >= len(counts) {
+			continue
+		}
+		byKind := make(map[string]int)
+		var found []string
+		best := ""
+		for class, n := range counts[i] {
+			if n == 0 {
+				continue
+			}
+			kind := inferClasses[class].kind
+			byKind[kind] += n
+			if best == "" || byKind[kind] > byKind[best] {
+				best = kind
+			}
+			found = append(found, fmt.Sprintf("%s: %d", inferClasses[class].name, n))
+		}
+		if best == "" {
+			continue
+		}
+		kinds[i] = keyKind{name: best}
+		if best == typeNumeric {
+			kinds[i].epsilon = s.opts.Epsilon
+		}
+		if len(byKind) > 1 {
+			warnf("--infer-types: в ключе %d значения разных типов (%s), выбран тип %s; остальные значения идут раньше разобранных", i+1, strings.Join(found, ", "), best)
+		}
+		if s.opts.Debug {
+			fmt.Fprintf(os.Stderr, "--infer-types: ключ %d - %s\n", i+1, best)
+		}
+	}
+	s.keyKinds = kinds
+}
+
+// classifyKey возвращает номер класса значения ключа в inferClasses или -1 для пустого
+// ключа. Число разбирается по правилам -n с учетом --numeric-locale и --radix, дата -
+// по форматам --time и --time-format
+func (s *Sorter) classifyKey(text string) int {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return -1
+	}
+	var key Key
+	s.parseNumericKey(&key, text)
+	switch key.tag {
+	case valueInt, valueOverflow:
+		return 0
+	case valueFloat:
+		return 1
+	}
+	if _, ok := parseTimeKey(s.layouts, text); ok {
+		return 2
+	}
+	if month := s.parseKey(keyKind{name: typeMonth}, text); month.month() != 0 {
+		return 3
+	}
+	if versionPattern.MatchString(text) {
+		return 4
+	}
+	return 5
+}
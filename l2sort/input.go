@@ -0,0 +1,260 @@
+package l2sort
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// Сигнатуры сжатых форматов
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// readCloser объединяет распаковывающий поток с закрытием исходного файла
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r readCloser) Close() error { return r.close() }
+
+// openInput открывает входной файл и прозрачно распаковывает gzip, bzip2 и zstd.
+// Формат определяется по сигнатуре в начале файла, а не только по расширению,
+// поэтому переименованные архивы тоже читаются
+func openInput(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(file)
+	magic, _ := br.Peek(4)
+
+	switch compressionFormat(magic) {
+	case "gzip":
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return readCloser{zr, func() error {
+			zr.Close()
+			return file.Close()
+		}}, nil
+	case "bzip2":
+		return readCloser{bzip2.NewReader(br), file.Close}, nil
+	case "zstd":
+		return readCloser{newZstdReader(br), file.Close}, nil
+	}
+	return readCloser{br, file.Close}, nil
+}
+
+// compressionFormat определяет по первым байтам данных формат сжатия: gzip, bzip2, zstd
+// или пустую строку для несжатых данных
+func compressionFormat(magic []byte) string {
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return "gzip"
+	case len(magic) >= 4 && bytes.HasPrefix(magic, bzip2Magic) && magic[3] >= '1' && magic[3] <= '9':
+		return "bzip2"
+	case bytes.HasPrefix(magic, zstdMagic):
+		return "zstd"
+	}
+	return ""
+}
+
+// fileCompression возвращает формат сжатия существующего файла path или пустую строку,
+// если файл не сжат или его нет
+func fileCompression(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	magic := make([]byte, 4)
+	n, _ := io.ReadFull(file, magic)
+	return compressionFormat(magic[:n])
+}
+
+// isCompressedName сообщает, следует ли сжимать вывод в файл с таким именем
+func isCompressedName(path string) bool {
+	return strings.HasSuffix(path, ".gz")
+}
+
+// newLineScanner создает сканер строк без ограничения стандартного bufio.Scanner в 64 КБ.
+// maxLine > 0 задает предельную длину строки, 0 означает отсутствие ограничения
+func newLineScanner(r io.Reader, maxLine int64) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	limit := math.MaxInt
+	if maxLine > 0 && maxLine < int64(limit) {
+		limit = int(maxLine)
+	}
+	scanner.Buffer(make([]byte, 0, 64*1024), limit)
+	return scanner
+}
+
+// eolStyle описывает окончания строк, которые нужно воспроизвести при выводе
+type eolStyle struct {
+	sep   string // "\n" или "\r\n"
+	final bool   // завершена ли последняя строка
+	// multiline означает, что строка результата может состоять из нескольких строк входа
+	// (записи --record-sep, строки с комментариями --comments=keep)
+	multiline bool
+	// records означает, что выводятся записи --record-sep, которые разделяются строкой recordSep
+	records   bool
+	recordSep string
+}
+
+// between возвращает то, что выводится между двумя строками (или записями)
+func (e eolStyle) between() string {
+	if e.records {
+		return e.sep + e.recordSep + e.sep
+	}
+	return e.sep
+}
+
+// text подготавливает строку к выводу: строки внутри записи получают окончания входа
+func (e eolStyle) text(line string) string {
+	if e.multiline && e.sep != "\n" {
+		return strings.ReplaceAll(line, "\n", e.sep)
+	}
+	return line
+}
+
+// eolCounter - функция разбиения для bufio.Scanner, которая, в отличие от bufio.ScanLines,
+// запоминает, какие окончания встречались и завершена ли последняя строка
+type eolCounter struct {
+	lf, crlf   int
+	unfinished bool
+}
+
+func (c *eolCounter) split(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		if i > 0 && data[i-1] == '\r' {
+			c.crlf++
+			return i + 1, data[:i-1], nil
+		}
+		c.lf++
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		c.unfinished = true
+		return len(data), bytes.TrimSuffix(data, []byte{'\r'}), nil
+	}
+	return 0, nil, nil
+}
+
+// style возвращает преобладающее окончание строк входа
+func (c *eolCounter) style() eolStyle {
+	st := eolStyle{sep: "\n", final: !c.unfinished}
+	if c.crlf > c.lf {
+		st.sep = "\r\n"
+	}
+	return st
+}
+
+// inputChain читает входные файлы один за другим как единый поток, открывая следующий
+// файл только после закрытия предыдущего, поэтому число входов не ограничено числом
+// дескрипторов. Каждый файл распаковывается и декодируется отдельно; кодировка вывода
+// берется из первого файла. Если файл (кроме последнего) не оканчивается переводом
+// строки, он добавляется, чтобы последняя строка не склеилась с первой строкой следующего
+type inputChain struct {
+	sorter   *Sorter
+	paths    []string
+	progress *progressReporter
+	enc      *textEncoding
+	cur      io.Reader
+	file     io.Closer
+}
+
+// openNext открывает следующий файл из paths
+func (c *inputChain) openNext() error {
+	file, err := openInput(c.paths[0])
+	if err != nil {
+		return err
+	}
+	c.paths = c.paths[1:]
+	var raw io.Reader = file
+	if c.progress != nil {
+		raw = countingReader{r: file, p: c.progress}
+	}
+	reader, enc := detectEncoding(raw, c.sorter.encoding)
+	if c.enc == nil {
+		c.enc = enc
+	}
+	if len(c.paths) > 0 {
+		reader = &terminatedReader{r: reader}
+	}
+	c.cur, c.file = reader, file
+	return nil
+}
+
+func (c *inputChain) Read(p []byte) (int, error) {
+	for c.cur != nil {
+		n, err := c.cur.Read(p)
+		if err != io.EOF {
+			return n, err
+		}
+		c.Close()
+		if len(c.paths) > 0 {
+			if err := c.openNext(); err != nil {
+				return n, err
+			}
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+	return 0, io.EOF
+}
+
+func (c *inputChain) Close() error {
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.cur, c.file = nil, nil
+	return err
+}
+
+// terminatedReader дописывает '\n' в конец непустого потока, если его там нет
+type terminatedReader struct {
+	r      io.Reader
+	seen   bool
+	last   byte
+	eof    bool
+	needLF bool
+}
+
+func (t *terminatedReader) Read(p []byte) (int, error) {
+	if t.eof {
+		if !t.needLF || len(p) == 0 {
+			return 0, io.EOF
+		}
+		t.needLF = false
+		p[0] = '\n'
+		return 1, nil
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		t.seen, t.last = true, p[n-1]
+	}
+	if err == io.EOF {
+		t.eof, t.needLF = true, t.seen && t.last != '\n'
+		if n == 0 {
+			return t.Read(p)
+		}
+		err = nil
+	}
+	return n, err
+}
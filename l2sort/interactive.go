@@ -0,0 +1,241 @@
+package l2sort
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// interactiveSampleLines - по скольким первым строкам файла строится предпросмотр
+// --interactive; файл покороче показывается точно
+const interactiveSampleLines = 10000
+
+// interactiveHelp - команды режима --interactive
+const interactiveHelp = `Команды (ввод - Enter):
+  n, r, u, b       переключить -n, -r, -u, -b
+  k СПЕЦ...        задать ключи, например k 2n или k 3 1r; k без аргументов - без ключей
+  t СИМВОЛ         разделитель полей, например t , или t \t; t без аргумента - пробелы
+  f ФЛАГИ          добавить флаги сортировки, например f --natural
+  l N              показывать N строк
+  w [ФАЙЛ]         отсортировать весь файл с текущими флагами и записать (по умолчанию на место)
+  q                выйти без записи
+  ?                эта справка`
+
+// runInteractive показывает первые lines строк отсортированного path и позволяет
+// переключать флаги сортировки командами из стандартного ввода, сразу обновляя
+// предпросмотр. Файл не меняется, пока не введена команда w: она сортирует весь файл
+// с выбранными флагами. Предпросмотр строится по первым interactiveSampleLines строкам.
+// Возвращает код завершения
+func runInteractive(ctx context.Context, path string, base Options, lines int) int {
+	if lines <= 0 {
+		return reportUsage(fmt.Errorf("в параметре --interactive-lines: число строк должно быть положительным"))
+	}
+	if _, err := NewSorter(base); err != nil {
+		return reportUsage(err)
+	}
+	sample, truncated, err := writeInteractiveSample(base.TempDir, path)
+	if err != nil {
+		return reportError(fmt.Errorf("при чтении файла: %w", err))
+	}
+	defer temps.remove(sample)
+
+	o := base.clone()
+	status := ""
+	commands := bufio.NewScanner(os.Stdin)
+	for {
+		if err := ctx.Err(); err != nil {
+			return reportError(err)
+		}
+		showInteractive(ctx, os.Stdout, o, sample, truncated, lines, status)
+		fmt.Print("> ")
+		if !commands.Scan() {
+			fmt.Println()
+			return 0
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimSpace(commands.Text()), " ")
+		arg = strings.TrimSpace(arg)
+		next := o.clone()
+		status = ""
+		switch cmd {
+		case "":
+			continue
+		case "q":
+			return 0
+		case "?":
+			status = interactiveHelp
+			continue
+		case "w":
+			output := path
+			if arg != "" {
+				output = arg
+			}
+			return runSortFiles(ctx, o, []string{path}, output)
+		case "n":
+			next.Numeric = !next.Numeric
+		case "r":
+			next.Reverse = !next.Reverse
+		case "u":
+			next.Unique = !next.Unique
+		case "b":
+			next.IgnoreBlanks = !next.IgnoreBlanks
+		case "k":
+			next.Keys, next.WholeLineKey = nil, false
+			keys := &keySpecFlag{specs: &next.Keys, whole: &next.WholeLineKey}
+			for spec := range strings.FieldsSeq(arg) {
+				if err := keys.Set(spec); err != nil {
+					status = fmt.Sprintf("Ошибка в ключе %s: %v", spec, err)
+					break
+				}
+			}
+		case "t":
+			next.FieldRegex, next.TSV = "", arg == `\t`
+			if arg != "" && !next.TSV {
+				next.FieldRegex = regexp.QuoteMeta(arg)
+			}
+		case "f":
+			fs := flag.NewFlagSet("interactive", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			next.registerFlags(fs)
+			if err := fs.Parse(strings.Fields(arg)); err != nil {
+				status = "Ошибка в флагах: " + err.Error()
+			} else if fs.NArg() > 0 {
+				status = fmt.Sprintf("Ошибка в флагах: лишние аргументы %v", fs.Args())
+			}
+		case "l":
+			n, err := strconv.Atoi(arg)
+			if err != nil || n <= 0 {
+				status = "Ошибка: ожидалось положительное число строк"
+				break
+			}
+			lines = n
+		default:
+			status = fmt.Sprintf("Неизвестная команда %q, справка - ?", cmd)
+		}
+		if status != "" {
+			continue
+		}
+		if _, err := NewSorter(next); err != nil {
+			status = "Ошибка " + err.Error()
+			continue
+		}
+		o = next
+	}
+}
+
+// writeInteractiveSample копирует первые interactiveSampleLines строк path во временный
+// файл без изменений и сообщает, были ли в path еще строки
+func writeInteractiveSample(tempDir, path string) (string, bool, error) {
+	input, err := openInput(path)
+	if err != nil {
+		return "", false, err
+	}
+	defer input.Close()
+	file, err := temps.create(tempDir, "interactive")
+	if err != nil {
+		return "", false, err
+	}
+	defer file.Close()
+	r := bufio.NewReader(input)
+	w := bufio.NewWriter(file)
+	truncated := false
+	for n := 0; ; n++ {
+		line, err := r.ReadString('\n')
+		if line != "" && n == interactiveSampleLines {
+			truncated = true
+			break
+		}
+		w.WriteString(line)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			temps.remove(file.Name())
+			return "", false, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		temps.remove(file.Name())
+		return "", false, err
+	}
+	return file.Name(), truncated, file.Close()
+}
+
+// showInteractive выводит экран предпросмотра: текущие флаги, первые lines строк
+// отсортированной выборки и сообщение status. В терминале экран перед этим очищается
+func showInteractive(ctx context.Context, w io.Writer, o Options, sample string, truncated bool, lines int, status string) {
+	if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprint(w, "\x1b[H\x1b[2J")
+	}
+	fmt.Fprintf(w, "Флаги: %s\n", interactiveFlags(o))
+	if truncated {
+		fmt.Fprintf(w, "Предпросмотр по первым %d строкам файла\n", interactiveSampleLines)
+	}
+	fmt.Fprintln(w, strings.Repeat("-", 40))
+	if err := previewSorted(ctx, w, o, sample, lines); err != nil {
+		fmt.Fprintf(w, "Ошибка %v\n", err)
+	}
+	fmt.Fprintln(w, strings.Repeat("-", 40))
+	if status != "" {
+		fmt.Fprintln(w, status)
+	}
+}
+
+// previewSorted выводит первые lines строк sample, отсортированного по o
+func previewSorted(ctx context.Context, w io.Writer, o Options, sample string, lines int) error {
+	s, err := NewSorter(o)
+	if err != nil {
+		return err
+	}
+	in, err := s.readRows(ctx, []string{sample}, newMemBudget(0))
+	if err != nil {
+		return fmt.Errorf("при чтении файла: %w", err)
+	}
+	defer in.close()
+	src, err := s.sortedSource(ctx, in, nil)
+	if err != nil {
+		return err
+	}
+	for range lines {
+		row, ok, err := src.next()
+		if err != nil || !ok {
+			return err
+		}
+		line := row.Original
+		if s.format != nil {
+			if line, err = s.format(&row); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintln(w, line)
+	}
+	return nil
+}
+
+// interactiveFlags описывает флаги, которые переключает --interactive
+func interactiveFlags(o Options) string {
+	var flags []string
+	for _, f := range []struct {
+		on   bool
+		flag string
+	}{{o.Numeric, "-n"}, {o.Reverse, "-r"}, {o.Unique, "-u"}, {o.IgnoreBlanks, "-b"}, {o.TSV, "--tsv"}} {
+		if f.on {
+			flags = append(flags, f.flag)
+		}
+	}
+	for _, spec := range o.Keys {
+		flags = append(flags, "-k "+spec.String())
+	}
+	if o.FieldRegex != "" {
+		flags = append(flags, "--field-regex "+strconv.Quote(o.FieldRegex))
+	}
+	if len(flags) == 0 {
+		return "(по умолчанию)"
+	}
+	return strings.Join(flags, " ")
+}
@@ -0,0 +1,276 @@
+package l2sort
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Виды соединения для подкоманды join
+const (
+	joinInner = "inner"
+	joinLeft  = "left"
+	joinRight = "right"
+	joinOuter = "outer"
+)
+
+// runJoin выполняет подкоманду
+//
+//	l2sort join [--type inner|left|right|outer] [--flags '-k 1'] [-o вывод] [--presorted] ФАЙЛ1 ФАЙЛ2
+//
+// Оба входа упорядочиваются по ключам, настроенным флагами из --flags поверх общих (без
+// ключа - по первому полю), и соединяются слиянием, как утилитой join: для строк с равными
+// ключами выводится ключ, затем остальные поля строки первого файла и остальные поля строки
+// второго. Возвращает код завершения
+func runJoin(ctx context.Context, args []string, base Options) int {
+	fs := flag.NewFlagSet("join", flag.ContinueOnError)
+	kind := fs.String("type", joinInner, "Вид соединения: inner, left (и непарные строки первого файла), right (второго), outer (обоих)")
+	flags := fs.String("flags", "", "Флаги сортировки, задающие ключ соединения, например '-k 2 -n' (по умолчанию ключ - первое поле, -k 0 - вся строка)")
+	output := fs.String("o", "-", "Файл результата (\"-\" - стандартный вывод)")
+	presorted := fs.Bool("presorted", false, "Входы уже отсортированы: только проверить порядок, не сортируя")
+	usage := func() {
+		fmt.Println("Использование: l2sort join [опции] файл1 файл2")
+		fs.PrintDefaults()
+	}
+	fs.Usage = usage
+
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(files) != 2 {
+		usage()
+		return exitUsage
+	}
+	switch *kind {
+	case joinInner, joinLeft, joinRight, joinOuter:
+	default:
+		return reportUsage(fmt.Errorf("в параметре --type: неизвестный вид соединения %q", *kind))
+	}
+	sorter, err := sorterWithFlags(base, *flags)
+	if err != nil {
+		return reportUsage(err)
+	}
+	// Как и join(1), без ключа соединение идет по первому полю
+	if !sorter.hasJoinKey() {
+		if sorter, err = sorterWithFlags(base, "-k 1 "+*flags); err != nil {
+			return reportUsage(err)
+		}
+	}
+	if err := sorter.JoinFiles(ctx, files[0], files[1], *output, *kind, *presorted); err != nil {
+		return reportError(err)
+	}
+	return 0
+}
+
+// JoinFiles соединяет left и right по ключам и записывает результат в output.
+// Ограничение -S делится между входами поровну
+func (s *Sorter) JoinFiles(ctx context.Context, left, right, output, kind string, presorted bool) error {
+	sources, in, err := s.sortedInputs(ctx, []string{left, right}, presorted)
+	defer closeInputs(in)
+	if err != nil {
+		return err
+	}
+	var src rowSource = &joinSource{
+		sorter:    s,
+		left:      peekSource{src: sources[0]},
+		right:     peekSource{src: sources[1]},
+		keepLeft:  kind == joinLeft || kind == joinOuter,
+		keepRight: kind == joinRight || kind == joinOuter,
+	}
+	src = &ctxSource{ctx: ctx, src: src}
+	return s.writeOutputs(output, nil, func(w io.Writer) error {
+		return writeRows(in[0].enc.encoder(w), src, s.format, in[0].eol)
+	})
+}
+
+// sortedInputs читает входы paths и возвращает их упорядоченные потоки. Ограничение -S
+// делится между входами поровну. При presorted (--presorted, подкоманда merge) входы не сортируются, а только
+// проверяются. Прочитанные входы нужно закрыть closeInputs, даже если вернулась ошибка
+func (s *Sorter) sortedInputs(ctx context.Context, paths []string, presorted bool) ([]rowSource, []*inputData, error) {
+	limit := s.limit / int64(len(paths))
+	var sources []rowSource
+	var inputs []*inputData
+	for _, path := range paths {
+		in, err := s.readRows(ctx, []string{path}, newMemBudget(limit))
+		if err != nil {
+			return sources, inputs, fmt.Errorf("при чтении файла %s: %w", path, err)
+		}
+		inputs = append(inputs, in)
+		if presorted && !in.sorted {
+			return sources, inputs, fmt.Errorf("файл %s не отсортирован по ключу", path)
+		}
+		var src rowSource
+		if presorted && len(in.runs) == 0 {
+			src = &sliceSource{rows: in.rows}
+		} else if src, err = s.sortedSource(ctx, in, nil); err != nil {
+			return sources, inputs, err
+		}
+		sources = append(sources, src)
+	}
+	return sources, inputs, nil
+}
+
+func closeInputs(inputs []*inputData) {
+	for _, in := range inputs {
+		in.close()
+	}
+}
+
+// peekSource позволяет заглянуть в следующую строку потока, не забирая ее
+type peekSource struct {
+	src    rowSource
+	row    Row
+	ok     bool
+	loaded bool
+}
+
+func (p *peekSource) peek() (*Row, bool, error) {
+	if !p.loaded {
+		row, ok, err := p.src.next()
+		if err != nil {
+			return nil, false, err
+		}
+		p.row, p.ok, p.loaded = row, ok, true
+	}
+	return &p.row, p.ok, nil
+}
+
+// group забирает из потока все строки, равные по ключам первой (с допуском --epsilon)
+func (p *peekSource) group(s *Sorter) ([]Row, error) {
+	first, ok, err := p.peek()
+	if err != nil || !ok {
+		return nil, err
+	}
+	rows := []Row{*first}
+	p.loaded = false
+	for {
+		row, ok, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if !ok || !s.sameGroup(&rows[0], row) {
+			return rows, nil
+		}
+		rows = append(rows, *row)
+		p.loaded = false
+	}
+}
+
+// joinSource сливает два упорядоченных потока и выдает соединенные строки
+type joinSource struct {
+	sorter    *Sorter
+	left      peekSource
+	right     peekSource
+	keepLeft  bool
+	keepRight bool
+	pending   []Row
+}
+
+func (j *joinSource) next() (Row, bool, error) {
+	for len(j.pending) == 0 {
+		l, lok, err := j.left.peek()
+		if err != nil {
+			return Row{}, false, err
+		}
+		r, rok, err := j.right.peek()
+		if err != nil {
+			return Row{}, false, err
+		}
+		if !lok && !rok {
+			return Row{}, false, nil
+		}
+		c := 0
+		switch {
+		case !rok:
+			c = -1
+		case !lok:
+			c = 1
+		default:
+			c = j.sorter.compareOrder(l, r)
+		}
+
+		switch {
+		case c < 0:
+			rows, err := j.left.group(j.sorter)
+			if err != nil {
+				return Row{}, false, err
+			}
+			if j.keepLeft {
+				for i := range rows {
+					j.pending = append(j.pending, Row{Original: j.sorter.joinLine(&rows[i], nil)})
+				}
+			}
+		case c > 0:
+			rows, err := j.right.group(j.sorter)
+			if err != nil {
+				return Row{}, false, err
+			}
+			if j.keepRight {
+				for i := range rows {
+					j.pending = append(j.pending, Row{Original: j.sorter.joinLine(nil, &rows[i])})
+				}
+			}
+		default:
+			lrows, err := j.left.group(j.sorter)
+			if err != nil {
+				return Row{}, false, err
+			}
+			rrows, err := j.right.group(j.sorter)
+			if err != nil {
+				return Row{}, false, err
+			}
+			for a := range lrows {
+				for b := range rrows {
+					j.pending = append(j.pending, Row{Original: j.sorter.joinLine(&lrows[a], &rrows[b])})
+				}
+			}
+		}
+	}
+	row := j.pending[0]
+	j.pending = j.pending[1:]
+	return row, true, nil
+}
+
+// hasJoinKey сообщает, что ключ соединения задан явно: -k, --key-regex или --expr
+func (s *Sorter) hasJoinKey() bool {
+	return len(s.opts.Keys) > 0 || s.opts.WholeLineKey || s.keyRegex != nil || s.expr != nil
+}
+
+// joinLine собирает выходную строку соединения; a или b равны nil для непарной строки.
+// При -k N ключевое поле (первого ключа) выводится первым, а из строк выводятся остальные поля.
+// При -k 0, --key-regex или --expr ключ выбран из всей строки, и строки выводятся
+// целиком через пробел
+func (s *Sorter) joinLine(a, b *Row) string {
+	if s.keyColumn() == 0 || s.keyRegex != nil {
+		var parts []string
+		for _, row := range []*Row{a, b} {
+			if row != nil {
+				parts = append(parts, row.Original)
+			}
+		}
+		return strings.Join(parts, " ")
+	}
+	var parts []string
+	for _, row := range []*Row{a, b} {
+		if row == nil {
+			continue
+		}
+		fields := s.splitFields(row.Original)
+		if len(parts) == 0 {
+			if len(row.Keys) > 0 {
+				parts = append(parts, row.keyText(0))
+			} else {
+				parts = append(parts, "")
+			}
+		}
+		for i, field := range fields {
+			if i != s.keyColumn()-1 {
+				parts = append(parts, field)
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
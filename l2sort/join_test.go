@@ -0,0 +1,96 @@
+package l2sort
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// runCommand записывает inputs во временные файлы и выполняет подкоманду run с args,
+// -o и этими файлами. Возвращает код завершения и строки результата
+func runCommand(t *testing.T, run func(context.Context, []string, Options) int, args []string, inputs ...string) (int, []string) {
+	t.Helper()
+	dir := t.TempDir()
+	output := filepath.Join(dir, "out.txt")
+	args = append(slices.Clone(args), "-o", output)
+	for i, input := range inputs {
+		path := filepath.Join(dir, fmt.Sprintf("in%d.txt", i+1))
+		if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		args = append(args, path)
+	}
+	code := run(context.Background(), args, Options{})
+	data, err := os.ReadFile(output)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	var lines []string
+	if len(data) > 0 {
+		lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+	return code, lines
+}
+
+func TestJoinDefaultKey(t *testing.T) {
+	left := "1 a\n2 b\n3 c\n"
+	right := "1 x\n3 y\n4 z\n"
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{nil, []string{"1 a x", "3 c y"}},
+		{[]string{"--flags", "-n"}, []string{"1 a x", "3 c y"}},
+		{[]string{"--type", "outer"}, []string{"1 a x", "2 b", "3 c y", "4 z"}},
+		{[]string{"--flags", "-k 2"}, nil},
+		{[]string{"--flags", "-k 0"}, nil},
+	}
+	for _, tt := range tests {
+		code, got := runCommand(t, runJoin, tt.args, left, right)
+		if code != 0 {
+			t.Fatalf("join %q: код %d", tt.args, code)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("join %q = %q, ожидалось %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+// TestJoin проверяет виды соединения, повторяющиеся ключи и выбор ключевого поля
+func TestJoin(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		left, right string
+		code        int
+		want        []string
+	}{
+		{"inner", nil, "b 2\na 1\nc 3\n", "c z\na x\n", 0, []string{"a 1 x", "c 3 z"}},
+		{"left", []string{"--type", "left"}, "a 1\nb 2\n", "a x\nc z\n", 0, []string{"a 1 x", "b 2"}},
+		{"right", []string{"--type", "right"}, "a 1\nb 2\n", "a x\nc z\n", 0, []string{"a 1 x", "c z"}},
+		{"outer", []string{"--type", "outer"}, "a 1\nb 2\n", "a x\nc z\n", 0, []string{"a 1 x", "b 2", "c z"}},
+		{"повторы ключей", nil, "a 1\na 2\nb 3\n", "a x\na y\n", 0, []string{"a 1 x", "a 1 y", "a 2 x", "a 2 y"}},
+		{"ключ во втором поле", []string{"--flags", "-k 2"}, "1 a\n2 b\n", "x b\ny a\n", 0, []string{"a 1 y", "b 2 x"}},
+		{"числовой ключ", []string{"--flags", "-n"}, "10 a\n9 b\n", "9 x\n10 y\n", 0, []string{"9 b x", "10 a y"}},
+		{"ключ - вся строка", []string{"--flags", "-k 0"}, "a\nb\n", "b\nc\n", 0, []string{"b b"}},
+		{"пустой вход", []string{"--type", "outer"}, "", "a x\n", 0, []string{"a x"}},
+		{"presorted", []string{"--presorted"}, "a 1\nc 3\n", "a x\nc z\n", 0, []string{"a 1 x", "c 3 z"}},
+		{"presorted с неупорядоченным входом", []string{"--presorted"}, "c 3\na 1\n", "a x\n", exitData, nil},
+		{"неизвестный вид", []string{"--type", "cross"}, "a\n", "a\n", exitUsage, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, got := runCommand(t, runJoin, tt.args, tt.left, tt.right)
+			if code != tt.code {
+				t.Fatalf("код %d, ожидался %d", code, tt.code)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("получено %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,361 @@
+package l2sort
+
+import (
+	"bytes"
+	"cmp"
+	"math"
+	"net"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Key представляет ключ сортировки вместе с заранее разобранным значением его типа.
+// Разбор выполняется один раз при чтении, а Less только сравнивает готовые значения.
+// Текст ключа задан границами в данных строки (Row.keyText). Ключ хранит одно значение:
+// числа, месяц, время, длительность и хэш помещаются в num, остальные (адреса, части
+// email и URL, значения пользовательских типов) - в ext
+type Key struct {
+	start, end uint32
+
+	// tag - что лежит в num или ext; valueNone - ключ не разобран своим типом
+	tag valueTag
+	num uint64
+	ext any
+}
+
+// valueTag - вид значения, разобранного из ключа
+type valueTag uint8
+
+const (
+	valueNone valueTag = iota
+	// valueInt - целое int64 в num
+	valueInt
+	// valueOverflow - целое вне int64, в num ближайшая граница int64
+	valueOverflow
+	// valueFloat - float64 в num: дробное число -n или число -h
+	valueFloat
+	// valueMonth - time.Month в num
+	valueMonth
+	// valueTime - время в наносекундах Unix в num
+	valueTime
+	// valueDuration - time.Duration в num
+	valueDuration
+	// valueHash - хэш текста в num
+	valueHash
+	// valueExt - значение в ext, включая время вне диапазона наносекунд Unix
+	valueExt
+)
+
+// Границы времени, представимого в наносекундах Unix
+var (
+	minNanoTime = time.Unix(0, math.MinInt64)
+	maxNanoTime = time.Unix(0, math.MaxInt64)
+)
+
+func (k *Key) setInt(n int64, overflow bool) {
+	k.tag, k.num = valueInt, uint64(n)
+	if overflow {
+		k.tag = valueOverflow
+	}
+}
+
+// intValue возвращает целое ключа -n или :size
+func (k *Key) intValue() (int64, bool) {
+	return int64(k.num), k.tag == valueInt || k.tag == valueOverflow
+}
+
+func (k *Key) setFloat(f float64) {
+	k.tag, k.num = valueFloat, math.Float64bits(f)
+}
+
+// floatValue возвращает дробное число -n или число -h
+func (k *Key) floatValue() (float64, bool) {
+	return math.Float64frombits(k.num), k.tag == valueFloat
+}
+
+// month возвращает месяц ключа -M, 0 - не месяц
+func (k *Key) month() time.Month {
+	if k.tag != valueMonth {
+		return 0
+	}
+	return time.Month(k.num)
+}
+
+func (k *Key) setTime(t time.Time) {
+	if t.Before(minNanoTime) || t.After(maxNanoTime) {
+		k.tag, k.ext = valueExt, t
+		return
+	}
+	k.tag, k.num = valueTime, uint64(t.UnixNano())
+}
+
+// timeValue возвращает время ключа --time или :mtime
+func (k *Key) timeValue() (time.Time, bool) {
+	if k.tag == valueTime {
+		return time.Unix(0, int64(k.num)), true
+	}
+	t, ok := k.ext.(time.Time)
+	return t, ok
+}
+
+// setExt сохраняет значение, не помещающееся в num; ok ложно, если ключ не разобран
+func (k *Key) setExt(value any, ok bool) {
+	if ok {
+		k.tag, k.ext = valueExt, value
+	}
+}
+
+// parsed сообщает, что ключ разобран своим типом
+func (k *Key) parsed() bool {
+	return k.tag != valueNone
+}
+
+// newRow собирает строку line с ключами из текстов texts, разобранными по типу сравнения
+// каждого ключа. Ключи ссылаются на свой текст смещениями: текст, взятый прямо из line,
+// не копируется, а полученный преобразованиями дописывается в arena сразу за строкой.
+// Строка и ключи размещаются в arena (nil - отдельными выделениями), которая их и учитывает
+func (s *Sorter) newRow(arena *rowArena, line string, texts []string) Row {
+	row := Row{Original: line, data: line}
+	var lines *lineArena
+	var slab *keySlab
+	if arena != nil {
+		lines, slab = &arena.lines, &arena.keys
+		defer arena.charge(&row)
+	}
+	if texts == nil {
+		return row
+	}
+	row.Keys = slab.alloc(len(texts))
+	extra := s.keyBuf[:0]
+	for i, text := range texts {
+		kind := s.kindOf(i)
+		text = s.stripKeyZeros(kind, s.normalizeKey(s.transformKey(i, text)))
+		key := &row.Keys[i]
+		*key = s.parseKey(kind, text)
+		start, ok := offsetIn(line, text)
+		if !ok {
+			start = len(line) + len(extra)
+			extra = append(extra, text...)
+		}
+		key.start, key.end = uint32(start), uint32(start+len(text))
+	}
+	if len(extra) > 0 {
+		row.data = lines.extend(line, extra)
+	}
+	s.keyBuf = extra
+	return row
+}
+
+// parseKey разбирает текст ключа типом kind; для текстового ключа ничего не разбирается
+func (s *Sorter) parseKey(kind keyKind, text string) Key {
+	var key Key
+	switch kind.name {
+	case typeIP:
+		addr, err := netip.ParseAddr(text)
+		key.setExt(addr.Unmap(), err == nil)
+	case typeEmail:
+		email := parseEmailKey(text)
+		key.setExt(email, email != nil)
+	case typeURL:
+		url := parseURLKey(text)
+		key.setExt(url, url != nil)
+	case typeMAC:
+		mac := parseMACKey(text)
+		key.setExt(mac, mac != nil)
+	case typeUUID:
+		key.setExt(parseUUIDKey(text))
+	case typeNumeric:
+		s.parseNumericKey(&key, text)
+	case typeHuman:
+		if f, ok := parseHumanNumber(text); ok {
+			key.setFloat(f)
+		}
+	case typeMonth:
+		if t, err := time.Parse("January", text); err == nil {
+			key.tag, key.num = valueMonth, uint64(t.Month())
+		}
+	case typeTime:
+		if t, ok := parseTimeKey(s.layouts, text); ok {
+			key.setTime(t)
+		}
+	case typeDuration:
+		if d, ok := parseDurationKey(text); ok {
+			key.tag, key.num = valueDuration, uint64(d)
+		}
+	case typeRandom:
+		key.tag, key.num = valueHash, keyHash(s.salt, text)
+	case typeHash:
+		key.tag, key.num = valueHash, keyHash(s.hashSalt, text)
+	case typeMtime, typeSize:
+		s.parseStatKey(kind.name, &key, text)
+	case typeCompound:
+		parts := parseCompoundKey(text, cmp.Or(s.opts.CompoundSep, compoundSepDefault))
+		key.setExt(parts, parts != nil)
+	}
+	if kind.custom != nil {
+		key.setExt(kind.custom.Parse(text))
+	}
+	return key
+}
+
+// compareKeys сравнивает k-е ключи строк их типом. Ключ, не разобранный типом, идет раньше
+// разобранного; не разобранные оба и равные по значению ключи сравниваются как текст,
+// поэтому результат - строгий слабый порядок при любом содержимом. Возвращает -1, 0 или 1
+func (s *Sorter) compareKeys(k int, a, b *Row) int {
+	kind := s.kindOf(k)
+	x, y := a.keyText(k), b.keyText(k)
+	if s.opts.Compat == compatGNU {
+		switch kind.name {
+		case typeText:
+			return strings.Compare(x, y)
+		case typeNumeric:
+			return compareGNUNumeric(x, y)
+		case typeHuman:
+			return compareGNUHuman(x, y)
+		case typeMonth:
+			return cmp.Compare(gnuMonth(x), gnuMonth(y))
+		}
+	}
+	if c := s.compareTyped(kind, &a.Keys[k], &b.Keys[k]); c != 0 {
+		return c
+	}
+	if kind.name == typeNatural {
+		if c := naturalCompare(x, y); c != 0 {
+			return c
+		}
+	}
+	return s.compareText(x, y)
+}
+
+// compareTyped сравнивает разобранные значения ключей; 0 - значения равны или ключи
+// не разобраны
+func (s *Sorter) compareTyped(kind keyKind, a, b *Key) int {
+	if kind.name == typeRandom || kind.name == typeHash {
+		return compareOrdered(a.num, b.num)
+	}
+	if kind.name == typeMonth {
+		return compareOrdered(a.month(), b.month())
+	}
+	if c := compareParsed(a.parsed(), b.parsed()); c != 0 || !a.parsed() {
+		return c
+	}
+	switch kind.name {
+	case typeIP:
+		return a.ext.(netip.Addr).Compare(b.ext.(netip.Addr))
+	case typeEmail, typeURL:
+		return s.compareParts(a.ext.([]string), b.ext.([]string))
+	case typeMAC:
+		return bytes.Compare(a.ext.(net.HardwareAddr), b.ext.(net.HardwareAddr))
+	case typeUUID:
+		x, y := a.ext.([16]byte), b.ext.([16]byte)
+		return bytes.Compare(x[:], y[:])
+	case typeCompound:
+		return compareCompound(a.ext.([]string), b.ext.([]string))
+	case typeNumeric:
+		return compareNumeric(a, b)
+	case typeHuman:
+		x, _ := a.floatValue()
+		y, _ := b.floatValue()
+		return compareOrdered(x, y)
+	case typeDuration, typeSize:
+		return compareOrdered(int64(a.num), int64(b.num))
+	case typeTime, typeMtime:
+		if a.tag == valueTime && b.tag == valueTime {
+			return compareOrdered(int64(a.num), int64(b.num))
+		}
+		x, _ := a.timeValue()
+		y, _ := b.timeValue()
+		return x.Compare(y)
+	}
+	if kind.custom != nil {
+		return s.compareCustom(kind.custom, a, b)
+	}
+	return 0
+}
+
+// compareText - завершающее текстовое сравнение ключей. Здесь подключаются правила
+// упорядочивания текста; при --bytes сравнение всегда побайтовое, как при LC_ALL=C.
+// Строки, равные по алфавиту --alphabet (например, с разными некорректными байтами),
+// упорядочиваются побайтово
+func (s *Sorter) compareText(a, b string) int {
+	if s.alphabet != nil && !s.opts.Bytes {
+		if c := s.alphabet.compare(a, b); c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// compareParsed упорядочивает неразобранные значения раньше разобранных
+func compareParsed(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case !a:
+		return -1
+	}
+	return 1
+}
+
+func compareOrdered[T int64 | uint64 | float64 | time.Month](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// humanSuffixes - множители суффиксов для -h
+var humanSuffixes = map[byte]float64{
+	'K': 1 << 10, 'k': 1 << 10,
+	'M': 1 << 20,
+	'G': 1 << 30,
+	'T': 1 << 40,
+	'P': 1 << 50,
+	'E': 1 << 60,
+}
+
+// parseHumanNumber разбирает число с необязательным суффиксом размера, например "2K" или "1.5G"
+func parseHumanNumber(s string) (float64, bool) {
+	multiplier := 1.0
+	if s != "" {
+		if m, ok := humanSuffixes[s[len(s)-1]]; ok {
+			multiplier = m
+			s = s[:len(s)-1]
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, false
+	}
+	return f * multiplier, true
+}
+
+// durationDays находит в длительности дни и недели, которых нет в time.ParseDuration
+var durationDays = regexp.MustCompile(`(\d+(?:\.\d*)?|\.\d+)([dw])`)
+
+// parseDurationKey разбирает длительность в формате time.ParseDuration ("1h30m", "250ms"),
+// дополнительно понимая дни (d) и недели (w): "2d", "1w3d12h"
+func parseDurationKey(s string) (time.Duration, bool) {
+	s = durationDays.ReplaceAllStringFunc(s, func(part string) string {
+		n, err := strconv.ParseFloat(part[:len(part)-1], 64)
+		if err != nil {
+			return part
+		}
+		hours := n * 24
+		if part[len(part)-1] == 'w' {
+			hours *= 7
+		}
+		return strconv.FormatFloat(hours, 'f', -1, 64) + "h"
+	})
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, false
+	}
+	return d, true
+}
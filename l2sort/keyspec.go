@@ -0,0 +1,279 @@
+package l2sort
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// keySpec - описание ключа из -k: номер колонки (с 1) и модификаторы, действующие
+// только на этот ключ. Type - тип сравнения ключа (см. typeText и соседние), пусто -
+// тип из общего флага. Transform - цепочка преобразований ключа (см. parseKeyTransforms),
+// пусто - цепочка из --key-transform. Group - номер указания -k, из которого взят ключ
+// (с 1; 0 - ключ задан не флагом): при --compat gnu пара ключей одного указания -k 2,3
+// означает один ключ от поля 2 до поля 3. End - последнее поле такого ключа, 0 - до конца строки.
+// Epsilon - допуск сравнения числового ключа, 0 - допуск из --epsilon
+type keySpec struct {
+	Column       int
+	Reverse      bool
+	IgnoreBlanks bool
+	Type         string
+	Transform    string
+	Group        int
+	End          int
+	Epsilon      float64
+}
+
+// parseKeySpec разбирает ключ вида N[модификаторы][:тип][~допуск][@преобразования],
+// например "2", "3r", "2n", "1b:time", "2n~1e-9" или "2@trim,lower". Модификаторы: r -
+// обратный порядок для этого ключа, b - без начальных пробелов в ключе, f - без учета
+// регистра (как @lower), n, h, M, V и R - тип сравнения, как -n, -h, -M, --natural и -R.
+// После двоеточия тип указывается именем: встроенным (text, numeric, time, ip и другие)
+// или зарегистрированным RegisterKeyType. После ~ - допуск числового ключа, как в
+// --epsilon. После @ - преобразования, как в --key-transform
+func parseKeySpec(raw string) (keySpec, error) {
+	head, transform, _ := strings.Cut(raw, "@")
+	head, epsilon, hasEpsilon := strings.Cut(head, "~")
+	value, typeName, _ := strings.Cut(head, ":")
+	digits := strings.TrimRightFunc(value, func(r rune) bool { return r < '0' || r > '9' })
+	n, err := strconv.Atoi(digits)
+	if err != nil || n < 0 {
+		return keySpec{}, fmt.Errorf("некорректный ключ %q: ожидался номер колонки и модификаторы, например 2r", raw)
+	}
+	spec := keySpec{Column: n, Transform: transform}
+	var letters []rune
+	for _, mod := range value[len(digits):] {
+		switch {
+		case mod == 'f':
+			spec.Transform = strings.Trim("lower,"+transform, ",")
+		case mod == 'r':
+			spec.Reverse = true
+		case mod == 'b':
+			spec.IgnoreBlanks = true
+		case typeLetters[mod] != "":
+			letters = append(letters, mod)
+		default:
+			return keySpec{}, fmt.Errorf("неизвестный модификатор %q в ключе %q", mod, raw)
+		}
+	}
+	if spec.Type, err = parseKeyType(letters, typeName); err != nil {
+		return keySpec{}, fmt.Errorf("в ключе %q: %w", raw, err)
+	}
+	if hasEpsilon {
+		spec.Epsilon, err = strconv.ParseFloat(epsilon, 64)
+		if err != nil || !(spec.Epsilon > 0) || math.IsInf(spec.Epsilon, 0) {
+			return keySpec{}, fmt.Errorf("в ключе %q: допуск после ~ должен быть положительным числом", raw)
+		}
+	}
+	return spec, nil
+}
+
+func (k keySpec) String() string {
+	s := strconv.Itoa(k.Column)
+	if k.IgnoreBlanks {
+		s += "b"
+	}
+	if k.Reverse {
+		s += "r"
+	}
+	if k.Type != "" {
+		s += ":" + k.Type
+	}
+	if k.Epsilon > 0 {
+		s += "~" + strconv.FormatFloat(k.Epsilon, 'g', -1, 64)
+	}
+	if k.Transform != "" {
+		s += "@" + k.Transform
+	}
+	return s
+}
+
+// keySpecList - ключи сортировки в порядке приоритета
+type keySpecList []keySpec
+
+func (l keySpecList) String() string {
+	parts := make([]string, len(l))
+	for i, spec := range l {
+		parts[i] = spec.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// keySpecFlag - значение флага -k. Флаг можно указать несколько раз, ключи добавляются
+// по порядку; первое указание во флагах заменяет унаследованные ключи (например, общие
+// ключи в задании пакетного режима). -k 0 означает сортировку по всей строке: строка
+// становится одним ключом, что важно для ключей с пробелами внутри, например чисел
+// "1 000,5" при --numeric-locale ru
+type keySpecFlag struct {
+	specs  *keySpecList
+	whole  *bool
+	set    bool
+	groups int
+}
+
+func (f *keySpecFlag) String() string {
+	if f.specs == nil {
+		return ""
+	}
+	return f.specs.String()
+}
+
+func (f *keySpecFlag) Set(value string) error {
+	if !f.set {
+		*f.specs, f.set = nil, true
+	}
+	// Запятые разделяют и ключи, и преобразования после @: часть, которая не начинается
+	// с цифры, продолжает цепочку преобразований предыдущего ключа
+	var parts []string
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if n := len(parts); n > 0 && strings.Contains(parts[n-1], "@") && (part == "" || part[0] < '0' || part[0] > '9') {
+			parts[n-1] += "," + part
+			continue
+		}
+		parts = append(parts, part)
+	}
+	f.groups++
+	for _, part := range parts {
+		spec, err := parseKeySpec(part)
+		if err != nil {
+			return err
+		}
+		spec.Group = f.groups
+		if spec.Column == 0 {
+			if spec.Reverse || spec.IgnoreBlanks || spec.Type != "" || spec.Transform != "" || spec.Epsilon > 0 {
+				return fmt.Errorf("ключ 0 (вся строка) не принимает модификаторы")
+			}
+			*f.specs = nil
+			if f.whole != nil {
+				*f.whole = true
+			}
+			continue
+		}
+		*f.specs = append(*f.specs, spec)
+		if f.whole != nil {
+			*f.whole = false
+		}
+	}
+	return nil
+}
+
+// keyColumn возвращает колонку первого ключа -k или 0, если ключи не заданы
+func (s *Sorter) keyColumn() int {
+	if len(s.opts.Keys) == 0 {
+		return 0
+	}
+	return s.opts.Keys[0].Column
+}
+
+// lastKeyColumn возвращает наибольшую колонку -k, 0 - без -k сравниваются все поля
+func (s *Sorter) lastKeyColumn() int {
+	last := 0
+	for _, spec := range s.opts.Keys {
+		last = max(last, spec.Column)
+	}
+	return last
+}
+
+// pickKeys выбирает из values значения по колонкам -k. Если у строки нет колонки очередного
+// ключа, ключи на нем обрываются, и такая строка идет раньше строк с полным набором ключей
+func (s *Sorter) pickKeys(values []string) []string {
+	if len(s.opts.Keys) == 0 {
+		return values
+	}
+	var keys []string
+	for _, spec := range s.opts.Keys {
+		if spec.Column > len(values) {
+			break
+		}
+		keys = append(keys, values[spec.Column-1])
+	}
+	return keys
+}
+
+// trimKeyBlanks убирает начальные пробелы и табуляции из ключей при -b (у всех ключей)
+// или модификаторе b (у отдельного ключа), как GNU sort. Нумерация полей от этого не меняется
+func (s *Sorter) trimKeyBlanks(keys []string) []string {
+	for i := range keys {
+		if s.opts.IgnoreBlanks || i < len(s.opts.Keys) && s.opts.Keys[i].IgnoreBlanks {
+			keys[i] = strings.TrimLeft(keys[i], " \t")
+		}
+	}
+	return keys
+}
+
+// splitFields делит строку на поля: по колонкам --fixed-cols, --field-regex или табуляциям
+// --tsv, если они заданы, иначе по пробельным символам. При --field-regex и --tsv пустые
+// поля сохраняются, как при -t в GNU sort, а сама строка не меняется
+func (s *Sorter) splitFields(line string) []string {
+	if s.fixedCols != nil {
+		return s.cutFixedCols(line)
+	}
+	if s.opts.TSV {
+		return splitTSV(line)
+	}
+	if s.fieldSep != nil {
+		return s.fieldSep.Split(line, -1)
+	}
+	return strings.Fields(line)
+}
+
+// ownFields сообщает, что поля задает --field-regex, --fixed-cols или --tsv, а не пробелы
+func (s *Sorter) ownFields() bool {
+	return s.fieldSep != nil || s.fixedCols != nil || s.opts.TSV
+}
+
+// Размещение строк без ключа --missing. Без флага строка с меньшим числом ключей просто
+// меньше остальных (и при -r оказывается в конце); first и last не зависят от -r
+const (
+	missingFirst = "first"
+	missingLast  = "last"
+	missingError = "error"
+)
+
+// expectedKeys возвращает, сколько ключей должно быть у строки при -k, --key-regex или --expr;
+// 0 означает, что ключом служит вся строка и отсутствующих ключей не бывает
+func (s *Sorter) expectedKeys() int {
+	switch {
+	case len(s.opts.Keys) > 0:
+		return len(s.opts.Keys)
+	case s.keyRegex != nil:
+		return max(s.keyRegex.NumSubexp(), 1)
+	case s.expr != nil:
+		return 1
+	}
+	return 0
+}
+
+// checkMissing при --missing=error сообщает о строке, у которой нет части ключей
+func (s *Sorter) checkMissing(keys []string, lineNum int, line string) error {
+	if s.opts.Missing != missingError || len(keys) >= s.expectedKeys() {
+		return nil
+	}
+	if len(s.opts.Keys) > 0 {
+		return fmt.Errorf("строка %d: нет колонки %d для ключа -k: %q", lineNum, s.opts.Keys[len(keys)].Column, line)
+	}
+	if s.expr != nil {
+		return fmt.Errorf("строка %d: не удалось вычислить --expr: %q", lineNum, line)
+	}
+	return fmt.Errorf("строка %d: не соответствует --key-regex: %q", lineNum, line)
+}
+
+// compareMissing упорядочивает строки с разным числом ключей при равной общей части
+func (s *Sorter) compareMissing(a, b *Row) int {
+	c := compareOrdered(int64(len(a.Keys)), int64(len(b.Keys)))
+	switch s.opts.Missing {
+	case missingLast:
+		c = -c
+	case missingFirst:
+	default:
+		return c
+	}
+	// first и last задают место независимо от направления, поэтому обращение -r здесь
+	// заранее компенсируется
+	if s.opts.Reverse {
+		c = -c
+	}
+	return c
+}
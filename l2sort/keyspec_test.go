@@ -0,0 +1,167 @@
+package l2sort
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseKeySpec(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want keySpec
+	}{
+		{"2", keySpec{Column: 2}},
+		{"0", keySpec{Column: 0}},
+		{"3r", keySpec{Column: 3, Reverse: true}},
+		{"1b", keySpec{Column: 1, IgnoreBlanks: true}},
+		{"2n", keySpec{Column: 2, Type: typeNumeric}},
+		{"2h", keySpec{Column: 2, Type: typeHuman}},
+		{"2M", keySpec{Column: 2, Type: typeMonth}},
+		{"2V", keySpec{Column: 2, Type: typeNatural}},
+		{"2R", keySpec{Column: 2, Type: typeRandom}},
+		{"2nr", keySpec{Column: 2, Type: typeNumeric, Reverse: true}},
+		{"1b:time", keySpec{Column: 1, IgnoreBlanks: true, Type: typeTime}},
+		{"4:compound", keySpec{Column: 4, Type: typeCompound}},
+		{"2n~1e-9", keySpec{Column: 2, Type: typeNumeric, Epsilon: 1e-9}},
+		{"2@trim,lower", keySpec{Column: 2, Transform: "trim,lower"}},
+		{"2f", keySpec{Column: 2, Transform: "lower"}},
+		{"2f@trim", keySpec{Column: 2, Transform: "lower,trim"}},
+		{"3rn~0.5@trim", keySpec{Column: 3, Reverse: true, Type: typeNumeric, Epsilon: 0.5, Transform: "trim"}},
+	}
+	for _, tt := range tests {
+		got, err := parseKeySpec(tt.raw)
+		if err != nil {
+			t.Errorf("parseKeySpec(%q): %v", tt.raw, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseKeySpec(%q) = %+v, ожидалось %+v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestParseKeySpecErrors(t *testing.T) {
+	for _, raw := range []string{
+		"", "x", "-1", "2x", "2nh", "2n:time", "2M:ip", "2~", "2~0", "2~-1", "2~abc", "2~Inf",
+	} {
+		if spec, err := parseKeySpec(raw); err == nil {
+			t.Errorf("parseKeySpec(%q) = %+v, ожидалась ошибка", raw, spec)
+		}
+	}
+}
+
+func TestKeySpecFlagSet(t *testing.T) {
+	tests := []struct {
+		name      string
+		inherited keySpecList
+		values    []string
+		want      string
+		whole     bool
+	}{
+		{"один ключ", nil, []string{"2"}, "2", false},
+		{"ключи через запятую", nil, []string{"2n,1r"}, "2:numeric,1r", false},
+		{"флаг несколько раз", nil, []string{"2n", "1"}, "2:numeric,1", false},
+		{"преобразования после запятой", nil, []string{"2@trim,lower,1"}, "2@trim,lower,1", false},
+		{"первый -k заменяет унаследованные ключи", keySpecList{{Column: 5}}, []string{"1", "2"}, "1,2", false},
+		{"-k 0 - вся строка", nil, []string{"0"}, "", true},
+		{"ключ после -k 0", nil, []string{"0", "3"}, "3", false},
+		{"-k 0 после ключей", nil, []string{"3", "0"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			specs := slices.Clone(tt.inherited)
+			var whole bool
+			f := &keySpecFlag{specs: &specs, whole: &whole}
+			for _, value := range tt.values {
+				if err := f.Set(value); err != nil {
+					t.Fatalf("Set(%q): %v", value, err)
+				}
+			}
+			if got := specs.String(); got != tt.want || whole != tt.whole {
+				t.Errorf("-k %s: ключи %q, вся строка %v; ожидалось %q, %v", strings.Join(tt.values, " -k "), got, whole, tt.want, tt.whole)
+			}
+		})
+	}
+}
+
+func TestKeySpecFlagSetErrors(t *testing.T) {
+	for _, value := range []string{"0r", "0n", "0@trim", "a", "1,x"} {
+		var specs keySpecList
+		if err := (&keySpecFlag{specs: &specs}).Set(value); err == nil {
+			t.Errorf("-k %s: ожидалась ошибка", value)
+		}
+	}
+}
+
+// TestKeyKindPrecedence проверяет, какой тип сравнения получает каждый ключ: тип
+// в модификаторе ключа важнее общего флага, а ключи без модификатора получают тип
+// общего флага или текст
+func TestKeyKindPrecedence(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string // типы ключей по порядку, последний - тип ключа без -k
+	}{
+		{[]string{}, []string{typeText}},
+		{[]string{"-n"}, []string{typeNumeric}},
+		{[]string{"-k", "2"}, []string{typeText, typeText}},
+		{[]string{"-n", "-k", "2"}, []string{typeNumeric, typeNumeric}},
+		{[]string{"-k", "2", "-n"}, []string{typeNumeric, typeNumeric}},
+		{[]string{"-n", "-k", "2M"}, []string{typeMonth, typeNumeric}},
+		{[]string{"-h", "-k", "1", "-k", "2:time"}, []string{typeHuman, typeTime, typeHuman}},
+		{[]string{"-k", "1V", "-k", "2"}, []string{typeNatural, typeText, typeText}},
+		{[]string{"--numerals", "-k", "1"}, []string{typeNatural, typeNatural}},
+		{[]string{"--numerals", "-n", "-k", "1"}, []string{typeNumeric, typeNumeric}},
+		{[]string{"--compound", "-k", "1", "-k", "2n"}, []string{typeCompound, typeNumeric, typeCompound}},
+		{[]string{"--by", "size", "-k", "1"}, []string{typeSize, typeSize}},
+	}
+	for _, tt := range tests {
+		s := newTestSorter(t, tt.args)
+		var got []string
+		for i := range s.opts.Keys {
+			got = append(got, s.kindOf(i).name)
+		}
+		got = append(got, s.kindOf(len(s.opts.Keys)).name)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: типы %v, ожидалось %v", strings.Join(tt.args, " "), got, tt.want)
+		}
+	}
+}
+
+func TestKeyKindConflicts(t *testing.T) {
+	for _, args := range [][]string{
+		{"-n", "-h"},
+		{"-M", "--time"},
+		{"-n", "--key-type", "nosuchtype"},
+		{"-k", "1:nosuchtype"},
+	} {
+		if _, err := NewSorter(parseTestFlags(t, args)); err == nil {
+			t.Errorf("%s: ожидалась ошибка", strings.Join(args, " "))
+		}
+	}
+}
+
+// TestKeyModifierPrecedence проверяет общие -r и -b вместе с модификаторами ключей:
+// r ключа обращает только его, общий -r - весь порядок, b ключа и общий -b убирают
+// начальные пробелы ключа
+func TestKeyModifierPrecedence(t *testing.T) {
+	lines := []string{"a  2", "b 10", "a 1", "b  3"}
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"-k", "1", "-k", "2n"}, []string{"a 1", "a  2", "b  3", "b 10"}},
+		{[]string{"-k", "1", "-k", "2nr"}, []string{"a  2", "a 1", "b 10", "b  3"}},
+		{[]string{"-r", "-k", "1", "-k", "2n"}, []string{"b 10", "b  3", "a  2", "a 1"}},
+		{[]string{"-r", "-k", "1", "-k", "2nr"}, []string{"b  3", "b 10", "a 1", "a  2"}},
+		{[]string{"-k", "1r", "-k", "2n"}, []string{"b  3", "b 10", "a 1", "a  2"}},
+		{[]string{"-s", "--compat", "gnu", "-k", "2"}, []string{"a  2", "b  3", "a 1", "b 10"}},
+		{[]string{"-s", "--compat", "gnu", "-k", "2b"}, []string{"a 1", "b 10", "a  2", "b  3"}},
+		{[]string{"-s", "--compat", "gnu", "-b", "-k", "2"}, []string{"a 1", "b 10", "a  2", "b  3"}},
+	}
+	for _, tt := range tests {
+		if got := sortLines(t, tt.args, lines); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: получено %q, ожидалось %q", strings.Join(tt.args, " "), got, tt.want)
+		}
+	}
+}
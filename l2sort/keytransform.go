@@ -0,0 +1,90 @@
+package l2sort
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// keyTransform преобразует текст ключа перед сравнением; строка выводится без изменений
+type keyTransform func(key string) string
+
+// keyTransformNames - преобразования без параметра
+var keyTransformNames = map[string]keyTransform{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+	"strip-punct": func(key string) string {
+		return strings.Map(func(r rune) rune {
+			if unicode.IsPunct(r) {
+				return -1
+			}
+			return r
+		}, key)
+	},
+}
+
+// parseKeyTransforms разбирает цепочку преобразований через запятую, например
+// "trim,lower,strip-prefix=ID-". Кроме keyTransformNames есть strip-prefix=ТЕКСТ и
+// strip-suffix=ТЕКСТ, убирающие текст в начале или в конце ключа, если он там есть.
+// Преобразования применяются слева направо
+func parseKeyTransforms(chain string) ([]keyTransform, error) {
+	if chain == "" {
+		return nil, nil
+	}
+	var transforms []keyTransform
+	for item := range strings.SplitSeq(chain, ",") {
+		name, arg, hasArg := strings.Cut(item, "=")
+		switch {
+		case name == "strip-prefix" && hasArg:
+			transforms = append(transforms, func(key string) string { return strings.TrimPrefix(key, arg) })
+		case name == "strip-suffix" && hasArg:
+			transforms = append(transforms, func(key string) string { return strings.TrimSuffix(key, arg) })
+		case keyTransformNames[name] != nil && !hasArg:
+			transforms = append(transforms, keyTransformNames[name])
+		default:
+			return nil, fmt.Errorf("неизвестное преобразование %q: ожидалось lower, upper, trim, strip-punct, strip-prefix=ТЕКСТ или strip-suffix=ТЕКСТ", item)
+		}
+	}
+	return transforms, nil
+}
+
+// resolveKeyTransforms разбирает преобразования ключей -k и --key-transform для ключей
+// без своих преобразований
+func (s *Sorter) resolveKeyTransforms() error {
+	var err error
+	if s.defaultTransforms, err = parseKeyTransforms(s.opts.KeyTransform); err != nil {
+		return fmt.Errorf("в параметре --key-transform: %w", err)
+	}
+	s.keyTransforms = make([][]keyTransform, len(s.opts.Keys))
+	for i, spec := range s.opts.Keys {
+		if spec.Transform == "" {
+			s.keyTransforms[i] = s.defaultTransforms
+			continue
+		}
+		if s.keyTransforms[i], err = parseKeyTransforms(spec.Transform); err != nil {
+			return fmt.Errorf("в параметре -k: в ключе %s: %w", spec, err)
+		}
+	}
+	return nil
+}
+
+// transformsKey сообщает, что у ключа номер k (с 0) есть преобразования
+func (s *Sorter) transformsKey(k int) bool {
+	if k < len(s.keyTransforms) {
+		return len(s.keyTransforms[k]) > 0
+	}
+	return len(s.defaultTransforms) > 0
+}
+
+// transformKey применяет к тексту ключа номер k (с 0) его цепочку преобразований
+func (s *Sorter) transformKey(k int, text string) string {
+	transforms := s.defaultTransforms
+	if k < len(s.keyTransforms) {
+		transforms = s.keyTransforms[k]
+	}
+	for _, t := range transforms {
+		text = t(text)
+	}
+	return text
+}
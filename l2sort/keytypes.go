@@ -0,0 +1,71 @@
+package l2sort
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// KeyType - пользовательский тип ключа (например, ULID или geohash). Тип включается для
+// всех ключей через Options.KeyTypes или для отдельного ключа модификатором -k 2:имя и
+// сравнивает так же, как -n, -M или --time: ключ, разобранный типом, идет после
+// неразобранных и сравнивается его Compare, а неразобранные ключи - как текст
+type KeyType interface {
+	// Parse разбирает текст ключа; ok равно false, если текст не относится к типу
+	Parse(text string) (value any, ok bool)
+	// Compare сравнивает два значения, полученные от Parse. Возвращает -1, 0 или 1
+	Compare(a, b any) int
+}
+
+// keyTypes - типы, зарегистрированные RegisterKeyType
+var keyTypes = struct {
+	sync.RWMutex
+	byName map[string]KeyType
+}{byName: make(map[string]KeyType)}
+
+// RegisterKeyType делает тип ключа доступным под именем name для Options.KeyTypes, флага
+// --key-type и модификатора -k N:name. Повторная регистрация имени - ошибка программы и вызывает панику
+func RegisterKeyType(name string, t KeyType) {
+	keyTypes.Lock()
+	defer keyTypes.Unlock()
+	if t == nil {
+		panic("RegisterKeyType: тип " + name + " равен nil")
+	}
+	if _, ok := keyTypes.byName[name]; ok {
+		panic("RegisterKeyType: тип " + name + " уже зарегистрирован")
+	}
+	keyTypes.byName[name] = t
+}
+
+// namedKeyType - включенный тип вместе с именем для --debug
+type namedKeyType struct {
+	name string
+	KeyType
+}
+
+// lookupKeyTypes находит зарегистрированные типы по именам в порядке приоритета
+func lookupKeyTypes(names []string) ([]namedKeyType, error) {
+	keyTypes.RLock()
+	defer keyTypes.RUnlock()
+	var types []namedKeyType
+	for _, name := range names {
+		t, ok := keyTypes.byName[name]
+		if !ok {
+			known := slices.Sorted(maps.Keys(keyTypes.byName))
+			if len(known) == 0 {
+				return nil, fmt.Errorf("неизвестный тип %q: типы не зарегистрированы", name)
+			}
+			return nil, fmt.Errorf("неизвестный тип %q, известны: %s", name, strings.Join(known, ", "))
+		}
+		types = append(types, namedKeyType{name: name, KeyType: t})
+	}
+	return types, nil
+}
+
+// compareCustom сравнивает разобранные обоими ключами значения пользовательского типа t
+// методом Compare типа
+func (s *Sorter) compareCustom(t *namedKeyType, a, b *Key) int {
+	return compareOrdered(int64(t.Compare(a.ext, b.ext)), 0)
+}
@@ -0,0 +1,68 @@
+package l2sort
+
+import (
+	"errors"
+	"strings"
+)
+
+// checkIgnoreLeadingZeros проверяет --ignore-leading-zeros: при --radix auto ведущий 0
+// означает восьмеричное число, и отбросить его нельзя
+func (s *Sorter) checkIgnoreLeadingZeros() error {
+	if s.opts.IgnoreLeadingZeros && s.radix == 0 {
+		return errors.New("в параметрах: --ignore-leading-zeros нельзя использовать с --radix auto - ведущий 0 задает восьмеричное число")
+	}
+	return nil
+}
+
+// zeroStripKind сообщает, что у ключей типа name --ignore-leading-zeros отбрасывает
+// ведущие нули
+func zeroStripKind(name string) bool {
+	return name == typeNumeric || name == typeHuman || name == typeNatural || name == typeCompound
+}
+
+// stripKeyZeros отбрасывает ведущие нули ключа типа kind при --ignore-leading-zeros, чтобы
+// "000123" и "123" были равны и по тексту ключа: так они не различаются ни при сортировке,
+// ни при -u. Строка вывода остается исходной
+func (s *Sorter) stripKeyZeros(kind keyKind, text string) string {
+	if !s.opts.IgnoreLeadingZeros || !zeroStripKind(kind.name) {
+		return text
+	}
+	if kind.name == typeNumeric || kind.name == typeHuman {
+		return stripNumberZeros(text)
+	}
+	return stripRunZeros(text)
+}
+
+// stripNumberZeros отбрасывает ведущие нули целой части числа после пробелов и знака,
+// оставляя хотя бы одну цифру: "-007.5" становится "-7.5". Дробная часть и группы
+// разрядов не меняются
+func stripNumberZeros(text string) string {
+	start := len(text) - len(strings.TrimLeft(text, " \t"))
+	if start < len(text) && (text[start] == '-' || text[start] == '+') {
+		start++
+	}
+	end := start
+	for end+1 < len(text) && text[end] == '0' && isDigit(text[end+1]) {
+		end++
+	}
+	if end == start {
+		return text
+	}
+	return text[:start] + text[end:]
+}
+
+// stripRunZeros отбрасывает ведущие нули каждой последовательности цифр, оставляя хотя бы
+// одну цифру: "v01.002" становится "v1.2"
+func stripRunZeros(text string) string {
+	if !strings.Contains(text, "0") {
+		return text
+	}
+	b := make([]byte, 0, len(text))
+	for i := 0; i < len(text); i++ {
+		if text[i] == '0' && i+1 < len(text) && isDigit(text[i+1]) && (len(b) == 0 || !isDigit(b[len(b)-1])) {
+			continue
+		}
+		b = append(b, text[i])
+	}
+	return string(b)
+}
@@ -0,0 +1,199 @@
+package l2sort
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// runLook выполняет подкоманду
+//
+//	l2sort look [--flags '-k 2 -n'] ФАЙЛ ПРЕФИКС
+//
+// В файле, отсортированном с теми же флагами, двоичным поиском по смещениям находит
+// и печатает строки, первый ключ которых начинается с ПРЕФИКС (при -n, -M, --time и
+// других типизированных режимах - равен ему), не читая файл целиком. Возвращает код
+// завершения: 0, если строки найдены, 1, если нет, 2 при ошибке
+func runLook(ctx context.Context, args []string, base Options) int {
+	fs := flag.NewFlagSet("look", flag.ContinueOnError)
+	flags := fs.String("flags", "", "Флаги сортировки, с которыми отсортирован файл, например '-k 2 -n'")
+	usage := func() {
+		fmt.Println("Использование: l2sort look [--flags '...'] файл префикс")
+		fs.PrintDefaults()
+	}
+	fs.Usage = usage
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) != 2 {
+		usage()
+		return 2
+	}
+	sorter, err := sorterWithFlags(base, *flags)
+	if err != nil {
+		return reportUsage(err)
+	}
+	found, err := sorter.Look(ctx, positional[0], positional[1], os.Stdout)
+	if err != nil {
+		if reportError(err) == exitInterrupted {
+			return exitInterrupted
+		}
+		return 2
+	}
+	if found == 0 {
+		return 1
+	}
+	return 0
+}
+
+// Look ищет в отсортированном файле path строки с первым ключом prefix и записывает их
+// в w. Возвращает число найденных строк
+func (s *Sorter) Look(ctx context.Context, path, prefix string, w io.Writer) (int, error) {
+	if s.encoding.transcoded() {
+		return 0, fmt.Errorf("в параметре --encoding: поиск по смещениям работает только с UTF-8")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("при чтении файла: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("при чтении файла: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return 0, fmt.Errorf("при чтении файла %s: поиск возможен только в обычном файле", path)
+	}
+	magic := make([]byte, 4)
+	n, _ := file.ReadAt(magic, 0)
+	if magic = magic[:n]; bytes.HasPrefix(magic, gzipMagic) || bytes.HasPrefix(magic, bzip2Magic) || bytes.HasPrefix(magic, zstdMagic) {
+		return 0, fmt.Errorf("при чтении файла %s: поиск по смещениям невозможен в сжатом файле", path)
+	}
+
+	l := &lookup{sorter: s, file: file, size: info.Size()}
+	l.probe = s.newRow(nil, prefix, []string{prefix})
+	l.textual = s.kindOf(0).name == typeText && !s.opts.Length
+
+	start, err := l.search(ctx)
+	if err != nil {
+		return 0, err
+	}
+	r := bufio.NewReader(io.NewSectionReader(file, start, l.size-start))
+	bw := bufio.NewWriter(w)
+	found := 0
+	for {
+		line, err := readLookLine(r)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return found, fmt.Errorf("при чтении файла: %w", err)
+		}
+		if l.compare(line) != 0 {
+			break
+		}
+		found++
+		bw.WriteString(line)
+		bw.WriteByte('\n')
+	}
+	if err := bw.Flush(); err != nil {
+		return found, fmt.Errorf("при записи результата: %w", err)
+	}
+	return found, nil
+}
+
+// lookup - состояние двоичного поиска по смещениям в файле
+type lookup struct {
+	sorter *Sorter
+	file   *os.File
+	size   int64
+	probe  Row
+	// textual означает, что ключи сравниваются как текст и совпадением считается префикс
+	textual bool
+}
+
+// search возвращает смещение первой строки, не меньшей искомого ключа. Все строки,
+// начинающиеся до lo, меньше ключа, а начинающиеся с hi и дальше - не меньше
+func (l *lookup) search(ctx context.Context) (int64, error) {
+	lo, hi := int64(0), l.size
+	for lo < hi {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		mid := lo + (hi-lo)/2
+		start, line, err := l.lineAfter(mid)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case start >= hi:
+			hi = mid
+		case l.compare(line) < 0:
+			lo = min(start+int64(len(line))+1, l.size)
+		default:
+			hi = start
+		}
+	}
+	return lo, nil
+}
+
+// lineAfter находит первую строку, начинающуюся не раньше off, и возвращает ее смещение
+// и текст без перевода строки. За концом файла смещение равно размеру файла
+func (l *lookup) lineAfter(off int64) (int64, string, error) {
+	start := off
+	if off > 0 {
+		// Строка начинается с off, только если перед ним перевод строки
+		start = off - 1
+	}
+	r := bufio.NewReader(io.NewSectionReader(l.file, start, l.size-start))
+	if off > 0 {
+		skipped, err := r.ReadString('\n')
+		start += int64(len(skipped))
+		if errors.Is(err, io.EOF) {
+			return l.size, "", nil
+		}
+		if err != nil {
+			return 0, "", fmt.Errorf("при чтении файла: %w", err)
+		}
+	}
+	line, err := readLookLine(r)
+	if errors.Is(err, io.EOF) {
+		return l.size, "", nil
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("при чтении файла: %w", err)
+	}
+	return start, line, nil
+}
+
+// readLookLine читает строку без завершающего перевода строки; \r перед ним
+// сохраняется, чтобы смещения оставались точными
+func readLookLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+// compare сравнивает первый ключ строки с искомым в порядке файла: 0 - строка подходит
+func (l *lookup) compare(line string) int {
+	s := l.sorter
+	text := strings.TrimSuffix(line, "\r")
+	row := s.newRow(nil, text, s.extractKeys(s.keyPart(text)))
+	if len(row.Keys) > 1 {
+		row.Keys = row.Keys[:1]
+	}
+	if l.textual && len(row.Keys) == 1 && strings.HasPrefix(row.keyText(0), l.probe.keyText(0)) {
+		return 0
+	}
+	return s.compareOrder(&row, &l.probe)
+}
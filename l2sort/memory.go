@@ -0,0 +1,91 @@
+package l2sort
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// Размеры служебных структур, которые удерживаются в памяти вместе с данными
+const (
+	stringHeaderSize = int64(unsafe.Sizeof(""))
+	rowSize          = int64(unsafe.Sizeof(Row{}))
+	keySize          = int64(unsafe.Sizeof(Key{}))
+)
+
+// memBudget ведет учет байт, фактически удерживаемых строками, ключами и служебными структурами.
+// Когда учтенный объем превышает ограничение -S, накопленная порция сбрасывается на диск
+type memBudget struct {
+	limit int64
+	used  int64
+	peak  int64
+}
+
+func newMemBudget(limit int64) *memBudget {
+	return &memBudget{limit: limit}
+}
+
+// add учитывает n дополнительных байт
+func (b *memBudget) add(n int64) {
+	b.used += n
+	if b.used > b.peak {
+		b.peak = b.used
+	}
+}
+
+// release возвращает n байт в бюджет
+func (b *memBudget) release(n int64) {
+	b.used -= n
+}
+
+// reset обнуляет учет после того, как накопленные данные сброшены на диск
+func (b *memBudget) reset() {
+	b.used = 0
+}
+
+// exceeded сообщает, превышено ли ограничение -S
+func (b *memBudget) exceeded() bool {
+	return b.limit > 0 && b.used > b.limit
+}
+
+// growSlice учитывает перераспределение массива, лежащего под срезом: старый массив
+// освобождается, новый с емкостью newCap элементов размера elem добавляется
+func (b *memBudget) growSlice(oldCap, newCap int, elem int64) {
+	if newCap == oldCap {
+		return
+	}
+	b.release(int64(oldCap) * elem)
+	b.add(int64(newCap) * elem)
+}
+
+// parseSize разбирает размер в формате GNU sort: число с необязательным суффиксом
+// b, K, M, G, T. Число без суффикса означает килобайты
+func parseSize(s string) (int64, error) {
+	orig := s
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	multiplier := int64(1024)
+	switch s[len(s)-1] {
+	case 'b', 'B':
+		multiplier = 1
+	case 'k', 'K':
+		multiplier = 1 << 10
+	case 'm', 'M':
+		multiplier = 1 << 20
+	case 'g', 'G':
+		multiplier = 1 << 30
+	case 't', 'T':
+		multiplier = 1 << 40
+	}
+	if s[len(s)-1] < '0' || s[len(s)-1] > '9' {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("некорректный размер: %q", orig)
+	}
+	return n * multiplier, nil
+}
@@ -0,0 +1,127 @@
+package l2sort
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Границы планировщика слияния
+const (
+	// mergeReservedFiles - сколько дескрипторов оставляется под входы, результат
+	// и промежуточный прогон сверх сливаемых прогонов
+	mergeReservedFiles = 32
+	// minReadAhead и maxReadAhead - пределы буфера чтения одного прогона
+	minReadAhead = 64 << 10
+	maxReadAhead = 4 << 20
+	// defaultMergeMemory - память под буферы чтения прогонов, когда -S не задан
+	defaultMergeMemory = 64 << 20
+)
+
+// mergePlan - как сливаются прогоны: сколько их сливается за раз, какой буфер чтения
+// у каждого и за сколько проходов слияние сводится к итоговому
+type mergePlan struct {
+	runs      int
+	fanIn     int
+	readAhead int
+	passes    int
+	// limit - чем ограничено fanIn: дескрипторами, памятью или --merge-fan-in
+	limit string
+}
+
+// checkMergeFanIn проверяет --merge-fan-in: 0 - подобрать автоматически, иначе не меньше 2
+func checkMergeFanIn(o Options) error {
+	if o.MergeFanIn < 0 || o.MergeFanIn == 1 {
+		return fmt.Errorf("в параметре --merge-fan-in: ожидалось 0 (автоматически) или число не меньше 2, получено %d", o.MergeFanIn)
+	}
+	return nil
+}
+
+// planMerge выбирает план слияния runs прогонов. Число прогонов, сливаемых за раз,
+// ограничено свободными дескрипторами файлов и тем, сколько буферов чтения не меньше
+// minReadAhead помещается в -S; память делится между прогонами прохода поровну
+func (s *Sorter) planMerge(runs int) mergePlan {
+	plan := mergePlan{runs: runs, fanIn: openFileLimit() - mergeReservedFiles, limit: "дескрипторы файлов"}
+	memory := s.limit
+	if memory <= 0 {
+		memory = defaultMergeMemory
+	}
+	if byMemory := int(memory / minReadAhead); byMemory < plan.fanIn {
+		plan.fanIn, plan.limit = byMemory, "память -S"
+	}
+	if s.opts.MergeFanIn > 0 {
+		plan.fanIn, plan.limit = s.opts.MergeFanIn, "--merge-fan-in"
+	}
+	plan.fanIn = max(plan.fanIn, 2)
+	plan.readAhead = int(min(max(memory/int64(min(plan.fanIn, max(runs, 1))), minReadAhead), maxReadAhead))
+	plan.passes = 1
+	for n := runs; n > plan.fanIn; n = (n + plan.fanIn - 1) / plan.fanIn {
+		plan.passes++
+	}
+	return plan
+}
+
+// openFileLimit возвращает ограничение числа открытых файлов процесса
+func openFileLimit() int {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil || limit.Cur > 1<<20 {
+		return 1 << 20
+	}
+	return int(limit.Cur)
+}
+
+// reduceRuns сливает прогоны группами по plan.fanIn подряд идущих, пока их не останется
+// столько, чтобы итоговое слияние открыло все сразу. Группы сливаются по порядку,
+// поэтому порядок равных строк, как и при слиянии за один проход, сохраняется
+func (s *Sorter) reduceRuns(ctx context.Context, runs []string, plan mergePlan) ([]string, error) {
+	for len(runs) > plan.fanIn {
+		var merged []string
+		for start := 0; start < len(runs); start += plan.fanIn {
+			group := runs[start:min(start+plan.fanIn, len(runs))]
+			if len(group) == 1 {
+				merged = append(merged, group[0])
+				continue
+			}
+			run, err := s.mergeGroup(ctx, group, plan.readAhead)
+			if err != nil {
+				return nil, err
+			}
+			merged = append(merged, run)
+		}
+		runs = merged
+	}
+	return runs, nil
+}
+
+// mergeGroup сливает группу прогонов в новый промежуточный прогон. Прочитанные
+// прогоны удаляются, кроме прогонов --resume, нужных для возобновления
+func (s *Sorter) mergeGroup(ctx context.Context, group []string, readAhead int) (string, error) {
+	compressed := s.opts.CompressTemp || s.opts.CompressProgram != ""
+	var size int64
+	var count int
+	for _, path := range group {
+		if info, err := os.Stat(path); err == nil && !compressed {
+			size += info.Size()
+		}
+		count += s.runRows[path]
+	}
+	file, err := temps.createSized(s.opts.TempDir, "merge", size, s.maxTemp)
+	if err != nil {
+		return "", err
+	}
+	s.stats.addTempFile()
+	defer file.Close()
+	if s.runRows != nil {
+		s.runRows[file.Name()] = count
+	}
+	src, err := s.openRuns(group, nil, readAhead)
+	if err != nil {
+		return "", err
+	}
+	if err := s.writeRun(file, &ctxSource{ctx: ctx, src: src}); err != nil {
+		return "", err
+	}
+	temps.finish(file)
+	return file.Name(), file.Close()
+}
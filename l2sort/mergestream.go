@@ -0,0 +1,119 @@
+package l2sort
+
+import (
+	"context"
+	"fmt"
+)
+
+// streamBuffer - сколько прочитанных строк одного входа слияния ждут получателя; когда
+// буфер полон, чтение входа приостанавливается, пока вывод не заберет строки
+const streamBuffer = 1024
+
+// streamInput читает отсортированный файл в отдельной горутине через scanRows и отдает
+// строки по мере чтения, проверяя попутно их порядок. Кодировка enc и окончание строк
+// eol входа известны после его первой строки (started), итоговые сведения in - после
+// конца входа (done)
+type streamInput struct {
+	rows    chan Row
+	started chan struct{}
+	done    chan struct{}
+	enc     *textEncoding
+	eol     eolStyle
+	in      *inputData
+	err     error
+}
+
+// streamInput запускает чтение path; чтение прекращается при отмене ctx
+func (s *Sorter) streamInput(ctx context.Context, path string) *streamInput {
+	st := &streamInput{rows: make(chan Row, streamBuffer), started: make(chan struct{}), done: make(chan struct{})}
+	// У каждого читателя свой буфер полей extractKeys, остальное состояние Sorter
+	// при чтении не меняется
+	reader := *s
+	reader.fields = nil
+	go func() {
+		defer close(st.done)
+		defer close(st.rows)
+		var prev Row
+		first := true
+		in, err := reader.scanRows(ctx, []string{path}, nil, func(in *inputData, row Row) error {
+			if first {
+				st.enc, st.eol, first = in.enc, in.eol, false
+				close(st.started)
+			} else if reader.sortOrder(&row, &prev) < 0 {
+				return fmt.Errorf("файл %s не отсортирован по ключу: строка %d", path, in.lines)
+			}
+			prev = row
+			select {
+			case st.rows <- row:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			err = fmt.Errorf("при чтении файла %s: %w", path, err)
+		}
+		st.in, st.err = in, err
+		if first {
+			if in != nil {
+				st.enc, st.eol = in.enc, in.eol
+			}
+			close(st.started)
+		}
+	}()
+	return st
+}
+
+func (st *streamInput) next() (Row, bool, error) {
+	row, ok := <-st.rows
+	if ok {
+		return row, true, nil
+	}
+	<-st.done
+	return Row{}, false, st.err
+}
+
+// idle сообщает, что следующая строка еще не прочитана и next будет ее ждать
+func (st *streamInput) idle() bool {
+	if len(st.rows) > 0 {
+		return false
+	}
+	select {
+	case <-st.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// close дожидается конца чтения и освобождает вход; перед этим чтение нужно
+// прекратить отменой контекста или дочитать вход до конца
+func (st *streamInput) close() {
+	for range st.rows {
+	}
+	<-st.done
+	if st.in != nil {
+		st.in.close()
+	}
+}
+
+// idleSource - поток строк, который может сообщить, что следующей строки придется ждать
+type idleSource interface {
+	idle() bool
+}
+
+// sourceIdle сообщает, что src - idleSource и следующей строки придется ждать
+func sourceIdle(src rowSource) bool {
+	i, ok := src.(idleSource)
+	return ok && i.idle()
+}
+
+// idle сообщает, что следующая строка слияния ждет чтения победившего входа
+func (m *mergeSource) idle() bool {
+	return m.taken && sourceIdle(m.sources[m.heap.items[0].src])
+}
+
+// idle сообщает, что отложенных строк нет, а следующей придется ждать
+func (u *uniqueSource) idle() bool {
+	return len(u.pending) == 0 && sourceIdle(u.src.src)
+}
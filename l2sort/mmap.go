@@ -0,0 +1,118 @@
+package l2sort
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// mappedInput - входной файл, отображенный в память при --mmap. Строки, прочитанные
+// из него, ссылаются прямо на отображение, поэтому в памяти процесса хранятся только
+// заголовки строк (по сути, индекс смещений) и ключи, ссылающиеся на текст смещениями.
+// Только строку, ключ которой изменен преобразованиями, приходится копировать в арену
+// вместе с текстом ключа. Отображение нужно освободить через close, когда строки
+// больше не используются
+type mappedInput struct {
+	data []byte
+	text []byte // data без метки порядка байт
+	enc  *textEncoding
+}
+
+func (m *mappedInput) close() error {
+	if m == nil {
+		return nil
+	}
+	return munmapFile(m.data)
+}
+
+// mapInput отображает файл в память. Если файл нельзя читать напрямую (сжатый, не UTF-8,
+// не обычный файл), выводит предупреждение и возвращает nil: такой файл читается как обычно.
+// Файл не должен изменяться на месте, пока идет сортировка
+func (s *Sorter) mapInput(path string) (*mappedInput, error) {
+	warn := func(reason string) (*mappedInput, error) {
+		warnf("--mmap не применяется к %s (%s), файл читается обычным образом", path, reason)
+		return nil, nil
+	}
+	if s.encoding.transcoded() {
+		return warn("кодировка " + s.encoding.name)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case !info.Mode().IsRegular():
+		return warn("не обычный файл")
+	case info.Size() == 0:
+		return nil, nil
+	}
+	data, err := mmapFile(file, info.Size())
+	if err != nil {
+		return warn(err.Error())
+	}
+	m := &mappedInput{data: data, text: data, enc: s.encoding}
+	switch {
+	case bytes.HasPrefix(data, gzipMagic), bytes.HasPrefix(data, bzip2Magic), bytes.HasPrefix(data, zstdMagic):
+		m.close()
+		return warn("сжатый файл")
+	case bytes.HasPrefix(data, utf16LEBOM), bytes.HasPrefix(data, utf16BEBOM):
+		m.close()
+		return warn("UTF-16")
+	case bytes.HasPrefix(data, utf8BOM):
+		m.text = data[len(utf8BOM):]
+		m.enc = &textEncoding{name: "utf-8", bom: utf8BOM}
+	}
+	return m, nil
+}
+
+// mmapFile отображает size байт файла в память только для чтения (Unix)
+func mmapFile(file *os.File, size int64) ([]byte, error) {
+	return syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func munmapFile(data []byte) error {
+	return syscall.Munmap(data)
+}
+
+// mappedScanner выдает строки отображенного файла без копирования, разбивая его той же
+// функцией, что и bufio.Scanner при обычном чтении
+type mappedScanner struct {
+	data     []byte
+	split    bufio.SplitFunc
+	maxLine  int64
+	progress *progressReporter
+	token    []byte
+	err      error
+}
+
+func (m *mappedScanner) Scan() bool {
+	if len(m.data) == 0 || m.err != nil {
+		return false
+	}
+	advance, token, err := m.split(m.data, true)
+	if err == nil && m.maxLine > 0 && int64(len(token)) > m.maxLine {
+		err = bufio.ErrTooLong
+	}
+	if err != nil {
+		m.err = err
+		return false
+	}
+	if m.progress != nil {
+		m.progress.bytes.Add(int64(advance))
+	}
+	m.data, m.token = m.data[advance:], token
+	return true
+}
+
+func (m *mappedScanner) Text() string {
+	return unsafe.String(unsafe.SliceData(m.token), len(m.token))
+}
+
+func (m *mappedScanner) Err() error { return m.err }
@@ -0,0 +1,53 @@
+package l2sort
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// readHeap читает файл path в память сортировщиком с флагами args и возвращает, на сколько
+// байт выросла куча, пока прочитанные строки удерживаются
+func readHeap(t *testing.T, args []string, path string) uint64 {
+	t.Helper()
+	s := newTestSorter(t, args)
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	in, err := s.readRows(context.Background(), []string{path}, newMemBudget(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	if len(in.rows) == 0 {
+		t.Fatal("строки не прочитаны")
+	}
+	runtime.KeepAlive(in.rows)
+	in.close()
+	return after.HeapAlloc - min(before.HeapAlloc, after.HeapAlloc)
+}
+
+// TestMmapReducesHeap проверяет, что при --mmap строки остаются в отображении файла:
+// в куче лежат только заголовки строк и ключи, и она заметно меньше, чем при обычном
+// чтении, когда строки копируются в арену
+func TestMmapReducesHeap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "in.txt")
+	var b strings.Builder
+	for i := range 40000 {
+		b.WriteString(strings.Repeat(string(rune('a'+i%26)), 200))
+		b.WriteString(" 42\n")
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	plain := readHeap(t, []string{"-k", "2n"}, path)
+	mapped := readHeap(t, []string{"--mmap", "-k", "2n"}, path)
+	t.Logf("куча после чтения: %d КБ без --mmap, %d КБ с --mmap", plain>>10, mapped>>10)
+	if mapped*2 > plain {
+		t.Errorf("--mmap не уменьшил кучу: %d КБ против %d КБ", mapped>>10, plain>>10)
+	}
+}
@@ -0,0 +1,49 @@
+package l2sort
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Формы нормализации для --normalize
+const (
+	normalizeNFC  = "nfc"
+	normalizeNFKC = "nfkc"
+)
+
+// parseNormalizeForm проверяет значение --normalize
+func parseNormalizeForm(value string) (string, error) {
+	switch form := strings.ToLower(value); form {
+	case "", normalizeNFC, normalizeNFKC:
+		return form, nil
+	}
+	return "", fmt.Errorf("неизвестная форма %q: ожидалось nfc или nfkc", value)
+}
+
+// normalizeKey приводит текст ключа к форме --normalize, чтобы составные и разложенные
+// символы ("é" и "e" + U+0301) давали равные ключи, и при --numerals заменяет числа
+// словами и римскими цифрами десятичными. Строки без не-ASCII символов не нормализуются
+func (s *Sorter) normalizeKey(text string) string {
+	if s.opts.Numerals {
+		text = replaceNumerals(text)
+	}
+	if s.normalize == "" {
+		return text
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] >= utf8.RuneSelf {
+			// Замена некорректных байтов на U+FFFD сделала бы разные ключи равными
+			if !utf8.ValidString(text) {
+				return text
+			}
+			if s.normalize == normalizeNFKC {
+				return norm.NFKC.String(text)
+			}
+			return norm.NFC.String(text)
+		}
+	}
+	return text
+}
@@ -0,0 +1,29 @@
+package l2sort
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestNormalize проверяет, что составные и разложенные символы дают равные ключи,
+// а строки выводятся без изменений
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		args  []string
+		lines []string
+		want  []string
+	}{
+		{[]string{"--normalize", "nfc", "-u"}, []string{"e\u0301", "\u00e9", "d"}, []string{"d", "e\u0301"}},
+		{[]string{"--normalize", "nfc", "-u"}, []string{"\u1100\u1161", "\uac00"}, []string{"\u1100\u1161"}},
+		{[]string{"--normalize", "nfc", "-u"}, []string{"\ufb01", "fi"}, []string{"fi", "\ufb01"}},
+		{[]string{"--normalize", "nfkc", "-u"}, []string{"\ufb01", "fi"}, []string{"\ufb01"}},
+		{[]string{"--normalize", "nfkc", "-s"}, []string{"\u2462", "2", "\u00b9"}, []string{"\u00b9", "2", "\u2462"}},
+		{[]string{"--normalize", "nfc", "-u"}, []string{"a\xff", "a\xfe"}, []string{"a\xfe", "a\xff"}},
+	}
+	for _, tt := range tests {
+		if got := sortLines(t, tt.args, tt.lines); !slices.Equal(got, tt.want) {
+			t.Errorf("sort %s %q:\nполучено %q\nожидалось %q", strings.Join(tt.args, " "), tt.lines, got, tt.want)
+		}
+	}
+}
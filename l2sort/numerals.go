@@ -0,0 +1,214 @@
+package l2sort
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// numberWord - значение числительного: value складывается с текущей группой, а mult
+// (hundred, тысяча, million) умножает ее
+type numberWord struct {
+	value int64
+	mult  int64
+}
+
+// numberWords - английские и русские количественные числительные; русские - во всех
+// родах и в формах, которые стоят после числа (две тысячи, пять миллионов)
+var numberWords = func() map[string]numberWord {
+	m := make(map[string]numberWord)
+	add := func(value int64, words ...string) {
+		for _, w := range words {
+			m[w] = numberWord{value: value}
+		}
+	}
+	mult := func(mult int64, words ...string) {
+		for _, w := range words {
+			m[w] = numberWord{mult: mult}
+		}
+	}
+	for i, w := range strings.Fields("zero one two three four five six seven eight nine ten eleven twelve thirteen fourteen fifteen sixteen seventeen eighteen nineteen") {
+		add(int64(i), w)
+	}
+	for i, w := range strings.Fields("twenty thirty forty fifty sixty seventy eighty ninety") {
+		add(int64(i+2)*10, w)
+	}
+	mult(100, "hundred")
+	mult(1000, "thousand")
+	mult(1000000, "million")
+	mult(1000000000, "billion")
+
+	add(0, "ноль", "нуль")
+	add(1, "один", "одна", "одно")
+	add(2, "два", "две")
+	for i, w := range strings.Fields("три четыре пять шесть семь восемь девять десять одиннадцать двенадцать тринадцать четырнадцать пятнадцать шестнадцать семнадцать восемнадцать девятнадцать") {
+		add(int64(i+3), w)
+	}
+	for i, w := range strings.Fields("двадцать тридцать сорок пятьдесят шестьдесят семьдесят восемьдесят девяносто") {
+		add(int64(i+2)*10, w)
+	}
+	for i, w := range strings.Fields("сто двести триста четыреста пятьсот шестьсот семьсот восемьсот девятьсот") {
+		add(int64(i+1)*100, w)
+	}
+	mult(1000, "тысяча", "тысячи", "тысяч")
+	mult(1000000, "миллион", "миллиона", "миллионов")
+	mult(1000000000, "миллиард", "миллиарда", "миллиардов")
+	return m
+}()
+
+// replaceNumerals заменяет в тексте ключа числа, записанные словами ("twenty-one",
+// "двадцать одна") и римскими цифрами в верхнем регистре ("XIV"), десятичными, чтобы
+// сравнение типа natural или numeric упорядочило их по значению. Остальной текст не
+// меняется. Слова числа могут разделяться пробелами и дефисами, а английские - еще и "and"
+func replaceNumerals(text string) string {
+	var b strings.Builder
+	copied := 0
+	for pos := 0; pos < len(text); {
+		start, end := nextWord(text, pos)
+		if start < 0 {
+			break
+		}
+		pos = end
+		if n, ok := parseRoman(text[start:end]); ok {
+			b.WriteString(text[copied:start])
+			b.WriteString(strconv.Itoa(n))
+			copied = end
+			continue
+		}
+		if _, ok := numberWords[strings.ToLower(text[start:end])]; !ok {
+			continue
+		}
+		// Число словами продолжается, пока за разделителем идет следующее числительное
+		words := []string{strings.ToLower(text[start:end])}
+		for {
+			sep := end
+			for sep < len(text) && (text[sep] == ' ' || text[sep] == '-') {
+				sep++
+			}
+			if sep == end {
+				break
+			}
+			nextStart, nextEnd := nextWord(text, sep)
+			if nextStart != sep {
+				break
+			}
+			word := strings.ToLower(text[nextStart:nextEnd])
+			if word == "and" {
+				// "and" входит в число, только если за ним снова числительное
+				after := nextEnd
+				for after < len(text) && text[after] == ' ' {
+					after++
+				}
+				wordStart, wordEnd := nextWord(text, after)
+				if after == nextEnd || wordStart != after {
+					break
+				}
+				word = strings.ToLower(text[wordStart:wordEnd])
+				if _, ok := numberWords[word]; !ok {
+					break
+				}
+				nextEnd = wordEnd
+			} else if _, ok := numberWords[word]; !ok {
+				break
+			}
+			words = append(words, word)
+			end = nextEnd
+		}
+		pos = end
+		b.WriteString(text[copied:start])
+		b.WriteString(strconv.FormatInt(numberValue(words), 10))
+		copied = end
+	}
+	if copied == 0 {
+		return text
+	}
+	b.WriteString(text[copied:])
+	return b.String()
+}
+
+// nextWord находит первое слово из букв, начиная с pos; start < 0, если слов больше нет
+func nextWord(text string, pos int) (start, end int) {
+	start = -1
+	for i := pos; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if unicode.IsLetter(r) {
+			if start < 0 {
+				start = i
+			}
+		} else if start >= 0 {
+			return start, i
+		}
+		i += size
+	}
+	if start < 0 {
+		return -1, -1
+	}
+	return start, len(text)
+}
+
+// numberValue складывает значение числа из числительных: "two hundred five thousand"
+// дает 205000
+func numberValue(words []string) int64 {
+	var total, group int64
+	for _, w := range words {
+		word := numberWords[w]
+		switch {
+		case word.mult == 100:
+			group = max(group, 1) * 100
+		case word.mult > 0:
+			total += max(group, 1) * word.mult
+			group = 0
+		default:
+			group += word.value
+		}
+	}
+	return total + group
+}
+
+// romanValues - значения римских цифр
+var romanValues = map[byte]int{'I': 1, 'V': 5, 'X': 10, 'L': 50, 'C': 100, 'D': 500, 'M': 1000}
+
+// parseRoman разбирает римское число в верхнем регистре, записанное по правилам: цифры
+// идут по убыванию, вычитаются только I, X и C из следующих двух больших цифр, и число
+// записано кратчайшим образом (IIII, VV, IC не допускаются)
+func parseRoman(word string) (int, bool) {
+	if word == "" || len(word) > 15 {
+		return 0, false
+	}
+	n := 0
+	for i := 0; i < len(word); i++ {
+		v := romanValues[word[i]]
+		if v == 0 {
+			return 0, false
+		}
+		if i+1 < len(word) && romanValues[word[i+1]] > v {
+			n -= v
+		} else {
+			n += v
+		}
+	}
+	if n <= 0 || n >= 4000 || formatRoman(n) != word {
+		return 0, false
+	}
+	return n, true
+}
+
+// formatRoman записывает число от 1 до 3999 римскими цифрами
+func formatRoman(n int) string {
+	numerals := []struct {
+		value  int
+		digits string
+	}{
+		{1000, "M"}, {900, "CM"}, {500, "D"}, {400, "CD"}, {100, "C"}, {90, "XC"},
+		{50, "L"}, {40, "XL"}, {10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"},
+	}
+	var b strings.Builder
+	for _, numeral := range numerals {
+		for n >= numeral.value {
+			b.WriteString(numeral.digits)
+			n -= numeral.value
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,212 @@
+package l2sort
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// numericLocale задает разделители чисел для -n: разделитель групп разрядов (0 - нет)
+// и десятичный разделитель
+type numericLocale struct {
+	thousands rune
+	decimal   rune
+}
+
+// isThousands сообщает, что r - разделитель групп разрядов. Пробел в роли разделителя
+// принимается и неразрывным (U+00A0) или узким неразрывным (U+202F), которыми его
+// заменяют при форматировании чисел
+func (loc numericLocale) isThousands(r rune) bool {
+	if loc.thousands == ' ' {
+		return r == ' ' || r == '\u00a0' || r == '\u202f'
+	}
+	return loc.thousands != 0 && r == loc.thousands
+}
+
+// numericLocales - готовые наборы разделителей для --numeric-locale
+var numericLocales = map[string]numericLocale{
+	"c":  {thousands: 0, decimal: '.'},
+	"en": {thousands: ',', decimal: '.'},
+	"ru": {thousands: ' ', decimal: ','},
+	"de": {thousands: '.', decimal: ','},
+	"fr": {thousands: ' ', decimal: ','},
+	"ch": {thousands: '\'', decimal: '.'},
+}
+
+// lookupNumericLocale возвращает набор разделителей по имени или по паре символов
+// "<группы><дробь>", например ",." или " ,". Пустое имя означает en
+func lookupNumericLocale(name string) (numericLocale, error) {
+	if name == "" {
+		return numericLocales["en"], nil
+	}
+	if loc, ok := numericLocales[strings.ToLower(name)]; ok {
+		return loc, nil
+	}
+	if utf8.RuneCountInString(name) == 2 {
+		thousands, size := utf8.DecodeRuneInString(name)
+		decimal, _ := utf8.DecodeRuneInString(name[size:])
+		if thousands != decimal && !isDigit(byte(thousands)) && !isDigit(byte(decimal)) {
+			return numericLocale{thousands: thousands, decimal: decimal}, nil
+		}
+	}
+	return numericLocale{}, fmt.Errorf("неизвестный набор разделителей %q: ожидалось c, en, ru, de, fr, ch или пара символов, например \",.\"", name)
+}
+
+// normalizeNumber приводит число к виду, понятному strconv: убирает пробелы вокруг,
+// знак "+", разделители групп между цифрами и заменяет десятичный разделитель точкой.
+// Возвращает false, если текст не является числом
+func (loc numericLocale) normalizeNumber(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	var b strings.Builder
+	if text != "" && (text[0] == '+' || text[0] == '-') {
+		if text[0] == '-' {
+			b.WriteByte('-')
+		}
+		text = text[1:]
+	}
+	digits, seenDecimal := 0, false
+	prevDigit := false
+	for i, r := range text {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+			digits++
+			prevDigit = true
+			continue
+		case r == loc.decimal && !seenDecimal:
+			b.WriteByte('.')
+			seenDecimal = true
+		case loc.isThousands(r) && prevDigit && !seenDecimal &&
+			i+utf8.RuneLen(r) < len(text) && isDigit(text[i+utf8.RuneLen(r)]):
+			// разделитель групп допустим только между цифрами целой части
+		default:
+			return "", false
+		}
+		prevDigit = false
+	}
+	if digits == 0 {
+		return "", false
+	}
+	return b.String(), true
+}
+
+// parseNumericKey разбирает ключ для -n: целые хранятся точно, дробные - как float64
+func (s *Sorter) parseNumericKey(key *Key, text string) {
+	if s.radix != 10 {
+		s.parseRadixKey(key, text)
+		return
+	}
+	text, ok := s.numeric.normalizeNumber(text)
+	if !ok {
+		return
+	}
+	if !strings.Contains(text, ".") {
+		n, err := strconv.ParseInt(text, 10, 64)
+		switch {
+		case err == nil:
+			key.setInt(n, false)
+			return
+		case errors.Is(err, strconv.ErrRange):
+			key.setInt(n, true)
+			return
+		}
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil {
+		key.setFloat(f)
+	}
+}
+
+// compareNumeric сравнивает разобранные -n ключи: целые между собой точно, а целое
+// с дробным - без потери точности через сравнение целой и дробной частей
+func compareNumeric(a, b *Key) int {
+	x, xInt := a.intValue()
+	y, yInt := b.intValue()
+	switch {
+	case xInt && yInt:
+		return compareOrdered(x, y)
+	case xInt:
+		f, _ := b.floatValue()
+		return compareIntFloat(x, f)
+	case yInt:
+		f, _ := a.floatValue()
+		return -compareIntFloat(y, f)
+	}
+	f, _ := a.floatValue()
+	g, _ := b.floatValue()
+	return compareOrdered(f, g)
+}
+
+// compareIntFloat сравнивает целое с дробным точно, без приведения целого к float64
+func compareIntFloat(i int64, f float64) int {
+	switch {
+	case f >= math.MaxInt64:
+		return -1
+	case f < math.MinInt64:
+		return 1
+	}
+	whole := math.Trunc(f)
+	if c := compareOrdered(i, int64(whole)); c != 0 {
+		return c
+	}
+	return compareOrdered(0, f-whole)
+}
+
+// parseRadix разбирает значение --radix: auto (по префиксу 0x, 0o, 0b или ведущему 0),
+// 16, 8 или 10. Для auto возвращает 0, как принято в strconv
+func parseRadix(value string) (int, error) {
+	switch value {
+	case "", "10":
+		return 10, nil
+	case "auto":
+		return 0, nil
+	case "16":
+		return 16, nil
+	case "8":
+		return 8, nil
+	}
+	return 0, fmt.Errorf("неизвестное основание %q: ожидалось auto, 16, 8 или 10", value)
+}
+
+// radixPrefixes - префиксы, допустимые при явном основании --radix
+var radixPrefixes = map[int][]string{
+	16: {"0x", "0X"},
+	8:  {"0o", "0O"},
+}
+
+// parseRadixKey разбирает целое с основанием --radix. Значения больше int64 (например,
+// адреса ядра 0xffffffff81000000) хранятся как float64 и сравниваются приближенно, а равенство
+// приближений разрешается сравнением текста
+func (s *Sorter) parseRadixKey(key *Key, text string) {
+	text = strings.TrimSpace(text)
+	negative := false
+	if text != "" && (text[0] == '+' || text[0] == '-') {
+		negative = text[0] == '-'
+		text = text[1:]
+	}
+	for _, prefix := range radixPrefixes[s.radix] {
+		if rest, ok := strings.CutPrefix(text, prefix); ok {
+			text = rest
+			break
+		}
+	}
+	if text == "" || text[0] == '+' || text[0] == '-' {
+		return
+	}
+	u, err := strconv.ParseUint(text, s.radix, 64)
+	if err != nil {
+		return
+	}
+	switch {
+	case u <= math.MaxInt64 && negative:
+		key.setInt(-int64(u), false)
+	case u <= math.MaxInt64:
+		key.setInt(int64(u), false)
+	case negative:
+		key.setFloat(-float64(u))
+	default:
+		key.setFloat(float64(u))
+	}
+}
@@ -0,0 +1,243 @@
+package l2sort
+
+import "flag"
+
+// Options содержит настройки одного запуска сортировки. Значения заполняются из флагов
+// командной строки, а в пакетном режиме - из флагов отдельного задания
+type Options struct {
+	Keys keySpecList
+	// WholeLineKey - задан -k 0: ключ - вся строка, а не все поля по отдельности
+	WholeLineKey       bool
+	Numeric            bool
+	Reverse            bool
+	Unique             bool
+	Month              bool
+	IgnoreBlanks       bool
+	Check              bool
+	HumanNumeric       bool
+	BufferSize         string
+	Natural            bool
+	KeepTemp           bool
+	Time               bool
+	TimeFormat         string
+	IP                 bool
+	AlsoOutputs        stringList
+	Strict             bool
+	Debug              bool
+	Backup             bool
+	CompressOutput     bool
+	Filter             string
+	Grep               string
+	Exclude            string
+	TempDir            string
+	MaxTemp            string
+	MergeFanIn         int
+	Compound           bool
+	CompoundSep        string
+	Sed                stringList
+	CompressTemp       bool
+	CompressProgram    string
+	FormatLine         string
+	MaxLineSize        string
+	CacheDir           string
+	MaxLineBytes       int64
+	LongLines          string
+	Encoding           string
+	Bytes              bool
+	KeyRegex           string
+	PartitionBy        int
+	OutputTemplate     string
+	Split              int
+	SplitMode          string
+	GroupBy            bool
+	Aggregate          string
+	Freq               bool
+	NoCounts           bool
+	Length             bool
+	RecordSep          string
+	RecordKey          string
+	Skip               int
+	Head               int
+	Comments           string
+	CommentPrefix      string
+	UniqueKeep         string
+	UniqueOnly         bool
+	DupsOutput         string
+	CheckUnique        bool
+	NumericLocale      string
+	Radix              string
+	Duration           bool
+	Progress           bool
+	Stats              bool
+	Mmap               bool
+	Resume             bool
+	KeyOnly            bool
+	OutputDelimiter    string
+	OutputFields       string
+	Order              string
+	FieldRegex         string
+	FixedCols          string
+	Missing            string
+	Normalize          string
+	Alphabet           string
+	Expr               string
+	KeyTypes           stringList
+	Index              string
+	IndexStride        string
+	Sample             int
+	SamplePct          float64
+	Seed               uint64
+	RandomSort         bool
+	Shuffle            bool
+	Email              bool
+	URL                bool
+	MAC                bool
+	UUID               bool
+	DryRun             bool
+	Checksum           bool
+	ChecksumFile       bool
+	TSV                bool
+	ToSQLite           string
+	SQLiteTable        string
+	By                 string
+	ByMissing          string
+	Numerals           bool
+	Binary             string
+	KeyTransform       string
+	HashOrder          bool
+	HashSalt           string
+	RecordSize         int
+	KeyOffset          int
+	KeySize            int
+	Stable             bool
+	Compat             string
+	InferTypes         bool
+	VerifyOutput       bool
+	Epsilon            float64
+	IgnoreLeadingZeros bool
+}
+
+// registerFlags привязывает флаги к полям o. Текущие значения полей становятся значениями
+// по умолчанию, поэтому флаги задания в пакетном режиме дополняют общие, а не сбрасывают их
+func (o *Options) registerFlags(fs *flag.FlagSet) {
+	fs.Var(&keySpecFlag{specs: &o.Keys, whole: &o.WholeLineKey}, "k", "Ключ сортировки: номер колонки с модификаторами, например 2 или 3r (r - обратный порядок, b - без начальных пробелов, f - без учета регистра) и типом сравнения этого ключа - буквой n, h, M, V, R или :имя, например 2n или 3:time, допуском числового ключа после ~, как в --epsilon, например 2n~1e-9, и преобразованиями после @, как в --key-transform, например 2@trim,lower; можно указать несколько раз, 0 - вся строка")
+	fs.BoolVar(&o.Numeric, "n", o.Numeric, "Сортировать по числовому значению")
+	fs.StringVar(&o.NumericLocale, "numeric-locale", o.NumericLocale, "Разделители чисел для -n: c, en (1,000.5; по умолчанию), ru и fr (1 000,5; пробел и неразрывный пробел), de (1.000,5), ch (1'000.5) или пара символов группы и дроби, например \",.\". При разбиении на поля по пробелам число с пробелом-разделителем делится на два поля: такие числа сравнивайте с полями --field-regex или --tsv либо целой строкой -k 0")
+	fs.StringVar(&o.Radix, "radix", o.Radix, "Основание целых для -n: 10 (по умолчанию), 16 (0x7fff), 8 (0755) или auto - по префиксу 0x, 0o, 0b или ведущему 0")
+	fs.BoolVar(&o.Reverse, "r", o.Reverse, "Сортировать в обратном порядке")
+	fs.BoolVar(&o.Stable, "s", o.Stable, "Устойчивая сортировка: строки с равными ключами сохраняют порядок входа, а не сравниваются целиком в последнюю очередь, как в GNU sort")
+	fs.BoolVar(&o.VerifyOutput, "verify-output", o.VerifyOutput, "После записи перечитать результат и проверить тем же сравнением порядок строк и их число; при расхождении результат удаляется, код завершения ненулевой")
+	fs.Float64Var(&o.Epsilon, "epsilon", o.Epsilon, "Допуск группировки чисел -n и -h: сортировка остается точной, а -u и --group-by считают значения, отличающиеся от первого в группе не больше чем на допуск (например, 1e-9), одним ключом; -u тогда удаляет все строки с такими ключами. Свой допуск ключа задается в -k, например -k 2n~1e-6")
+	fs.BoolVar(&o.IgnoreLeadingZeros, "ignore-leading-zeros", o.IgnoreLeadingZeros, "Не учитывать ведущие нули в ключах -n, -h, -V и --compound: \"000123\" и \"123\" равны при сортировке и -u, который тогда удаляет все строки с равными ключами; строки выводятся без изменений")
+	fs.BoolVar(&o.InferTypes, "infer-types", o.InferTypes, "Определить тип каждого ключа без модификатора типа по первым 1000 строкам входа: целые и дробные числа, даты, месяцы, версии или строки; если в ключе значения разных типов, выбирается самый частый и выводится предупреждение")
+	fs.StringVar(&o.Compat, "compat", o.Compat, "Режим совместимости gnu: ключи и сравнение как у coreutils sort при LC_ALL=C - без -k ключ вся строка, -k N - от поля N с начальными пробелами до конца строки, -k N,M - до конца поля M, текст побайтово, -n, -h и -M по правилам GNU (ключ без числа или месяца равен 0, другие типы сравнения недоступны), -u удаляет строки с равными ключами")
+	fs.BoolVar(&o.Unique, "u", o.Unique, "Не выводить повторяющиеся строки")
+	fs.StringVar(&o.UniqueKeep, "unique-keep", o.UniqueKeep, "Какое из повторяющихся вхождений оставлять при -u и --unique-only: first (по умолчанию) или last")
+	fs.BoolVar(&o.UniqueOnly, "unique-only", o.UniqueOnly, "Только удалить повторы, как -u, сохранив порядок входа, без сортировки: различные строки хранятся в памяти, повторы находятся по хэш-таблице")
+	fs.StringVar(&o.DupsOutput, "dups-output", o.DupsOutput, "Записать отброшенные -u повторы с числом отброшенных копий в отдельный файл")
+	fs.BoolVar(&o.Month, "M", o.Month, "Сортировать по названию месяца")
+	fs.BoolVar(&o.IgnoreBlanks, "b", o.IgnoreBlanks, "Игнорировать начальные пробелы в каждом ключе (для отдельного ключа - модификатор b в -k)")
+	fs.IntVar(&o.Skip, "skip", o.Skip, "Вывести первые N строк (например, заголовок и комментарии) без сортировки")
+	fs.IntVar(&o.Sample, "sample", o.Sample, "Отобрать за один проход N случайных строк входа (равновероятно) и отсортировать только их")
+	fs.Float64Var(&o.SamplePct, "sample-pct", o.SamplePct, "Отобрать каждую строку входа с вероятностью P процентов и отсортировать только отобранные")
+	fs.Uint64Var(&o.Seed, "seed", o.Seed, "Начальное значение генератора случайных чисел для --sample, --sample-pct, -R и --shuffle; 0 - случайное")
+	fs.BoolVar(&o.RandomSort, "R", o.RandomSort, "Случайный порядок по хэшу ключей с солью из --seed: строки с равными ключами остаются рядом, как в GNU sort")
+	fs.BoolVar(&o.RandomSort, "random-sort", o.RandomSort, "То же, что -R")
+	fs.BoolVar(&o.HashOrder, "hash-order", o.HashOrder, "Упорядочить по хэшу строки (при -k - ключей) с солью --hash-salt: порядок выглядит случайным, но одинаков при каждом запуске и на любой машине; для отдельного ключа - -k 2:hash")
+	fs.StringVar(&o.HashSalt, "hash-salt", o.HashSalt, "Соль для --hash-order и ключей :hash; разная соль дает разный порядок")
+	fs.BoolVar(&o.Shuffle, "shuffle", o.Shuffle, "Перемешать строки в равновероятном порядке (как shuf), не группируя равные ключи")
+	fs.IntVar(&o.Head, "head", o.Head, "Вывести только первые N строк результата; без -u, --freq и --group-by в памяти держится не больше N строк")
+	fs.StringVar(&o.Comments, "comments", o.Comments, "Обработка строк-комментариев: keep (оставить при следующей строке данных), top (вывести в начале), drop (удалить); по умолчанию сортируются как данные")
+	fs.StringVar(&o.CommentPrefix, "comment-prefix", o.CommentPrefix, "Начало строки-комментария для --comments (по умолчанию #)")
+	fs.BoolVar(&o.Check, "c", o.Check, "Проверить, отсортированы ли данные, ничего не записывая; если нет - сообщить первую строку не по порядку и завершиться с кодом 1")
+	fs.BoolVar(&o.DryRun, "dry-run", o.DryRun, "Ничего не записывая, сообщить, отсортированы ли данные, сколько строк сменят позицию и сколько повторов удалит -u")
+	fs.BoolVar(&o.CheckUnique, "check-unique", o.CheckUnique, "Не сортируя, найти в отсортированных данных повторяющиеся ключи и вывести номера строк")
+	fs.BoolVar(&o.HumanNumeric, "h", o.HumanNumeric, "Сортировать по числовому значению с учетом суффиксов")
+	fs.StringVar(&o.RecordSep, "record-sep", o.RecordSep, "Регулярное выражение строки-разделителя многострочных записей, например '^$' для блоков через пустую строку; записи сортируются целиком")
+	fs.StringVar(&o.RecordKey, "record-key", o.RecordKey, "Регулярное выражение строки записи, из которой берутся ключи (по умолчанию - первая строка записи)")
+	fs.StringVar(&o.KeyRegex, "key-regex", o.KeyRegex, "Регулярное выражение, группы захвата которого становятся ключами вместо колонок (-k N выбирает N-ю группу)")
+	fs.StringVar(&o.Expr, "expr", o.Expr, "Шаблон text/template, вычисляющий ключ строки вместо -k: доступны .Line, .F (поля), .Field N и функции first, last, lower, upper, trim, add, sub, mul, div, например '{{last 2 .Line}}' или '{{div (.Field 1) (.Field 2)}}' с -n")
+	fs.StringVar(&o.FieldRegex, "field-regex", o.FieldRegex, "Регулярное выражение - разделитель полей для -k вместо пробелов, например '\\s*[|\\t]\\s*'")
+	fs.BoolVar(&o.TSV, "tsv", o.TSV, "Поля для -k разделены табуляцией (TSV): пустые поля сохраняют места, экранирование \\t, \\n и \\\\ в полях раскрывается")
+	fs.StringVar(&o.FixedCols, "fixed-cols", o.FixedCols, "Колонки фиксированной ширины для -k вместо полей: позиции символов с нуля, например 0-9,10-25,26-")
+	fs.StringVar(&o.Missing, "missing", o.Missing, "Строки без ключа -k: first - в начало, last - в конец (независимо от -r), error - ошибка с номером строки")
+	fs.StringVar(&o.KeyTransform, "key-transform", o.KeyTransform, "Преобразования ключей перед сравнением через запятую: lower, upper, trim, strip-punct, strip-prefix=ТЕКСТ, strip-suffix=ТЕКСТ; строки выводятся без изменений; для отдельного ключа - -k 2@trim,lower")
+	fs.StringVar(&o.Normalize, "normalize", o.Normalize, "Нормализовать ключи Unicode перед сравнением и удалением повторов: nfc или nfkc; строки выводятся без изменений")
+	fs.StringVar(&o.Alphabet, "alphabet", o.Alphabet, "Файл с порядком символов для текстового сравнения: по символу или лексеме на строку, например A, C, G, T; остальные символы идут после них")
+	fs.StringVar(&o.Order, "order", o.Order, "Цепочка правил сравнения через запятую, например 'k2n,k1f,length,line': строки, равные по правилу, сравниваются по следующему, а равные по всем - остаются в порядке входа. kСПЕЦ - ключ, как в -k; length - длина строки в символах; line - вся строка")
+	fs.BoolVar(&o.Length, "length", o.Length, "Сортировать по длине в символах: всей строки, а при -k или --key-regex - ключа; равные по длине - обычным сравнением")
+	fs.BoolVar(&o.Natural, "natural", o.Natural, "Естественная сортировка: числа внутри строк сравниваются по значению (file2 < file10)")
+	fs.BoolVar(&o.Numerals, "numerals", o.Numerals, "Сравнивать по значению числа в ключах, записанные римскими цифрами (IV, XII) и словами по-английски и по-русски (nine, twenty-one, двадцать одна); ключи без типа сравниваются естественно, как при --natural")
+	fs.BoolVar(&o.Time, "time", o.Time, "Сортировать по метке времени (RFC3339, syslog, Apache CLF и др.)")
+	fs.StringVar(&o.TimeFormat, "time-format", o.TimeFormat, "Формат метки времени в нотации Go, например 2006-01-02T15:04:05 (включает --time)")
+	fs.BoolVar(&o.Duration, "duration", o.Duration, "Сортировать по длительности: 250ms, 1h30m, 2d, 1w (дни и недели в дополнение к time.ParseDuration)")
+	fs.BoolVar(&o.IP, "ip", o.IP, "Сортировать по IPv4/IPv6-адресу")
+	fs.BoolVar(&o.Email, "email", o.Email, "Сортировать адреса электронной почты по домену (без учета регистра), затем по имени")
+	fs.BoolVar(&o.URL, "url", o.URL, "Сортировать URL по хосту (без учета регистра), затем по пути и строке запроса")
+	fs.BoolVar(&o.Compound, "compound", o.Compound, "Сортировать по составным номерам вроде 3.12.7.1 (пункты, разделы): части сравниваются как целые числа, 3.12 идет раньше 3.12.1; для отдельного ключа - -k 2:compound")
+	fs.StringVar(&o.CompoundSep, "compound-sep", o.CompoundSep, "Разделитель частей составного номера для --compound и :compound (по умолчанию точка)")
+	fs.BoolVar(&o.MAC, "mac", o.MAC, "Сортировать по MAC-адресу в любой записи (01:23:..., 01-23-..., 0123.4567.89ab) без учета регистра")
+	fs.BoolVar(&o.UUID, "uuid", o.UUID, "Сортировать по UUID без учета регистра; UUID версий 1, 6 и 7 идут по метке времени")
+	fs.StringVar(&o.By, "by", o.By, "Строки - пути к файлам: сортировать по времени изменения (mtime) или размеру (size) файлов; для отдельного ключа - -k 2:mtime или -k 2:size")
+	fs.StringVar(&o.ByMissing, "by-missing", o.ByMissing, "Пути, о которых нет сведений (файла нет или он недоступен), для --by и ключей :mtime и :size: warn (по умолчанию) - предупредить и поставить в начало, skip - пропустить строку, error - ошибка с номером строки")
+	fs.Var(&o.KeyTypes, "key-type", "Сортировать по типу ключа, зарегистрированному приложением через RegisterKeyType; для отдельного ключа - -k 2:имя. С --record-size - тип ключа записи: bytes (по умолчанию), uint16be ... uint64be, int16be ... int64be и такие же с le")
+	fs.StringVar(&o.Index, "index", o.Index, "Записать рядом с результатом индекс: смещение в байтах и первый ключ строк, с которых начинается новое значение ключа")
+	fs.StringVar(&o.IndexStride, "index-stride", o.IndexStride, "Наименьшее расстояние между записями --index, например 64K (по умолчанию - запись на каждое значение ключа)")
+	fs.Var(&o.AlsoOutputs, "also-output", "Дополнительно записать результат в файл (\"-\" - стандартный вывод); можно указать несколько раз")
+	fs.BoolVar(&o.Strict, "strict", o.Strict, "Считать ошибкой (код завершения 4) проблемы в данных: нечисловой ключ -n и -h, переполнение числового ключа, строку без колонки ключа (если не задан --missing) и некорректный UTF-8 (если не задан --binary)")
+	fs.BoolVar(&o.Debug, "debug", o.Debug, "Выводить в stderr отладочные сообщения и разметку ключей каждой строки с правилом сравнения")
+	fs.BoolVar(&o.Backup, "backup", o.Backup, "Сохранить исходный файл с расширением .bak")
+	fs.BoolVar(&o.Checksum, "checksum", o.Checksum, "Вывести в stderr SHA-256 записанного результата в формате sha256sum")
+	fs.BoolVar(&o.ChecksumFile, "checksum-file", o.ChecksumFile, "Сохранить SHA-256 результата рядом с ним в ФАЙЛ.sha256 (проверка - --verify)")
+	fs.BoolVar(&o.CompressOutput, "compress-output", o.CompressOutput, "Сжимать результат gzip (файлы *.gz сжимаются всегда)")
+	fs.StringVar(&o.Filter, "filter", o.Filter, "Сортировать только строки, удовлетворяющие выражению, например 'f3 > 100' или 'fields[2] == \"ERROR\" && f4 ~ \"^web\"' (fN - поле с 1, fields[N] - с 0); остальные строки отбрасываются при чтении, до сортировки")
+	fs.StringVar(&o.Grep, "grep", o.Grep, "Сортировать только строки, соответствующие регулярному выражению")
+	fs.StringVar(&o.Grep, "include", o.Grep, "То же, что --grep")
+	fs.StringVar(&o.Exclude, "exclude", o.Exclude, "Отбросить до сортировки строки, соответствующие регулярному выражению (как grep -v); вместе с --grep строка должна соответствовать --grep и не соответствовать --exclude")
+	fs.Var(&o.Sed, "sed", "Замена в стиле sed, применяемая к строкам после сортировки, например 's/foo/bar/g'; можно указать несколько раз")
+	fs.StringVar(&o.FormatLine, "format-line", o.FormatLine, "Шаблон text/template для выводимой строки; доступны .Line, .Fields, .Key, .Keys")
+	fs.BoolVar(&o.KeyOnly, "output-key-only", o.KeyOnly, "Выводить только ключи сортировки (как cut) вместо строк целиком")
+	fs.BoolVar(&o.KeyOnly, "cut", o.KeyOnly, "То же, что --output-key-only")
+	fs.StringVar(&o.OutputDelimiter, "output-delimiter", o.OutputDelimiter, "Разделитель ключей при --output-key-only и полей при --output-fields (\\t - табуляция, по умолчанию пробел)")
+	fs.StringVar(&o.OutputFields, "output-fields", o.OutputFields, "Выводить вместо строки ее поля в заданном порядке, например 3,1,2 или 2-4,1 (как awk '{print $3, $1, $2}'); поля делятся так же, как для ключей, и соединяются тем же разделителем (для --field-regex - первым найденным в строке) или --output-delimiter")
+	fs.BoolVar(&o.GroupBy, "group-by", o.GroupBy, "Свернуть подряд идущие строки с равными ключами в одну строку: ключ и значения --aggregate")
+	fs.StringVar(&o.Aggregate, "aggregate", o.Aggregate, "Функции для --group-by через запятую: count, sum:N, min:N, max:N, first:N, last:N (N - колонка с 1); по умолчанию count")
+	fs.BoolVar(&o.Freq, "freq", o.Freq, "Выводить различные строки по убыванию частоты с числом вхождений (как sort | uniq -c | sort -rn)")
+	fs.BoolVar(&o.NoCounts, "no-counts", o.NoCounts, "Не выводить число вхождений при --freq")
+	fs.IntVar(&o.PartitionBy, "partition-by", o.PartitionBy, "Разложить результат по файлам по значению колонки N (с 1) вместо записи в исходный файл")
+	fs.StringVar(&o.OutputTemplate, "output-template", o.OutputTemplate, "Шаблон имени файла для --partition-by ({key} - значение колонки) и --split ({n} - номер части), например 'out-{key}.txt'")
+	fs.IntVar(&o.Split, "split", o.Split, "Разложить результат на N отсортированных частей ФАЙЛ.0 ... ФАЙЛ.N-1 (или по --output-template)")
+	fs.StringVar(&o.SplitMode, "split-mode", o.SplitMode, "Способ раскладки для --split: round-robin (по кругу) или range (непрерывными диапазонами)")
+	fs.StringVar(&o.ToSQLite, "to-sqlite", o.ToSQLite, "Загрузить результат, разбитый на поля, в новую базу SQLite ФАЙЛ вместо записи текста (нужна программа sqlite3); колонки ключей -k индексируются")
+	fs.StringVar(&o.SQLiteTable, "sqlite-table", o.SQLiteTable, "Имя таблицы для --to-sqlite (по умолчанию rows)")
+	fs.BoolVar(&o.CompressTemp, "compress-temp", o.CompressTemp, "Сжимать временные файлы встроенным gzip")
+	fs.StringVar(&o.CompressProgram, "compress-program", o.CompressProgram, "Сжимать временные файлы внешней программой PROG (распаковка - PROG -d)")
+	fs.BoolVar(&o.Progress, "progress", o.Progress, "Раз в секунду выводить в stderr прочитанный объем, число строк и прогонов, записанные строки и скорость")
+	fs.BoolVar(&o.Mmap, "mmap", o.Mmap, "Отображать входной файл в память и сортировать ссылки на строки в нем, не копируя строки (только несжатый UTF-8)")
+	fs.BoolVar(&o.Stats, "stats", o.Stats, "После сортировки вывести в stderr число строк и сравнений, время чтения, сортировки и записи, пик памяти, число временных файлов, план слияния и число удаленных повторов")
+	fs.BoolVar(&o.Resume, "resume", o.Resume, "Сохранять готовые прогоны между запусками: после прерывания повторный запуск с --resume продолжит с них")
+	fs.BoolVar(&o.KeepTemp, "keep-temp", o.KeepTemp, "Не удалять временные файлы (для отладки)")
+	fs.StringVar(&o.BufferSize, "S", o.BufferSize, "Ограничение памяти под данные, например 512M (без суффикса - в килобайтах); при превышении данные сбрасываются на диск")
+	fs.StringVar(&o.MaxLineSize, "max-line-size", o.MaxLineSize, "Максимальная длина строки, например 16M (без суффикса - в килобайтах); по умолчанию не ограничена")
+	fs.Int64Var(&o.MaxLineBytes, "max-line-bytes", o.MaxLineBytes, "Длина строки в байтах, сверх которой применяется политика --long-lines (0 - без ограничения)")
+	fs.IntVar(&o.RecordSize, "record-size", o.RecordSize, "Сортировать двоичные записи фиксированной длины N байт без разделителей (\"-\" - стандартный ввод) по ключу --key-offset, --key-size и --key-type")
+	fs.IntVar(&o.KeyOffset, "key-offset", o.KeyOffset, "Смещение ключа в записи --record-size в байтах, с 0")
+	fs.IntVar(&o.KeySize, "key-size", o.KeySize, "Длина ключа в записи --record-size в байтах (по умолчанию - ширина --key-type или до конца записи)")
+	fs.StringVar(&o.Binary, "binary", o.Binary, "Строки с некорректным UTF-8: raw (по умолчанию) - оставить, некорректные байты сравниваются как есть, skip - пропустить и сообщить их число, error - ошибка с номером строки")
+	fs.StringVar(&o.LongLines, "long-lines", o.LongLines, "Политика для строк длиннее --max-line-bytes: truncate-key (ключ по началу строки), skip (пропустить с предупреждением), error")
+	fs.StringVar(&o.Encoding, "encoding", o.Encoding, "Кодировка входа и вывода: utf-8, windows-1251, koi8-r, utf-16le, utf-16be; метка BOM определяется автоматически")
+	fs.BoolVar(&o.Bytes, "bytes", o.Bytes, "Сравнивать текст побайтово независимо от настроек упорядочивания (как LC_ALL=C)")
+	fs.StringVar(&o.CacheDir, "cache-dir", o.CacheDir, "Каталог кэша результатов: повторная сортировка того же входа с теми же опциями копирует готовый результат")
+	fs.Var(&tempDirFlag{dirs: &o.TempDir}, "T", "Каталог для временных файлов (по умолчанию системный); можно указать несколько раз или через двоеточие - каталоги используются по очереди, переполненные пропускаются. Каталоги упавших запусков удаляются автоматически")
+	fs.IntVar(&o.MergeFanIn, "merge-fan-in", o.MergeFanIn, "Сколько временных файлов сливать за раз (не меньше 2); по умолчанию выбирается по ограничению открытых файлов и -S, а если файлов больше, они сливаются в несколько проходов. Выбранный план выводит --stats")
+	fs.StringVar(&o.MaxTemp, "max-temp", o.MaxTemp, "Ограничение места под временные файлы, например 2G (без суффикса - в килобайтах): при превышении сортировка прекращается с ошибкой до записи прогона")
+}
+
+// clone возвращает копию настроек, не разделяющую с оригиналом срезы
+func (o Options) clone() Options {
+	o.Keys = append(keySpecList(nil), o.Keys...)
+	o.AlsoOutputs = append(stringList(nil), o.AlsoOutputs...)
+	o.Sed = append(stringList(nil), o.Sed...)
+	o.KeyTypes = append(stringList(nil), o.KeyTypes...)
+	return o
+}
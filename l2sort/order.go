@@ -0,0 +1,104 @@
+package l2sort
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Правила цепочки --order, кроме ключей kN
+const (
+	orderLength = "length"
+	orderLine   = "line"
+)
+
+// orderRule - звено цепочки --order: ключ с номером key в opts.Keys либо length или line
+type orderRule struct {
+	name string
+	key  int
+}
+
+// resolveOrder разбирает --order: правила через запятую в порядке применения, например
+// "k2n,k1f,length,line". kСПЕЦ - ключ, как в -k (k2n, k3r, k1b:time, k2@trim), length -
+// длина строки в символах, line - вся строка текстом. Строки, равные по правилу,
+// сравниваются по следующему, а равные по всей цепочке сохраняют порядок входа.
+// Ключи цепочки становятся ключами -k, поэтому -k и другие способы задать ключ
+// вместе с --order не указываются
+func (s *Sorter) resolveOrder() error {
+	o := &s.opts
+	if o.Order == "" {
+		return nil
+	}
+	if len(o.Keys) > 0 || o.Length || o.KeyRegex != "" || o.Expr != "" || o.Compat != "" || o.RecordSize > 0 {
+		return fmt.Errorf("в параметрах: --order задает всю цепочку сравнения и несовместим с -k, --length, --key-regex, --expr, --compat и --record-size")
+	}
+	var parts []string
+	for part := range strings.SplitSeq(o.Order, ",") {
+		part = strings.TrimSpace(part)
+		// Как в -k, запятые после @ продолжают цепочку преобразований ключа
+		if n := len(parts); n > 0 && strings.Contains(parts[n-1], "@") && !isOrderRule(part) {
+			parts[n-1] += "," + part
+			continue
+		}
+		parts = append(parts, part)
+	}
+	for _, part := range parts {
+		switch {
+		case part == orderLength || part == orderLine:
+			s.order = append(s.order, orderRule{name: part})
+		case isOrderRule(part):
+			spec, err := parseKeySpec(part[1:])
+			if err != nil {
+				return fmt.Errorf("в параметре --order: %w", err)
+			}
+			if spec.Column == 0 {
+				return fmt.Errorf("в параметре --order: ключ k0 не задан; вся строка - правило line")
+			}
+			s.order = append(s.order, orderRule{key: len(o.Keys)})
+			o.Keys = append(o.Keys, spec)
+		default:
+			return fmt.Errorf("в параметре --order: неизвестное правило %q, ожидалось kСПЕЦ (как в -k, например k2n), length или line", part)
+		}
+	}
+	// Равные по всей цепочке строки остаются в порядке входа
+	o.Stable = true
+	return nil
+}
+
+// isOrderRule сообщает, что part начинает новое правило --order, а не продолжает
+// преобразования предыдущего ключа
+func isOrderRule(part string) bool {
+	return part == orderLength || part == orderLine || len(part) > 1 && part[0] == 'k' && isDigit(part[1])
+}
+
+// compareChain сравнивает строки по цепочке --order до первого различия
+func (s *Sorter) compareChain(a, b *Row) int {
+	for _, rule := range s.order {
+		var c int
+		switch rule.name {
+		case orderLength:
+			c = compareOrdered(int64(utf8.RuneCountInString(a.Original)), int64(utf8.RuneCountInString(b.Original)))
+		case orderLine:
+			c = s.compareText(a.Original, b.Original)
+		default:
+			k := rule.key
+			if k >= len(a.Keys) || k >= len(b.Keys) {
+				if len(a.Keys) == len(b.Keys) {
+					continue
+				}
+				return s.compareMissing(a, b)
+			}
+			if a.keyText(k) == b.keyText(k) {
+				continue
+			}
+			c = s.compareKeys(k, a, b)
+			if s.opts.Keys[k].Reverse {
+				c = -c
+			}
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
@@ -0,0 +1,210 @@
+package l2sort
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// stringList - значение флага, который можно указать несколько раз
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// nopCloser не закрывает стандартный вывод вместе с остальными получателями
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// openOutput открывает получателя результата; "-" означает стандартный вывод.
+// Файлы записываются атомарно: цель подменяется только при успешном Close.
+// Вывод сжимается gzip, если так решает outputGzip.
+// Если задан hash, в него попадают записанные байты после сжатия
+func openOutput(path string, backup, compress bool, hash io.Writer) (io.WriteCloser, error) {
+	gzipped, err := outputGzip(path, compress)
+	if err != nil {
+		return nil, err
+	}
+	var out io.WriteCloser
+	if path == "-" {
+		out = nopCloser{os.Stdout}
+	} else {
+		file, err := createAtomic(path, backup)
+		if err != nil {
+			return nil, err
+		}
+		out = file
+	}
+	if hash != nil {
+		out = &hashedOutput{WriteCloser: out, hash: hash}
+	}
+	if gzipped {
+		return &gzipWriteCloser{Writer: gzip.NewWriter(out), dst: out}, nil
+	}
+	return out, nil
+}
+
+// outputGzip сообщает, сжимать ли вывод в path gzip: при --compress-output, по расширению
+// .gz или если path уже сжат gzip (например, при сортировке файла на месте). Сжатие,
+// которое записать нельзя (bzip2, zstd), - ошибка: иначе сжатый файл был бы перезаписан
+// несжатым под прежним именем
+func outputGzip(path string, compress bool) (bool, error) {
+	if path == "-" {
+		return compress, nil
+	}
+	format := map[string]string{".bz2": "bzip2", ".zst": "zstd"}[filepath.Ext(path)]
+	if format == "" {
+		format = fileCompression(path)
+	}
+	switch format {
+	case "gzip":
+		return true, nil
+	case "":
+		return compress || isCompressedName(path), nil
+	}
+	return false, fmt.Errorf("запись %s не поддерживается: %s нельзя сохранить в том же формате; укажите результат без сжатия или с расширением .gz", format, path)
+}
+
+// gzipWriteCloser сжимает данные и при закрытии завершает поток gzip, а затем закрывает получателя
+type gzipWriteCloser struct {
+	*gzip.Writer
+	dst io.WriteCloser
+}
+
+func (w *gzipWriteCloser) Close() error {
+	if err := w.Writer.Close(); err != nil {
+		return err
+	}
+	return w.dst.Close()
+}
+
+// atomicFile пишет во временный файл в каталоге цели и переименовывает его поверх цели в Close,
+// поэтому сбой посреди записи не портит исходный файл
+type atomicFile struct {
+	*os.File
+	target string
+	backup bool
+}
+
+// createAtomic начинает запись в target. Символическая ссылка сохраняется: заменяется
+// файл, на который она указывает. Новый файл получает права 0666 за вычетом umask, как
+// при os.Create, существующий - сохраняет свои
+func createAtomic(target string, backup bool) (*atomicFile, error) {
+	if resolved, err := filepath.EvalSymlinks(target); err == nil {
+		target = resolved
+	}
+	dir, base := filepath.Split(target)
+	if dir == "" {
+		dir = "."
+	}
+	file, err := createTempFile(dir, "."+base+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	temps.register(file.Name())
+	if info, err := os.Stat(target); err == nil {
+		file.Chmod(info.Mode().Perm())
+	}
+	return &atomicFile{File: file, target: target, backup: backup}, nil
+}
+
+// createTempFile создает в dir новый файл по шаблону pattern, как os.CreateTemp, но
+// с правами 0666 за вычетом umask вместо 0600
+func createTempFile(dir, pattern string) (*os.File, error) {
+	prefix, suffix, _ := strings.Cut(pattern, "*")
+	for range 10000 {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10)+suffix)
+		file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o666)
+		if !os.IsExist(err) {
+			return file, err
+		}
+	}
+	return nil, &os.PathError{Op: "createtemp", Path: filepath.Join(dir, pattern), Err: os.ErrExist}
+}
+
+// Close сбрасывает данные на диск, при необходимости сохраняет оригинал как .bak,
+// переименовывает временный файл поверх цели и сбрасывает на диск каталог цели
+func (f *atomicFile) Close() error {
+	if err := f.File.Sync(); err != nil {
+		f.File.Close()
+		return err
+	}
+	if err := f.File.Close(); err != nil {
+		return err
+	}
+	if f.backup {
+		if err := backupFile(f.target); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(f.File.Name(), f.target); err != nil {
+		return err
+	}
+	temps.forget(f.File.Name())
+	return syncDir(filepath.Dir(f.target))
+}
+
+// syncDir сбрасывает на диск каталог dir, чтобы переименование в нем пережило сбой питания
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// discard закрывает и удаляет незавершенный временный файл, не трогая цель
+func (f *atomicFile) discard() {
+	f.File.Close()
+	os.Remove(f.File.Name())
+	temps.forget(f.File.Name())
+}
+
+func (w *gzipWriteCloser) discard() {
+	discardOutput(w.dst)
+}
+
+// discardOutput отменяет запись в получателя, если он это поддерживает
+func discardOutput(w io.WriteCloser) {
+	if d, ok := w.(interface{ discard() }); ok {
+		d.discard()
+	}
+}
+
+// backupFile сохраняет текущее содержимое файла в path.bak. Используется жесткая ссылка,
+// а если она невозможна - копирование
+func backupFile(path string) error {
+	bak := path + ".bak"
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	os.Remove(bak)
+	if err := os.Link(path, bak); err == nil {
+		return nil
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(bak)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
@@ -0,0 +1,53 @@
+package l2sort
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOutputGzip(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	gzipped := filepath.Join(dir, "log")
+	file, err := os.Create(gzipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(file)
+	zw.Write([]byte("b\na\n"))
+	zw.Close()
+	file.Close()
+
+	tests := []struct {
+		path     string
+		compress bool
+		want     bool
+		fails    bool
+	}{
+		{"-", false, false, false},
+		{"-", true, true, false},
+		{filepath.Join(dir, "new.txt"), false, false, false},
+		{filepath.Join(dir, "new.txt"), true, true, false},
+		{filepath.Join(dir, "new.gz"), false, true, false},
+		{gzipped, false, true, false},
+		{write("plain", []byte("b\na\n")), false, false, false},
+		{filepath.Join(dir, "new.zst"), false, false, true},
+		{filepath.Join(dir, "new.bz2"), true, false, true},
+		{write("renamed-zstd", []byte{0x28, 0xb5, 0x2f, 0xfd, 0}), false, false, true},
+		{write("renamed-bzip2", []byte("BZh9")), false, false, true},
+	}
+	for _, tt := range tests {
+		got, err := outputGzip(tt.path, tt.compress)
+		if (err != nil) != tt.fails || got != tt.want {
+			t.Errorf("outputGzip(%s, %v) = %v, %v", filepath.Base(tt.path), tt.compress, got, err)
+		}
+	}
+}
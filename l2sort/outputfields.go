@@ -0,0 +1,65 @@
+package l2sort
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseOutputFields разбирает --output-fields: номера полей с 1 через запятую в порядке
+// вывода, например "3,1,2", или диапазоны N-M, как в cut. Поле можно вывести несколько раз
+func parseOutputFields(value string) ([]int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var fields []int
+	for part := range strings.SplitSeq(value, ",") {
+		from, to, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		first, err := strconv.Atoi(from)
+		last := first
+		if err == nil && isRange {
+			last, err = strconv.Atoi(to)
+		}
+		if err != nil || first < 1 || last < first {
+			return nil, fmt.Errorf("--output-fields: некорректное поле %q, ожидались номера полей с 1 через запятую, например 3,1,2 или 2-4", part)
+		}
+		for n := first; n <= last; n++ {
+			fields = append(fields, n)
+		}
+	}
+	return fields, nil
+}
+
+// rawFields делит строку на поля для --output-fields так же, как для ключей, но без
+// раскрытия экранирования TSV, и возвращает разделитель, которым их соединить: табуляцию
+// для --tsv, первый найденный в строке разделитель --field-regex, иначе пробел
+func (s *Sorter) rawFields(line string) ([]string, string) {
+	switch {
+	case s.fixedCols != nil:
+		return s.cutFixedCols(line), " "
+	case s.opts.TSV:
+		return strings.Split(line, "\t"), "\t"
+	case s.fieldSep != nil:
+		sep := s.fieldSep.FindString(line)
+		if sep == "" {
+			sep = " "
+		}
+		return s.fieldSep.Split(line, -1), sep
+	}
+	return strings.Fields(line), " "
+}
+
+// pickOutputFields собирает строку из полей fields по номерам numbers; поле за концом
+// строки выводится пустым, чтобы остальные не сдвигались
+func pickOutputFields(buf *strings.Builder, fields []string, numbers []int, delim string) string {
+	buf.Reset()
+	for i, n := range numbers {
+		if i > 0 {
+			buf.WriteString(delim)
+		}
+		if n <= len(fields) {
+			buf.WriteString(fields[n-1])
+		}
+	}
+	return buf.String()
+}
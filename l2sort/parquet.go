@@ -0,0 +1,935 @@
+package l2sort
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// parquetMagic обрамляет файл Parquet с обеих сторон
+const parquetMagic = "PAR1"
+
+// Физические типы Parquet
+const (
+	parquetBoolean   = 0
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetInt96     = 3
+	parquetFloat     = 4
+	parquetDouble    = 5
+	parquetByteArray = 6
+	parquetFixed     = 7
+)
+
+// Типы страниц и кодировки, которые понимает l2sort
+const (
+	parquetDataPage       = 0
+	parquetDictionaryPage = 2
+	parquetDataPageV2     = 3
+
+	parquetPlain         = 0
+	parquetPlainDict     = 2
+	parquetRLE           = 3
+	parquetRLEDictionary = 8
+	parquetUncompressed  = 0
+	parquetSnappy        = 1
+	parquetGzip          = 2
+	parquetOptional      = 1
+	parquetRepeated      = 2
+)
+
+// parquetTimestampLayout - вид меток времени в ключе и NDJSON: одинаковая ширина
+// делает текстовый порядок хронологическим
+const parquetTimestampLayout = "2006-01-02T15:04:05.000000000Z"
+
+// Представление значений колонки в тексте ключа и NDJSON
+const (
+	parquetKindPlain = iota
+	parquetKindString
+	parquetKindDecimal
+	parquetKindDate
+	parquetKindTime
+	parquetKindTimestamp
+	parquetKindUnsigned
+)
+
+var parquetCodecNames = map[int64]string{3: "LZO", 4: "BROTLI", 5: "LZ4", 6: "ZSTD", 7: "LZ4_RAW"}
+
+// parquetColumn - колонка плоской схемы. raw - исходный SchemaElement, который при записи
+// Parquet копируется без изменений вместе с логическим типом
+type parquetColumn struct {
+	name       string
+	typ        int64
+	typeLength int
+	optional   bool
+	kind       int
+	scale      int
+	unit       time.Duration
+	raw        []byte
+}
+
+// parquetTable - содержимое файла Parquet: схема и строки значений. Значение - nil (null),
+// bool, int32, int64, float32, float64 или []byte (BYTE_ARRAY, FIXED_LEN_BYTE_ARRAY, INT96)
+type parquetTable struct {
+	root     []byte
+	metadata [][]byte
+	columns  []parquetColumn
+	rows     [][]any
+}
+
+// runParquet выполняет подкоманду
+//
+//	l2sort parquet --column ИМЯ [--column ...] [опции] [-o результат.parquet|результат.ndjson|-] файл.parquet
+//
+// Сортирует записи файла Parquet по колонкам с заданными именами теми же компараторами,
+// что и текст: числовые колонки - с -n, строки - по умолчанию, а метки времени и даты
+// записываются в ключ в виде 2006-01-02T15:04:05.000000000Z, который упорядочен и как
+// текст. Результат записывается в Parquet, если имя -o оканчивается на .parquet, иначе
+// в NDJSON (по объекту на запись); по умолчанию NDJSON выводится в стандартный вывод.
+// Возвращает код завершения
+func runParquet(ctx context.Context, args []string, base Options) int {
+	cmd, _ := findCommand("parquet")
+	fs, o := sortFlagSet("parquet", cmd.usage, base)
+	var columns stringList
+	fs.Var(&columns, "column", "Колонка сортировки по имени; можно указать несколько раз")
+	output := fs.String("o", "-", "Файл результата: .parquet - Parquet, иначе NDJSON (\"-\" - стандартный вывод)")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(files) != 1 || len(columns) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	sorter, err := NewSorter(*o)
+	if err != nil {
+		return reportUsage(err)
+	}
+	if err := sorter.SortParquet(ctx, files[0], *output, columns); err != nil {
+		return reportError(err)
+	}
+	return 0
+}
+
+// SortParquet сортирует записи файла Parquet input по колонкам columns и записывает их в
+// output. Поддерживаются плоские схемы без повторяющихся полей, кодировки PLAIN и словарь,
+// сжатие Snappy и gzip, страницы данных обеих версий. Файл читается в память целиком;
+// Parquet записывается одной группой строк без сжатия и словаря
+func (s *Sorter) SortParquet(ctx context.Context, input, output string, columns []string) error {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return fmt.Errorf("при чтении файла: %w", err)
+	}
+	table, err := readParquet(data)
+	if err != nil {
+		return fmt.Errorf("при чтении файла Parquet %s: %w", input, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var cols []int
+	for _, name := range columns {
+		found := -1
+		for i, col := range table.columns {
+			if col.name == name {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			return fmt.Errorf("в параметре --column: в файле нет колонки %q", name)
+		}
+		cols = append(cols, found)
+	}
+
+	// Запись с null в ключевой колонке считается записью без ключа
+	keyed := make([]Row, len(table.rows))
+	for i, values := range table.rows {
+		texts := make([]string, len(cols))
+		for k, col := range cols {
+			if values[col] == nil {
+				texts = nil
+				break
+			}
+			texts[k] = table.columns[col].text(values[col])
+		}
+		keyed[i] = s.newRow(nil, strings.Join(texts, "\t"), s.trimKeyBlanks(texts))
+	}
+	order := make([]int, len(table.rows))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return s.sortOrder(&keyed[order[a]], &keyed[order[b]]) < 0
+	})
+	sorted := make([][]any, len(order))
+	for i, n := range order {
+		sorted[i] = table.rows[n]
+	}
+	table.rows = sorted
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.writeOutputs(output, nil, func(w io.Writer) error {
+		if strings.HasSuffix(strings.ToLower(output), ".parquet") {
+			return table.writeParquet(w)
+		}
+		return table.writeNDJSON(w)
+	})
+}
+
+// readParquet разбирает файл Parquet целиком
+func readParquet(data []byte) (*parquetTable, error) {
+	if len(data) < 12 || string(data[:4]) != parquetMagic || string(data[len(data)-4:]) != parquetMagic {
+		return nil, fmt.Errorf("это не файл Parquet")
+	}
+	size := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if size > len(data)-12 {
+		return nil, fmt.Errorf("некорректная длина метаданных")
+	}
+	r := thriftReader{buf: data[len(data)-8-size : len(data)-8]}
+	meta, err := r.readStruct()
+	if err != nil {
+		return nil, err
+	}
+	schema, _ := meta.field(2)
+	if len(schema.list) < 2 {
+		return nil, fmt.Errorf("в схеме нет колонок")
+	}
+	table := &parquetTable{root: schema.list[0].raw}
+	if int(schema.list[0].int(5, 0)) != len(schema.list)-1 {
+		return nil, fmt.Errorf("поддерживаются только плоские схемы")
+	}
+	for _, el := range schema.list[1:] {
+		if el.int(5, 0) > 0 {
+			return nil, fmt.Errorf("поддерживаются только плоские схемы")
+		}
+		name, _ := el.field(4)
+		if el.int(3, 0) == parquetRepeated {
+			return nil, fmt.Errorf("повторяющееся поле %q не поддерживается", name.b)
+		}
+		table.columns = append(table.columns, newParquetColumn(el, string(name.b)))
+	}
+	if kv, ok := meta.field(5); ok {
+		for _, item := range kv.list {
+			table.metadata = append(table.metadata, item.raw)
+		}
+	}
+
+	groups, _ := meta.field(4)
+	for _, group := range groups.list {
+		n := int(group.int(3, 0))
+		chunks, _ := group.field(1)
+		if len(chunks.list) != len(table.columns) {
+			return nil, fmt.Errorf("в группе строк %d колонок вместо %d", len(chunks.list), len(table.columns))
+		}
+		first := len(table.rows)
+		for i := 0; i < n; i++ {
+			table.rows = append(table.rows, make([]any, len(table.columns)))
+		}
+		for c, chunk := range chunks.list {
+			if _, ok := chunk.field(1); ok {
+				return nil, fmt.Errorf("колонки во внешних файлах не поддерживаются")
+			}
+			md, _ := chunk.field(3)
+			values, err := readParquetChunk(data, md, &table.columns[c], n)
+			if err != nil {
+				return nil, fmt.Errorf("в колонке %q: %w", table.columns[c].name, err)
+			}
+			for i, v := range values {
+				table.rows[first+i][c] = v
+			}
+		}
+	}
+	return table, nil
+}
+
+// newParquetColumn определяет по логическому типу (или устаревшему converted_type),
+// как показывать значения колонки
+func newParquetColumn(el thriftValue, name string) parquetColumn {
+	col := parquetColumn{
+		name:       name,
+		typ:        el.int(1, -1),
+		typeLength: int(el.int(2, 0)),
+		optional:   el.int(3, 0) == parquetOptional,
+		scale:      int(el.int(7, 0)),
+		unit:       time.Millisecond,
+		raw:        el.raw,
+	}
+	if logical, ok := el.field(10); ok {
+		switch {
+		case logical.has(1), logical.has(4), logical.has(12):
+			col.kind = parquetKindString
+		case logical.has(5):
+			dec, _ := logical.field(5)
+			col.kind, col.scale = parquetKindDecimal, int(dec.int(1, 0))
+		case logical.has(6):
+			col.kind = parquetKindDate
+		case logical.has(7), logical.has(8):
+			col.kind = parquetKindTime
+			id := int16(7)
+			if logical.has(8) {
+				col.kind, id = parquetKindTimestamp, 8
+			}
+			t, _ := logical.field(id)
+			unit, _ := t.field(2)
+			switch {
+			case unit.has(2):
+				col.unit = time.Microsecond
+			case unit.has(3):
+				col.unit = time.Nanosecond
+			}
+		case logical.has(10):
+			integer, _ := logical.field(10)
+			if integer.int(2, 1) == 0 {
+				col.kind = parquetKindUnsigned
+			}
+		}
+		return col
+	}
+	switch el.int(6, -1) {
+	case 0, 4, 19:
+		col.kind = parquetKindString
+	case 5:
+		col.kind = parquetKindDecimal
+	case 6:
+		col.kind = parquetKindDate
+	case 7, 8:
+		col.kind = parquetKindTime
+	case 9, 10:
+		col.kind = parquetKindTimestamp
+	case 11, 12, 13, 14:
+		col.kind = parquetKindUnsigned
+	}
+	if c := el.int(6, -1); c == 8 || c == 10 {
+		col.unit = time.Microsecond
+	}
+	return col
+}
+
+// readParquetChunk читает n значений колонки из ее страниц
+func readParquetChunk(data []byte, md thriftValue, col *parquetColumn, n int) ([]any, error) {
+	codec := md.int(4, parquetUncompressed)
+	total := int(md.int(5, 0))
+	pos := md.int(9, 0)
+	if dict := md.int(11, 0); dict > 0 && dict < pos {
+		pos = dict
+	}
+	var dict []any
+	values := make([]any, 0, n)
+	for len(values) < total {
+		if pos < 0 || pos >= int64(len(data)) {
+			return nil, fmt.Errorf("страница за пределами файла")
+		}
+		r := thriftReader{buf: data, pos: int(pos)}
+		header, err := r.readStruct()
+		if err != nil {
+			return nil, err
+		}
+		size := int(header.int(3, 0))
+		if size < 0 || r.pos+size > len(data) {
+			return nil, fmt.Errorf("страница за пределами файла")
+		}
+		page := data[r.pos : r.pos+size]
+		pos = int64(r.pos + size)
+
+		switch header.int(1, -1) {
+		case parquetDictionaryPage:
+			body, err := parquetDecompress(codec, page)
+			if err != nil {
+				return nil, err
+			}
+			dh, _ := header.field(7)
+			if dict, err = col.decodePlain(body, int(dh.int(1, 0))); err != nil {
+				return nil, fmt.Errorf("в словаре: %w", err)
+			}
+		case parquetDataPage:
+			body, err := parquetDecompress(codec, page)
+			if err != nil {
+				return nil, err
+			}
+			dh, _ := header.field(5)
+			count := int(dh.int(1, 0))
+			var defs []uint32
+			if col.optional {
+				if len(body) < 4 || int(binary.LittleEndian.Uint32(body)) > len(body)-4 {
+					return nil, fmt.Errorf("уровни определения за пределами страницы")
+				}
+				end := 4 + int(binary.LittleEndian.Uint32(body))
+				if defs, err = decodeHybrid(body[4:end], 1, count); err != nil {
+					return nil, err
+				}
+				body = body[end:]
+			}
+			if values, err = col.appendPage(values, body, dh.int(2, parquetPlain), count, defs, dict); err != nil {
+				return nil, err
+			}
+		case parquetDataPageV2:
+			dh, _ := header.field(8)
+			count := int(dh.int(1, 0))
+			defLen, repLen := int(dh.int(5, 0)), int(dh.int(6, 0))
+			if repLen != 0 {
+				return nil, fmt.Errorf("уровни повторения не поддерживаются")
+			}
+			if defLen < 0 || defLen > len(page) {
+				return nil, fmt.Errorf("уровни определения за пределами страницы")
+			}
+			var defs []uint32
+			if col.optional {
+				if defs, err = decodeHybrid(page[:defLen], 1, count); err != nil {
+					return nil, err
+				}
+			}
+			body := page[defLen:]
+			if dh.int(7, 1) != 0 {
+				if body, err = parquetDecompress(codec, body); err != nil {
+					return nil, err
+				}
+			}
+			if values, err = col.appendPage(values, body, dh.int(4, parquetPlain), count, defs, dict); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(values) != n {
+		return nil, fmt.Errorf("значений %d, а строк %d", len(values), n)
+	}
+	return values, nil
+}
+
+// appendPage добавляет к values count значений страницы; defs - уровни определения
+// (0 - null) или nil для обязательной колонки
+func (col *parquetColumn) appendPage(values []any, body []byte, encoding int64, count int, defs []uint32, dict []any) ([]any, error) {
+	present := count
+	if defs != nil {
+		present = 0
+		for _, d := range defs {
+			present += int(d)
+		}
+	}
+	var page []any
+	switch encoding {
+	case parquetPlain:
+		var err error
+		if page, err = col.decodePlain(body, present); err != nil {
+			return nil, err
+		}
+	case parquetPlainDict, parquetRLEDictionary:
+		if dict == nil {
+			return nil, fmt.Errorf("страница ссылается на словарь, которого нет")
+		}
+		if len(body) == 0 {
+			return nil, fmt.Errorf("пустая страница словарных индексов")
+		}
+		indices, err := decodeHybrid(body[1:], int(body[0]), present)
+		if err != nil {
+			return nil, err
+		}
+		page = make([]any, present)
+		for i, idx := range indices {
+			if int(idx) >= len(dict) {
+				return nil, fmt.Errorf("индекс %d за пределами словаря", idx)
+			}
+			page[i] = dict[idx]
+		}
+	default:
+		return nil, fmt.Errorf("кодировка %d не поддерживается", encoding)
+	}
+	if defs == nil {
+		return append(values, page...), nil
+	}
+	for _, d := range defs {
+		if d == 0 {
+			values = append(values, nil)
+			continue
+		}
+		values = append(values, page[0])
+		page = page[1:]
+	}
+	return values, nil
+}
+
+// decodePlain разбирает n значений в кодировке PLAIN
+func (col *parquetColumn) decodePlain(body []byte, n int) ([]any, error) {
+	values := make([]any, n)
+	short := fmt.Errorf("страница короче, чем нужно для %d значений", n)
+	width := map[int64]int{parquetInt32: 4, parquetInt64: 8, parquetInt96: 12, parquetFloat: 4, parquetDouble: 8, parquetFixed: col.typeLength}[col.typ]
+	switch col.typ {
+	case parquetBoolean:
+		if len(body)*8 < n {
+			return nil, short
+		}
+		for i := range values {
+			values[i] = body[i/8]>>(i%8)&1 != 0
+		}
+		return values, nil
+	case parquetByteArray:
+		for i := range values {
+			if len(body) < 4 || int(binary.LittleEndian.Uint32(body)) > len(body)-4 {
+				return nil, short
+			}
+			size := int(binary.LittleEndian.Uint32(body))
+			values[i] = body[4 : 4+size]
+			body = body[4+size:]
+		}
+		return values, nil
+	case parquetInt32, parquetInt64, parquetInt96, parquetFloat, parquetDouble, parquetFixed:
+	default:
+		return nil, fmt.Errorf("неизвестный физический тип %d", col.typ)
+	}
+	if len(body) < n*width {
+		return nil, short
+	}
+	for i := range values {
+		b := body[i*width : (i+1)*width]
+		switch col.typ {
+		case parquetInt32:
+			values[i] = int32(binary.LittleEndian.Uint32(b))
+		case parquetInt64:
+			values[i] = int64(binary.LittleEndian.Uint64(b))
+		case parquetFloat:
+			values[i] = math.Float32frombits(binary.LittleEndian.Uint32(b))
+		case parquetDouble:
+			values[i] = math.Float64frombits(binary.LittleEndian.Uint64(b))
+		default:
+			values[i] = b
+		}
+	}
+	return values, nil
+}
+
+// decodeHybrid разбирает n значений шириной width бит в гибридной кодировке RLE и
+// упаковки битов
+func decodeHybrid(buf []byte, width, n int) ([]uint32, error) {
+	if width > 32 {
+		return nil, fmt.Errorf("ширина значений %d бит не поддерживается", width)
+	}
+	out := make([]uint32, 0, n)
+	for len(out) < n {
+		header, k := binary.Uvarint(buf)
+		if k <= 0 {
+			return nil, fmt.Errorf("кодировка RLE обрывается")
+		}
+		buf = buf[k:]
+		if header&1 == 0 {
+			bytesWidth := (width + 7) / 8
+			if len(buf) < bytesWidth {
+				return nil, fmt.Errorf("кодировка RLE обрывается")
+			}
+			var v uint32
+			for i := 0; i < bytesWidth; i++ {
+				v |= uint32(buf[i]) << (8 * i)
+			}
+			buf = buf[bytesWidth:]
+			for i := uint64(0); i < header>>1 && len(out) < n; i++ {
+				out = append(out, v)
+			}
+			continue
+		}
+		count := int(header>>1) * 8
+		size := min(int(header>>1)*width, len(buf))
+		for i := 0; i < count && len(out) < n; i++ {
+			var v uint32
+			for b := 0; b < width; b++ {
+				bit := i*width + b
+				if bit/8 >= size {
+					return nil, fmt.Errorf("кодировка RLE обрывается")
+				}
+				v |= uint32(buf[bit/8]>>(bit%8)&1) << b
+			}
+			out = append(out, v)
+		}
+		buf = buf[size:]
+	}
+	return out, nil
+}
+
+// parquetDecompress распаковывает страницу
+func parquetDecompress(codec int64, page []byte) ([]byte, error) {
+	switch codec {
+	case parquetUncompressed:
+		return page, nil
+	case parquetSnappy:
+		return snappyDecode(page)
+	case parquetGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(page))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(zr)
+	}
+	if name, ok := parquetCodecNames[codec]; ok {
+		return nil, fmt.Errorf("сжатие %s не поддерживается", name)
+	}
+	return nil, fmt.Errorf("неизвестное сжатие %d", codec)
+}
+
+// snappyDecode распаковывает блок Snappy (без обрамления потока)
+func snappyDecode(src []byte) ([]byte, error) {
+	corrupt := fmt.Errorf("поврежденные данные Snappy")
+	n, k := binary.Uvarint(src)
+	if k <= 0 || n > thriftMaxLength {
+		return nil, corrupt
+	}
+	src = src[k:]
+	dst := make([]byte, 0, n)
+	for len(src) > 0 {
+		tag := src[0]
+		var length, offset int
+		switch tag & 3 {
+		case 0:
+			length = int(tag >> 2)
+			src = src[1:]
+			if length >= 60 {
+				extra := length - 59
+				if len(src) < extra {
+					return nil, corrupt
+				}
+				length = 0
+				for i := 0; i < extra; i++ {
+					length |= int(src[i]) << (8 * i)
+				}
+				src = src[extra:]
+			}
+			length++
+			if length <= 0 || len(src) < length {
+				return nil, corrupt
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+			continue
+		case 1:
+			if len(src) < 2 {
+				return nil, corrupt
+			}
+			length, offset = 4+int(tag>>2&7), int(tag>>5)<<8|int(src[1])
+			src = src[2:]
+		case 2:
+			if len(src) < 3 {
+				return nil, corrupt
+			}
+			length, offset = 1+int(tag>>2), int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+		case 3:
+			if len(src) < 5 {
+				return nil, corrupt
+			}
+			length, offset = 1+int(tag>>2), int(binary.LittleEndian.Uint32(src[1:]))
+			src = src[5:]
+		}
+		if offset <= 0 || offset > len(dst) || uint64(len(dst)+length) > n {
+			return nil, corrupt
+		}
+		for i := 0; i < length; i++ {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+	if uint64(len(dst)) != n {
+		return nil, corrupt
+	}
+	return dst, nil
+}
+
+// text возвращает значение в виде текста ключа сортировки
+func (col *parquetColumn) text(v any) string {
+	switch v := v.(type) {
+	case bool:
+		return strconv.FormatBool(v)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case int32:
+		switch col.kind {
+		case parquetKindDate:
+			return time.Unix(int64(v)*86400, 0).UTC().Format(time.DateOnly)
+		case parquetKindTime:
+			return parquetTimeOfDay(int64(v), col.unit)
+		case parquetKindUnsigned:
+			return strconv.FormatUint(uint64(uint32(v)), 10)
+		case parquetKindDecimal:
+			return parquetDecimal(big.NewInt(int64(v)), col.scale)
+		}
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		switch col.kind {
+		case parquetKindTimestamp:
+			return parquetTimestamp(v, col.unit)
+		case parquetKindTime:
+			return parquetTimeOfDay(v, col.unit)
+		case parquetKindUnsigned:
+			return strconv.FormatUint(uint64(v), 10)
+		case parquetKindDecimal:
+			return parquetDecimal(big.NewInt(v), col.scale)
+		}
+		return strconv.FormatInt(v, 10)
+	case []byte:
+		switch {
+		case col.typ == parquetInt96:
+			// Устаревшая метка времени Impala: наносекунды от начала суток и юлианский день
+			nanos := int64(binary.LittleEndian.Uint64(v))
+			day := int64(binary.LittleEndian.Uint32(v[8:]))
+			return time.Unix((day-2440588)*86400, nanos).UTC().Format(parquetTimestampLayout)
+		case col.kind == parquetKindDecimal:
+			unscaled := new(big.Int).SetBytes(v)
+			if len(v) > 0 && v[0]&0x80 != 0 {
+				unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(len(v))*8))
+			}
+			return parquetDecimal(unscaled, col.scale)
+		}
+		return string(v)
+	}
+	return ""
+}
+
+func parquetTimestamp(v int64, unit time.Duration) string {
+	per := int64(time.Second / unit)
+	return time.Unix(v/per, v%per*int64(unit)).UTC().Format(parquetTimestampLayout)
+}
+
+func parquetTimeOfDay(v int64, unit time.Duration) string {
+	return time.Unix(0, 0).UTC().Add(time.Duration(v) * unit).Format("15:04:05.000000000")
+}
+
+// parquetDecimal записывает целое unscaled с scale знаками после точки
+func parquetDecimal(unscaled *big.Int, scale int) string {
+	digits := new(big.Int).Abs(unscaled).String()
+	if scale <= 0 {
+		return unscaled.String()
+	}
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	sign := ""
+	if unscaled.Sign() < 0 {
+		sign = "-"
+	}
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
+
+// writeNDJSON записывает по объекту JSON на запись с полями в порядке схемы. Числа
+// остаются числами, метки времени и даты - строками; двоичные значения, не являющиеся
+// UTF-8, записываются в base64
+func (t *parquetTable) writeNDJSON(w io.Writer) error {
+	names := make([][]byte, len(t.columns))
+	for i, col := range t.columns {
+		names[i], _ = json.Marshal(col.name)
+	}
+	var line []byte
+	for _, values := range t.rows {
+		line = append(line[:0], '{')
+		for i, v := range values {
+			if i > 0 {
+				line = append(line, ',')
+			}
+			line = append(append(line, names[i]...), ':')
+			line = append(line, t.columns[i].json(v)...)
+		}
+		line = append(line, '}', '\n')
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (col *parquetColumn) json(v any) []byte {
+	var out []byte
+	switch v := v.(type) {
+	case nil:
+		return []byte("null")
+	case bool:
+		out, _ = json.Marshal(v)
+		return out
+	case float32, float64:
+		if f := toFloat(v); math.IsNaN(f) || math.IsInf(f, 0) {
+			out, _ = json.Marshal(col.text(v))
+			return out
+		}
+		return []byte(col.text(v))
+	case int32, int64:
+		if col.kind == parquetKindPlain || col.kind == parquetKindUnsigned || col.kind == parquetKindDecimal {
+			return []byte(col.text(v))
+		}
+	case []byte:
+		if col.typ == parquetInt96 || col.kind == parquetKindDecimal {
+			break
+		}
+		if !utf8.Valid(v) {
+			out, _ = json.Marshal(v)
+			return out
+		}
+	}
+	out, _ = json.Marshal(col.text(v))
+	return out
+}
+
+func toFloat(v any) float64 {
+	if f, ok := v.(float32); ok {
+		return float64(f)
+	}
+	return v.(float64)
+}
+
+// writeParquet записывает таблицу в Parquet: одна группа строк, по одной странице данных
+// PLAIN на колонку без сжатия, схема и метаданные ключ-значение - как во входном файле
+func (t *parquetTable) writeParquet(w io.Writer) error {
+	out := []byte(parquetMagic)
+	type chunk struct {
+		offset, size int64
+	}
+	chunks := make([]chunk, len(t.columns))
+	for c := range t.columns {
+		col := &t.columns[c]
+		var body []byte
+		var plain []any
+		if col.optional {
+			defs := make([]uint32, len(t.rows))
+			for i, values := range t.rows {
+				if values[c] != nil {
+					defs[i] = 1
+					plain = append(plain, values[c])
+				}
+			}
+			levels := encodeRuns(defs)
+			body = binary.LittleEndian.AppendUint32(body, uint32(len(levels)))
+			body = append(body, levels...)
+		} else {
+			for _, values := range t.rows {
+				if values[c] == nil {
+					return fmt.Errorf("в обязательной колонке %q нет значения", col.name)
+				}
+				plain = append(plain, values[c])
+			}
+		}
+		body = col.encodePlain(body, plain)
+		if len(body) > math.MaxInt32 {
+			return fmt.Errorf("колонка %q не помещается в одну страницу", col.name)
+		}
+
+		var h thriftWriter
+		h.beginStruct()
+		h.i32(1, parquetDataPage)
+		h.i32(2, int32(len(body)))
+		h.i32(3, int32(len(body)))
+		h.field(5)
+		h.i32(1, int32(len(t.rows)))
+		h.i32(2, parquetPlain)
+		h.i32(3, parquetRLE)
+		h.i32(4, parquetRLE)
+		h.endStruct()
+		h.endStruct()
+		chunks[c] = chunk{int64(len(out)), int64(len(h.buf) + len(body))}
+		out = append(append(out, h.buf...), body...)
+	}
+
+	var m thriftWriter
+	m.beginStruct()
+	m.i32(1, 1)
+	m.list(2, thriftStruct, len(t.columns)+1)
+	m.buf = append(m.buf, t.root...)
+	for _, col := range t.columns {
+		m.buf = append(m.buf, col.raw...)
+	}
+	m.i64(3, int64(len(t.rows)))
+	m.list(4, thriftStruct, 1)
+	m.beginStruct()
+	m.list(1, thriftStruct, len(t.columns))
+	var total int64
+	for c, col := range t.columns {
+		m.beginStruct()
+		m.i64(2, chunks[c].offset)
+		m.field(3)
+		m.i32(1, int32(col.typ))
+		m.list(2, thriftI32, 2)
+		m.varint(parquetPlain)
+		m.varint(parquetRLE)
+		m.list(3, thriftBinary, 1)
+		m.uvarint(uint64(len(col.name)))
+		m.buf = append(m.buf, col.name...)
+		m.i32(4, parquetUncompressed)
+		m.i64(5, int64(len(t.rows)))
+		m.i64(6, chunks[c].size)
+		m.i64(7, chunks[c].size)
+		m.i64(9, chunks[c].offset)
+		m.endStruct()
+		m.endStruct()
+		total += chunks[c].size
+	}
+	m.i64(2, total)
+	m.i64(3, int64(len(t.rows)))
+	m.endStruct()
+	if len(t.metadata) > 0 {
+		m.list(5, thriftStruct, len(t.metadata))
+		for _, kv := range t.metadata {
+			m.buf = append(m.buf, kv...)
+		}
+	}
+	m.binary(6, []byte("l2sort"))
+	m.endStruct()
+
+	out = append(out, m.buf...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(m.buf)))
+	out = append(out, parquetMagic...)
+	_, err := w.Write(out)
+	return err
+}
+
+// encodePlain дописывает значения в кодировке PLAIN
+func (col *parquetColumn) encodePlain(buf []byte, values []any) []byte {
+	if col.typ == parquetBoolean {
+		bits := make([]byte, (len(values)+7)/8)
+		for i, v := range values {
+			if v.(bool) {
+				bits[i/8] |= 1 << (i % 8)
+			}
+		}
+		return append(buf, bits...)
+	}
+	for _, v := range values {
+		switch v := v.(type) {
+		case int32:
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(v))
+		case int64:
+			buf = binary.LittleEndian.AppendUint64(buf, uint64(v))
+		case float32:
+			buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(v))
+		case float64:
+			buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+		case []byte:
+			if col.typ == parquetByteArray {
+				buf = binary.LittleEndian.AppendUint32(buf, uint32(len(v)))
+			}
+			buf = append(buf, v...)
+		}
+	}
+	return buf
+}
+
+// encodeRuns записывает однобитные уровни определения сериями RLE
+func encodeRuns(levels []uint32) []byte {
+	var buf []byte
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		buf = binary.AppendUvarint(buf, uint64(j-i)<<1)
+		buf = append(buf, byte(levels[i]))
+		i = j
+	}
+	return buf
+}
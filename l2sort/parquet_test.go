@@ -0,0 +1,106 @@
+package l2sort
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testdata/records.*.parquet записаны библиотекой github.com/parquet-go/parquet-go без
+// сжатия, со Snappy и с gzip из записей
+//
+//	type Record struct {
+//		ID    int64     `parquet:"id"`
+//		Name  string    `parquet:"name,dict"`
+//		Score *float64  `parquet:"score,optional"`
+//		At    time.Time `parquet:"at,timestamp(millisecond)"`
+//	}
+//
+// со значениями (3, вера, 2.5, 14:00), (1, анна, 10, 11:00), (10, борис, null, 12:00)
+// и (2, анна, -1, 12:01) 1 марта 2024 года UTC
+var parquetTestFiles = []string{"records.plain.parquet", "records.snappy.parquet", "records.gzip.parquet"}
+
+// parquetIDs возвращает колонку id записей NDJSON
+func parquetIDs(t *testing.T, ndjson string) []int64 {
+	t.Helper()
+	var ids []int64
+	for line := range strings.Lines(ndjson) {
+		var record struct{ ID int64 }
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("строка NDJSON %q: %v", line, err)
+		}
+		ids = append(ids, record.ID)
+	}
+	return ids
+}
+
+// TestSortParquet сортирует файлы Parquet подкомандой parquet по колонкам разных типов
+func TestSortParquet(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		code int
+		want []int64
+	}{
+		{"целые", []string{"--column", "id", "-n"}, 0, []int64{1, 2, 3, 10}},
+		{"целые как текст", []string{"--column", "id"}, 0, []int64{1, 10, 2, 3}},
+		{"строки и время", []string{"--column", "name", "--column", "at"}, 0, []int64{1, 2, 10, 3}},
+		{"строки и время по убыванию", []string{"--column", "name", "--column", "at", "-r"}, 0, []int64{3, 10, 2, 1}},
+		{"null как запись без ключа", []string{"--column", "score", "-n"}, 0, []int64{10, 2, 3, 1}},
+		{"метки времени", []string{"--column", "at"}, 0, []int64{1, 10, 2, 3}},
+		{"нет колонки", []string{"--column", "age"}, exitData, nil},
+	}
+	for _, file := range parquetTestFiles {
+		for _, tt := range tests {
+			t.Run(file+" "+tt.name, func(t *testing.T) {
+				output := filepath.Join(t.TempDir(), "out.ndjson")
+				args := append(slices.Clone(tt.args), "-o", output, filepath.Join("testdata", file))
+				if code := runParquet(context.Background(), args, Options{}); code != tt.code {
+					t.Fatalf("код %d, ожидался %d", code, tt.code)
+				}
+				if tt.code != 0 {
+					return
+				}
+				data, err := os.ReadFile(output)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := parquetIDs(t, string(data)); !slices.Equal(got, tt.want) {
+					t.Errorf("id %v, ожидалось %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+// TestSortParquetOutput проверяет значения записей в NDJSON и то, что записанный
+// результат Parquet читается снова и сохраняет порядок и значения
+func TestSortParquetOutput(t *testing.T) {
+	dir := t.TempDir()
+	sorted, ndjson := filepath.Join(dir, "sorted.parquet"), filepath.Join(dir, "sorted.ndjson")
+	args := []string{"--column", "score", "-n", "-r", "-o", sorted, filepath.Join("testdata", "records.snappy.parquet")}
+	if code := runParquet(context.Background(), args, Options{}); code != 0 {
+		t.Fatalf("parquet %q: код %d", args, code)
+	}
+	// Повторная сортировка по той же колонке устойчива и не меняет порядок
+	args = []string{"--column", "score", "-n", "-r", "-o", ndjson, sorted}
+	if code := runParquet(context.Background(), args, Options{}); code != 0 {
+		t.Fatalf("parquet %q: код %d", args, code)
+	}
+	data, err := os.ReadFile(ndjson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"id":1,"name":"анна","score":10,"at":"2024-03-01T11:00:00.000000000Z"}
+{"id":3,"name":"вера","score":2.5,"at":"2024-03-01T14:00:00.000000000Z"}
+{"id":2,"name":"анна","score":-1,"at":"2024-03-01T12:01:00.000000000Z"}
+{"id":10,"name":"борис","score":null,"at":"2024-03-01T12:00:00.000000000Z"}
+`
+	if string(data) != want {
+		t.Errorf("получено\n%s\nожидалось\n%s", data, want)
+	}
+}
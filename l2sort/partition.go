@@ -0,0 +1,167 @@
+package l2sort
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// partitionKeyPlaceholder заменяется в --output-template значением колонки --partition-by
+const partitionKeyPlaceholder = "{key}"
+
+// partition - открытый файл одной группы --partition-by или части --split
+type partition struct {
+	file  io.WriteCloser
+	w     *bufio.Writer
+	lines int
+}
+
+// writePartitions раскладывает отсортированный поток по файлам: каждая строка попадает
+// в файл, имя которого получено подстановкой значения колонки --partition-by в шаблон
+// --output-template. Возвращает число созданных файлов
+func (s *Sorter) writePartitions(src rowSource, in *inputData) (int, error) {
+	return s.writeShards(src, in, nil, func(row *Row, _ int) string {
+		return strings.ReplaceAll(s.opts.OutputTemplate, partitionKeyPlaceholder, partitionName(s.partitionKey(row.Original)))
+	})
+}
+
+// writeShards раскладывает поток по файлам, путь для каждой строки (с ее порядковым
+// номером в потоке) выбирает pathFor. Файлы из always создаются даже без строк.
+// Строки --skip выводятся в начало каждого файла.
+// Порядок строк внутри файла сохраняется, поэтому каждый файл остается отсортированным.
+// Файлы создаются атомарно и появляются только при успешной записи всех.
+// Возвращает число созданных файлов
+func (s *Sorter) writeShards(src rowSource, in *inputData, always []string, pathFor func(row *Row, index int) string) (n int, err error) {
+	parts := make(map[string]*partition)
+	var order []*partition
+	defer func() {
+		if err != nil {
+			for _, p := range order {
+				discardOutput(p.file)
+			}
+		}
+	}()
+	open := func(path string) (*partition, error) {
+		if p := parts[path]; p != nil {
+			return p, nil
+		}
+		file, err := openOutput(path, false, s.opts.CompressOutput, nil)
+		if err != nil {
+			return nil, fmt.Errorf("при создании файла: %w", err)
+		}
+		p := &partition{file: file, w: bufio.NewWriter(in.enc.encoder(file))}
+		if err := writeHeader(p.w, in.header, in.eol); err != nil {
+			discardOutput(file)
+			return nil, fmt.Errorf("при записи результата: %w", err)
+		}
+		parts[path] = p
+		order = append(order, p)
+		return p, nil
+	}
+	for _, path := range always {
+		if _, err := open(path); err != nil {
+			return 0, err
+		}
+	}
+
+	for index := 0; ; index++ {
+		row, ok, err := src.next()
+		if err != nil {
+			return 0, fmt.Errorf("при записи результата: %w", err)
+		}
+		if !ok {
+			break
+		}
+		p, err := open(pathFor(&row, index))
+		if err != nil {
+			return 0, err
+		}
+		line := row.Original
+		if s.format != nil {
+			if line, err = s.format(&row); err != nil {
+				return 0, fmt.Errorf("при записи результата: %w", err)
+			}
+		}
+		if p.lines > 0 {
+			p.w.WriteString(in.eol.between())
+		}
+		p.w.WriteString(in.eol.text(line))
+		p.lines++
+	}
+
+	for i, p := range order {
+		if in.eol.final && p.lines > 0 {
+			p.w.WriteString(in.eol.sep)
+		}
+		if err := p.w.Flush(); err != nil {
+			return 0, fmt.Errorf("при записи результата: %w", err)
+		}
+		if err := p.file.Close(); err != nil {
+			order = order[i+1:]
+			return 0, fmt.Errorf("при сохранении результата: %w", err)
+		}
+	}
+	return len(order), nil
+}
+
+// partitionKey возвращает значение колонки --partition-by; у строки без такой колонки оно пустое
+func (s *Sorter) partitionKey(line string) string {
+	fields := s.splitFields(line)
+	if s.opts.PartitionBy > len(fields) {
+		return ""
+	}
+	return fields[s.opts.PartitionBy-1]
+}
+
+// partitionName делает значение ключа пригодным для имени файла: разделители путей
+// и управляющие символы заменяются на '_', а пустое значение и "."/".." - на "_"
+func partitionName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, key)
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
+
+// Способы раскладки результата по --split
+const (
+	splitRoundRobin = "round-robin"
+	splitRange      = "range"
+)
+
+// shardPlaceholder заменяется в --output-template номером части при --split
+const shardPlaceholder = "{n}"
+
+// writeSplit раскладывает отсортированный поток на --split частей: по кругу (round-robin)
+// или непрерывными диапазонами примерно равного размера (range). Размер диапазона считается
+// по числу прочитанных строк; при -u повторы отбрасываются позже, и последние части могут
+// оказаться короче. Создаются все части, даже пустые
+func (s *Sorter) writeSplit(src rowSource, in *inputData, output string) (int, error) {
+	n := s.opts.Split
+	total := in.kept
+	if s.opts.Head > 0 {
+		total = min(total, s.opts.Head)
+	}
+	paths := make([]string, n)
+	width := len(strconv.Itoa(n - 1))
+	for i := range paths {
+		if strings.Contains(s.opts.OutputTemplate, shardPlaceholder) {
+			paths[i] = strings.ReplaceAll(s.opts.OutputTemplate, shardPlaceholder, fmt.Sprintf("%0*d", width, i))
+		} else {
+			paths[i] = fmt.Sprintf("%s.%0*d", output, width, i)
+		}
+	}
+	return s.writeShards(src, in, paths, func(_ *Row, index int) string {
+		if s.opts.SplitMode == splitRange && total > 0 {
+			return paths[min(index*n/total, n-1)]
+		}
+		return paths[index%n]
+	})
+}
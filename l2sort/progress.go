@@ -0,0 +1,134 @@
+package l2sort
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progressInterval - период вывода --progress
+const progressInterval = time.Second
+
+// progressReporter при --progress периодически печатает в out, сколько прочитано байт
+// и строк, сколько прогонов сброшено на диск и сколько строк записано, со скоростью
+// обработки. Счетчики атомарные, методы nil-получателя ничего не делают
+type progressReporter struct {
+	out     io.Writer
+	start   time.Time
+	bytes   atomic.Int64
+	lines   atomic.Int64
+	runs    atomic.Int64
+	written atomic.Int64
+	merging atomic.Bool
+	// writeStart - время начала записи в наносекундах от start
+	writeStart atomic.Int64
+	done       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// startProgress запускает периодический вывод; остановить его нужно через stop
+func startProgress(out io.Writer) *progressReporter {
+	p := &progressReporter{out: out, start: time.Now(), done: make(chan struct{})}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.print()
+			case <-p.done:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// stop прекращает периодический вывод и печатает итоговую строку
+func (p *progressReporter) stop() {
+	if p == nil {
+		return
+	}
+	close(p.done)
+	p.wg.Wait()
+	p.print()
+}
+
+func (p *progressReporter) print() {
+	elapsed := time.Since(p.start)
+	bytes := p.bytes.Load()
+	rate := float64(bytes) / max(elapsed.Seconds(), 1e-3) / (1 << 20)
+	if !p.merging.Load() {
+		fmt.Fprintf(p.out, "[%s] чтение: прочитано %.1f МБ (%.1f МБ/с), строк %d, прогонов на диске %d\n",
+			elapsed.Round(time.Second), float64(bytes)/(1<<20), rate, p.lines.Load(), p.runs.Load())
+		return
+	}
+	writing := elapsed - time.Duration(p.writeStart.Load())
+	written := p.written.Load()
+	fmt.Fprintf(p.out, "[%s] слияние и запись: прочитано %.1f МБ, строк %d, прогонов %d, записано строк %d (%.0f строк/с)\n",
+		elapsed.Round(time.Second), float64(bytes)/(1<<20), p.lines.Load(), p.runs.Load(), written, float64(written)/max(writing.Seconds(), 1e-3))
+}
+
+func (p *progressReporter) addLine() {
+	if p != nil {
+		p.lines.Add(1)
+	}
+}
+
+func (p *progressReporter) addRun() {
+	if p != nil {
+		p.runs.Add(1)
+	}
+}
+
+// startWriting отмечает переход от чтения к слиянию и записи результата
+func (p *progressReporter) startWriting() {
+	if p != nil {
+		p.writeStart.Store(int64(time.Since(p.start)))
+		p.merging.Store(true)
+	}
+}
+
+// countingReader учитывает прочитанные байты в progressReporter
+type countingReader struct {
+	r io.Reader
+	p *progressReporter
+}
+
+func (c countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.p.bytes.Add(int64(n))
+	return n, err
+}
+
+// progressSource учитывает записанные строки
+type progressSource struct {
+	src rowSource
+	p   *progressReporter
+}
+
+func (s *progressSource) next() (Row, bool, error) {
+	row, ok, err := s.src.next()
+	if ok {
+		s.p.written.Add(1)
+	}
+	return row, ok, err
+}
+
+type progressKey struct{}
+
+// withProgress сохраняет progressReporter в контексте, чтобы чтение и запись могли его найти
+func withProgress(ctx context.Context, p *progressReporter) context.Context {
+	return context.WithValue(ctx, progressKey{}, p)
+}
+
+// progressFrom возвращает progressReporter из контекста или nil
+func progressFrom(ctx context.Context) *progressReporter {
+	p, _ := ctx.Value(progressKey{}).(*progressReporter)
+	return p
+}
@@ -0,0 +1,99 @@
+package l2sort
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+)
+
+// keyHash - хэш текста ключа с солью для -R и --hash-order: FNV-1a (64 бита) от соли
+// (8 байт, младший первым) и текста, пропущенный через финализатор splitmix64. Равные
+// ключи получают равные хэши и остаются рядом, а порядок разных ключей определяется солью.
+// Алгоритм не зависит от платформы, поэтому --hash-order дает один порядок на любой машине
+func keyHash(salt uint64, text string) uint64 {
+	h := fnv.New64a()
+	var b [8]byte
+	for i := range b {
+		b[i] = byte(salt >> (8 * i))
+	}
+	h.Write(b[:])
+	h.Write([]byte(text))
+	// FNV плохо перемешивает старшие биты для коротких ключей, поэтому результат
+	// дополнительно проходит через финализатор splitmix64
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// hashOrderSalt - соль --hash-order из --hash-salt: FNV-1a (64 бита) от текста соли
+func hashOrderSalt(salt string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(salt))
+	return h.Sum64()
+}
+
+// checkShuffle проверяет совместимость --shuffle: перемешанный результат не упорядочен,
+// поэтому режимы, которым нужны соседние равные строки или порядок, с ним не работают
+func checkShuffle(o Options) error {
+	if !o.Shuffle {
+		return nil
+	}
+	switch {
+	case o.RandomSort:
+		return fmt.Errorf("в параметрах: -R и --shuffle несовместимы")
+	case o.Unique || o.Check || o.GroupBy || o.CheckUnique:
+		return fmt.Errorf("в параметре --shuffle: несовместим с -u, -c, --check-unique и --group-by")
+	case o.Resume:
+		return fmt.Errorf("в параметре --shuffle: прогоны перемешиваются при слиянии и не сохраняются для --resume")
+	}
+	return nil
+}
+
+// shuffleRows перемешивает строки на месте (алгоритм Фишера - Йетса)
+func (s *Sorter) shuffleRows(rows []Row) {
+	s.rng.Shuffle(len(rows), func(i, j int) { rows[i], rows[j] = rows[j], rows[i] })
+}
+
+// shuffleMerge сливает перемешанные прогоны в случайном порядке: следующая строка
+// берется из источника с вероятностью, пропорциональной числу оставшихся в нем строк,
+// поэтому результат - равновероятная перестановка всего входа
+type shuffleMerge struct {
+	sources []rowSource
+	left    []int
+	total   int
+	rng     *rand.Rand
+}
+
+func newShuffleMerge(rng *rand.Rand, sources []rowSource, counts []int) *shuffleMerge {
+	m := &shuffleMerge{sources: sources, left: counts, rng: rng}
+	for _, n := range counts {
+		m.total += n
+	}
+	return m
+}
+
+func (m *shuffleMerge) next() (Row, bool, error) {
+	if m.total == 0 {
+		return Row{}, false, nil
+	}
+	pick := m.rng.IntN(m.total)
+	i := 0
+	for pick >= m.left[i] {
+		pick -= m.left[i]
+		i++
+	}
+	row, ok, err := m.sources[i].next()
+	if err != nil {
+		return Row{}, false, err
+	}
+	if !ok {
+		return Row{}, false, fmt.Errorf("прогон %d закончился раньше ожидаемого", i+1)
+	}
+	m.left[i]--
+	m.total--
+	return row, true, nil
+}
@@ -0,0 +1,107 @@
+package l2sort
+
+import (
+	"bufio"
+	"strings"
+)
+
+// arenaScanner - bufio.Scanner, размещающий выданные строки в lineArena
+type arenaScanner struct {
+	*bufio.Scanner
+	arena *lineArena
+}
+
+func (a *arenaScanner) Text() string { return a.arena.intern(a.Bytes()) }
+
+// lineReader - общий интерфейс построчного чтения для bufio.Scanner и recordScanner
+type lineReader interface {
+	Scan() bool
+	Text() string
+	Err() error
+}
+
+// recordScanner собирает многострочные записи, разделенные строками, которые
+// соответствуют --record-sep. Строки записи соединяются через '\n'; несколько
+// разделителей подряд считаются одним, пустых записей не бывает
+type recordScanner struct {
+	lines   lineReader
+	sorter  *Sorter
+	text    string
+	sepLine string // текст первого встреченного разделителя, воспроизводится при выводе
+	seenSep bool
+}
+
+func (r *recordScanner) Scan() bool {
+	var record []string
+	for r.lines.Scan() {
+		line := r.lines.Text()
+		if r.sorter.recordSep.MatchString(line) {
+			if !r.seenSep {
+				r.sepLine, r.seenSep = line, true
+			}
+			if len(record) > 0 {
+				break
+			}
+			continue
+		}
+		record = append(record, line)
+	}
+	if len(record) == 0 {
+		return false
+	}
+	r.text = strings.Join(record, "\n")
+	return true
+}
+
+func (r *recordScanner) Text() string { return r.text }
+
+func (r *recordScanner) Err() error { return r.lines.Err() }
+
+// recordKeyLine возвращает строку записи, из которой извлекаются ключи: первую строку,
+// соответствующую --record-key, а без него - первую строку записи. При --comments=keep
+// это строка данных после прикрепленных комментариев. Иначе текст возвращается без изменений
+func (s *Sorter) recordKeyLine(record string) string {
+	if s.opts.Comments == commentsKeep {
+		if i := strings.LastIndexByte(record, '\n'); i >= 0 {
+			return record[i+1:]
+		}
+		return record
+	}
+	if s.recordSep == nil {
+		return record
+	}
+	for line := range strings.SplitSeq(record, "\n") {
+		if s.recordKey == nil || s.recordKey.MatchString(line) {
+			return line
+		}
+	}
+	return ""
+}
+
+// escapeRecord и unescapeRecord позволяют хранить многострочную запись в прогоне
+// на диске одной строкой
+var (
+	recordEscaper   = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	recordUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n")
+)
+
+func escapeRecord(record string) string { return recordEscaper.Replace(record) }
+
+func unescapeRecord(line string) string { return recordUnescaper.Replace(line) }
+
+// multiline сообщает, может ли строка результата состоять из нескольких строк входа
+func (s *Sorter) multiline() bool {
+	return s.recordSep != nil || s.opts.Comments == commentsKeep
+}
+
+// Обработка комментариев --comments
+const (
+	commentsKeep = "keep"
+	commentsTop  = "top"
+	commentsDrop = "drop"
+)
+
+// isComment сообщает, является ли строка комментарием --comment-prefix при заданном --comments
+func (s *Sorter) isComment(line string) bool {
+	return s.opts.Comments != "" && strings.HasPrefix(strings.TrimLeft(line, " \t"), s.opts.CommentPrefix)
+}
@@ -0,0 +1,39 @@
+package l2sort
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+// runRecursive сортирует на месте каждый обычный файл в дереве каталога dir, имя
+// которого соответствует шаблону pattern (например, *.txt), независимо от остальных.
+// Файлы обрабатываются параллельно тем же пулом, что и --batch; сводка перечисляет
+// измененные файлы. Возвращает код завершения
+func runRecursive(ctx context.Context, dir, pattern string, base Options) int {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return reportUsage(fmt.Errorf("в параметре --glob: %w", err))
+	}
+	manifest := &batchManifestFile{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if ok, _ := filepath.Match(pattern, d.Name()); ok {
+			manifest.Jobs = append(manifest.Jobs, batchJob{Input: path})
+		}
+		return nil
+	})
+	if err != nil {
+		return reportError(fmt.Errorf("при обходе каталога: %w", err))
+	}
+	if len(manifest.Jobs) == 0 {
+		fmt.Printf("В %s нет файлов, соответствующих %q\n", dir, pattern)
+		return 0
+	}
+	return runBatchJobs(ctx, manifest, base, true)
+}
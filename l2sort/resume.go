@@ -0,0 +1,183 @@
+package l2sort
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// resumeManifestName - имя файла описания в каталоге состояния --resume
+const resumeManifestName = "manifest.json"
+
+// resumeInput - признаки, по которым проверяется, что вход не изменился с прошлого запуска
+type resumeInput struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// resumeRun - готовый прогон: файл, число строк входа, прочитанных к моменту его
+// сброса на диск, и диапазон ключей в нем
+type resumeRun struct {
+	Path     string `json:"path"`
+	Lines    int    `json:"lines"`
+	FirstKey string `json:"first_key"`
+	LastKey  string `json:"last_key"`
+}
+
+type resumeManifest struct {
+	Inputs  []resumeInput   `json:"inputs"`
+	Options json.RawMessage `json:"options"`
+	Runs    []resumeRun     `json:"runs"`
+}
+
+// resumeState хранит при --resume готовые прогоны в постоянном каталоге рядом
+// с временными файлами и после каждого прогона обновляет описание. Если сортировка
+// прервется, следующий запуск с теми же входом и настройками возьмет готовые прогоны
+// и пропустит уже разобранные строки входа
+type resumeState struct {
+	dir      string
+	manifest resumeManifest
+	// resumed - прогоны прошлого запуска и число строк входа, которые они покрывают
+	resumed []string
+	lines   int
+}
+
+// openResume находит или создает каталог состояния для inputs и output. Состояние
+// от другого входа или других настроек отбрасывается
+func (s *Sorter) openResume(inputs []string, output string) (*resumeState, error) {
+	manifest := resumeManifest{}
+	for _, input := range inputs {
+		info, err := os.Stat(input)
+		if err != nil {
+			return nil, err
+		}
+		abs, err := filepath.Abs(input)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Inputs = append(manifest.Inputs, resumeInput{Path: abs, Size: info.Size(), ModTime: info.ModTime().UTC()})
+	}
+	// Настройки, не влияющие на содержимое прогонов, в сравнение не входят
+	o := s.opts.clone()
+	o.AlsoOutputs, o.Backup, o.KeepTemp, o.Debug, o.Progress, o.Stats, o.BufferSize = nil, false, false, false, false, false, ""
+	o.Index, o.IndexStride = "", ""
+	options, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	manifest.Options = options
+
+	abs, err := filepath.Abs(output)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	for _, input := range manifest.Inputs {
+		fmt.Fprintf(h, "%s\x00", input.Path)
+	}
+	fmt.Fprintf(h, "%s", abs)
+	parent := firstTempDir(s.opts.TempDir)
+	if parent == "" {
+		parent = os.TempDir()
+	}
+	st := &resumeState{
+		dir:      filepath.Join(parent, "l2sort-resume-"+hex.EncodeToString(h.Sum(nil))[:16]),
+		manifest: manifest,
+	}
+
+	previous, err := readResumeManifest(st.dir)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		warnf("состояние в %s повреждено и будет создано заново: %v", st.dir, err)
+		os.RemoveAll(st.dir)
+	case !previous.matches(manifest):
+		warnf("вход или настройки изменились с прошлого запуска, состояние в %s отброшено", st.dir)
+		os.RemoveAll(st.dir)
+	default:
+		for _, run := range previous.Runs {
+			if _, err := os.Stat(run.Path); err != nil {
+				break
+			}
+			st.manifest.Runs = append(st.manifest.Runs, run)
+			st.resumed = append(st.resumed, run.Path)
+			st.lines = run.Lines
+		}
+		if len(st.resumed) > 0 {
+			fmt.Fprintf(os.Stderr, "Продолжение с прошлого запуска: готовых прогонов %d, пропускается строк %d\n", len(st.resumed), st.lines)
+		}
+	}
+	if err := os.MkdirAll(st.dir, 0o700); err != nil {
+		return nil, err
+	}
+	return st, st.save()
+}
+
+// matches сообщает, описывают ли m и other один и тот же вход с теми же настройками
+func (m resumeManifest) matches(other resumeManifest) bool {
+	var a, b bytes.Buffer
+	if json.Compact(&a, m.Options) != nil || json.Compact(&b, other.Options) != nil || a.String() != b.String() {
+		return false
+	}
+	return slices.EqualFunc(m.Inputs, other.Inputs, func(x, y resumeInput) bool {
+		return x.Path == y.Path && x.Size == y.Size && x.ModTime.Equal(y.ModTime)
+	})
+}
+
+func readResumeManifest(dir string) (resumeManifest, error) {
+	var manifest resumeManifest
+	data, err := os.ReadFile(filepath.Join(dir, resumeManifestName))
+	if err != nil {
+		return manifest, err
+	}
+	return manifest, json.Unmarshal(data, &manifest)
+}
+
+// createRun создает файл для очередного прогона в каталоге состояния
+func (st *resumeState) createRun() (*os.File, error) {
+	return os.CreateTemp(st.dir, "run-*")
+}
+
+// addRun записывает в описание прогон, сброшенный после lines строк входа
+func (st *resumeState) addRun(path string, lines int, rows []Row) error {
+	st.manifest.Runs = append(st.manifest.Runs, resumeRun{
+		Path:     path,
+		Lines:    lines,
+		FirstKey: keysText(&rows[0]),
+		LastKey:  keysText(&rows[len(rows)-1]),
+	})
+	return st.save()
+}
+
+// save атомарно перезаписывает описание, чтобы прерывание не оставило его недописанным
+func (st *resumeState) save() error {
+	data, err := json.MarshalIndent(st.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(st.dir, resumeManifestName+".tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(st.dir, resumeManifestName))
+}
+
+// finish удаляет состояние после успешной сортировки, а после неудачной сообщает, где оно
+func (st *resumeState) finish(err error) {
+	if err == nil {
+		os.RemoveAll(st.dir)
+		return
+	}
+	if len(st.manifest.Runs) > 0 {
+		fmt.Fprintf(os.Stderr, "Готовые прогоны (%d) сохранены в %s; повторный запуск с --resume продолжит с них\n", len(st.manifest.Runs), st.dir)
+	}
+}
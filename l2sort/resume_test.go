@@ -0,0 +1,101 @@
+package l2sort
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestResume прерывает сортировку с --resume после сброса прогонов (результат нельзя
+// записать, потому что на его месте каталог) и запускает ее снова. Первый готовый
+// прогон подменяется, чтобы было видно, взят ли он из прошлого запуска
+func TestResume(t *testing.T) {
+	var lines []string
+	for i := range 20000 {
+		lines = append(lines, fmt.Sprintf("%05d строка", (i*7919)%20000))
+	}
+	tests := []struct {
+		name   string
+		change func(t *testing.T, input string)
+		reused bool
+	}{
+		{"тот же вход", func(*testing.T, string) {}, true},
+		{"вход изменился", func(t *testing.T, input string) {
+			f, err := os.OpenFile(input, os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if _, err := f.WriteString("20000 строка\n"); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			temp := filepath.Join(dir, "tmp")
+			input, output := filepath.Join(dir, "in.txt"), filepath.Join(dir, "out.txt")
+			if err := os.Mkdir(temp, 0o700); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(input, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Mkdir(output, 0o700); err != nil {
+				t.Fatal(err)
+			}
+			sorter := newTestSorter(t, []string{"-S", "256K", "--resume", "-T", temp})
+			if _, err := sorter.SortFile(input, output); err == nil {
+				t.Fatal("запись результата в каталог завершилась успешно")
+			}
+
+			states, err := filepath.Glob(filepath.Join(temp, "l2sort-resume-*"))
+			if err != nil || len(states) != 1 {
+				t.Fatalf("каталоги состояния %q, %v", states, err)
+			}
+			manifest, err := readResumeManifest(states[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(manifest.Runs) < 2 {
+				t.Fatalf("сохранено прогонов %d, ожидалось не меньше 2", len(manifest.Runs))
+			}
+			if err := os.WriteFile(manifest.Runs[0].Path, []byte("!\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.change(t, input)
+			if err := os.Remove(output); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := sorter.SortFile(input, output); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+			if !slices.IsSorted(got) {
+				t.Error("результат не отсортирован")
+			}
+			if reused := got[0] == "!"; reused != tt.reused {
+				t.Errorf("прогон прошлого запуска использован: %v, ожидалось %v", reused, tt.reused)
+			}
+			want := len(lines) + 1 // строка "!" вместо первого прогона или добавленная строка
+			if tt.reused {
+				want -= manifest.Runs[0].Lines
+			}
+			if len(got) != want {
+				t.Errorf("строк %d, ожидалось %d", len(got), want)
+			}
+			if _, err := os.Stat(states[0]); !os.IsNotExist(err) {
+				t.Errorf("каталог состояния не удален после успешной сортировки: %v", err)
+			}
+		})
+	}
+}
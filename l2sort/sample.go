@@ -0,0 +1,64 @@
+package l2sort
+
+import (
+	"fmt"
+	"math/rand/v2"
+)
+
+// newRand создает генератор случайных чисел из --seed для выборки, -R и --shuffle; 0 означает случайное начальное
+// значение, и тогда результат от запуска к запуску разный
+func newRand(seed uint64) *rand.Rand {
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	return rand.New(rand.NewPCG(seed, 0))
+}
+
+// checkSample проверяет --sample и --sample-pct
+func checkSample(o Options) error {
+	switch {
+	case o.Sample < 0:
+		return fmt.Errorf("в параметре --sample: число строк не может быть отрицательным")
+	case o.SamplePct < 0 || o.SamplePct > 100:
+		return fmt.Errorf("в параметре --sample-pct: процент должен быть от 0 до 100")
+	case o.Sample > 0 && o.SamplePct > 0:
+		return fmt.Errorf("в параметрах: --sample и --sample-pct несовместимы")
+	case o.Sample > 0 && o.Resume:
+		return fmt.Errorf("в параметрах: выборка --sample держится в памяти и не сохраняется для --resume")
+	}
+	return nil
+}
+
+// reservoir хранит при --sample N равновероятную выборку из N строк входа (алгоритм R):
+// k-я строка заменяет случайную строку выборки с вероятностью N/k. Память не зависит
+// от размера входа
+type reservoir struct {
+	rows   []Row
+	sorter *Sorter
+	n      int
+	seen   int
+}
+
+// offer учитывает очередную строку входа. Принятая строка копируется из общих блоков
+// чтения, как в topRows
+func (r *reservoir) offer(row Row) {
+	r.seen++
+	if len(r.rows) < r.n {
+		r.rows = append(r.rows, r.sorter.detachRow(row))
+		return
+	}
+	if j := r.sorter.rng.IntN(r.seen); j < r.n {
+		r.rows[j] = r.sorter.detachRow(row)
+	}
+}
+
+// sampled сообщает, попадает ли строка в выборку --sample-pct
+func (s *Sorter) sampled() bool {
+	return s.opts.SamplePct == 0 || s.rng.Float64()*100 < s.opts.SamplePct
+}
+
+// randomized сообщает, что результат зависит от случайного начального значения и не
+// может браться из кэша --cache-dir
+func (s *Sorter) randomized() bool {
+	return s.opts.Seed == 0 && (s.opts.Sample > 0 || s.opts.SamplePct > 0 || s.usesKind(typeRandom) || s.opts.Shuffle)
+}
@@ -0,0 +1,108 @@
+package l2sort
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+)
+
+// runSelftest выполняет подкоманду
+//
+//	l2sort selftest ФАЙЛ [--flags '-n -k 2'] [--pairs N] [--seed N]
+//
+// Компаратор, настроенный флагами из --flags поверх общих, прогоняется по случайным
+// парам и тройкам строк файла; нарушения рефлексивности, антисимметрии и транзитивности
+// печатаются вместе со строками. Файл не изменяется. Возвращает код завершения:
+// 0, если нарушений нет
+func runSelftest(ctx context.Context, args []string, base Options) int {
+	fs := flag.NewFlagSet("selftest", flag.ContinueOnError)
+	flags := fs.String("flags", "", "Флаги сортировки, компаратор которых проверяется, например '-n -k 2'")
+	pairs := fs.Int("pairs", 10000, "Число проверяемых пар и троек строк")
+	seed := fs.Uint64("seed", 1, "Начальное значение генератора случайных чисел")
+	usage := func() {
+		fmt.Println("Использование: l2sort selftest файл [--flags '...'] [--pairs N] [--seed N]")
+		fs.PrintDefaults()
+	}
+	fs.Usage = usage
+
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(files) != 1 {
+		usage()
+		return exitUsage
+	}
+	filePath := files[0]
+
+	sorter, err := sorterWithFlags(base, *flags)
+	if err != nil {
+		return reportUsage(err)
+	}
+	// Все строки нужны в памяти для случайной выборки, поэтому -S здесь не применяется
+	in, err := sorter.readRows(ctx, []string{filePath}, newMemBudget(0))
+	if err != nil {
+		return reportError(fmt.Errorf("при чтении файла: %w", err))
+	}
+	defer in.close()
+
+	violations := sorter.checkInvariants(in.rows, *pairs, rand.New(rand.NewPCG(*seed, 0)))
+	for _, v := range violations {
+		fmt.Println(v)
+	}
+	fmt.Printf("Строк: %d, проверок: %d, нарушений: %d\n", len(in.rows), *pairs, len(violations))
+	if len(violations) > 0 {
+		return reportCheck(fmt.Errorf("в selftest: найдено нарушений: %d", len(violations)))
+	}
+	return 0
+}
+
+// maxReportedViolations ограничивает число нарушений, выводимых подробно
+const maxReportedViolations = 20
+
+// checkInvariants проверяет на n случайных парах и тройках строк, что CompareRows задает
+// строгий слабый порядок: cmp(a, a) = 0, sign(cmp(a, b)) = -sign(cmp(b, a)), а из a <= b
+// и b <= c следует a <= c (в том числе транзитивность равенства). Возвращает описания нарушений
+func (s *Sorter) checkInvariants(rows []Row, n int, rng *rand.Rand) []string {
+	var violations []string
+	report := func(format string, args ...any) {
+		if len(violations) < maxReportedViolations {
+			violations = append(violations, fmt.Sprintf(format, args...))
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		a := &rows[rng.IntN(len(rows))]
+		b := &rows[rng.IntN(len(rows))]
+		c := &rows[rng.IntN(len(rows))]
+
+		if s.CompareRows(a, a) != 0 {
+			report("Нарушена рефлексивность: строка %q не равна самой себе", a.Original)
+		}
+		ab, ba := s.CompareRows(a, b), s.CompareRows(b, a)
+		if sign(ab) != -sign(ba) {
+			report("Нарушена антисимметрия: cmp(%q, %q) = %d, но cmp в обратном порядке = %d", a.Original, b.Original, ab, ba)
+		}
+		bc, ac := s.CompareRows(b, c), s.CompareRows(a, c)
+		switch {
+		case ab == 0 && bc == 0 && ac != 0:
+			report("Нарушена транзитивность равенства: %q = %q = %q, но cmp(первая, третья) = %d", a.Original, b.Original, c.Original, ac)
+		case ab <= 0 && bc <= 0 && ac > 0:
+			report("Нарушена транзитивность: %q <= %q <= %q, но первая больше третьей", a.Original, b.Original, c.Original)
+		}
+	}
+	return violations
+}
+
+func sign(c int) int {
+	switch {
+	case c < 0:
+		return -1
+	case c > 0:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,184 @@
+package l2sort
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// serveDeniedFlags - флаги, недоступные через --serve: они читают или пишут файлы сервера
+// (--by - сведения о файлах по путям из строк), запускают внешние программы
+// (--to-sqlite - sqlite3), выводят отладку в его stderr или не дают ответа (-c, --dry-run)
+var serveDeniedFlags = map[string]bool{
+	"alphabet":         true,
+	"also-output":      true,
+	"T":                true,
+	"backup":           true,
+	"by":               true,
+	"by-missing":       true,
+	"c":                true,
+	"cache-dir":        true,
+	"check-unique":     true,
+	"checksum":         true,
+	"checksum-file":    true,
+	"compress-program": true,
+	"debug":            true,
+	"dry-run":          true,
+	"dups-output":      true,
+	"index":            true,
+	"keep-temp":        true,
+	"output-template":  true,
+	"partition-by":     true,
+	"progress":         true,
+	"split":            true,
+	"split-mode":       true,
+	"sqlite-table":     true,
+	"stats":            true,
+	"to-sqlite":        true,
+}
+
+// runServe запускает HTTP-сервис сортировки на addr до отмены ctx. POST /sort принимает
+// текст (в том числе CSV или JSONL по строкам) в теле запроса и возвращает его
+// отсортированным. Параметры запроса повторяют флаги командной строки: ?k=2&n, ?k=1&k=3r,
+// ?key-regex=... Параметр без значения включает логический флаг. Тело больше maxBody
+// отклоняется с кодом 413. Общие флаги запуска служат значениями по умолчанию
+func runServe(ctx context.Context, addr string, base Options, maxBody int64) int {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /sort", func(w http.ResponseWriter, r *http.Request) {
+		serveSort(w, r, base, maxBody)
+	})
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	fmt.Printf("Сервис сортировки слушает %s, POST /sort\n", addr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return reportError(err)
+	}
+	fmt.Println("Сервис остановлен.")
+	return 0
+}
+
+// serveSort обрабатывает один запрос: сохраняет тело во временный файл, сортирует его
+// и передает результат клиенту потоком
+func serveSort(w http.ResponseWriter, r *http.Request, base Options, maxBody int64) {
+	sorter, err := sorterFromQuery(base, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	input, err := temps.create(base.TempDir, "request")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("при создании временного файла: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer temps.remove(input.Name())
+	_, err = io.Copy(input, http.MaxBytesReader(w, r.Body, maxBody))
+	if closeErr := input.Close(); err == nil {
+		err = closeErr
+	}
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		http.Error(w, fmt.Sprintf("тело запроса больше %d байт", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("при чтении запроса: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	out := &responseOutput{w: w}
+	sorter.stdout = out
+	if _, err := sorter.SortFileContext(r.Context(), input.Name(), "-"); err != nil {
+		if out.started {
+			// Заголовок уже отправлен: оборвать ответ, чтобы клиент не принял его за полный
+			panic(http.ErrAbortHandler)
+		}
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	}
+}
+
+// sorterFromQuery создает Sorter по параметрам запроса поверх общих настроек base
+func sorterFromQuery(base Options, r *http.Request) (*Sorter, error) {
+	reqOpts := base.clone()
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	reqOpts.registerFlags(fs)
+	for name, values := range r.URL.Query() {
+		if serveDeniedFlags[name] {
+			return nil, fmt.Errorf("параметр %s недоступен в режиме сервиса", name)
+		}
+		if fs.Lookup(name) == nil {
+			return nil, fmt.Errorf("неизвестный параметр %s", name)
+		}
+		for _, value := range values {
+			if value == "" {
+				value = "true"
+			}
+			if err := fs.Set(name, value); err != nil {
+				return nil, fmt.Errorf("в параметре %s: %w", name, err)
+			}
+		}
+	}
+	return newServiceSorter(reqOpts)
+}
+
+// newServiceSorter создает Sorter для запроса к сервису. Ключи :mtime и :size отклоняются,
+// как и --by: иначе клиент узнавал бы по путям в строках, есть ли файлы на сервере, их
+// размер и время изменения
+func newServiceSorter(opts Options) (*Sorter, error) {
+	s, err := NewSorter(opts)
+	if err != nil {
+		return nil, err
+	}
+	if s.usesStat() {
+		return nil, fmt.Errorf("ключи :mtime и :size недоступны в режиме сервиса")
+	}
+	return s, nil
+}
+
+// responseOutput отправляет результат в ответ HTTP и запоминает, начата ли отправка
+type responseOutput struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (o *responseOutput) Write(p []byte) (int, error) {
+	o.started = true
+	return o.w.Write(p)
+}
+
+// serveMaxBodyDefault - ограничение размера тела запроса --serve по умолчанию
+const serveMaxBodyDefault = "64M"
+
+// parseServeMaxBody разбирает --serve-max-body в формате -S
+func parseServeMaxBody(value string) (int64, error) {
+	n, err := parseSize(value)
+	if err != nil {
+		return 0, fmt.Errorf("в параметре --serve-max-body: %w", err)
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("в параметре --serve-max-body: размер должен быть больше нуля")
+	}
+	return n, nil
+}
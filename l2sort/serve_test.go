@@ -0,0 +1,48 @@
+package l2sort
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeSort(t *testing.T) {
+	tests := []struct {
+		query string
+		code  int
+		want  string
+	}{
+		{"", http.StatusOK, "a\nb\nc\n"},
+		{"r=1", http.StatusOK, "c\nb\na\n"},
+		{"by=size", http.StatusBadRequest, ""},
+		{"by-missing=skip", http.StatusBadRequest, ""},
+		{"k=1:size", http.StatusBadRequest, ""},
+		{"order=k1:mtime", http.StatusBadRequest, ""},
+		{"to-sqlite=x.db", http.StatusBadRequest, ""},
+		{"nope=1", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/sort?"+tt.query, strings.NewReader("b\nc\na\n"))
+		w := httptest.NewRecorder()
+		serveSort(w, r, Options{}, 1<<20)
+		if w.Code != tt.code {
+			t.Errorf("%q: код %d, ожидался %d: %s", tt.query, w.Code, tt.code, w.Body)
+			continue
+		}
+		if tt.code == http.StatusOK && w.Body.String() != tt.want {
+			t.Errorf("%q: ответ %q, ожидался %q", tt.query, w.Body, tt.want)
+		}
+	}
+}
+
+func TestSorterFromArgsDeniesStat(t *testing.T) {
+	for _, args := range [][]string{{"--by", "mtime"}, {"-k", "2:size"}, {"--to-sqlite", "x.db"}} {
+		if _, err := sorterFromArgs(Options{}, args); err == nil {
+			t.Errorf("%q принято в режиме сервиса", args)
+		}
+	}
+	if _, err := sorterFromArgs(Options{}, []string{"-n"}); err != nil {
+		t.Errorf("-n: %v", err)
+	}
+}
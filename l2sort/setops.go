@@ -0,0 +1,143 @@
+package l2sort
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+)
+
+// Операции подкоманды set
+const (
+	setUnion     = "union"
+	setIntersect = "intersect"
+	setExcept    = "except"
+)
+
+// runSet выполняет подкоманду
+//
+//	l2sort set --union|--intersect|--except [--flags '-k 1'] [-o вывод] [--presorted] ФАЙЛ...
+//
+// Входы упорядочиваются по ключам из --flags поверх общих и сливаются; за тот же проход
+// выполняется операция над множествами ключей: --union - ключи хотя бы одного файла,
+// --intersect - ключи, которые есть во всех файлах, --except - ключи первого файла, которых
+// нет ни в одном из остальных. Для каждого ключа выводится одна строка - первая из файла
+// с наименьшим номером. Возвращает код завершения
+func runSet(ctx context.Context, args []string, base Options) int {
+	fs := flag.NewFlagSet("set", flag.ContinueOnError)
+	union := fs.Bool("union", false, "Объединение: ключи, которые есть хотя бы в одном файле")
+	intersect := fs.Bool("intersect", false, "Пересечение: ключи, которые есть во всех файлах")
+	except := fs.Bool("except", false, "Разность: ключи первого файла, которых нет в остальных")
+	flags := fs.String("flags", "", "Флаги сортировки, задающие ключ, например '-k 2 -n'")
+	output := fs.String("o", "-", "Файл результата (\"-\" - стандартный вывод)")
+	presorted := fs.Bool("presorted", false, "Входы уже отсортированы: только проверить порядок, не сортируя")
+	usage := func() {
+		fmt.Println("Использование: l2sort set --union|--intersect|--except [опции] файл...")
+		fs.PrintDefaults()
+	}
+	fs.Usage = usage
+
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	var ops []string
+	for op, on := range map[string]bool{setUnion: *union, setIntersect: *intersect, setExcept: *except} {
+		if on {
+			ops = append(ops, op)
+		}
+	}
+	if len(ops) != 1 || len(files) == 0 {
+		usage()
+		return exitUsage
+	}
+	sorter, err := sorterWithFlags(base, *flags)
+	if err != nil {
+		return reportUsage(err)
+	}
+	if err := sorter.SetFiles(ctx, files, *output, ops[0], *presorted); err != nil {
+		return reportError(err)
+	}
+	return 0
+}
+
+// SetFiles выполняет операцию op (union, intersect, except) над ключами файлов inputs
+// и записывает результат в output. Ограничение -S делится между входами поровну
+func (s *Sorter) SetFiles(ctx context.Context, inputs []string, output, op string, presorted bool) error {
+	sources, in, err := s.sortedInputs(ctx, inputs, presorted)
+	defer closeInputs(in)
+	if err != nil {
+		return err
+	}
+	set := &setSource{sorter: s, op: op}
+	for _, src := range sources {
+		set.inputs = append(set.inputs, peekSource{src: src})
+	}
+	var src rowSource = &ctxSource{ctx: ctx, src: set}
+	return s.writeOutputs(output, nil, func(w io.Writer) error {
+		return writeRows(in[0].enc.encoder(w), src, s.format, in[0].eol)
+	})
+}
+
+// setSource сливает упорядоченные потоки по группам равных ключей и выдает по строке
+// на ключ, прошедший операцию
+type setSource struct {
+	sorter *Sorter
+	inputs []peekSource
+	op     string
+}
+
+func (m *setSource) next() (Row, bool, error) {
+	for {
+		// Наименьший ключ среди первых строк входов
+		var least *Row
+		for i := range m.inputs {
+			row, ok, err := m.inputs[i].peek()
+			if err != nil {
+				return Row{}, false, err
+			}
+			if ok && (least == nil || m.sorter.compareOrder(row, least) < 0) {
+				least = row
+			}
+		}
+		if least == nil {
+			return Row{}, false, nil
+		}
+		key := *least
+
+		// Забираются группы с этим ключом из всех входов, где он есть
+		var first Row
+		found, inFirst := 0, false
+		for i := range m.inputs {
+			row, ok, err := m.inputs[i].peek()
+			if err != nil {
+				return Row{}, false, err
+			}
+			if !ok || m.sorter.compareOrder(row, &key) != 0 {
+				continue
+			}
+			group, err := m.inputs[i].group(m.sorter)
+			if err != nil {
+				return Row{}, false, err
+			}
+			if found == 0 {
+				first = group[0]
+			}
+			found++
+			inFirst = inFirst || i == 0
+		}
+
+		var keep bool
+		switch m.op {
+		case setUnion:
+			keep = true
+		case setIntersect:
+			keep = found == len(m.inputs)
+		case setExcept:
+			keep = inFirst && found == 1
+		}
+		if keep {
+			return first, true, nil
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package l2sort
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestSet проверяет операции над множествами ключей нескольких файлов
+func TestSet(t *testing.T) {
+	inputs := []string{"c 1\na 1\nb 1\na 2\n", "b 2\nd 2\n", "b 3\nc 3\n"}
+	tests := []struct {
+		name   string
+		args   []string
+		inputs []string
+		code   int
+		want   []string
+	}{
+		{"union", []string{"--union", "--flags", "-k 1"}, inputs, 0, []string{"a 1", "b 1", "c 1", "d 2"}},
+		{"intersect", []string{"--intersect", "--flags", "-k 1"}, inputs, 0, []string{"b 1"}},
+		{"intersect двух файлов", []string{"--intersect", "--flags", "-k 1"}, inputs[1:], 0, []string{"b 2"}},
+		{"except", []string{"--except", "--flags", "-k 1"}, inputs, 0, []string{"a 1"}},
+		{"строки целиком", []string{"--union"}, []string{"b\na\n", "a\nc\n"}, 0, []string{"a", "b", "c"}},
+		{"числовой ключ", []string{"--except", "--flags", "-k 1 -n"}, []string{"10\n9\n2\n", "9\n"}, 0, []string{"2", "10"}},
+		{"без операции", nil, inputs, exitUsage, nil},
+		{"две операции", []string{"--union", "--except"}, inputs, exitUsage, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, got := runCommand(t, runSet, tt.args, tt.inputs...)
+			if code != tt.code {
+				t.Fatalf("код %d, ожидался %d", code, tt.code)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("получено %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,545 @@
+package l2sort
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Пространства имен XML документов ods
+const (
+	odsTableNS  = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	odsOfficeNS = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	odsTextNS   = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+)
+
+// runSheet выполняет подкоманду
+//
+//	l2sort sheet --column C [--column ...] [--header-rows N] [опции] [-o результат] ФАЙЛ.xlsx|ФАЙЛ.ods
+//
+// Сортирует строки первого листа книги xlsx или ods по колонкам, заданным номером (с 1)
+// или названием из первой строки, теми же компараторами, что и текст (-n, -M, --time, -r
+// и другие), и записывает книгу с переставленными строками; строки заголовка остаются на
+// месте. Возвращает код завершения
+func runSheet(ctx context.Context, args []string, base Options) int {
+	cmd, _ := findCommand("sheet")
+	fs, o := sortFlagSet("sheet", cmd.usage, base)
+	var columns stringList
+	fs.Var(&columns, "column", "Колонка сортировки: номер с 1 или название из первой строки; можно указать несколько раз")
+	headerRows := fs.Int("header-rows", 1, "Число строк заголовка, которые остаются на месте")
+	output := fs.String("o", "", "Файл результата; по умолчанию книга перезаписывается на месте")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(files) != 1 || len(columns) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if *headerRows < 0 {
+		return reportUsage(fmt.Errorf("в параметре --header-rows: число строк не может быть отрицательным"))
+	}
+	if *output == "" {
+		*output = files[0]
+	}
+	sorter, err := NewSorter(*o)
+	if err != nil {
+		return reportUsage(err)
+	}
+	if err := sorter.SortSheet(ctx, files[0], *output, columns, *headerRows); err != nil {
+		return reportError(err)
+	}
+	return 0
+}
+
+// sheetRow - строка листа: границы ее элемента в XML листа и значения ячеек по номеру
+// колонки (с 0)
+type sheetRow struct {
+	start, end int64
+	num        int
+	cells      []string
+}
+
+func (r *sheetRow) empty() bool {
+	for _, cell := range r.cells {
+		if cell != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *sheetRow) set(col int, value string) {
+	if col >= len(r.cells) {
+		r.cells = append(r.cells, make([]string, col+1-len(r.cells))...)
+	}
+	r.cells[col] = value
+}
+
+// SortSheet сортирует строки первого листа книги input по колонкам columns и записывает
+// книгу в output. Первые headerRows строк и пустые строки не сортируются: пустые идут
+// после данных. Формулы не пересчитываются, а объединенные ячейки и ссылки на строки
+// из других мест книги не переносятся вместе со строками
+func (s *Sorter) SortSheet(ctx context.Context, input, output string, columns []string, headerRows int) error {
+	zr, err := zip.OpenReader(input)
+	if err != nil {
+		return fmt.Errorf("при чтении книги: %w", err)
+	}
+	defer zr.Close()
+
+	var sheetPath string
+	var rows []sheetRow
+	var data []byte
+	switch ext := strings.ToLower(filepath.Ext(input)); ext {
+	case ".xlsx":
+		if sheetPath, err = xlsxFirstSheet(&zr.Reader); err != nil {
+			return fmt.Errorf("при чтении книги %s: %w", input, err)
+		}
+		shared, err := xlsxSharedStrings(&zr.Reader)
+		if err != nil {
+			return fmt.Errorf("при чтении книги %s: %w", input, err)
+		}
+		if data, err = readZipFile(&zr.Reader, sheetPath); err != nil {
+			return fmt.Errorf("при чтении книги %s: %w", input, err)
+		}
+		rows, err = parseXLSXRows(data, shared)
+		if err != nil {
+			return fmt.Errorf("при чтении листа %s: %w", sheetPath, err)
+		}
+	case ".ods":
+		sheetPath = "content.xml"
+		if data, err = readZipFile(&zr.Reader, sheetPath); err != nil {
+			return fmt.Errorf("при чтении книги %s: %w", input, err)
+		}
+		if rows, err = parseODSRows(data); err != nil {
+			return fmt.Errorf("при чтении листа: %w", err)
+		}
+	default:
+		return fmt.Errorf("при чтении книги %s: поддерживаются только .xlsx и .ods", input)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	header := rows[:min(headerRows, len(rows))]
+	cols, err := sheetColumns(columns, header)
+	if err != nil {
+		return err
+	}
+
+	// Места сортируемых строк в документе; пустые строки занимают последние из них
+	var filled, blank []int
+	for i := len(header); i < len(rows); i++ {
+		if rows[i].empty() {
+			blank = append(blank, i)
+		} else {
+			filled = append(filled, i)
+		}
+	}
+	slots := append(append([]int(nil), filled...), blank...)
+	sort.Ints(slots)
+	keyed := make([]Row, len(rows))
+	for _, i := range filled {
+		texts := make([]string, len(cols))
+		for k, col := range cols {
+			if col < len(rows[i].cells) {
+				texts[k] = rows[i].cells[col]
+			}
+		}
+		keyed[i] = s.newRow(nil, strings.Join(texts, "\t"), s.trimKeyBlanks(texts))
+	}
+	sort.SliceStable(filled, func(a, b int) bool {
+		return s.sortOrder(&keyed[filled[a]], &keyed[filled[b]]) < 0
+	})
+	order := append(filled, blank...)
+
+	var out bytes.Buffer
+	var pos int64
+	xlsx := sheetPath != "content.xml"
+	for n, slot := range slots {
+		from := rows[order[n]]
+		out.Write(data[pos:rows[slot].start])
+		snippet := data[from.start:from.end]
+		if xlsx && from.num != rows[slot].num {
+			snippet = renumberXLSXRow(snippet, rows[slot].num)
+		}
+		out.Write(snippet)
+		pos = rows[slot].end
+	}
+	out.Write(data[pos:])
+	return writeSheetBook(&zr.Reader, sheetPath, out.Bytes(), output, s.opts.Backup)
+}
+
+// sheetColumns находит номера колонок (с 0) по номерам с 1 или названиям из первой
+// строки заголовка
+func sheetColumns(columns []string, header []sheetRow) ([]int, error) {
+	var cols []int
+	for _, name := range columns {
+		if n, err := strconv.Atoi(name); err == nil {
+			if n < 1 {
+				return nil, fmt.Errorf("в параметре --column: номер колонки должен быть положительным")
+			}
+			cols = append(cols, n-1)
+			continue
+		}
+		found := -1
+		if len(header) > 0 {
+			for i, cell := range header[0].cells {
+				if strings.TrimSpace(cell) == name {
+					found = i
+					break
+				}
+			}
+		}
+		if found < 0 {
+			return nil, fmt.Errorf("в параметре --column: в первой строке нет колонки %q", name)
+		}
+		cols = append(cols, found)
+	}
+	return cols, nil
+}
+
+func readZipFile(zr *zip.Reader, name string) ([]byte, error) {
+	file, err := zr.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// writeSheetBook записывает книгу в output: все части архива копируются без изменений
+// и без повторного сжатия, кроме листа sheetPath, который заменяется на data
+func writeSheetBook(zr *zip.Reader, sheetPath string, data []byte, output string, backup bool) (err error) {
+	file, err := createAtomic(output, backup)
+	if err != nil {
+		return fmt.Errorf("при создании файла: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			file.discard()
+		}
+	}()
+	zw := zip.NewWriter(file)
+	for _, f := range zr.File {
+		if f.Name != sheetPath {
+			if err := zw.Copy(f); err != nil {
+				return fmt.Errorf("при записи книги: %w", err)
+			}
+			continue
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: zip.Deflate, Modified: f.Modified})
+		if err != nil {
+			return fmt.Errorf("при записи книги: %w", err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return fmt.Errorf("при записи книги: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("при записи книги: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("при сохранении результата: %w", err)
+	}
+	return nil
+}
+
+// xlsxFirstSheet возвращает имя части архива с первым листом книги xlsx
+func xlsxFirstSheet(zr *zip.Reader) (string, error) {
+	data, err := readZipFile(zr, "xl/workbook.xml")
+	if err != nil {
+		return "", err
+	}
+	var workbook struct {
+		Sheets []struct {
+			ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xml.Unmarshal(data, &workbook); err != nil {
+		return "", err
+	}
+	if len(workbook.Sheets) == 0 {
+		return "", fmt.Errorf("в книге нет листов")
+	}
+	if data, err = readZipFile(zr, "xl/_rels/workbook.xml.rels"); err != nil {
+		return "", err
+	}
+	var rels struct {
+		Rels []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := xml.Unmarshal(data, &rels); err != nil {
+		return "", err
+	}
+	for _, rel := range rels.Rels {
+		if rel.ID != workbook.Sheets[0].ID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return "", fmt.Errorf("не найден файл листа %s", workbook.Sheets[0].ID)
+}
+
+// xlsxSharedStrings читает общую таблицу строк книги xlsx; ее может не быть
+func xlsxSharedStrings(zr *zip.Reader) ([]string, error) {
+	data, err := readZipFile(zr, "xl/sharedStrings.xml")
+	if err != nil {
+		return nil, nil
+	}
+	var table struct {
+		Items []struct {
+			T    string `xml:"t"`
+			Runs []struct {
+				T string `xml:"t"`
+			} `xml:"r"`
+		} `xml:"si"`
+	}
+	if err := xml.Unmarshal(data, &table); err != nil {
+		return nil, err
+	}
+	strs := make([]string, len(table.Items))
+	for i, item := range table.Items {
+		var b strings.Builder
+		b.WriteString(item.T)
+		for _, run := range item.Runs {
+			b.WriteString(run.T)
+		}
+		strs[i] = b.String()
+	}
+	return strs, nil
+}
+
+// parseXLSXRows находит строки листа xlsx (элементы row в sheetData) и значения их ячеек
+func parseXLSXRows(data []byte, shared []string) ([]sheetRow, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var rows []sheetRow
+	var stack []string
+	var row *sheetRow
+	var cellRef, cellType string
+	var text strings.Builder
+	inText, nextCol := false, 0
+	for {
+		start := d.InputOffset()
+		tok, err := d.Token()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			parent := ""
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			}
+			stack = append(stack, t.Name.Local)
+			switch {
+			case t.Name.Local == "row" && parent == "sheetData":
+				rows = append(rows, sheetRow{start: start, num: len(rows) + 1})
+				row, nextCol = &rows[len(rows)-1], 0
+				if n, err := strconv.Atoi(xmlAttr(t, "", "r")); err == nil {
+					row.num = n
+				}
+			case t.Name.Local == "c" && row != nil:
+				cellRef, cellType = xmlAttr(t, "", "r"), xmlAttr(t, "", "t")
+				text.Reset()
+			case (t.Name.Local == "v" || t.Name.Local == "t") && row != nil:
+				inText = true
+			}
+		case xml.CharData:
+			if inText {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+			switch {
+			case t.Name.Local == "v" || t.Name.Local == "t":
+				inText = false
+			case t.Name.Local == "c" && row != nil:
+				col := nextCol
+				if cellRef != "" {
+					col = xlsxColumn(cellRef)
+				}
+				row.set(col, xlsxCellValue(cellType, text.String(), shared))
+				nextCol = col + 1
+			case t.Name.Local == "row" && row != nil:
+				row.end = d.InputOffset()
+				row = nil
+			}
+		}
+	}
+}
+
+// xlsxColumn возвращает номер колонки (с 0) по ссылке на ячейку, например 1 для B7
+func xlsxColumn(ref string) int {
+	col := 0
+	for _, r := range strings.ToUpper(strings.TrimPrefix(ref, "$")) {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+	}
+	return col - 1
+}
+
+// xlsxCellValue возвращает текст ячейки по ее типу: s - номер в общей таблице строк,
+// b - логическое значение, остальные (числа, строки формул, строки в ячейке) - как есть
+func xlsxCellValue(kind, value string, shared []string) string {
+	switch kind {
+	case "s":
+		if i, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && i >= 0 && i < len(shared) {
+			return shared[i]
+		}
+	case "b":
+		if value == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	return value
+}
+
+var (
+	xlsxRowRef  = regexp.MustCompile(`^(<(?:\w+:)?row\b[^>]*?\sr=")\d+(")`)
+	xlsxCellRef = regexp.MustCompile(`(<(?:\w+:)?c\b[^>]*?\sr="\$?[A-Za-z]+\$?)\d+(")`)
+)
+
+// renumberXLSXRow переносит элемент строки xlsx на строку num: меняет номер строки и
+// ссылки ее ячеек
+func renumberXLSXRow(snippet []byte, num int) []byte {
+	n := []byte(strconv.Itoa(num))
+	repl := append(append([]byte("${1}"), n...), "${2}"...)
+	snippet = xlsxRowRef.ReplaceAll(snippet, repl)
+	return xlsxCellRef.ReplaceAll(snippet, repl)
+}
+
+// parseODSRows находит строки первой таблицы документа ods (элементы table-row, прямо
+// вложенные в table) и значения их ячеек. Строки внутри table-header-rows и групп
+// строк не сортируются
+func parseODSRows(data []byte) ([]sheetRow, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var rows []sheetRow
+	var stack []xml.Name
+	var row *sheetRow
+	tableDepth := 0
+	var text strings.Builder
+	inCell, paragraphs := false, 0
+	var cellValue string
+	col, repeat := 0, 1
+	for {
+		start := d.InputOffset()
+		tok, err := d.Token()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name)
+			switch {
+			case t.Name.Space == odsTableNS && t.Name.Local == "table" && tableDepth == 0:
+				tableDepth = len(stack)
+			case t.Name.Space == odsTableNS && t.Name.Local == "table-row" && tableDepth > 0 && len(stack) == tableDepth+1:
+				rows = append(rows, sheetRow{start: start})
+				row, col = &rows[len(rows)-1], 0
+			case row != nil && t.Name.Space == odsTableNS && (t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell"):
+				inCell, paragraphs = true, 0
+				text.Reset()
+				repeat = 1
+				if n, err := strconv.Atoi(xmlAttr(t, odsTableNS, "number-columns-repeated")); err == nil && n > 1 {
+					repeat = n
+				}
+				cellValue = odsTypedValue(t)
+			case inCell && t.Name.Space == odsTextNS:
+				switch t.Name.Local {
+				case "p":
+					if paragraphs > 0 {
+						text.WriteByte('\n')
+					}
+					paragraphs++
+				case "s":
+					n, err := strconv.Atoi(xmlAttr(t, odsTextNS, "c"))
+					if err != nil || n < 1 {
+						n = 1
+					}
+					text.WriteString(strings.Repeat(" ", n))
+				case "tab":
+					text.WriteByte('\t')
+				case "line-break":
+					text.WriteByte('\n')
+				}
+			}
+		case xml.CharData:
+			if inCell {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			depth := len(stack)
+			stack = stack[:depth-1]
+			switch {
+			case row != nil && t.Name.Space == odsTableNS && (t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell"):
+				inCell = false
+				value := cellValue
+				if value == "" {
+					value = text.String()
+				}
+				// Повторы пустых ячеек в конце строки бывают на тысячи колонок: они не
+				// хранятся, пустое значение и так подразумевается
+				if value != "" {
+					for i := 0; i < repeat; i++ {
+						row.set(col+i, value)
+					}
+				}
+				col += repeat
+			case row != nil && t.Name.Local == "table-row" && depth == tableDepth+1:
+				row.end = d.InputOffset()
+				row = nil
+			case depth == tableDepth:
+				tableDepth = -1
+			}
+		}
+	}
+}
+
+// odsTypedValue возвращает значение ячейки ods из атрибутов (число, дата, время,
+// логическое), если оно там есть; текстовые ячейки берутся из абзацев
+func odsTypedValue(t xml.StartElement) string {
+	switch xmlAttr(t, odsOfficeNS, "value-type") {
+	case "float", "percentage", "currency":
+		return xmlAttr(t, odsOfficeNS, "value")
+	case "date":
+		return xmlAttr(t, odsOfficeNS, "date-value")
+	case "time":
+		return xmlAttr(t, odsOfficeNS, "time-value")
+	case "boolean":
+		return strings.ToUpper(xmlAttr(t, odsOfficeNS, "boolean-value"))
+	}
+	return ""
+}
+
+func xmlAttr(t xml.StartElement, space, local string) string {
+	for _, attr := range t.Attr {
+		if attr.Name.Local == local && (space == "" && attr.Name.Space == "" || attr.Name.Space == space) {
+			return attr.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,196 @@
+package l2sort
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// writeZip записывает архив с частями files
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zw := zip.NewWriter(file)
+	names := slices.Sorted(func(yield func(string) bool) {
+		for name := range files {
+			if !yield(name) {
+				return
+			}
+		}
+	})
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeXLSX записывает книгу xlsx с одним листом: числа хранятся значениями ячеек,
+// остальной текст - в общей таблице строк
+func writeXLSX(t *testing.T, path string, rows [][]string) {
+	t.Helper()
+	var sheet, shared strings.Builder
+	count := 0
+	for i, row := range rows {
+		fmt.Fprintf(&sheet, `<row r="%d">`, i+1)
+		for j, cell := range row {
+			ref := fmt.Sprintf("%c%d", 'A'+j, i+1)
+			if _, err := strconv.ParseFloat(cell, 64); err == nil {
+				fmt.Fprintf(&sheet, `<c r="%s"><v>%s</v></c>`, ref, cell)
+				continue
+			}
+			fmt.Fprintf(&sheet, `<c r="%s" t="s"><v>%d</v></c>`, ref, count)
+			fmt.Fprintf(&shared, `<si><t>%s</t></si>`, cell)
+			count++
+		}
+		sheet.WriteString(`</row>`)
+	}
+	writeZip(t, path, map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Лист1" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`,
+		"xl/worksheets/sheet1.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+			sheet.String() + `</sheetData></worksheet>`,
+		"xl/sharedStrings.xml": `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` + shared.String() + `</sst>`,
+	})
+}
+
+// writeODS записывает документ ods с одной таблицей; числа хранятся в атрибутах ячеек
+func writeODS(t *testing.T, path string, rows [][]string) {
+	t.Helper()
+	var table strings.Builder
+	for _, row := range rows {
+		table.WriteString(`<table:table-row>`)
+		for _, cell := range row {
+			if _, err := strconv.ParseFloat(cell, 64); err == nil {
+				fmt.Fprintf(&table, `<table:table-cell office:value-type="float" office:value="%s"><text:p>%s</text:p></table:table-cell>`, cell, cell)
+				continue
+			}
+			fmt.Fprintf(&table, `<table:table-cell office:value-type="string"><text:p>%s</text:p></table:table-cell>`, cell)
+		}
+		table.WriteString(`</table:table-row>`)
+	}
+	writeZip(t, path, map[string]string{
+		"mimetype": "application/vnd.oasis.opendocument.spreadsheet",
+		"content.xml": `<office:document-content xmlns:office="` + odsOfficeNS + `" xmlns:table="` + odsTableNS +
+			`" xmlns:text="` + odsTextNS + `"><office:body><office:spreadsheet><table:table table:name="Лист1">` +
+			table.String() + `</table:table></office:spreadsheet></office:body></office:document-content>`,
+	})
+}
+
+var xlsxRefs = regexp.MustCompile(`<(?:row|c) r="[A-Z]*(\d+)"`)
+
+// readSheet читает значения ячеек первого листа книги. Для xlsx проверяет, что строки
+// и ссылки их ячеек перенумерованы по новым местам
+func readSheet(t *testing.T, path string) [][]string {
+	t.Helper()
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	var rows []sheetRow
+	if strings.HasSuffix(path, ".ods") {
+		data, err := readZipFile(&zr.Reader, "content.xml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rows, err = parseODSRows(data); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		shared, err := xlsxSharedStrings(&zr.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := readZipFile(&zr.Reader, "xl/worksheets/sheet1.xml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rows, err = parseXLSXRows(data, shared); err != nil {
+			t.Fatal(err)
+		}
+		for i, row := range rows {
+			for _, ref := range xlsxRefs.FindAllSubmatch(data[row.start:row.end], -1) {
+				if n, _ := strconv.Atoi(string(ref[1])); n != i+1 {
+					t.Errorf("строка %d содержит ссылку на строку %d: %s", i+1, n, ref[0])
+				}
+			}
+		}
+	}
+	var cells [][]string
+	for _, row := range rows {
+		cells = append(cells, row.cells)
+	}
+	return cells
+}
+
+// TestSortSheet сортирует листы xlsx и ods подкомандой sheet
+func TestSortSheet(t *testing.T) {
+	book := [][]string{
+		{"Имя", "Сумма"},
+		{"Борис", "10"},
+		{"Анна", "9"},
+		{},
+		{"Вера", "100"},
+	}
+	tests := []struct {
+		name string
+		args []string
+		code int
+		want [][]string
+	}{
+		{"по названию", []string{"--column", "Сумма", "-n"}, 0, [][]string{{"Имя", "Сумма"}, {"Анна", "9"}, {"Борис", "10"}, {"Вера", "100"}, nil}},
+		{"по номеру", []string{"--column", "1"}, 0, [][]string{{"Имя", "Сумма"}, {"Анна", "9"}, {"Борис", "10"}, {"Вера", "100"}, nil}},
+		{"обратный порядок", []string{"--column", "Сумма", "-n", "-r"}, 0, [][]string{{"Имя", "Сумма"}, {"Вера", "100"}, {"Борис", "10"}, {"Анна", "9"}, nil}},
+		{"текстовое сравнение чисел", []string{"--column", "2"}, 0, [][]string{{"Имя", "Сумма"}, {"Борис", "10"}, {"Вера", "100"}, {"Анна", "9"}, nil}},
+		{"без заголовка", []string{"--column", "1", "--header-rows", "0"}, 0, [][]string{{"Анна", "9"}, {"Борис", "10"}, {"Вера", "100"}, {"Имя", "Сумма"}, nil}},
+		{"нет колонки", []string{"--column", "Дата"}, exitData, nil},
+		{"без колонки", nil, exitUsage, nil},
+	}
+	for _, ext := range []string{".xlsx", ".ods"} {
+		for _, tt := range tests {
+			t.Run(ext+" "+tt.name, func(t *testing.T) {
+				dir := t.TempDir()
+				input, output := filepath.Join(dir, "in"+ext), filepath.Join(dir, "out"+ext)
+				if ext == ".ods" {
+					writeODS(t, input, book)
+				} else {
+					writeXLSX(t, input, book)
+				}
+				args := append(slices.Clone(tt.args), "-o", output, input)
+				if code := runSheet(context.Background(), args, Options{}); code != tt.code {
+					t.Fatalf("код %d, ожидался %d", code, tt.code)
+				}
+				if tt.code != 0 {
+					return
+				}
+				got := readSheet(t, output)
+				if !slices.EqualFunc(got, tt.want, slices.Equal) {
+					t.Errorf("получено %q, ожидалось %q", got, tt.want)
+				}
+			})
+		}
+	}
+}
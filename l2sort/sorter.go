@@ -0,0 +1,555 @@
+package l2sort
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Sorter выполняет сортировку с заданными настройками. Производное от настроек состояние
+// (ограничение памяти, фильтр, форматирование вывода, форматы времени) вычисляется один раз
+// в NewSorter, поэтому несколько Sorter с разными настройками могут работать одновременно
+type Sorter struct {
+	opts    Options
+	limit   int64
+	maxLine int64
+	// maxTemp - ограничение --max-temp места под временные файлы, 0 - без ограничения
+	maxTemp int64
+	keep    linePredicate
+	format  rowFormatter
+	layouts []timeLayout
+	// numeric - разделители чисел для -n из --numeric-locale
+	numeric numericLocale
+	// radix - основание целых для -n из --radix; 0 - по префиксу
+	radix int
+	// keyKinds - типы сравнения ключей -k по порядку (при --infer-types - и ключей-полей
+	// без -k); defaultKind - тип ключей без модификатора типа, из общего флага
+	keyKinds    []keyKind
+	defaultKind keyKind
+	// keyTransforms - преобразования ключей -k по порядку; defaultTransforms - из
+	// --key-transform для остальных ключей
+	keyTransforms     [][]keyTransform
+	defaultTransforms []keyTransform
+	// order - цепочка сравнения --order, nil - обычное сравнение по ключам
+	order []orderRule
+	// aggregates - функции --aggregate для --group-by
+	aggregates []aggregate
+	// keyRegex - выражение из --key-regex; его группы заменяют разбиение строки на поля
+	keyRegex *regexp.Regexp
+	// expr - шаблон --expr, вычисляющий ключ строки, иначе nil
+	expr *template.Template
+	// fieldSep - разделитель полей из --field-regex; nil - пробельные символы
+	fieldSep *regexp.Regexp
+	// normalize - форма нормализации ключей из --normalize (nfc, nfkc) или пустая строка
+	normalize string
+	// alphabet - порядок символов из --alphabet для текстового сравнения, иначе nil
+	alphabet *collation
+	// fixedCols - колонки --fixed-cols; заменяют разбиение строки на поля
+	fixedCols []fixedCol
+	// recordSep и recordKey - выражения --record-sep и --record-key для многострочных записей
+	recordSep *regexp.Regexp
+	recordKey *regexp.Regexp
+	// encoding - кодировка входа из --encoding; метка порядка байт во входе ее переопределяет
+	encoding *textEncoding
+	// indexStride - наименьшее расстояние между записями индекса --index из --index-stride
+	indexStride int64
+	// rng - генератор случайных чисел из --seed для --sample, --sample-pct и --shuffle
+	rng *rand.Rand
+	// salt - соль хэша ключей для -R; hashSalt - для --hash-order, из --hash-salt
+	salt     uint64
+	hashSalt uint64
+	// runRows - число строк в каждом прогоне при --shuffle
+	runRows map[string]int
+	// stdout - получатель вывода "-"; nil означает os.Stdout
+	stdout io.Writer
+	// fields - буфер полей строки, повторно используемый extractKeys
+	fields []string
+	// keyBuf - буфер текстов ключей, которых нет в строке, повторно используемый newRow
+	keyBuf []byte
+	// resume - состояние --resume текущей сортировки, иначе nil
+	resume *resumeState
+	// stats - статистика текущей сортировки при --stats, иначе nil
+	stats *sortStats
+	// stat - сведения о файлах для ключей :mtime и :size, иначе nil
+	stat *statCache
+	// records - разметка двоичных записей --record-size, иначе nil
+	records *recordLayout
+	// skipSorted - не перезаписывать уже отсортированный вход (для --watch)
+	skipSorted bool
+}
+
+// Result описывает итог сортировки одного файла
+type Result struct {
+	Lines         int
+	Runs          int
+	AlreadySorted bool
+	CacheHit      bool
+	// Partitions - число файлов, созданных при --partition-by или --split
+	Partitions int
+	// Moved и Duplicates - при --dry-run число строк, которые сменили бы позицию,
+	// и повторов, которые удалил бы -u
+	Moved      int
+	Duplicates int
+}
+
+// NewSorter проверяет настройки и готовит Sorter к работе
+func NewSorter(opts Options) (*Sorter, error) {
+	s := &Sorter{opts: opts.clone()}
+	// --time-format включает --time, если формат не относится к ключам -k N:time
+	timeKey := slices.ContainsFunc(s.opts.Keys, func(k keySpec) bool { return k.Type == typeTime })
+	if s.opts.TimeFormat != "" && !timeKey {
+		s.opts.Time = true
+	}
+	s.layouts = timeLayouts(s.opts.TimeFormat)
+
+	var err error
+	if s.limit, err = parseSize(s.opts.BufferSize); err != nil {
+		return nil, fmt.Errorf("в параметре -S: %w", err)
+	}
+	if s.maxTemp, err = parseSize(s.opts.MaxTemp); err != nil {
+		return nil, fmt.Errorf("в параметре --max-temp: %w", err)
+	}
+	if s.maxLine, err = parseSize(s.opts.MaxLineSize); err != nil {
+		return nil, fmt.Errorf("в параметре --max-line-size: %w", err)
+	}
+	switch s.opts.LongLines {
+	case "", longLineTruncateKey, longLineSkip, longLineError:
+	default:
+		return nil, fmt.Errorf("в параметре --long-lines: неизвестная политика %q", s.opts.LongLines)
+	}
+	if s.opts.Strict {
+		// --strict делает ошибками строки без колонки ключа и с некорректным UTF-8,
+		// если для них не выбрана своя политика
+		s.opts.Missing = cmp.Or(s.opts.Missing, missingError)
+		s.opts.Binary = cmp.Or(s.opts.Binary, binaryError)
+	}
+	switch s.opts.Binary {
+	case "", binaryRaw, binarySkip, binaryError:
+	default:
+		return nil, fmt.Errorf("в параметре --binary: неизвестная политика %q, ожидалось raw, skip или error", s.opts.Binary)
+	}
+	if s.encoding, err = lookupEncoding(s.opts.Encoding); err != nil {
+		return nil, fmt.Errorf("в параметре --encoding: %w", err)
+	}
+	if s.opts.Bytes && (s.encoding.transcoded()) {
+		return nil, fmt.Errorf("в параметрах: --bytes сравнивает исходные байты и несовместим с --encoding %s", s.encoding.name)
+	}
+	if err := checkCompat(s.opts); err != nil {
+		return nil, err
+	}
+	if s.opts.Compat == compatGNU {
+		// GNU sort при LC_ALL=C сравнивает текст побайтово
+		s.opts.Bytes = true
+		if err := gnuKeySpecs(&s.opts); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.resolveOrder(); err != nil {
+		return nil, err
+	}
+	if s.numeric, err = lookupNumericLocale(s.opts.NumericLocale); err != nil {
+		return nil, fmt.Errorf("в параметре --numeric-locale: %w", err)
+	}
+	if s.radix, err = parseRadix(s.opts.Radix); err != nil {
+		return nil, fmt.Errorf("в параметре --radix: %w", err)
+	}
+	if err := checkBy(s.opts); err != nil {
+		return nil, err
+	}
+	if s.records, err = parseRecordLayout(s.opts); err != nil {
+		return nil, err
+	}
+	if s.records != nil {
+		// --key-type задает тип ключа записи, а не тип сравнения текста
+		s.opts.KeyTypes = nil
+	}
+	if err := checkMergeFanIn(s.opts); err != nil {
+		return nil, err
+	}
+	if err := checkInferTypes(s.opts); err != nil {
+		return nil, err
+	}
+	if err := s.resolveKeyKinds(); err != nil {
+		return nil, err
+	}
+	if err := s.checkGNUKinds(); err != nil {
+		return nil, err
+	}
+	if err := s.resolveEpsilon(); err != nil {
+		return nil, err
+	}
+	if err := s.checkIgnoreLeadingZeros(); err != nil {
+		return nil, err
+	}
+	if err := s.resolveKeyTransforms(); err != nil {
+		return nil, err
+	}
+	if s.usesStat() {
+		s.stat = newStatCache(s.opts)
+	}
+	if s.opts.KeyRegex != "" {
+		if s.keyRegex, err = regexp.Compile(s.opts.KeyRegex); err != nil {
+			return nil, fmt.Errorf("в параметре --key-regex: %w", err)
+		}
+	}
+	if s.opts.FieldRegex != "" {
+		if s.keyRegex != nil {
+			return nil, fmt.Errorf("--field-regex и --key-regex нельзя указывать вместе")
+		}
+		if s.fieldSep, err = regexp.Compile(s.opts.FieldRegex); err != nil {
+			return nil, fmt.Errorf("в параметре --field-regex: %w", err)
+		}
+		if s.fieldSep.MatchString("") {
+			return nil, fmt.Errorf("в параметре --field-regex: выражение не должно совпадать с пустой строкой")
+		}
+	}
+	if s.opts.FixedCols != "" {
+		if s.keyRegex != nil || s.fieldSep != nil {
+			return nil, fmt.Errorf("--fixed-cols нельзя указывать вместе с --key-regex или --field-regex")
+		}
+		if s.fixedCols, err = parseFixedCols(s.opts.FixedCols); err != nil {
+			return nil, fmt.Errorf("в параметре --fixed-cols: %w", err)
+		}
+	}
+	if s.opts.TSV && (s.keyRegex != nil || s.fieldSep != nil || s.fixedCols != nil) {
+		return nil, fmt.Errorf("--tsv нельзя указывать вместе с --key-regex, --field-regex или --fixed-cols")
+	}
+	if s.opts.Expr != "" {
+		if len(s.opts.Keys) > 0 || s.keyRegex != nil {
+			return nil, fmt.Errorf("--expr нельзя указывать вместе с -k или --key-regex")
+		}
+		if s.expr, err = compileExpr(s.opts.Expr); err != nil {
+			return nil, fmt.Errorf("в параметре --expr: %w", err)
+		}
+	}
+	if s.opts.RecordSep != "" {
+		if s.recordSep, err = regexp.Compile(s.opts.RecordSep); err != nil {
+			return nil, fmt.Errorf("в параметре --record-sep: %w", err)
+		}
+	}
+	if s.opts.RecordKey != "" {
+		if s.recordSep == nil {
+			return nil, fmt.Errorf("в параметре --record-key: действует только вместе с --record-sep")
+		}
+		if s.recordKey, err = regexp.Compile(s.opts.RecordKey); err != nil {
+			return nil, fmt.Errorf("в параметре --record-key: %w", err)
+		}
+	}
+	switch s.opts.Comments {
+	case "", commentsKeep, commentsTop, commentsDrop:
+	default:
+		return nil, fmt.Errorf("в параметре --comments: неизвестный режим %q", s.opts.Comments)
+	}
+	if s.opts.Comments != "" && s.opts.CommentPrefix == "" {
+		s.opts.CommentPrefix = "#"
+	}
+	if s.opts.Comments != "" && s.recordSep != nil {
+		return nil, fmt.Errorf("в параметрах: --comments и --record-sep несовместимы")
+	}
+	if s.opts.PartitionBy < 0 {
+		return nil, fmt.Errorf("в параметре --partition-by: номер колонки должен быть положительным")
+	}
+	if s.opts.PartitionBy > 0 && !strings.Contains(s.opts.OutputTemplate, partitionKeyPlaceholder) {
+		return nil, fmt.Errorf("в параметре --output-template: шаблон должен содержать %s", partitionKeyPlaceholder)
+	}
+	switch {
+	case s.opts.Split < 0:
+		return nil, fmt.Errorf("в параметре --split: число частей должно быть положительным")
+	case s.opts.Split > 0 && s.opts.PartitionBy > 0:
+		return nil, fmt.Errorf("в параметрах: --split и --partition-by несовместимы")
+	}
+	switch s.opts.SplitMode {
+	case "", splitRoundRobin, splitRange:
+	default:
+		return nil, fmt.Errorf("в параметре --split-mode: неизвестный способ %q", s.opts.SplitMode)
+	}
+	if s.indexStride, err = parseSize(s.opts.IndexStride); err != nil {
+		return nil, fmt.Errorf("в параметре --index-stride: %w", err)
+	}
+	if s.opts.Index != "" {
+		switch {
+		case s.opts.PartitionBy > 0 || s.opts.Split > 0:
+			return nil, fmt.Errorf("в параметре --index: индекс строится для одного файла результата и несовместим с --partition-by и --split")
+		case s.opts.Freq:
+			return nil, fmt.Errorf("в параметре --index: результат --freq упорядочен не по ключам")
+		case s.opts.CompressOutput:
+			return nil, fmt.Errorf("в параметре --index: смещения в сжатом результате не имеют смысла")
+		case s.encoding.transcoded():
+			return nil, fmt.Errorf("в параметре --index: смещения считаются только для вывода в UTF-8")
+		}
+	}
+	if err := checkChecksum(s.opts); err != nil {
+		return nil, err
+	}
+	if err := checkSQLite(s.opts); err != nil {
+		return nil, err
+	}
+	if s.opts.ToSQLite != "" && s.opts.SQLiteTable == "" {
+		s.opts.SQLiteTable = sqliteDefaultTable
+	}
+	if err := checkSample(s.opts); err != nil {
+		return nil, err
+	}
+	if err := checkShuffle(s.opts); err != nil {
+		return nil, err
+	}
+	s.rng = newRand(s.opts.Seed)
+	s.salt = s.rng.Uint64()
+	s.hashSalt = hashOrderSalt(s.opts.HashSalt)
+	if s.opts.Shuffle {
+		s.runRows = make(map[string]int)
+	}
+	if s.opts.Skip < 0 || s.opts.Head < 0 {
+		return nil, fmt.Errorf("в параметрах: --skip и --head не могут быть отрицательными")
+	}
+	switch s.opts.UniqueKeep {
+	case "", uniqueKeepFirst, uniqueKeepLast:
+	default:
+		return nil, fmt.Errorf("в параметре --unique-keep: неизвестное значение %q", s.opts.UniqueKeep)
+	}
+	if s.normalize, err = parseNormalizeForm(s.opts.Normalize); err != nil {
+		return nil, fmt.Errorf("в параметре --normalize: %w", err)
+	}
+	if s.opts.Alphabet != "" {
+		if s.alphabet, err = loadAlphabet(s.opts.Alphabet); err != nil {
+			return nil, fmt.Errorf("в параметре --alphabet: %w", err)
+		}
+	}
+	switch s.opts.Missing {
+	case "", missingFirst, missingLast, missingError:
+	default:
+		return nil, fmt.Errorf("в параметре --missing: неизвестное значение %q, ожидалось first, last или error", s.opts.Missing)
+	}
+	if s.opts.DupsOutput != "" && !s.opts.Unique && !s.opts.UniqueOnly {
+		return nil, fmt.Errorf("в параметре --dups-output: действует только вместе с -u или --unique-only")
+	}
+	if s.opts.Freq && s.opts.GroupBy {
+		return nil, fmt.Errorf("в параметрах: --freq и --group-by несовместимы")
+	}
+	if s.opts.GroupBy {
+		if s.aggregates, err = parseAggregates(s.opts.Aggregate); err != nil {
+			return nil, fmt.Errorf("в параметре --aggregate: %w", err)
+		}
+	}
+	if s.keep, err = buildLineFilter(s.opts.Filter, s.opts.Grep, s.opts.Exclude); err != nil {
+		return nil, fmt.Errorf("в фильтре: %w", err)
+	}
+	if s.format, err = buildOutputTransform(s.opts, s.splitFields, s.rawFields); err != nil {
+		return nil, fmt.Errorf("в преобразовании вывода: %w", err)
+	}
+	if err := s.checkVerifyOutput(); err != nil {
+		return nil, err
+	}
+	if err := s.checkUniqueOnly(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SortFile сортирует input и записывает результат в output; см. SortFileContext
+func (s *Sorter) SortFile(input, output string) (Result, error) {
+	return s.SortFileContext(context.Background(), input, output)
+}
+
+// SortFileContext сортирует input и записывает результат в output; см. SortFilesContext
+func (s *Sorter) SortFileContext(ctx context.Context, input, output string) (Result, error) {
+	return s.SortFilesContext(ctx, []string{input}, output)
+}
+
+// SortFilesContext сортирует строки всех inputs вместе, как один вход, и записывает
+// результат в output (и в --also-output). При отмене ctx работа прекращается, временные
+// файлы удаляются, а output не изменяется. При --partition-by и --split вместо output
+// результат раскладывается по нескольким файлам. При -c и уже отсортированном входе
+// ничего не записывает. При --resume готовые прогоны сохраняются между запусками. При
+// --dry-run только оценивает изменения и ничего не записывает. При --record-size
+// сортируются двоичные записи (см. sortRecords)
+func (s *Sorter) SortFilesContext(ctx context.Context, inputs []string, output string) (Result, error) {
+	if s.records != nil {
+		return s.sortRecords(ctx, inputs, output)
+	}
+	if s.opts.DryRun {
+		return s.dryRun(ctx, inputs)
+	}
+	if !s.opts.Resume {
+		return s.sortFiles(ctx, inputs, output)
+	}
+	st, err := s.openResume(inputs, output)
+	if err != nil {
+		return Result{}, fmt.Errorf("в параметре --resume: %w", err)
+	}
+	s.resume = st
+	defer func() { s.resume = nil }()
+	result, err := s.sortFiles(ctx, inputs, output)
+	st.finish(err)
+	return result, err
+}
+
+func (s *Sorter) sortFiles(ctx context.Context, inputs []string, output string) (Result, error) {
+	var result Result
+	var cacheKey string
+	if s.opts.Index != "" && isCompressedName(output) {
+		return result, fmt.Errorf("в параметре --index: смещения в сжатом результате %s не имеют смысла", output)
+	}
+	if s.opts.VerifyOutput && output == "-" {
+		return result, fmt.Errorf("в параметре --verify-output: результат в стандартном выводе нельзя перечитать")
+	}
+	if s.usesCache() {
+		key, err := s.cacheKey(inputs)
+		if err != nil {
+			return result, fmt.Errorf("при чтении файла: %w", err)
+		}
+		if cached, ok := s.openCached(key); ok {
+			defer cached.Close()
+			result.CacheHit = true
+			return result, s.writeOutputs(output, nil, func(w io.Writer) error {
+				_, err := io.Copy(w, cached)
+				return err
+			})
+		}
+		cacheKey = key
+	}
+
+	if s.opts.Progress {
+		progress := startProgress(os.Stderr)
+		defer progress.stop()
+		ctx = withProgress(ctx, progress)
+	}
+	budget := newMemBudget(s.limit)
+	var phase time.Time
+	if s.opts.Stats {
+		s.stats = &sortStats{}
+		defer func() {
+			s.stats.peakMemory = budget.peak
+			s.stats.print(os.Stderr, inputsName(inputs))
+			s.stats = nil
+		}()
+		phase = time.Now()
+	}
+	in, err := s.readRows(ctx, inputs, budget)
+	if err != nil {
+		return result, fmt.Errorf("при чтении файла: %w", err)
+	}
+	defer in.close()
+	if s.resume == nil {
+		defer temps.remove(in.runs...)
+	}
+	result.Lines, result.Runs = in.lines, len(in.runs)
+	if s.stats != nil {
+		// сортировка порций перед сбросом на диск учтена отдельно
+		s.stats.lines = in.lines
+		s.stats.read = time.Since(phase) - s.stats.sort
+	}
+	if s.opts.Check {
+		if !in.sorted {
+			return result, fmt.Errorf("%w: строка %d нарушает порядок сортировки", errNotSorted, in.unsorted)
+		}
+		result.AlreadySorted = true
+		return result, nil
+	}
+	if s.skipSorted && in.sorted {
+		result.AlreadySorted = true
+		return result, nil
+	}
+
+	var dups *dupReport
+	if s.opts.DupsOutput != "" {
+		dups = newDupReport()
+	}
+	src, err := s.sortedSource(ctx, in, dups)
+	if err != nil {
+		return result, err
+	}
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	if s.stats != nil {
+		phase = time.Now()
+		defer func() { s.stats.write = time.Since(phase) }()
+	}
+	src = &ctxSource{ctx: ctx, src: src}
+	if progress := progressFrom(ctx); progress != nil {
+		progress.startWriting()
+		src = &progressSource{src: src, p: progress}
+	}
+	if s.opts.Freq {
+		src = &freqSource{src: src, counts: !s.opts.NoCounts}
+	}
+	if s.opts.GroupBy {
+		src = &groupSource{src: peekSource{src: src}, sorter: s, aggs: s.aggregates}
+	}
+	if s.opts.Head > 0 {
+		src = &headSource{src: src, n: s.opts.Head}
+	}
+	if s.opts.Debug {
+		src = &debugSource{src: src, sorter: s, out: os.Stderr}
+	}
+	switch {
+	case s.opts.PartitionBy > 0:
+		result.Partitions, err = s.writePartitions(src, in)
+	case s.opts.Split > 0:
+		result.Partitions, err = s.writeSplit(src, in, output)
+	case s.opts.ToSQLite != "":
+		err = s.writeSQLite(src, in)
+	default:
+		var cacheEntry io.WriteCloser
+		if cacheKey != "" {
+			if cacheEntry, err = s.createCacheEntry(cacheKey); err != nil {
+				return result, fmt.Errorf("при записи в кэш: %w", err)
+			}
+		}
+		written := &countSource{src: src}
+		err = s.writeOutputs(output, cacheEntry, func(w io.Writer) error {
+			w = in.enc.encoder(w)
+			if err := writeHeader(w, in.header, in.eol); err != nil {
+				return err
+			}
+			if s.opts.Index == "" {
+				return writeRows(w, written, s.format, in.eol)
+			}
+			return s.writeIndexed(w, written, in)
+		})
+		if err == nil && s.opts.VerifyOutput {
+			err = s.verifyOutput(ctx, output, written.n)
+		}
+	}
+	if err == nil && dups != nil {
+		err = s.writeDupsReport(dups, in)
+	}
+	return result, err
+}
+
+// sortedSource упорядочивает прочитанный вход: порцию в памяти сортирует на месте,
+// а при наличии прогонов сливает их с ней. При -u повторы отбрасываются и, если задан
+// dups, учитываются в нем
+func (s *Sorter) sortedSource(ctx context.Context, in *inputData, dups *dupReport) (rowSource, error) {
+	if s.opts.UniqueOnly {
+		// --unique-only: повторы уже отброшены при чтении, порядок входа сохраняется
+		for _, line := range in.dups {
+			dups.add(line)
+			s.stats.addDuplicate()
+		}
+		return &sliceSource{rows: in.rows}, nil
+	}
+	var src rowSource
+	if len(in.runs) == 0 {
+		s.sortRows(in.rows)
+		src = &sliceSource{rows: in.rows}
+	} else {
+		var err error
+		if src, err = s.mergeRuns(ctx, in.runs, in.rows); err != nil {
+			return nil, fmt.Errorf("при слиянии временных файлов: %w", err)
+		}
+	}
+	if s.opts.Unique {
+		src = &uniqueSource{src: peekSource{src: src}, sorter: s, keepLast: s.opts.UniqueKeep == uniqueKeepLast, dups: dups}
+	}
+	return src, nil
+}
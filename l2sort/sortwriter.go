@@ -0,0 +1,139 @@
+package l2sort
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SortWriter сортирует строки, которые подаются по одной по мере поступления, например
+// из запросов сервера, без предварительного сбора в срез:
+//
+//	w, err := NewSortWriter(ctx, opts)
+//	for ... {
+//		err = w.Write(line)
+//	}
+//	result, err := w.Flush(out)
+//
+// Как и при сортировке файла, порция в памяти при превышении -S сортируется
+// и сбрасывается на диск. После Flush и Reset SortWriter готов к следующей сортировке
+// с теми же настройками. SortWriter не предназначен для одновременного использования
+// из нескольких горутин
+type SortWriter struct {
+	sorter *Sorter
+	ctx    context.Context
+	budget *memBudget
+	arena  *rowArena
+	rows   []Row
+	runs   []string
+	// lines - число поданных строк
+	lines int
+}
+
+// NewSortWriter создает SortWriter с настройками opts. При отмене ctx Write и Flush
+// возвращают ошибку ctx
+func NewSortWriter(ctx context.Context, opts Options) (*SortWriter, error) {
+	s, err := NewSorter(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSortWriter(s); err != nil {
+		return nil, err
+	}
+	budget := newMemBudget(s.limit)
+	return &SortWriter{sorter: s, ctx: ctx, budget: budget, arena: newRowArena(budget)}, nil
+}
+
+// checkSortWriter отклоняет режимы, которым нужен файл входа или результата либо вход
+// целиком, а не по строкам
+func checkSortWriter(s *Sorter) error {
+	o := s.opts
+	switch {
+	case s.records != nil:
+		return errors.New("в параметрах: SortWriter сортирует строки и несовместим с --record-size")
+	case o.PartitionBy > 0 || o.Split > 0 || o.Index != "" || len(o.AlsoOutputs) > 0:
+		return errors.New("в параметрах: SortWriter пишет результат в один поток и несовместим с --partition-by, --split, --index и --also-output")
+	case o.Resume || o.CheckUnique || o.DryRun || o.Check:
+		return errors.New("в параметрах: SortWriter несовместим с --resume, --check-unique, --dry-run и -c")
+	case o.Sample > 0 || o.Skip > 0 || o.RecordSep != "" || o.Comments != "" || o.InferTypes:
+		return errors.New("в параметрах: SortWriter несовместим с --sample, --skip, --record-sep, --comments и --infer-types")
+	case o.Freq || o.GroupBy || o.Head > 0 || o.UniqueOnly:
+		return errors.New("в параметрах: SortWriter несовместим с --freq, --group-by, --head и --unique-only")
+	}
+	return nil
+}
+
+// Write добавляет строку line без перевода строки. Строка проходит фильтры и проверки
+// ключей, как строка файла; номер строки в ошибках считается от начала сортировки
+func (w *SortWriter) Write(line string) error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	if strings.ContainsAny(line, "\r\n") {
+		return fmt.Errorf("строка %d содержит перевод строки", w.lines+1)
+	}
+	w.lines++
+	s := w.sorter
+	row, keep, err := s.windowRow(w.arena, line, w.lines)
+	if err != nil || !keep {
+		return err
+	}
+	oldCap := cap(w.rows)
+	w.rows = append(w.rows, row)
+	w.budget.growSlice(oldCap, cap(w.rows), rowSize)
+	if w.budget.exceeded() {
+		run, err := s.spillRun(w.rows)
+		if err != nil {
+			return fmt.Errorf("при записи временного файла: %w", err)
+		}
+		w.runs = append(w.runs, run)
+		w.rows = nil
+		w.arena.reset()
+		w.budget.reset()
+	}
+	return nil
+}
+
+// Flush записывает в out все поданные с прошлого Flush или Reset строки в порядке
+// сортировки и начинает новую сортировку. При ошибке, в том числе при отмене ctx до
+// или во время вывода, поданные строки отбрасываются
+func (w *SortWriter) Flush(out io.Writer) (Result, error) {
+	defer w.Reset()
+	s := w.sorter
+	result := Result{Lines: w.lines, Runs: len(w.runs)}
+	if err := w.ctx.Err(); err != nil {
+		return result, err
+	}
+	var src rowSource
+	if len(w.runs) == 0 {
+		s.sortRows(w.rows)
+		src = &sliceSource{rows: w.rows}
+	} else {
+		var err error
+		if src, err = s.mergeRuns(w.ctx, w.runs, w.rows); err != nil {
+			return result, fmt.Errorf("при слиянии временных файлов: %w", err)
+		}
+	}
+	if s.opts.Unique {
+		src = &uniqueSource{src: peekSource{src: src}, sorter: s, keepLast: s.opts.UniqueKeep == uniqueKeepLast}
+	}
+	src = &ctxSource{ctx: w.ctx, src: src}
+	if err := writeRows(out, src, s.format, eolStyle{sep: "\n", final: true}); err != nil {
+		if ctxErr := w.ctx.Err(); ctxErr != nil {
+			return result, ctxErr
+		}
+		return result, fmt.Errorf("при записи результата: %w", err)
+	}
+	return result, nil
+}
+
+// Reset отбрасывает поданные строки и удаляет их временные файлы, не выводя их
+func (w *SortWriter) Reset() {
+	temps.remove(w.runs...)
+	w.rows, w.runs = nil, nil
+	w.arena.reset()
+	w.lines = 0
+	w.budget.reset()
+}
@@ -0,0 +1,76 @@
+package l2sort
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// cmdWriteCloser передает данные внешней программе сжатия и при закрытии дожидается ее завершения
+type cmdWriteCloser struct {
+	io.WriteCloser
+	cmd *exec.Cmd
+}
+
+func (w *cmdWriteCloser) Close() error {
+	if err := w.WriteCloser.Close(); err != nil {
+		return err
+	}
+	return w.cmd.Wait()
+}
+
+// cmdReadCloser читает вывод внешней программы распаковки
+type cmdReadCloser struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (r *cmdReadCloser) Close() error {
+	r.ReadCloser.Close()
+	return r.cmd.Wait()
+}
+
+// newRunWriter оборачивает файл прогона сжатием: внешней программой из --compress-program
+// или встроенным gzip при --compress-temp. Close не закрывает сам файл
+func (s *Sorter) newRunWriter(file *os.File) (io.WriteCloser, error) {
+	switch {
+	case s.opts.CompressProgram != "":
+		cmd := exec.Command(s.opts.CompressProgram)
+		cmd.Stdout = file
+		cmd.Stderr = os.Stderr
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+		return &cmdWriteCloser{WriteCloser: stdin, cmd: cmd}, nil
+	case s.opts.CompressTemp:
+		return gzip.NewWriter(file), nil
+	}
+	return nopCloser{file}, nil
+}
+
+// newRunReader открывает чтение прогона, записанного newRunWriter.
+// Внешняя программа вызывается с ключом -d, как в GNU sort
+func (s *Sorter) newRunReader(file io.Reader) (io.ReadCloser, error) {
+	switch {
+	case s.opts.CompressProgram != "":
+		cmd := exec.Command(s.opts.CompressProgram, "-d")
+		cmd.Stdin = file
+		cmd.Stderr = os.Stderr
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+		return &cmdReadCloser{ReadCloser: stdout, cmd: cmd}, nil
+	case s.opts.CompressTemp:
+		return gzip.NewReader(file)
+	}
+	return io.NopCloser(file), nil
+}
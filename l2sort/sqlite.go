@@ -0,0 +1,194 @@
+package l2sort
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// sqliteProgram - консольная программа SQLite, которой загружается результат --to-sqlite;
+// sqliteDefaultTable - таблица по умолчанию
+const (
+	sqliteProgram      = "sqlite3"
+	sqliteDefaultTable = "rows"
+)
+
+// checkSQLite проверяет совместимость --to-sqlite: результат - одна таблица вместо файла
+func checkSQLite(o Options) error {
+	if o.ToSQLite == "" {
+		return nil
+	}
+	switch {
+	case o.PartitionBy > 0 || o.Split > 0 || o.Index != "" || len(o.AlsoOutputs) > 0:
+		return fmt.Errorf("в параметре --to-sqlite: несовместим с --partition-by, --split, --index и --also-output")
+	case o.Checksum || o.ChecksumFile:
+		return fmt.Errorf("в параметре --to-sqlite: контрольная сумма базы SQLite не имеет смысла")
+	}
+	return nil
+}
+
+// writeSQLite загружает отсортированные строки в таблицу --sqlite-table новой базы
+// --to-sqlite вместо записи текстового файла. Строка разбивается на поля, как для -k, и
+// каждое поле становится колонкой; названия колонок берутся из первой строки --skip, иначе
+// c1, c2, ... Порядок сортировки сохраняется в rowid, по колонкам ключей -k строится
+// индекс. Значения записываются как текст, для числовых сравнений в запросах нужен CAST.
+// База создается программой sqlite3 во временном файле и заменяет цель только при
+// успешной загрузке
+func (s *Sorter) writeSQLite(src rowSource, in *inputData) (err error) {
+	program, err := exec.LookPath(sqliteProgram)
+	if err != nil {
+		return fmt.Errorf("в параметре --to-sqlite: %w", err)
+	}
+	file, err := createAtomic(s.opts.ToSQLite, s.opts.Backup)
+	if err != nil {
+		return fmt.Errorf("при создании файла: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			file.discard()
+		}
+	}()
+	var stderr bytes.Buffer
+	cmd := exec.Command(program, "-batch", "-bail", "-init", os.DevNull, file.Name())
+	cmd.Stderr = &stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("при запуске %s: %w", sqliteProgram, err)
+	}
+	w := bufio.NewWriter(stdin)
+	loadErr := s.writeSQL(w, src, in)
+	if loadErr == nil {
+		loadErr = w.Flush()
+	}
+	stdin.Close()
+	if err := cmd.Wait(); err != nil && !errors.Is(loadErr, context.Canceled) {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("при загрузке в SQLite: %s", msg)
+		}
+		return fmt.Errorf("при загрузке в SQLite: %w", err)
+	}
+	if loadErr != nil {
+		return loadErr
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("при сохранении результата: %w", err)
+	}
+	return nil
+}
+
+// writeSQL пишет сценарий загрузки: таблица растет новыми колонками, если в строке
+// полей больше, чем было до нее; недостающие поля - NULL
+func (s *Sorter) writeSQL(w io.Writer, src rowSource, in *inputData) error {
+	var header []string
+	if s.opts.Skip > 0 && len(in.header) > 0 {
+		header = s.splitFields(in.header[0])
+	}
+	table := sqlIdent(s.opts.SQLiteTable)
+	var names []string
+	seen := make(map[string]bool)
+	column := func(i int) string {
+		for len(names) <= i {
+			n := len(names)
+			name := ""
+			if n < len(header) {
+				name = strings.TrimSpace(header[n])
+			}
+			if name == "" || seen[name] {
+				name = "c" + strconv.Itoa(n+1)
+			}
+			for seen[name] {
+				name += "_"
+			}
+			seen[name] = true
+			names = append(names, sqlIdent(name))
+		}
+		return names[i]
+	}
+	created := false
+	create := func(n int) {
+		cols := make([]string, max(n, 1))
+		for i := range cols {
+			cols[i] = column(i)
+		}
+		fmt.Fprintf(w, "CREATE TABLE %s (%s);\n", table, strings.Join(cols, ", "))
+		created = true
+	}
+	fmt.Fprint(w, "PRAGMA journal_mode=OFF;\nPRAGMA synchronous=OFF;\nBEGIN;\n")
+	var values []string
+	for {
+		row, ok, err := src.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		line := row.Original
+		if s.format != nil {
+			if line, err = s.format(&row); err != nil {
+				return err
+			}
+		}
+		fields := s.splitFields(line)
+		if !created {
+			create(max(len(fields), len(header)))
+		}
+		for len(names) < len(fields) {
+			fmt.Fprintf(w, "ALTER TABLE %s ADD COLUMN %s;\n", table, column(len(names)))
+		}
+		values = values[:0]
+		for i := range names {
+			if i < len(fields) {
+				values = append(values, sqlLiteral(fields[i]))
+			} else {
+				values = append(values, "NULL")
+			}
+		}
+		if _, err := fmt.Fprintf(w, "INSERT INTO %s VALUES (%s);\n", table, strings.Join(values, ", ")); err != nil {
+			return err
+		}
+	}
+	if !created {
+		create(len(header))
+	}
+
+	var keyCols []string
+	indexed := make(map[int]bool)
+	for _, spec := range s.opts.Keys {
+		if spec.Column > 0 && spec.Column <= len(names) && !indexed[spec.Column] {
+			indexed[spec.Column] = true
+			keyCols = append(keyCols, names[spec.Column-1])
+		}
+	}
+	if len(keyCols) > 0 {
+		fmt.Fprintf(w, "CREATE INDEX %s ON %s (%s);\n", sqlIdent(s.opts.SQLiteTable+"_key"), table, strings.Join(keyCols, ", "))
+	}
+	_, err := fmt.Fprint(w, "COMMIT;\n")
+	return err
+}
+
+// sqlIdent заключает имя в двойные кавычки
+func sqlIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// sqlLiteral записывает текст строковым литералом SQL. Текст с нулевыми байтами или не в
+// UTF-8 записывается шестнадцатеричным литералом, который консоль sqlite3 не исказит
+func sqlLiteral(text string) string {
+	if !utf8.ValidString(text) || strings.IndexByte(text, 0) >= 0 {
+		return "CAST(X'" + hex.EncodeToString([]byte(text)) + "' AS TEXT)"
+	}
+	return "'" + strings.ReplaceAll(text, "'", "''") + "'"
+}
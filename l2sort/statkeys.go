@@ -0,0 +1,194 @@
+package l2sort
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Политика --by-missing для путей, сведения о которых получить не удалось
+const (
+	statMissingWarn  = "warn"
+	statMissingSkip  = "skip"
+	statMissingError = "error"
+)
+
+// statWorkers - сколько файлов опрашивается одновременно; statLookahead - на сколько строк
+// вперед читается вход, чтобы сведения о файлах были готовы к разбору ключей
+const (
+	statWorkers   = 16
+	statLookahead = 256
+)
+
+// checkBy проверяет --by и --by-missing
+func checkBy(o Options) error {
+	switch o.By {
+	case "", typeMtime, typeSize:
+	default:
+		return fmt.Errorf("в параметре --by: неизвестное значение %q, ожидалось mtime или size", o.By)
+	}
+	switch o.ByMissing {
+	case "", statMissingWarn, statMissingSkip, statMissingError:
+	default:
+		return fmt.Errorf("в параметре --by-missing: неизвестная политика %q, ожидалось warn, skip или error", o.ByMissing)
+	}
+	return nil
+}
+
+// statEntry - сведения об одном файле; once гарантирует, что файл опрашивается один раз,
+// а читатель ждет опроса, начатого заранее
+type statEntry struct {
+	once   sync.Once
+	path   string
+	mtime  time.Time
+	size   int64
+	err    error
+	warned bool
+}
+
+func (e *statEntry) stat() {
+	e.once.Do(func() {
+		info, err := os.Stat(e.path)
+		if err != nil {
+			e.err = err
+			return
+		}
+		e.mtime, e.size = info.ModTime(), info.Size()
+	})
+}
+
+// statCache хранит сведения о файлах для ключей :mtime и :size все время работы Sorter,
+// поэтому повторные пути и повторное чтение прогонов при слиянии не опрашивают файлы заново
+type statCache struct {
+	mu      sync.Mutex
+	entries map[string]*statEntry
+	// workers ограничивает число одновременных опросов prefetch
+	workers chan struct{}
+	warn    bool
+}
+
+func newStatCache(o Options) *statCache {
+	return &statCache{
+		entries: make(map[string]*statEntry),
+		workers: make(chan struct{}, statWorkers),
+		warn:    o.ByMissing == "" || o.ByMissing == statMissingWarn,
+	}
+}
+
+func (c *statCache) entry(path string) (*statEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[path]
+	if !ok {
+		e = &statEntry{path: path}
+		c.entries[path] = e
+	}
+	return e, ok
+}
+
+// prefetch начинает опрос файла в фоне, если он еще не начат
+func (c *statCache) prefetch(path string) {
+	e, ok := c.entry(path)
+	if ok {
+		return
+	}
+	c.workers <- struct{}{}
+	go func() {
+		defer func() { <-c.workers }()
+		e.stat()
+	}()
+}
+
+// lookup возвращает сведения о файле, дожидаясь опроса. Если файла нет или он недоступен,
+// при --by-missing warn об этом один раз предупреждается
+func (c *statCache) lookup(path string) *statEntry {
+	e, _ := c.entry(path)
+	e.stat()
+	if e.err != nil && c.warn {
+		c.mu.Lock()
+		warn := !e.warned
+		e.warned = true
+		c.mu.Unlock()
+		if warn {
+			warnf("%v", e.err)
+		}
+	}
+	return e
+}
+
+// usesStat сообщает, что хотя бы один ключ сравнивается по сведениям о файле
+func (s *Sorter) usesStat() bool {
+	return s.usesKind(typeMtime) || s.usesKind(typeSize)
+}
+
+// parseStatKey заполняет ключ :mtime или :size сведениями о файле с путем text
+func (s *Sorter) parseStatKey(kind string, key *Key, text string) {
+	e := s.stat.lookup(text)
+	if e.err != nil {
+		return
+	}
+	if kind == typeMtime {
+		key.setTime(e.mtime)
+	} else {
+		key.setInt(e.size, false)
+	}
+}
+
+// checkStat применяет --by-missing к ключам строки: skip истинно, если строку нужно
+// пропустить. При warn строка остается, а ключ без сведений идет раньше остальных
+func (s *Sorter) checkStat(row *Row, lineNum int) (skip bool, err error) {
+	if s.stat == nil || s.stat.warn {
+		return false, nil
+	}
+	for i := range row.Keys {
+		name := s.kindOf(i).name
+		if name != typeMtime && name != typeSize {
+			continue
+		}
+		if e := s.stat.lookup(row.keyText(i)); e.err != nil {
+			if s.opts.ByMissing == statMissingSkip {
+				return true, nil
+			}
+			return false, fmt.Errorf("строка %d: нет сведений о файле: %v", lineNum, e.err)
+		}
+	}
+	return false, nil
+}
+
+// statPrefetcher читает вход на statLookahead строк вперед и заранее начинает опрос
+// файлов из их ключей, чтобы медленная файловая система опрашивалась параллельно
+type statPrefetcher struct {
+	lines  lineReader
+	sorter *Sorter
+	queue  []string
+	text   string
+}
+
+func (p *statPrefetcher) Scan() bool {
+	if len(p.queue) == 0 {
+		for len(p.queue) < statLookahead && p.lines.Scan() {
+			line := p.lines.Text()
+			p.queue = append(p.queue, line)
+			p.sorter.prefetchStats(line)
+		}
+		if len(p.queue) == 0 {
+			return false
+		}
+	}
+	p.text, p.queue = p.queue[0], p.queue[1:]
+	return true
+}
+
+func (p *statPrefetcher) Text() string { return p.text }
+
+func (p *statPrefetcher) Err() error { return p.lines.Err() }
+
+// prefetchStats начинает опрос файлов из ключей :mtime и :size строки
+func (s *Sorter) prefetchStats(line string) {
+	for i, text := range s.extractKeys(s.keyPart(line)) {
+		if name := s.kindOf(i).name; name == typeMtime || name == typeSize {
+			s.stat.prefetch(s.normalizeKey(text))
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package l2sort
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// sortStats собирает при --stats сведения об одной сортировке: сколько прочитано строк,
+// сколько выполнено сравнений, сколько времени заняли чтение, сортировка и запись,
+// пик учтенной памяти, число временных файлов и удаленных повторов.
+// Sorter используется одной горутиной, поэтому счетчики не атомарные; методы nil-получателя
+// ничего не делают
+type sortStats struct {
+	lines       int
+	comparisons int64
+	// sort - время сортировок в памяти, в том числе порций перед сбросом на диск
+	read, sort, write time.Duration
+	peakMemory        int64
+	tempFiles         int
+	duplicates        int
+	// merge - план слияния, если вход сбрасывался на диск
+	merge *mergePlan
+}
+
+func (st *sortStats) addComparison() {
+	if st != nil {
+		st.comparisons++
+	}
+}
+
+func (st *sortStats) addTempFile() {
+	if st != nil {
+		st.tempFiles++
+	}
+}
+
+func (st *sortStats) addDuplicate() {
+	if st != nil {
+		st.duplicates++
+	}
+}
+
+// timeSort учитывает время сортировки, начатой в start
+func (st *sortStats) timeSort(start time.Time) {
+	if st != nil {
+		st.sort += time.Since(start)
+	}
+}
+
+// print выводит сводку одним блоком, чтобы сводки параллельных заданий не перемешивались
+func (st *sortStats) print(out io.Writer, input string) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	var b strings.Builder
+	fmt.Fprintf(&b, "Статистика сортировки %s:\n", input)
+	fmt.Fprintf(&b, "  прочитано строк:      %d\n", st.lines)
+	fmt.Fprintf(&b, "  сравнений:            %d\n", st.comparisons)
+	fmt.Fprintf(&b, "  чтение:               %s\n", st.read.Round(time.Millisecond))
+	fmt.Fprintf(&b, "  сортировка в памяти:  %s\n", st.sort.Round(time.Millisecond))
+	fmt.Fprintf(&b, "  слияние и запись:     %s\n", st.write.Round(time.Millisecond))
+	fmt.Fprintf(&b, "  пик памяти:           %.1f МБ учтено для -S, %.1f МБ получено процессом от ОС\n",
+		float64(st.peakMemory)/(1<<20), float64(mem.Sys)/(1<<20))
+	fmt.Fprintf(&b, "  временных файлов:     %d\n", st.tempFiles)
+	if st.merge != nil {
+		fmt.Fprintf(&b, "  слияние:              %d прогонов по %d за раз (ограничение: %s), проходов %d, буфер чтения %d КБ\n",
+			st.merge.runs, st.merge.fanIn, st.merge.limit, st.merge.passes, st.merge.readAhead>>10)
+	}
+	fmt.Fprintf(&b, "  удалено повторов:     %d\n", st.duplicates)
+	io.WriteString(out, b.String())
+}
@@ -0,0 +1,60 @@
+package l2sort
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Что записывается в метку источника --tag-source
+const (
+	tagSourceName  = "name"
+	tagSourceIndex = "index"
+)
+
+// SourceTag описывает метку, которой merge помечает строку файлом, из которого она
+// пришла. Mode - name (имя файла, как в командной строке), index (номер файла с 1) или
+// пусто, если метка не нужна. Метка отделяется от строки Sep и ставится в начало строки
+// или, при Append, в конец, то есть становится первым или последним полем
+type SourceTag struct {
+	Mode   string
+	Append bool
+	Sep    string
+}
+
+// check проверяет метку вместе с настройками сортировки
+func (t SourceTag) check(o Options) error {
+	switch t.Mode {
+	case "":
+		return nil
+	case tagSourceName, tagSourceIndex:
+	default:
+		return fmt.Errorf("в параметре --tag-source: неизвестное значение %q, ожидалось name или index", t.Mode)
+	}
+	if o.Unique {
+		// Повторы сравниваются по строке целиком, а метки у одинаковых строк из разных
+		// файлов разные
+		return fmt.Errorf("в параметре --tag-source: несовместим с -u")
+	}
+	return nil
+}
+
+// tagger возвращает функцию, которая помечает строку из файла номер src (с 0) среди
+// inputs, или nil, если метка не нужна
+func (t SourceTag) tagger(inputs []string) func(line string, src int) string {
+	if t.Mode == "" {
+		return nil
+	}
+	tags := make([]string, len(inputs))
+	for i, path := range inputs {
+		tags[i] = path
+		if t.Mode == tagSourceIndex {
+			tags[i] = strconv.Itoa(i + 1)
+		}
+	}
+	return func(line string, src int) string {
+		if t.Append {
+			return line + t.Sep + tags[src]
+		}
+		return tags[src] + t.Sep + line
+	}
+}
@@ -0,0 +1,269 @@
+package l2sort
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// ownerFile - файл в каталоге запуска с хостом и PID запустившего процесса: по нему
+// следующие запуски находят каталоги упавших процессов
+const ownerFile = "owner"
+
+// tempRegistry выдает временные файлы с уникальными именами внутри отдельного каталога
+// текущего запуска и отвечает за их удаление при любом завершении программы
+type tempRegistry struct {
+	mu   sync.Mutex
+	keep bool
+	// dirs - каталоги запуска, по одному на каждый родительский каталог -T
+	dirs map[string]string
+	// sizes - временные файлы запуска и занятое ими место, used - их сумма: по ней
+	// проверяется --max-temp без обхода всех файлов
+	sizes map[string]int64
+	used  int64
+	// next - номер каталога -T для следующего файла, когда каталогов несколько
+	next int
+	// pending - временные файлы вне каталога запуска, например незавершенный атомарный вывод
+	pending map[string]bool
+}
+
+// temps - реестр временных файлов текущего запуска
+var temps = &tempRegistry{}
+
+// create создает новый временный файл в каталоге запуска внутри parents (пустая строка -
+// системный каталог). Каталог запуска создается при первом обращении, поэтому параллельные
+// запуски и параллельные горутины не пересекаются по именам. parents может содержать
+// несколько каталогов через os.PathListSeparator (см. tempDirFlag): они используются по очереди
+func (r *tempRegistry) create(parents, prefix string) (*os.File, error) {
+	return r.createSized(parents, prefix, 0, 0)
+}
+
+// createSized создает временный файл, в который будет записано около size байт. Каталог
+// выбирается по очереди среди тех, где для них хватает свободного места; если места нет
+// нигде или вместе с уже созданными временными файлами будет занято больше limit байт
+// (0 - без ограничения), возвращается ошибка до записи
+func (r *tempRegistry) createSized(parents, prefix string, size, limit int64) (*os.File, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if limit > 0 && r.used+size > limit {
+		return nil, fmt.Errorf("временные файлы заняли бы %d байт, больше ограничения --max-temp %d байт; включите --compress-temp или увеличьте ограничение", r.used+size, limit)
+	}
+	candidates := filepath.SplitList(parents)
+	if len(candidates) == 0 {
+		candidates = []string{""}
+	}
+	parent := ""
+	found := false
+	for i := range candidates {
+		parent = candidates[(r.next+i)%len(candidates)]
+		if size == 0 || freeSpace(tempParent(parent)) >= size {
+			r.next += i + 1
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("во временных каталогах %s нет места для %d байт; укажите другие каталоги в -T", strings.Join(candidates, ", "), size)
+	}
+
+	dir, ok := r.dirs[parent]
+	if !ok {
+		var err error
+		if dir, err = r.createRunDir(parent); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.CreateTemp(dir, prefix+"-*")
+	if err != nil {
+		return nil, err
+	}
+	if r.sizes == nil {
+		r.sizes = make(map[string]int64)
+	}
+	// До finish файл учитывается по ожидаемому размеру
+	r.sizes[file.Name()] = size
+	r.used += size
+	return file, nil
+}
+
+// finish учитывает настоящий размер записанного временного файла вместо ожидаемого
+func (r *tempRegistry) finish(file *os.File) {
+	info, err := file.Stat()
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if size, ok := r.sizes[file.Name()]; ok {
+		r.used += info.Size() - size
+		r.sizes[file.Name()] = info.Size()
+	}
+}
+
+// createRunDir создает каталог запуска внутри parent, записывая в него владельца, и
+// перед этим удаляет там каталоги запусков, чьи процессы на этом хосте уже завершились
+func (r *tempRegistry) createRunDir(parent string) (string, error) {
+	removeOrphanDirs(tempParent(parent))
+	dir, err := os.MkdirTemp(parent, "l2sort-")
+	if err != nil {
+		return "", err
+	}
+	host, _ := os.Hostname()
+	if err := os.WriteFile(filepath.Join(dir, ownerFile), []byte(host+" "+strconv.Itoa(os.Getpid())+"\n"), 0o600); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	if r.dirs == nil {
+		r.dirs = make(map[string]string)
+	}
+	r.dirs[parent] = dir
+	return dir, nil
+}
+
+// removeOrphanDirs удаляет в parent каталоги запусков, оставшиеся от аварийно завершенных
+// процессов этого хоста. Каталоги без файла владельца (сохраненные --keep-temp или
+// созданные старыми версиями) и каталоги других хостов на общем диске не трогаются
+func removeOrphanDirs(parent string) {
+	dirs, _ := filepath.Glob(filepath.Join(parent, "l2sort-*"))
+	host, _ := os.Hostname()
+	for _, dir := range dirs {
+		data, err := os.ReadFile(filepath.Join(dir, ownerFile))
+		if err != nil {
+			continue
+		}
+		owner, pid, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+		n, err := strconv.Atoi(pid)
+		if err != nil || owner != host || n == os.Getpid() {
+			continue
+		}
+		if err := syscall.Kill(n, 0); errors.Is(err, syscall.ESRCH) {
+			warnf("удален временный каталог завершившегося аварийно запуска: %s", dir)
+			os.RemoveAll(dir)
+		}
+	}
+}
+
+// freeSpace возвращает свободное для пользователя место в каталоге dir; если его не
+// удалось узнать - максимальное значение, чтобы не мешать записи
+func freeSpace(dir string) int64 {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return math.MaxInt64
+	}
+	return int64(st.Bavail) * int64(st.Bsize)
+}
+
+// tempParent возвращает каталог -T или системный каталог для пустой строки
+func tempParent(parent string) string {
+	if parent == "" {
+		return os.TempDir()
+	}
+	return parent
+}
+
+// firstTempDir возвращает первый каталог -T, пустая строка - системный каталог
+func firstTempDir(parents string) string {
+	if dirs := filepath.SplitList(parents); len(dirs) > 0 {
+		return dirs[0]
+	}
+	return ""
+}
+
+// tempDirFlag - значение -T. Флаг можно указать несколько раз, каталоги накапливаются
+// через os.PathListSeparator; первое указание во флагах заменяет унаследованный каталог
+type tempDirFlag struct {
+	dirs *string
+	set  bool
+}
+
+func (f *tempDirFlag) String() string {
+	if f.dirs == nil {
+		return ""
+	}
+	return *f.dirs
+}
+
+func (f *tempDirFlag) Set(value string) error {
+	if !f.set || *f.dirs == "" {
+		*f.dirs, f.set = value, true
+		return nil
+	}
+	*f.dirs += string(os.PathListSeparator) + value
+	return nil
+}
+
+// register добавляет файл вне каталога запуска, который нужно удалить при завершении
+func (r *tempRegistry) register(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pending == nil {
+		r.pending = make(map[string]bool)
+	}
+	r.pending[path] = true
+}
+
+// forget исключает файл из очистки, например после его переименования в итоговый
+func (r *tempRegistry) forget(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.pending, path)
+}
+
+// remove удаляет ставшие ненужными временные файлы до завершения программы, чтобы
+// долго работающий процесс (--watch, --serve) не накапливал их. При --keep-temp файлы остаются
+func (r *tempRegistry) remove(paths ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.keep {
+		return
+	}
+	for _, path := range paths {
+		os.Remove(path)
+		r.used -= r.sizes[path]
+		delete(r.sizes, path)
+	}
+}
+
+// cleanup удаляет каталоги запуска вместе со всеми временными файлами,
+// либо, при --keep-temp, сообщает, где их искать
+func (r *tempRegistry) cleanup() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for path := range r.pending {
+		os.Remove(path)
+	}
+	r.pending = nil
+
+	for _, dir := range r.dirs {
+		if r.keep {
+			// Без файла владельца сохраненный каталог не удалят следующие запуски
+			os.Remove(filepath.Join(dir, ownerFile))
+			fmt.Fprintf(os.Stderr, "Временные файлы сохранены в %s\n", dir)
+			continue
+		}
+		os.RemoveAll(dir)
+	}
+	if !r.keep {
+		r.dirs = nil
+		r.sizes, r.used = nil, 0
+	}
+}
+
+// exit завершает программу с заданным кодом, предварительно удалив временные файлы.
+// os.Exit не выполняет отложенные вызовы, поэтому все выходы из программы идут через exit
+func exit(code int) {
+	temps.cleanup()
+	os.Exit(code)
+}
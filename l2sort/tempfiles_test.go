@@ -0,0 +1,31 @@
+package l2sort
+
+import "testing"
+
+func TestTempRegistryUsage(t *testing.T) {
+	r := &tempRegistry{}
+	defer r.cleanup()
+	dir := t.TempDir()
+	a, err := r.createSized(dir, "run", 100, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.WriteString("0123456789")
+	r.finish(a)
+	a.Close()
+	if r.used != 10 {
+		t.Errorf("после finish занято %d байт, ожидалось 10", r.used)
+	}
+	b, err := r.createSized(dir, "run", 990, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+	if _, err := r.createSized(dir, "run", 1, 1000); err == nil {
+		t.Error("превышение --max-temp не обнаружено")
+	}
+	r.remove(a.Name(), b.Name())
+	if r.used != 0 || len(r.sizes) != 0 {
+		t.Errorf("после remove занято %d байт в %d файлах", r.used, len(r.sizes))
+	}
+}
@@ -0,0 +1,270 @@
+package l2sort
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Типы полей компактного протокола Thrift, которым записаны метаданные Parquet
+const (
+	thriftStop      = 0
+	thriftTrue      = 1
+	thriftFalse     = 2
+	thriftByte      = 3
+	thriftI16       = 4
+	thriftI32       = 5
+	thriftI64       = 6
+	thriftDouble    = 7
+	thriftBinary    = 8
+	thriftList      = 9
+	thriftSet       = 10
+	thriftMap       = 11
+	thriftStruct    = 12
+	thriftMaxDepth  = 64
+	thriftMaxLength = 1 << 30
+)
+
+// thriftValue - разобранное значение: целые и логические хранятся в i, строки в b,
+// списки в list, структуры в fields. raw - исходные байты структуры, чтобы ее можно
+// было записать обратно без изменений
+type thriftValue struct {
+	i      int64
+	f      float64
+	b      []byte
+	list   []thriftValue
+	fields map[int16]thriftValue
+	raw    []byte
+}
+
+func (v thriftValue) field(id int16) (thriftValue, bool) {
+	f, ok := v.fields[id]
+	return f, ok
+}
+
+func (v thriftValue) has(id int16) bool {
+	_, ok := v.fields[id]
+	return ok
+}
+
+// int возвращает целое поле структуры или def, если поля нет
+func (v thriftValue) int(id int16, def int64) int64 {
+	if f, ok := v.fields[id]; ok {
+		return f.i
+	}
+	return def
+}
+
+// thriftReader читает компактный протокол Thrift из буфера
+type thriftReader struct {
+	buf   []byte
+	pos   int
+	depth int
+}
+
+func (r *thriftReader) byte() (byte, error) {
+	if r.pos >= len(r.buf) {
+		return 0, fmt.Errorf("метаданные обрываются на смещении %d", r.pos)
+	}
+	r.pos++
+	return r.buf[r.pos-1], nil
+}
+
+func (r *thriftReader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		return 0, fmt.Errorf("некорректное число в метаданных на смещении %d", r.pos)
+	}
+	r.pos += n
+	return v, nil
+}
+
+func (r *thriftReader) varint() (int64, error) {
+	u, err := r.uvarint()
+	return int64(u>>1) ^ -int64(u&1), err
+}
+
+// readStruct читает структуру до поля STOP
+func (r *thriftReader) readStruct() (thriftValue, error) {
+	if r.depth++; r.depth > thriftMaxDepth {
+		return thriftValue{}, fmt.Errorf("слишком глубокая вложенность метаданных")
+	}
+	defer func() { r.depth-- }()
+	start := r.pos
+	v := thriftValue{fields: make(map[int16]thriftValue)}
+	var last int16
+	for {
+		header, err := r.byte()
+		if err != nil {
+			return v, err
+		}
+		typ := header & 0x0f
+		if typ == thriftStop {
+			v.raw = r.buf[start:r.pos]
+			return v, nil
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			n, err := r.varint()
+			if err != nil {
+				return v, err
+			}
+			id = int16(n)
+		}
+		last = id
+		if v.fields[id], err = r.readValue(typ); err != nil {
+			return v, err
+		}
+	}
+}
+
+func (r *thriftReader) readValue(typ byte) (thriftValue, error) {
+	var v thriftValue
+	var err error
+	switch typ {
+	case thriftTrue:
+		v.i = 1
+	case thriftFalse:
+		v.i = 0
+	case thriftByte:
+		var b byte
+		b, err = r.byte()
+		v.i = int64(int8(b))
+	case thriftI16, thriftI32, thriftI64:
+		v.i, err = r.varint()
+	case thriftDouble:
+		if r.pos+8 > len(r.buf) {
+			return v, fmt.Errorf("метаданные обрываются на смещении %d", r.pos)
+		}
+		v.f = math.Float64frombits(binary.LittleEndian.Uint64(r.buf[r.pos:]))
+		r.pos += 8
+	case thriftBinary:
+		var n uint64
+		if n, err = r.uvarint(); err != nil {
+			return v, err
+		}
+		if n > thriftMaxLength || r.pos+int(n) > len(r.buf) {
+			return v, fmt.Errorf("строка в метаданных выходит за их пределы")
+		}
+		v.b = r.buf[r.pos : r.pos+int(n)]
+		r.pos += int(n)
+	case thriftList, thriftSet:
+		var header byte
+		if header, err = r.byte(); err != nil {
+			return v, err
+		}
+		size, elem := uint64(header>>4), header&0x0f
+		if size == 15 {
+			if size, err = r.uvarint(); err != nil {
+				return v, err
+			}
+		}
+		if size > uint64(len(r.buf)) {
+			return v, fmt.Errorf("список в метаданных длиннее их самих")
+		}
+		v.list = make([]thriftValue, size)
+		for i := range v.list {
+			if elem == thriftTrue || elem == thriftFalse {
+				// логические значения в списках занимают по байту
+				var b byte
+				if b, err = r.byte(); err != nil {
+					return v, err
+				}
+				v.list[i].i = int64(b & 1)
+				continue
+			}
+			if v.list[i], err = r.readValue(elem); err != nil {
+				return v, err
+			}
+		}
+	case thriftMap:
+		var size uint64
+		if size, err = r.uvarint(); err != nil || size == 0 {
+			return v, err
+		}
+		var kinds byte
+		if kinds, err = r.byte(); err != nil {
+			return v, err
+		}
+		for i := uint64(0); i < size; i++ {
+			if _, err = r.readValue(kinds >> 4); err != nil {
+				return v, err
+			}
+			if _, err = r.readValue(kinds & 0x0f); err != nil {
+				return v, err
+			}
+		}
+	case thriftStruct:
+		return r.readStruct()
+	default:
+		return v, fmt.Errorf("неизвестный тип %d в метаданных", typ)
+	}
+	return v, err
+}
+
+// thriftWriter записывает компактный протокол Thrift
+type thriftWriter struct {
+	buf  []byte
+	last []int16
+}
+
+func (w *thriftWriter) uvarint(v uint64) {
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+func (w *thriftWriter) varint(v int64) {
+	w.uvarint(uint64(v<<1) ^ uint64(v>>63))
+}
+
+func (w *thriftWriter) fieldHeader(id int16, typ byte) {
+	last := &w.last[len(w.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf = append(w.buf, byte(delta)<<4|typ)
+	} else {
+		w.buf = append(w.buf, typ)
+		w.varint(int64(id))
+	}
+	*last = id
+}
+
+func (w *thriftWriter) beginStruct() {
+	w.last = append(w.last, 0)
+}
+
+func (w *thriftWriter) endStruct() {
+	w.buf = append(w.buf, thriftStop)
+	w.last = w.last[:len(w.last)-1]
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.fieldHeader(id, thriftI32)
+	w.varint(int64(v))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.fieldHeader(id, thriftI64)
+	w.varint(v)
+}
+
+func (w *thriftWriter) binary(id int16, b []byte) {
+	w.fieldHeader(id, thriftBinary)
+	w.uvarint(uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+// list начинает поле-список из n элементов типа elem
+func (w *thriftWriter) list(id int16, elem byte, n int) {
+	w.fieldHeader(id, thriftList)
+	if n < 15 {
+		w.buf = append(w.buf, byte(n)<<4|elem)
+		return
+	}
+	w.buf = append(w.buf, 0xf0|elem)
+	w.uvarint(uint64(n))
+}
+
+// field начинает поле-структуру; ее поля пишутся до endStruct
+func (w *thriftWriter) field(id int16) {
+	w.fieldHeader(id, thriftStruct)
+	w.beginStruct()
+}
@@ -0,0 +1,74 @@
+package l2sort
+
+import (
+	"strings"
+	"time"
+)
+
+// timeLayout описывает формат метки времени и число полей, которые она занимает в строке
+type timeLayout struct {
+	layout string
+	fields int
+}
+
+// commonTimeLayouts - форматы, которые распознаются автоматически, если --time-format не задан.
+// Более длинные форматы проверяются первыми, чтобы не принять часть метки за целую
+var commonTimeLayouts = newTimeLayouts(
+	time.RFC1123Z,
+	time.RFC1123,
+	time.Stamp,                     // syslog: "Jan _2 15:04:05"
+	"[02/Jan/2006:15:04:05 -0700]", // Apache CLF
+	"02/Jan/2006:15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	time.RFC3339Nano,
+	"2006-01-02",
+)
+
+func newTimeLayouts(layouts ...string) []timeLayout {
+	result := make([]timeLayout, 0, len(layouts))
+	for _, layout := range layouts {
+		result = append(result, timeLayout{layout: layout, fields: len(strings.Fields(layout))})
+	}
+	return result
+}
+
+// timeLayouts возвращает форматы, используемые для разбора ключей: заданный --time-format
+// или распространенные форматы
+func timeLayouts(format string) []timeLayout {
+	if format != "" {
+		return newTimeLayouts(format)
+	}
+	return commonTimeLayouts
+}
+
+// mergeTimeFields склеивает поля, начиная с from, в одно, если они вместе образуют метку времени.
+// strings.Fields разбивает метки вида "Jan  2 15:04:05" на несколько полей, а сравнивать их нужно целиком
+func mergeTimeFields(layouts []timeLayout, fields []string, from int) []string {
+	if from >= len(fields) {
+		return fields
+	}
+	for _, tl := range layouts {
+		if tl.fields < 2 || from+tl.fields > len(fields) {
+			continue
+		}
+		candidate := strings.Join(fields[from:from+tl.fields], " ")
+		if _, err := time.Parse(tl.layout, candidate); err != nil {
+			continue
+		}
+		merged := make([]string, 0, len(fields)-tl.fields+1)
+		merged = append(merged, fields[:from]...)
+		merged = append(merged, candidate)
+		return append(merged, fields[from+tl.fields:]...)
+	}
+	return fields
+}
+
+// parseTimeKey разбирает ключ как метку времени по заданному или одному из распространенных форматов
+func parseTimeKey(layouts []timeLayout, s string) (time.Time, bool) {
+	for _, tl := range layouts {
+		if t, err := time.Parse(tl.layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
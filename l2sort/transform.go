@@ -0,0 +1,193 @@
+package l2sort
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// lineTransform переписывает строку перед выводом
+type lineTransform func(line string) string
+
+// rowFormatter формирует выводимый текст для строки
+type rowFormatter func(row *Row) (string, error)
+
+// templateRow - данные, доступные в шаблоне --format-line
+type templateRow struct {
+	Line   string   // исходная строка
+	Fields []string // поля строки, разделенные пробелами или --field-regex
+	Key    string   // ключи сортировки через пробел
+	Keys   []string // ключи сортировки по отдельности
+}
+
+// buildOutputTransform собирает форматирование вывода: сначала шаблон --format-line,
+// только ключи --output-key-only или поля --output-fields, затем цепочка замен --sed.
+// split делит строку на поля для шаблона, raw - для --output-fields (см. rawFields).
+// Возвращает nil, если ничего не задано
+func buildOutputTransform(o Options, split func(string) []string, raw func(string) ([]string, string)) (rowFormatter, error) {
+	if o.KeyOnly && o.FormatLine != "" {
+		return nil, fmt.Errorf("--output-key-only и --format-line нельзя указывать вместе")
+	}
+	outputFields, err := parseOutputFields(o.OutputFields)
+	if err != nil {
+		return nil, err
+	}
+	if outputFields != nil && (o.KeyOnly || o.FormatLine != "") {
+		return nil, fmt.Errorf("--output-fields нельзя указывать вместе с --output-key-only и --format-line")
+	}
+	var tmpl *template.Template
+	if o.FormatLine != "" {
+		var err error
+		tmpl, err = template.New("format-line").Parse(o.FormatLine)
+		if err != nil {
+			return nil, fmt.Errorf("--format-line: %w", err)
+		}
+	}
+	delim := strings.ReplaceAll(o.OutputDelimiter, `\t`, "\t")
+	if delim == "" {
+		delim = " "
+	}
+	var chain []lineTransform
+	for _, expr := range o.Sed {
+		t, err := compileSed(expr)
+		if err != nil {
+			return nil, fmt.Errorf("--sed %q: %w", expr, err)
+		}
+		chain = append(chain, t)
+	}
+	if tmpl == nil && !o.KeyOnly && outputFields == nil && len(chain) == 0 {
+		return nil, nil
+	}
+
+	var buf strings.Builder
+	return func(row *Row) (string, error) {
+		line := row.Original
+		if o.KeyOnly {
+			buf.Reset()
+			for i := range row.Keys {
+				if i > 0 {
+					buf.WriteString(delim)
+				}
+				buf.WriteString(row.keyText(i))
+			}
+			line = buf.String()
+		}
+		if outputFields != nil {
+			fields, sep := raw(row.Original)
+			if o.OutputDelimiter != "" {
+				sep = delim
+			}
+			line = pickOutputFields(&buf, fields, outputFields, sep)
+		}
+		if tmpl != nil {
+			keys := make([]string, len(row.Keys))
+			for i := range row.Keys {
+				keys[i] = row.keyText(i)
+			}
+			buf.Reset()
+			err := tmpl.Execute(&buf, templateRow{
+				Line:   row.Original,
+				Fields: split(row.Original),
+				Key:    strings.Join(keys, " "),
+				Keys:   keys,
+			})
+			if err != nil {
+				return "", err
+			}
+			line = buf.String()
+		}
+		for _, t := range chain {
+			line = t(line)
+		}
+		return line, nil
+	}, nil
+}
+
+// compileSed разбирает замену в стиле sed: s/шаблон/замена/флаги. Разделителем служит
+// любой символ после s. В замене & обозначает все совпадение, \1..\9 - группы.
+// Флаг g заменяет все совпадения, i включает нечувствительность к регистру
+func compileSed(expr string) (lineTransform, error) {
+	if len(expr) < 2 || expr[0] != 's' {
+		return nil, fmt.Errorf("ожидалось выражение вида s/шаблон/замена/")
+	}
+	delim := expr[1]
+	parts := splitUnescaped(expr[2:], delim)
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("ожидалось выражение вида s%cшаблон%cзамена%c", delim, delim, delim)
+	}
+	pattern, repl, flags := parts[0], sedReplacement(parts[1]), parts[2]
+
+	global := false
+	for _, f := range flags {
+		switch f {
+		case 'g':
+			global = true
+		case 'i':
+			pattern = "(?i)" + pattern
+		default:
+			return nil, fmt.Errorf("неизвестный флаг %q", f)
+		}
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if global {
+		return func(line string) string { return re.ReplaceAllString(line, repl) }, nil
+	}
+	return func(line string) string {
+		loc := re.FindStringSubmatchIndex(line)
+		if loc == nil {
+			return line
+		}
+		dst := re.ExpandString(nil, repl, line, loc)
+		return line[:loc[0]] + string(dst) + line[loc[1]:]
+	}, nil
+}
+
+// splitUnescaped делит строку по разделителю, не учитывая экранированные \delim.
+// Экранирование разделителя снимается, остальные \ сохраняются для регулярного выражения
+func splitUnescaped(s string, delim byte) []string {
+	var parts []string
+	var cur strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == delim:
+			cur.WriteByte(delim)
+			i++
+		case s[i] == '\\' && i+1 < len(s):
+			cur.WriteByte(s[i])
+			cur.WriteByte(s[i+1])
+			i++
+		case s[i] == delim:
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(s[i])
+		}
+	}
+	return append(parts, cur.String())
+}
+
+// sedReplacement переводит замену из синтаксиса sed в синтаксис regexp.Expand
+func sedReplacement(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '&':
+			b.WriteString("${0}")
+		case s[i] == '$':
+			b.WriteString("$$")
+		case s[i] == '\\' && i+1 < len(s) && isDigit(s[i+1]):
+			b.WriteString("${" + string(s[i+1]) + "}")
+			i++
+		case s[i] == '\\' && i+1 < len(s):
+			b.WriteByte(s[i+1])
+			i++
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,44 @@
+package l2sort
+
+import "strings"
+
+// splitTSV делит строку TSV на поля по каждой табуляции (пустые поля сохраняют свои
+// места) и раскрывает в полях экранирование \t, \n, \r и \\, принятое в TSV
+func splitTSV(line string) []string {
+	fields := strings.Split(line, "\t")
+	for i, field := range fields {
+		if strings.IndexByte(field, '\\') >= 0 {
+			fields[i] = unescapeTSV(field)
+		}
+	}
+	return fields
+}
+
+// unescapeTSV раскрывает экранирование поля TSV; неизвестная последовательность и
+// обратная косая черта в конце поля остаются как есть
+func unescapeTSV(field string) string {
+	var b strings.Builder
+	b.Grow(len(field))
+	for i := 0; i < len(field); i++ {
+		c := field[i]
+		if c != '\\' || i == len(field)-1 {
+			b.WriteByte(c)
+			continue
+		}
+		switch field[i+1] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case '\\':
+			b.WriteByte('\\')
+		default:
+			b.WriteByte(c)
+			continue
+		}
+		i++
+	}
+	return b.String()
+}
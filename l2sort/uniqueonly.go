@@ -0,0 +1,89 @@
+package l2sort
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// checkUniqueOnly отклоняет --unique-only в режимах, которым нужен отсортированный поток
+// или сравнение ключей, которое нельзя свести к равенству текста
+func (s *Sorter) checkUniqueOnly() error {
+	o := s.opts
+	switch {
+	case !o.UniqueOnly:
+		return nil
+	case o.Check || o.DryRun || o.CheckUnique || o.VerifyOutput:
+		return fmt.Errorf("в параметре --unique-only: без сортировки нечего проверять с -c, --dry-run, --check-unique и --verify-output")
+	case o.Freq || o.GroupBy || o.Shuffle || o.Sample > 0 || o.PartitionBy > 0 || o.Index != "" || o.Resume || o.RecordSize > 0:
+		return fmt.Errorf("в параметре --unique-only: --freq, --group-by, --shuffle, --sample, --partition-by, --index, --resume и --record-size работают с отсортированным потоком")
+	case o.Compat != "" || o.usesEpsilon():
+		return fmt.Errorf("в параметре --unique-only: повторы ищутся по точному совпадению, а --compat и --epsilon считают равными разные записи ключей")
+	}
+	return nil
+}
+
+// firstSeen при --unique-only собирает строки входа в исходном порядке без повторов. Повтор
+// определяется, как при -u: строки с равными ключами и одинаковым текстом (после
+// --normalize), но вместо сортировки и сравнения соседних строк ищется в хэш-таблице,
+// поэтому в памяти остаются только различные строки. При --unique-keep last остается
+// последнее вхождение на своем месте во входе
+type firstSeen struct {
+	sorter   *Sorter
+	keepLast bool
+	rows     []Row
+	// seen - номер оставленной строки в rows по ее тождеству; удаленные при keepLast
+	// строки отмечены в dropped
+	seen    map[string]int
+	dropped []bool
+	dups    []string
+}
+
+func (f *firstSeen) offer(row Row) {
+	if f.seen == nil {
+		f.seen = make(map[string]int)
+	}
+	id := f.sorter.uniqueIdentity(&row)
+	i, ok := f.seen[id]
+	switch {
+	case !ok:
+	case !f.keepLast:
+		f.dups = append(f.dups, row.Original)
+		return
+	default:
+		f.dups = append(f.dups, f.rows[i].Original)
+		f.dropped[i] = true
+	}
+	f.seen[id] = len(f.rows)
+	f.rows = append(f.rows, row)
+	f.dropped = append(f.dropped, false)
+}
+
+// result возвращает оставленные строки в порядке входа
+func (f *firstSeen) result() []Row {
+	if !f.keepLast {
+		return f.rows
+	}
+	kept := f.rows[:0]
+	for i, row := range f.rows {
+		if !f.dropped[i] {
+			kept = append(kept, row)
+		}
+	}
+	return kept
+}
+
+// uniqueIdentity возвращает строку, равную у повторов в смысле -u: число ключей, их
+// текст и текст строки. Равные при сравнении ключи совпадают и текстом, потому что
+// равные значения типа в compareKeys дополнительно сравниваются как текст
+func (s *Sorter) uniqueIdentity(row *Row) string {
+	var b strings.Builder
+	b.WriteString(strconv.Itoa(len(row.Keys)))
+	for i := range row.Keys {
+		b.WriteByte(0)
+		b.WriteString(row.keyText(i))
+	}
+	b.WriteByte(0)
+	b.WriteString(s.dedupLine(row))
+	return b.String()
+}
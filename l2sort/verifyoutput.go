@@ -0,0 +1,78 @@
+package l2sort
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// checkVerifyOutput отклоняет --verify-output в режимах, результат которых нельзя
+// перечитать как отсортированные строки
+func (s *Sorter) checkVerifyOutput() error {
+	o := s.opts
+	switch {
+	case !o.VerifyOutput:
+		return nil
+	case o.Check || o.DryRun || o.CheckUnique:
+		return fmt.Errorf("в параметре --verify-output: с -c, --dry-run и --check-unique результат не записывается")
+	case o.PartitionBy > 0 || o.Split > 0 || o.ToSQLite != "" || o.RecordSize > 0:
+		return fmt.Errorf("в параметре --verify-output: проверяется один текстовый файл результата, а не --partition-by, --split, --to-sqlite или --record-size")
+	case o.Freq || o.GroupBy || o.Shuffle || s.format != nil:
+		return fmt.Errorf("в параметре --verify-output: результат --freq, --group-by, --shuffle и преобразований вывода (--format-line, --sed, --output-key-only, --output-fields) не упорядочен по исходным строкам")
+	}
+	return nil
+}
+
+// countSource считает выданные строки
+type countSource struct {
+	src rowSource
+	n   int
+}
+
+func (c *countSource) next() (Row, bool, error) {
+	row, ok, err := c.src.next()
+	if ok {
+		c.n++
+	}
+	return row, ok, err
+}
+
+// verifyOutput перечитывает записанный результат потоком и проверяет тем же сравнением,
+// что строки идут по порядку (при -u - без одинаковых строк подряд) и их столько же,
+// сколько было записано. При расхождении результат удаляется
+func (s *Sorter) verifyOutput(ctx context.Context, output string, written int) error {
+	var prev Row
+	rows, bad := 0, 0
+	errStop := errors.New("нарушен порядок")
+	// Повторное чтение не должно попадать в --progress как новый вход
+	in, err := s.scanRows(withProgress(ctx, nil), []string{output}, nil, func(in *inputData, row Row) error {
+		rows++
+		if rows > 1 {
+			c := s.sortOrder(&row, &prev)
+			if c < 0 || c == 0 && s.opts.Unique && s.dedupLine(&row) == s.dedupLine(&prev) {
+				bad = in.lines
+				return errStop
+			}
+		}
+		prev = row
+		return nil
+	})
+	if in != nil {
+		in.close()
+	}
+	switch {
+	case errors.Is(err, errStop):
+		err = fmt.Errorf("проверка результата: строка %d нарушает порядок сортировки", bad)
+	case err != nil:
+		err = fmt.Errorf("проверка результата: %w", err)
+	case rows != written:
+		err = fmt.Errorf("проверка результата: в файле %d строк, а записано %d", rows, written)
+	}
+	if err != nil {
+		if removeErr := os.Remove(output); removeErr != nil {
+			warnf("не удалось удалить непрошедший проверку результат: %v", removeErr)
+		}
+	}
+	return err
+}
@@ -0,0 +1,82 @@
+package l2sort
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// watchPollInterval - как часто --watch проверяет время изменения и размер файла
+const watchPollInterval = 200 * time.Millisecond
+
+// fileState - признаки, по которым --watch замечает изменение файла
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func statFile(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
+
+// runWatch сортирует файл и затем пересортировывает его после каждого изменения, пока
+// не будет отменен ctx. Изменения опрашиваются по времени изменения и размеру; сортировка
+// запускается, когда файл не менялся debounce, чтобы не читать его посреди сохранения.
+// Уже отсортированный файл не перезаписывается, поэтому собственная запись
+// не вызывает повторной сортировки, а сама запись атомарна: редактор не увидит
+// частичного результата. Ошибки сортировки печатаются, наблюдение продолжается
+func runWatch(ctx context.Context, path string, base Options, debounce time.Duration) int {
+	sorter, err := NewSorter(base)
+	if err != nil {
+		return reportUsage(err)
+	}
+	sorter.skipSorted = true
+
+	resort := func() (fileState, bool) {
+		result, err := sorter.SortFileContext(ctx, path, path)
+		switch {
+		case errors.Is(err, context.Canceled):
+			return fileState{}, false
+		case err != nil:
+			fmt.Printf("[%s] Ошибка %v\n", time.Now().Format(time.TimeOnly), err)
+		case !result.AlreadySorted:
+			fmt.Printf("[%s] Файл %s отсортирован заново, строк: %d\n", time.Now().Format(time.TimeOnly), path, result.Lines)
+		}
+		return statFile(path), true
+	}
+
+	fmt.Printf("Наблюдение за %s, остановка - Ctrl+C\n", path)
+	last, ok := resort()
+	if !ok {
+		return reportError(context.Canceled)
+	}
+	var changedAt time.Time
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println("Наблюдение остановлено.")
+			return 0
+		case <-ticker.C:
+		}
+		if state := statFile(path); state != last {
+			last, changedAt = state, time.Now()
+			continue
+		}
+		if changedAt.IsZero() || !last.exists || time.Since(changedAt) < debounce {
+			continue
+		}
+		changedAt = time.Time{}
+		if last, ok = resort(); !ok {
+			return reportError(context.Canceled)
+		}
+	}
+}
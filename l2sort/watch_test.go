@@ -0,0 +1,47 @@
+package l2sort
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestWatchResorts проверяет, что --watch сортирует файл при запуске и снова после
+// его изменения
+func TestWatchResorts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watch.txt")
+	if err := os.WriteFile(path, []byte("b\na\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan int)
+	go func() { done <- runWatch(ctx, path, Options{}, 50*time.Millisecond) }()
+	defer func() {
+		cancel()
+		if code := <-done; code != 0 {
+			t.Errorf("код завершения %d, ожидался 0", code)
+		}
+	}()
+
+	waitContent := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			data, err := os.ReadFile(path)
+			if err == nil && string(data) == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("содержимое %q, ожидалось %q", data, want)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	waitContent("a\nb\n")
+	if err := os.WriteFile(path, []byte("a\nb\n0\nc\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitContent("0\na\nb\nc\n")
+}
@@ -0,0 +1,175 @@
+package l2sort
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// runWindow сортирует поток окнами: читает path ("-" - стандартный ввод) по мере
+// поступления строк и выводит в стандартный вывод каждое окно отсортированным, когда
+// в нем набирается lines строк или с его первой строки проходит period. Годится для
+// "отсортированного tail" по бесконечному потоку: tail -f log | l2sort --window-time 5s.
+// Прерывание выводит накопленное окно и завершает работу. Возвращает код завершения
+func runWindow(ctx context.Context, path string, base Options, lines int, period time.Duration) int {
+	if lines < 0 || period < 0 {
+		return reportUsage(fmt.Errorf("в параметре --window: размер окна не может быть отрицательным"))
+	}
+	sorter, err := NewSorter(base)
+	if err != nil {
+		return reportUsage(err)
+	}
+	if err := checkWindow(base); err != nil {
+		return reportUsage(err)
+	}
+	var input io.ReadCloser = os.Stdin
+	if path != "-" {
+		if input, err = openInput(path); err != nil {
+			return reportError(fmt.Errorf("при чтении файла: %w", err))
+		}
+	}
+	defer input.Close()
+	if err := sorter.SortWindows(ctx, input, os.Stdout, lines, period); err != nil {
+		return reportError(err)
+	}
+	return 0
+}
+
+// checkWindow отклоняет режимы, которым нужен весь вход сразу или файл результата
+func checkWindow(o Options) error {
+	switch {
+	case o.PartitionBy > 0 || o.Split > 0 || o.Index != "" || len(o.AlsoOutputs) > 0:
+		return fmt.Errorf("в параметре --window: окна выводятся в стандартный вывод и несовместимы с --partition-by, --split, --index и --also-output")
+	case o.Resume || o.CheckUnique || o.DryRun || o.Check:
+		return fmt.Errorf("в параметре --window: несовместим с --resume, --check-unique, --dry-run и -c")
+	case o.Sample > 0 || o.Skip > 0 || o.RecordSep != "" || o.Comments != "" || o.InferTypes:
+		return fmt.Errorf("в параметре --window: несовместим с --sample, --skip, --record-sep, --comments и --infer-types")
+	case o.Freq || o.GroupBy || o.Head > 0 || o.UniqueOnly:
+		return fmt.Errorf("в параметре --window: несовместим с --freq, --group-by, --head и --unique-only")
+	}
+	return nil
+}
+
+// SortWindows читает строки из r и записывает в w отсортированные окна по lines строк
+// или period времени (что наступит раньше; нулевое значение не ограничивает). Окна
+// сортируются независимо, -u отбрасывает повторы внутри окна. При отмене ctx и в конце
+// входа выводится неполное окно
+func (s *Sorter) SortWindows(ctx context.Context, r io.Reader, w io.Writer, lines int, period time.Duration) error {
+	type scanned struct {
+		line string
+		err  error
+	}
+	// Чтение идет в отдельной горутине, чтобы окно по времени закрывалось, даже когда
+	// новых строк нет
+	incoming := make(chan scanned)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(incoming)
+		scanner := newLineScanner(r, s.maxLine)
+		for scanner.Scan() {
+			select {
+			case incoming <- scanned{line: scanner.Text()}:
+			case <-done:
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			select {
+			case incoming <- scanned{err: err}:
+			case <-done:
+			}
+		}
+	}()
+
+	var rows []Row
+	var arena rowArena
+	lineNum := 0
+	var deadline <-chan time.Time
+	var timer *time.Timer
+	flush := func() error {
+		if timer != nil {
+			timer.Stop()
+			timer, deadline = nil, nil
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		s.sortRows(rows)
+		var src rowSource = &sliceSource{rows: rows}
+		if s.opts.Unique {
+			src = &uniqueSource{src: peekSource{src: src}, sorter: s, keepLast: s.opts.UniqueKeep == uniqueKeepLast}
+		}
+		rows, arena = nil, rowArena{}
+		if err := writeRows(w, src, s.format, eolStyle{sep: "\n", final: true}); err != nil {
+			return fmt.Errorf("при записи результата: %w", err)
+		}
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return flush()
+		case <-deadline:
+			if err := flush(); err != nil {
+				return err
+			}
+		case in, ok := <-incoming:
+			if !ok {
+				return flush()
+			}
+			if in.err != nil {
+				flush()
+				return fmt.Errorf("при чтении входа: %w", in.err)
+			}
+			lineNum++
+			row, keep, err := s.windowRow(&arena, in.line, lineNum)
+			if err != nil {
+				return err
+			}
+			if !keep {
+				continue
+			}
+			rows = append(rows, row)
+			if period > 0 && timer == nil {
+				timer = time.NewTimer(period)
+				deadline = timer.C
+			}
+			if lines > 0 && len(rows) >= lines {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// windowRow готовит строку потока к сортировке так же, как scanRows: применяет политику
+// длинных строк и фильтры и извлекает ключи. keep ложно для отброшенной строки
+func (s *Sorter) windowRow(arena *rowArena, line string, lineNum int) (Row, bool, error) {
+	keyText, skip, err := s.checkLineLength(line, lineNum)
+	if err != nil || skip || (s.keep != nil && !s.keep(line)) {
+		return Row{}, false, err
+	}
+	if skip, err := s.checkUTF8(line, lineNum); err != nil || skip {
+		if skip {
+			warnf("строка %d с некорректным UTF-8 пропущена", lineNum)
+		}
+		return Row{}, false, err
+	}
+	texts := s.extractKeys(keyText)
+	if err := s.checkMissing(texts, lineNum, line); err != nil {
+		return Row{}, false, err
+	}
+	row := s.newRow(arena, line, texts)
+	if err := s.checkKeyValues(&row, lineNum); err != nil {
+		return Row{}, false, err
+	}
+	if skip, err := s.checkStat(&row, lineNum); err != nil || skip {
+		return Row{}, false, err
+	}
+	return row, true, nil
+}
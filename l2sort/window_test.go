@@ -0,0 +1,89 @@
+package l2sort
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSortWindows проверяет, что каждое окно из --window строк сортируется отдельно
+func TestSortWindows(t *testing.T) {
+	tests := []struct {
+		args  []string
+		lines int
+		input string
+		want  string
+	}{
+		{nil, 3, "c\nb\na\nf\ne\nd\n", "a\nb\nc\nd\ne\nf\n"},
+		{nil, 2, "c\nb\na\nf\ne\n", "b\nc\na\nf\ne\n"},
+		{nil, 0, "c\nb\na\n", "a\nb\nc\n"},
+		{[]string{"-n"}, 3, "10\n9\n100\n2\n1\n", "9\n10\n100\n1\n2\n"},
+		{[]string{"-u"}, 3, "b\na\nb\na\nc\nc\n", "a\nb\na\nc\n"},
+		{[]string{"-r", "-k", "2"}, 2, "x 1\ny 2\nz 3\n", "y 2\nx 1\nz 3\n"},
+		{nil, 2, "", ""},
+	}
+	for _, tt := range tests {
+		sorter := newTestSorter(t, tt.args)
+		var out strings.Builder
+		if err := sorter.SortWindows(context.Background(), strings.NewReader(tt.input), &out, tt.lines, 0); err != nil {
+			t.Fatalf("%q --window %d: %v", tt.args, tt.lines, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%q --window %d = %q, ожидалось %q", tt.args, tt.lines, out.String(), tt.want)
+		}
+	}
+}
+
+// TestSortWindowsTime проверяет, что окно --window-time выводится по истечении времени,
+// не дожидаясь конца входа
+func TestSortWindowsTime(t *testing.T) {
+	sorter := newTestSorter(t, nil)
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- sorter.SortWindows(context.Background(), inR, outW, 0, 20*time.Millisecond)
+		outW.Close()
+	}()
+	out := bufio.NewReader(outR)
+	readLines := func(n int) string {
+		t.Helper()
+		var got strings.Builder
+		for range n {
+			line, err := out.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			got.WriteString(line)
+		}
+		return got.String()
+	}
+
+	io.WriteString(inW, "b\na\n")
+	if got := readLines(2); got != "a\nb\n" {
+		t.Errorf("первое окно %q, ожидалось %q", got, "a\nb\n")
+	}
+	io.WriteString(inW, "d\nc\n")
+	inW.Close()
+	if got := readLines(2); got != "c\nd\n" {
+		t.Errorf("второе окно %q, ожидалось %q", got, "c\nd\n")
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestCheckWindow проверяет, что --window отклоняет режимы, которым нужен весь вход
+func TestCheckWindow(t *testing.T) {
+	for _, args := range [][]string{{"--head", "1"}, {"--freq"}, {"--skip", "1"}, {"--split", "10"}} {
+		if err := checkWindow(parseTestFlags(t, args)); err == nil {
+			t.Errorf("--window %s: ожидалась ошибка", strings.Join(args, " "))
+		}
+	}
+	if err := checkWindow(parseTestFlags(t, []string{"-n", "-u"})); err != nil {
+		t.Errorf("--window -n -u: %v", err)
+	}
+}
@@ -0,0 +1,902 @@
+package l2sort
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// Распаковка zstd по RFC 8878: в стандартной библиотеке ее нет. Поддерживаются все виды
+// блоков, несколько кадров подряд и пропускаемые кадры; кадры со словарем отклоняются
+
+var errZstdCorrupt = errors.New("поврежденные данные zstd")
+
+func zstdCorrupt(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", errZstdCorrupt, fmt.Sprintf(format, args...))
+}
+
+const (
+	zstdFrameMagic     = 0xFD2FB528
+	zstdSkippableMagic = 0x184D2A50
+	zstdMaxBlockSize   = 128 << 10
+	// zstdMaxWindow - наибольшее окно кадра: распакованные данные в его пределах
+	// хранятся в памяти
+	zstdMaxWindow = 1 << 31
+)
+
+// zstdReader распаковывает поток zstd по блокам: Read отдает данные очередного блока,
+// а из уже выданных хранит последние window байт, на которые могут ссылаться следующие
+type zstdReader struct {
+	r   *bufio.Reader
+	err error
+	// hist - распакованные данные кадра, pending - еще не выданная их часть
+	hist    []byte
+	pending []byte
+	window  int
+	inFrame bool
+	last    bool
+	// checksum - есть ли в конце кадра контрольная сумма
+	checksum bool
+	hash     xxh64
+	block    []byte
+	lits     []byte
+	huff     huffTable
+	rep      [3]int
+	// tables - таблицы длин литералов, смещений и длин совпадений прошлого блока
+	tables [3]fseTable
+}
+
+func newZstdReader(r *bufio.Reader) *zstdReader {
+	return &zstdReader{r: r}
+}
+
+func (z *zstdReader) Read(p []byte) (int, error) {
+	for len(z.pending) == 0 {
+		if z.err != nil {
+			return 0, z.err
+		}
+		z.err = z.next()
+	}
+	n := copy(p, z.pending)
+	z.pending = z.pending[n:]
+	return n, nil
+}
+
+// next читает заголовок кадра, очередной блок или завершение кадра
+func (z *zstdReader) next() error {
+	switch {
+	case !z.inFrame:
+		return z.readFrameHeader()
+	case z.last:
+		return z.finishFrame()
+	}
+	return z.readBlock()
+}
+
+func (z *zstdReader) readFull(buf []byte) error {
+	if _, err := io.ReadFull(z.r, buf); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return nil
+}
+
+func (z *zstdReader) readFrameHeader() error {
+	var buf [8]byte
+	if _, err := io.ReadFull(z.r, buf[:4]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return zstdCorrupt("обрезанный заголовок кадра")
+		}
+		return err
+	}
+	magic := binary.LittleEndian.Uint32(buf[:4])
+	if magic&^0xF == zstdSkippableMagic {
+		if err := z.readFull(buf[:4]); err != nil {
+			return err
+		}
+		if _, err := z.r.Discard(int(binary.LittleEndian.Uint32(buf[:4]))); err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		return nil
+	}
+	if magic != zstdFrameMagic {
+		return zstdCorrupt("неизвестная сигнатура кадра %#x", magic)
+	}
+	if err := z.readFull(buf[:1]); err != nil {
+		return err
+	}
+	desc := buf[0]
+	if desc&0x08 != 0 {
+		return zstdCorrupt("установлен зарезервированный бит заголовка кадра")
+	}
+	single := desc&0x20 != 0
+	z.window = 0
+	if !single {
+		if err := z.readFull(buf[:1]); err != nil {
+			return err
+		}
+		base := 1 << (10 + buf[0]>>3)
+		z.window = base + base/8*int(buf[0]&7)
+	}
+	dict := buf[:[4]int{0, 1, 2, 4}[desc&3]]
+	if err := z.readFull(dict); err != nil {
+		return err
+	}
+	for _, b := range dict {
+		if b != 0 {
+			return errors.New("словари zstd не поддерживаются")
+		}
+	}
+	size := buf[:[4]int{0, 2, 4, 8}[desc>>6]]
+	if single && desc>>6 == 0 {
+		size = buf[:1]
+	}
+	if err := z.readFull(size); err != nil {
+		return err
+	}
+	var content uint64
+	for i := len(size) - 1; i >= 0; i-- {
+		content = content<<8 | uint64(size[i])
+	}
+	if len(size) == 2 {
+		content += 256
+	}
+	if single {
+		z.window = int(min(content, zstdMaxWindow+1))
+	}
+	if z.window > zstdMaxWindow {
+		return fmt.Errorf("окно кадра zstd больше %d МБ", zstdMaxWindow>>20)
+	}
+	z.inFrame, z.last, z.checksum = true, false, desc&0x04 != 0
+	z.hist, z.rep = z.hist[:0], [3]int{1, 4, 8}
+	z.huff, z.tables = huffTable{}, [3]fseTable{}
+	z.hash.reset()
+	return nil
+}
+
+func (z *zstdReader) finishFrame() error {
+	z.inFrame = false
+	if !z.checksum {
+		return nil
+	}
+	var buf [4]byte
+	if err := z.readFull(buf[:]); err != nil {
+		return err
+	}
+	if binary.LittleEndian.Uint32(buf[:]) != uint32(z.hash.sum()) {
+		return zstdCorrupt("не совпала контрольная сумма кадра")
+	}
+	return nil
+}
+
+func (z *zstdReader) readBlock() error {
+	var buf [3]byte
+	if err := z.readFull(buf[:]); err != nil {
+		return err
+	}
+	header := int(buf[0]) | int(buf[1])<<8 | int(buf[2])<<16
+	z.last = header&1 != 0
+	size := header >> 3
+	maxBlock := min(z.window, zstdMaxBlockSize)
+	if size > maxBlock {
+		return zstdCorrupt("блок больше %d байт", maxBlock)
+	}
+	// Выданные данные дальше окна больше не нужны
+	if len(z.hist) > 2*z.window && len(z.hist) > 1<<20 {
+		z.hist = z.hist[:copy(z.hist, z.hist[len(z.hist)-z.window:])]
+	}
+	start := len(z.hist)
+	switch header >> 1 & 3 {
+	case 0:
+		z.hist = append(z.hist, make([]byte, size)...)
+		if err := z.readFull(z.hist[start:]); err != nil {
+			return err
+		}
+	case 1:
+		b, err := z.r.ReadByte()
+		if err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		for range size {
+			z.hist = append(z.hist, b)
+		}
+	case 2:
+		z.block = append(z.block[:0], make([]byte, size)...)
+		if err := z.readFull(z.block); err != nil {
+			return err
+		}
+		if err := z.decompressBlock(z.block); err != nil {
+			return err
+		}
+		if len(z.hist)-start > maxBlock {
+			return zstdCorrupt("распакованный блок больше %d байт", maxBlock)
+		}
+	default:
+		return zstdCorrupt("зарезервированный тип блока")
+	}
+	z.pending = z.hist[start:]
+	if z.checksum {
+		z.hash.write(z.pending)
+	}
+	return nil
+}
+
+func (z *zstdReader) decompressBlock(data []byte) error {
+	lits, n, err := z.readLiterals(data)
+	if err != nil {
+		return err
+	}
+	return z.execSequences(data[n:], lits)
+}
+
+// readLiterals распаковывает литералы блока и возвращает их и размер секции литералов
+func (z *zstdReader) readLiterals(data []byte) ([]byte, int, error) {
+	if len(data) == 0 {
+		return nil, 0, zstdCorrupt("пустой сжатый блок")
+	}
+	var b [5]int
+	for i := range min(len(data), 5) {
+		b[i] = int(data[i])
+	}
+	kind, format := b[0]&3, b[0]>>2&3
+	if kind < 2 {
+		regen, header := b[0]>>3, 1
+		switch format {
+		case 1:
+			regen, header = b[0]>>4|b[1]<<4, 2
+		case 3:
+			regen, header = b[0]>>4|b[1]<<4|b[2]<<12, 3
+		}
+		if regen > zstdMaxBlockSize {
+			return nil, 0, zstdCorrupt("литералов больше %d байт", zstdMaxBlockSize)
+		}
+		if kind == 0 {
+			if len(data) < header+regen {
+				return nil, 0, zstdCorrupt("обрезанные литералы")
+			}
+			return data[header : header+regen], header + regen, nil
+		}
+		if len(data) < header+1 {
+			return nil, 0, zstdCorrupt("обрезанные литералы")
+		}
+		z.lits = z.lits[:0]
+		for range regen {
+			z.lits = append(z.lits, data[header])
+		}
+		return z.lits, header + 1, nil
+	}
+	var regen, size, header int
+	streams := 4
+	switch format {
+	case 0, 1:
+		c := b[0] | b[1]<<8 | b[2]<<16
+		regen, size, header = c>>4&0x3FF, c>>14&0x3FF, 3
+		if format == 0 {
+			streams = 1
+		}
+	case 2:
+		c := b[0] | b[1]<<8 | b[2]<<16 | b[3]<<24
+		regen, size, header = c>>4&0x3FFF, c>>18&0x3FFF, 4
+	case 3:
+		c := b[0] | b[1]<<8 | b[2]<<16 | b[3]<<24 | b[4]<<32
+		regen, size, header = c>>4&0x3FFFF, c>>22&0x3FFFF, 5
+	}
+	if regen > zstdMaxBlockSize {
+		return nil, 0, zstdCorrupt("литералов больше %d байт", zstdMaxBlockSize)
+	}
+	if len(data) < header+size {
+		return nil, 0, zstdCorrupt("обрезанные литералы")
+	}
+	src := data[header : header+size]
+	if kind == 2 {
+		n, err := z.huff.read(src)
+		if err != nil {
+			return nil, 0, err
+		}
+		src = src[n:]
+	} else if z.huff.entries == nil {
+		return nil, 0, zstdCorrupt("нет таблицы Хаффмана для литералов")
+	}
+	lits, err := z.huff.decode(z.lits[:0], src, regen, streams)
+	if err != nil {
+		return nil, 0, err
+	}
+	z.lits = lits
+	return lits, header + size, nil
+}
+
+// zstdCode - база и число дополнительных бит кода длины
+type zstdCode struct {
+	base uint32
+	bits uint8
+}
+
+var (
+	zstdLitLengths = func() []zstdCode {
+		codes := make([]zstdCode, 16, 36)
+		for i := range codes {
+			codes[i].base = uint32(i)
+		}
+		return append(codes,
+			zstdCode{16, 1}, zstdCode{18, 1}, zstdCode{20, 1}, zstdCode{22, 1},
+			zstdCode{24, 2}, zstdCode{28, 2}, zstdCode{32, 3}, zstdCode{40, 3},
+			zstdCode{48, 4}, zstdCode{64, 6}, zstdCode{128, 7}, zstdCode{256, 8},
+			zstdCode{512, 9}, zstdCode{1024, 10}, zstdCode{2048, 11}, zstdCode{4096, 12},
+			zstdCode{8192, 13}, zstdCode{16384, 14}, zstdCode{32768, 15}, zstdCode{65536, 16})
+	}()
+	zstdMatchLengths = func() []zstdCode {
+		codes := make([]zstdCode, 32, 53)
+		for i := range codes {
+			codes[i].base = uint32(i + 3)
+		}
+		return append(codes,
+			zstdCode{35, 1}, zstdCode{37, 1}, zstdCode{39, 1}, zstdCode{41, 1},
+			zstdCode{43, 2}, zstdCode{47, 2}, zstdCode{51, 3}, zstdCode{59, 3},
+			zstdCode{67, 4}, zstdCode{83, 4}, zstdCode{99, 5}, zstdCode{131, 7},
+			zstdCode{259, 8}, zstdCode{515, 9}, zstdCode{1027, 10}, zstdCode{2051, 11},
+			zstdCode{4099, 12}, zstdCode{8195, 13}, zstdCode{16387, 14}, zstdCode{32771, 15},
+			zstdCode{65539, 16})
+	}()
+)
+
+// zstdSeqKinds - таблицы последовательностей в порядке заголовка: длины литералов,
+// смещения, длины совпадений; predefined - их распределения по умолчанию
+var zstdSeqKinds = [3]struct {
+	maxSym, maxLog int
+	predefined     fseTable
+}{
+	{35, 9, mustFSETable([]int16{4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1, 2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1, -1, -1, -1, -1}, 6)},
+	{31, 8, mustFSETable([]int16{1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1}, 5)},
+	{52, 9, mustFSETable([]int16{1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1, -1, -1}, 6)},
+}
+
+func mustFSETable(norm []int16, log int) fseTable {
+	t, err := buildFSETable(norm, log)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// execSequences декодирует последовательности блока и выполняет их: копирует литералы
+// и совпадения из уже распакованных данных
+func (z *zstdReader) execSequences(data, lits []byte) error {
+	if len(data) == 0 {
+		return zstdCorrupt("нет секции последовательностей")
+	}
+	count := int(data[0])
+	data = data[1:]
+	switch {
+	case count == 0:
+		z.hist = append(z.hist, lits...)
+		return nil
+	case count == 255:
+		if len(data) < 2 {
+			return zstdCorrupt("обрезанный заголовок последовательностей")
+		}
+		count, data = int(data[0])+int(data[1])<<8+0x7F00, data[2:]
+	case count >= 128:
+		if len(data) < 1 {
+			return zstdCorrupt("обрезанный заголовок последовательностей")
+		}
+		count, data = (count-128)<<8+int(data[0]), data[1:]
+	}
+	if len(data) < 1 || data[0]&3 != 0 {
+		return zstdCorrupt("неверные режимы таблиц последовательностей")
+	}
+	modes := data[0]
+	data = data[1:]
+	for i := range z.tables {
+		n, err := z.readSeqTable(i, int(modes>>(6-2*i)&3), data)
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	br, err := newReverseBits(data)
+	if err != nil {
+		return err
+	}
+	ll, of, ml := &z.tables[0], &z.tables[1], &z.tables[2]
+	llState, ofState, mlState := br.read(ll.log), br.read(of.log), br.read(ml.log)
+	for i := range count {
+		llCode, ofCode, mlCode := ll.entries[llState].sym, of.entries[ofState].sym, ml.entries[mlState].sym
+		value := 1<<ofCode + int(br.read(int(ofCode)))
+		match := zstdMatchLengths[mlCode]
+		matchLen := int(match.base) + int(br.read(int(match.bits)))
+		lit := zstdLitLengths[llCode]
+		litLen := int(lit.base) + int(br.read(int(lit.bits)))
+		offset := z.offset(value, litLen)
+		if i < count-1 {
+			llState = ll.next(llState, &br)
+			mlState = ml.next(mlState, &br)
+			ofState = of.next(ofState, &br)
+		}
+		if litLen > len(lits) {
+			return zstdCorrupt("последовательность длиннее литералов")
+		}
+		z.hist = append(z.hist, lits[:litLen]...)
+		lits = lits[litLen:]
+		if offset <= 0 || offset > len(z.hist) {
+			return zstdCorrupt("смещение %d вне распакованных данных", offset)
+		}
+		start := len(z.hist) - offset
+		if offset >= matchLen {
+			z.hist = append(z.hist, z.hist[start:start+matchLen]...)
+			continue
+		}
+		for j := range matchLen {
+			z.hist = append(z.hist, z.hist[start+j])
+		}
+	}
+	if br.left != 0 {
+		return zstdCorrupt("неверная длина потока последовательностей")
+	}
+	z.hist = append(z.hist, lits...)
+	return nil
+}
+
+// offset переводит значение смещения последовательности в смещение и обновляет три
+// последних смещения. Значения 1-3 ссылаются на последние смещения, при litLen == 0 - со сдвигом
+func (z *zstdReader) offset(value, litLen int) int {
+	if value > 3 {
+		z.rep = [3]int{value - 3, z.rep[0], z.rep[1]}
+		return z.rep[0]
+	}
+	index := value - 1
+	if litLen == 0 {
+		index++
+	}
+	var offset int
+	switch index {
+	case 0:
+		return z.rep[0]
+	case 1:
+		offset = z.rep[1]
+	case 2:
+		offset = z.rep[2]
+		z.rep[2] = z.rep[1]
+	default:
+		offset = z.rep[0] - 1
+		z.rep[2] = z.rep[1]
+	}
+	z.rep[1], z.rep[0] = z.rep[0], offset
+	return offset
+}
+
+// readSeqTable читает таблицу i в режиме mode: по умолчанию, из одного символа, сжатую
+// или таблицу прошлого блока. Возвращает число прочитанных байт
+func (z *zstdReader) readSeqTable(i, mode int, data []byte) (int, error) {
+	kind := zstdSeqKinds[i]
+	switch mode {
+	case 0:
+		z.tables[i] = kind.predefined
+	case 1:
+		if len(data) < 1 || int(data[0]) > kind.maxSym {
+			return 0, zstdCorrupt("неверный символ таблицы последовательностей")
+		}
+		z.tables[i] = fseTable{entries: []fseEntry{{sym: data[0]}}}
+		return 1, nil
+	case 2:
+		norm, log, n, err := readFSECounts(data, kind.maxSym, kind.maxLog)
+		if err != nil {
+			return 0, err
+		}
+		if z.tables[i], err = buildFSETable(norm, log); err != nil {
+			return 0, err
+		}
+		return n, nil
+	default:
+		if z.tables[i].entries == nil {
+			return 0, zstdCorrupt("нет таблицы прошлого блока")
+		}
+	}
+	return 0, nil
+}
+
+// fseEntry - состояние таблицы FSE: символ и как получить следующее состояние
+type fseEntry struct {
+	sym  uint8
+	bits uint8
+	base uint16
+}
+
+type fseTable struct {
+	log     int
+	entries []fseEntry
+}
+
+func (t *fseTable) next(state uint64, br *reverseBits) uint64 {
+	e := t.entries[state]
+	return uint64(e.base) + br.read(int(e.bits))
+}
+
+// readFSECounts читает описание таблицы FSE: точность и нормированные частоты символов.
+// Возвращает их и число прочитанных байт
+func readFSECounts(data []byte, maxSym, maxLog int) ([]int16, int, int, error) {
+	br := forwardBits{data: data}
+	log := int(br.read(4)) + 5
+	if log > maxLog {
+		return nil, 0, 0, zstdCorrupt("точность таблицы FSE %d больше %d", log, maxLog)
+	}
+	remaining, threshold, nbBits := 1<<log+1, 1<<log, log+1
+	var norm []int16
+	for remaining > 1 {
+		if len(norm) > maxSym {
+			return nil, 0, 0, zstdCorrupt("лишние символы таблицы FSE")
+		}
+		limit := 2*threshold - 1 - remaining
+		count := int(br.peek(nbBits - 1))
+		if count < limit {
+			br.pos += nbBits - 1
+		} else {
+			if count = int(br.peek(nbBits)); count >= threshold {
+				count -= limit
+			}
+			br.pos += nbBits
+		}
+		count--
+		remaining -= max(count, -count)
+		norm = append(norm, int16(count))
+		for count == 0 {
+			repeat := int(br.read(2))
+			for range repeat {
+				norm = append(norm, 0)
+			}
+			if repeat != 3 {
+				break
+			}
+		}
+		for remaining < threshold {
+			nbBits--
+			threshold >>= 1
+		}
+	}
+	if remaining != 1 || len(norm) > maxSym+1 || br.pos > len(data)*8 {
+		return nil, 0, 0, zstdCorrupt("неверное описание таблицы FSE")
+	}
+	return norm, log, (br.pos + 7) / 8, nil
+}
+
+// buildFSETable строит таблицу декодирования FSE по нормированным частотам
+func buildFSETable(norm []int16, log int) (fseTable, error) {
+	size := 1 << log
+	entries := make([]fseEntry, size)
+	next := make([]int, len(norm))
+	high := size - 1
+	for s, count := range norm {
+		if count == -1 {
+			entries[high].sym = uint8(s)
+			high--
+			next[s] = 1
+		} else {
+			next[s] = int(count)
+		}
+	}
+	step, pos := size>>1+size>>3+3, 0
+	for s, count := range norm {
+		for range max(count, 0) {
+			entries[pos].sym = uint8(s)
+			pos = (pos + step) & (size - 1)
+			for pos > high {
+				pos = (pos + step) & (size - 1)
+			}
+		}
+	}
+	if pos != 0 {
+		return fseTable{}, zstdCorrupt("неверные частоты таблицы FSE")
+	}
+	for i := range entries {
+		e := &entries[i]
+		state := next[e.sym]
+		next[e.sym]++
+		e.bits = uint8(log + 1 - bits.Len(uint(state)))
+		e.base = uint16(state<<e.bits - size)
+	}
+	return fseTable{log: log, entries: entries}, nil
+}
+
+// huffEntry - элемент таблицы Хаффмана: символ и длина его кода
+type huffEntry struct {
+	sym  uint8
+	bits uint8
+}
+
+type huffTable struct {
+	maxBits int
+	entries []huffEntry
+}
+
+// read читает описание дерева Хаффмана и возвращает число прочитанных байт
+func (h *huffTable) read(data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, zstdCorrupt("нет описания дерева Хаффмана")
+	}
+	var weights [256]uint8
+	var n, used int
+	if header := int(data[0]); header >= 128 {
+		n, used = header-127, 1+(header-126)/2
+		if len(data) < used {
+			return 0, zstdCorrupt("обрезанное описание дерева Хаффмана")
+		}
+		for i := range n {
+			weights[i] = data[1+i/2] >> (4 * (1 - i%2)) & 0xF
+		}
+	} else {
+		used = 1 + header
+		if len(data) < used {
+			return 0, zstdCorrupt("обрезанное описание дерева Хаффмана")
+		}
+		var err error
+		if n, err = readHuffWeights(data[1:used], weights[:255]); err != nil {
+			return 0, err
+		}
+	}
+	sum := 0
+	for _, w := range weights[:n] {
+		if w > 11 {
+			return 0, zstdCorrupt("вес Хаффмана %d больше 11", w)
+		}
+		sum += 1 << w >> 1
+	}
+	maxBits := bits.Len(uint(sum))
+	rest := 1<<maxBits - sum
+	if sum == 0 || maxBits > 11 || rest&(rest-1) != 0 {
+		return 0, zstdCorrupt("неверные веса Хаффмана")
+	}
+	weights[n] = uint8(bits.Len(uint(rest)))
+	n++
+	// Коды выделяются по возрастанию веса, при равном весе - по порядку символов
+	var start [14]int
+	for _, w := range weights[:n] {
+		if w > 0 {
+			start[w+1] += 1 << w >> 1
+		}
+	}
+	for w := 2; w < len(start); w++ {
+		start[w] += start[w-1]
+	}
+	h.maxBits = maxBits
+	h.entries = make([]huffEntry, 1<<maxBits)
+	for s, w := range weights[:n] {
+		if w == 0 {
+			continue
+		}
+		e := huffEntry{sym: uint8(s), bits: uint8(maxBits + 1 - int(w))}
+		for i := range 1 << w >> 1 {
+			h.entries[start[w]+i] = e
+		}
+		start[w] += 1 << w >> 1
+	}
+	return used, nil
+}
+
+// readHuffWeights распаковывает веса Хаффмана, сжатые FSE двумя чередующимися состояниями
+func readHuffWeights(data []byte, weights []uint8) (int, error) {
+	norm, log, used, err := readFSECounts(data, 255, 6)
+	if err != nil {
+		return 0, err
+	}
+	table, err := buildFSETable(norm, log)
+	if err != nil {
+		return 0, err
+	}
+	br, err := newReverseBits(data[used:])
+	if err != nil {
+		return 0, err
+	}
+	states := [2]uint64{br.read(log), br.read(log)}
+	for n := 0; ; n++ {
+		if n+2 > len(weights) {
+			return 0, zstdCorrupt("слишком много весов Хаффмана")
+		}
+		cur := &states[n%2]
+		weights[n] = table.entries[*cur].sym
+		*cur = table.next(*cur, &br)
+		if br.left < 0 {
+			weights[n+1] = table.entries[states[(n+1)%2]].sym
+			return n + 2, nil
+		}
+	}
+}
+
+// decode распаковывает regen байт литералов из одного или четырех потоков
+func (h *huffTable) decode(dst, src []byte, regen, streams int) ([]byte, error) {
+	if streams == 1 {
+		return h.decodeStream(dst, src, regen)
+	}
+	if len(src) < 6 {
+		return nil, zstdCorrupt("нет таблицы потоков литералов")
+	}
+	s1 := int(binary.LittleEndian.Uint16(src))
+	s2 := s1 + int(binary.LittleEndian.Uint16(src[2:]))
+	s3 := s2 + int(binary.LittleEndian.Uint16(src[4:]))
+	src = src[6:]
+	per := (regen + 3) / 4
+	if s3 > len(src) || regen < 3*per {
+		return nil, zstdCorrupt("неверные размеры потоков литералов")
+	}
+	var err error
+	for i, part := range [4][]byte{src[:s1], src[s1:s2], src[s2:s3], src[s3:]} {
+		n := per
+		if i == 3 {
+			n = regen - 3*per
+		}
+		if dst, err = h.decodeStream(dst, part, n); err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+func (h *huffTable) decodeStream(dst, src []byte, n int) ([]byte, error) {
+	br, err := newReverseBits(src)
+	if err != nil {
+		return nil, err
+	}
+	for range n {
+		e := h.entries[br.peek(h.maxBits)]
+		br.left -= int(e.bits)
+		dst = append(dst, e.sym)
+	}
+	if br.left != 0 {
+		return nil, zstdCorrupt("неверная длина потока литералов")
+	}
+	return dst, nil
+}
+
+// reverseBits читает поток бит с конца: от старших бит последнего байта к младшим битам
+// первого. Поток завершается единичным битом, за которым идут нули
+type reverseBits struct {
+	data []byte
+	// left - сколько бит осталось; после чтения за началом потока становится отрицательным
+	left int
+}
+
+func newReverseBits(data []byte) (reverseBits, error) {
+	if len(data) == 0 || data[len(data)-1] == 0 {
+		return reverseBits{}, zstdCorrupt("нет завершающего бита потока")
+	}
+	return reverseBits{data: data, left: len(data)*8 - 1 - bits.LeadingZeros8(data[len(data)-1])}, nil
+}
+
+// bitsAt возвращает n <= 32 бит, начиная с бита off
+func (r *reverseBits) bitsAt(off, n int) uint64 {
+	i := off >> 3
+	var v uint64
+	if i+8 <= len(r.data) {
+		v = binary.LittleEndian.Uint64(r.data[i:])
+	} else {
+		for j := len(r.data) - 1; j >= i; j-- {
+			v = v<<8 | uint64(r.data[j])
+		}
+	}
+	return v >> (off & 7) & (1<<n - 1)
+}
+
+// peek возвращает следующие n бит, не забирая их; за началом потока идут нули
+func (r *reverseBits) peek(n int) uint64 {
+	switch {
+	case r.left >= n:
+		return r.bitsAt(r.left-n, n)
+	case r.left <= 0:
+		return 0
+	}
+	return r.bitsAt(0, r.left) << (n - r.left)
+}
+
+func (r *reverseBits) read(n int) uint64 {
+	v := r.peek(n)
+	r.left -= n
+	return v
+}
+
+// forwardBits читает поток бит с начала, от младших бит первого байта
+type forwardBits struct {
+	data []byte
+	pos  int
+}
+
+func (r *forwardBits) peek(n int) uint64 {
+	i := r.pos >> 3
+	var v uint64
+	for j := min(i+8, len(r.data)) - 1; j >= i; j-- {
+		v = v<<8 | uint64(r.data[j])
+	}
+	return v >> (r.pos & 7) & (1<<n - 1)
+}
+
+func (r *forwardBits) read(n int) uint64 {
+	v := r.peek(n)
+	r.pos += n
+	return v
+}
+
+// xxh64 - потоковый XXH64 с нулевым начальным значением, которым считается контрольная
+// сумма кадра zstd
+type xxh64 struct {
+	v     [4]uint64
+	buf   [32]byte
+	n     int
+	total uint64
+}
+
+const (
+	xxhPrime1 uint64 = 11400714785074694791
+	xxhPrime2 uint64 = 14029467366897019727
+	xxhPrime3 uint64 = 1609587929392839161
+	xxhPrime4 uint64 = 9650029242287828579
+	xxhPrime5 uint64 = 2870177450012600261
+)
+
+func (h *xxh64) reset() {
+	var seed uint64
+	*h = xxh64{v: [4]uint64{seed + xxhPrime1 + xxhPrime2, seed + xxhPrime2, seed, seed - xxhPrime1}}
+}
+
+func xxhRound(acc, input uint64) uint64 {
+	return bits.RotateLeft64(acc+input*xxhPrime2, 31) * xxhPrime1
+}
+
+func (h *xxh64) write(p []byte) {
+	h.total += uint64(len(p))
+	if h.n > 0 {
+		k := copy(h.buf[h.n:], p)
+		h.n += k
+		p = p[k:]
+		if h.n < len(h.buf) {
+			return
+		}
+		h.stripe(h.buf[:])
+		h.n = 0
+	}
+	for ; len(p) >= 32; p = p[32:] {
+		h.stripe(p)
+	}
+	h.n = copy(h.buf[:], p)
+}
+
+func (h *xxh64) stripe(p []byte) {
+	for i := range h.v {
+		h.v[i] = xxhRound(h.v[i], binary.LittleEndian.Uint64(p[8*i:]))
+	}
+}
+
+func (h *xxh64) sum() uint64 {
+	var acc uint64
+	if h.total >= 32 {
+		acc = bits.RotateLeft64(h.v[0], 1) + bits.RotateLeft64(h.v[1], 7) +
+			bits.RotateLeft64(h.v[2], 12) + bits.RotateLeft64(h.v[3], 18)
+		for _, v := range h.v {
+			acc = (acc^xxhRound(0, v))*xxhPrime1 + xxhPrime4
+		}
+	} else {
+		acc = xxhPrime5
+	}
+	acc += h.total
+	p := h.buf[:h.n]
+	for ; len(p) >= 8; p = p[8:] {
+		acc ^= xxhRound(0, binary.LittleEndian.Uint64(p))
+		acc = bits.RotateLeft64(acc, 27)*xxhPrime1 + xxhPrime4
+	}
+	if len(p) >= 4 {
+		acc ^= uint64(binary.LittleEndian.Uint32(p)) * xxhPrime1
+		acc = bits.RotateLeft64(acc, 23)*xxhPrime2 + xxhPrime3
+		p = p[4:]
+	}
+	for _, b := range p {
+		acc ^= uint64(b) * xxhPrime5
+		acc = bits.RotateLeft64(acc, 11) * xxhPrime1
+	}
+	acc ^= acc >> 33
+	acc *= xxhPrime2
+	acc ^= acc >> 29
+	acc *= xxhPrime3
+	acc ^= acc >> 32
+	return acc
+}
@@ -0,0 +1,137 @@
+package l2sort
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"testing"
+)
+
+// zstdTestText - текст, из которого получены testdata/text.*.zst:
+//
+//	zstd -1 text -o testdata/text.1.zst
+//	zstd -19 text -o testdata/text.19.zst
+//	zstd -3 --no-check text -o testdata/text.nocheck.zst
+//
+// testdata/zeros.zst - zstd -3 от 300 КБ нулевых байт (блоки RLE)
+func zstdTestText() []byte {
+	words := []string{"alpha", "beta", "gamma", "ошибка", "запрос", "GET", "/api/v1/users", "404"}
+	var b bytes.Buffer
+	for i := range 20000 {
+		fmt.Fprintf(&b, "%05d %s %s %d\n", i*7919%20000, words[i%len(words)], words[i*i%len(words)], i*i%997)
+	}
+	return b.Bytes()
+}
+
+// zstdTestRandom - несжимаемые данные testdata/random.zst (zstd -3, блоки без сжатия)
+func zstdTestRandom() []byte {
+	r := rand.New(rand.NewPCG(1, 2))
+	data := make([]byte, 200<<10)
+	for i := range data {
+		data[i] = byte(r.Uint32())
+	}
+	return data
+}
+
+// zstdBlockTypes возвращает типы блоков первого кадра: 0 - без сжатия, 1 - RLE, 2 - сжатый
+func zstdBlockTypes(t *testing.T, data []byte) map[int]int {
+	t.Helper()
+	desc := data[4]
+	pos := 5 + [4]int{0, 1, 2, 4}[desc&3] + [4]int{0, 2, 4, 8}[desc>>6]
+	// Байт окна или однобайтный размер содержимого
+	if desc&0x20 == 0 || desc>>6 == 0 {
+		pos++
+	}
+	types := make(map[int]int)
+	for {
+		header := int(data[pos]) | int(data[pos+1])<<8 | int(data[pos+2])<<16
+		kind, size := header>>1&3, header>>3
+		types[kind]++
+		pos += 3
+		if kind == 1 {
+			pos++
+		} else {
+			pos += size
+		}
+		if header&1 != 0 {
+			return types
+		}
+	}
+}
+
+func readZstd(data []byte) ([]byte, error) {
+	return io.ReadAll(newZstdReader(bufio.NewReader(bytes.NewReader(data))))
+}
+
+func TestZstdReader(t *testing.T) {
+	text, random, zeros := zstdTestText(), zstdTestRandom(), make([]byte, 300<<10)
+	fixture := func(name string) []byte {
+		data, err := os.ReadFile("testdata/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	fast, best := fixture("text.1.zst"), fixture("text.19.zst")
+	// Пропускаемый кадр между двумя кадрами данных
+	skippable := []byte{0x50, 0x2a, 0x4d, 0x18, 3, 0, 0, 0, 1, 2, 3}
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+		// blocks - типы блоков, которые должны быть в первом кадре
+		blocks []int
+	}{
+		{"уровень 1", fast, text, []int{2}},
+		{"уровень 19", best, text, []int{2}},
+		{"без контрольной суммы", fixture("text.nocheck.zst"), text, []int{2}},
+		{"блоки без сжатия", fixture("random.zst"), random, []int{0}},
+		{"блоки RLE", fixture("zeros.zst"), zeros, []int{1}},
+		{"несколько кадров", bytes.Join([][]byte{fast, skippable, best}, nil), bytes.Join([][]byte{text, text}, nil), nil},
+		{"пустой кадр", []byte{0x28, 0xb5, 0x2f, 0xfd, 0x20, 0x00, 0x01, 0x00, 0x00}, nil, []int{0}},
+	}
+	for _, tt := range tests {
+		types := zstdBlockTypes(t, tt.data)
+		for _, kind := range tt.blocks {
+			if types[kind] == 0 {
+				t.Errorf("%s: в кадре нет блоков типа %d: %v", tt.name, kind, types)
+			}
+		}
+		if n := types[0] + types[1] + types[2]; len(tt.want) > zstdMaxBlockSize && n < 2 {
+			t.Errorf("%s: ожидалось несколько блоков: %v", tt.name, types)
+		}
+		got, err := readZstd(tt.data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: распаковано %d байт, ожидалось %d", tt.name, len(got), len(tt.want))
+		}
+	}
+}
+
+func TestZstdReaderCorrupt(t *testing.T) {
+	data, err := os.ReadFile("testdata/text.19.zst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum := bytes.Clone(data)
+	checksum[len(checksum)-1] ^= 1
+	if _, err := readZstd(checksum); !errors.Is(err, errZstdCorrupt) {
+		t.Errorf("неверная контрольная сумма: %v", err)
+	}
+	if _, err := readZstd(data[:len(data)/2]); err == nil {
+		t.Error("обрезанный файл распакован без ошибки")
+	}
+	// Поврежденные данные не должны приводить к панике
+	for i := range 500 {
+		d := bytes.Clone(data)
+		d[i*7919%len(d)] ^= byte(1 << (i % 8))
+		readZstd(d)
+	}
+}